@@ -1,52 +1,128 @@
-import { useCallback, useEffect, useState } from 'react'
+import { useCallback, useEffect, useRef, useState } from 'react'
 import { use_websocket } from './hooks/use_websocket'
+import { use_rules } from './hooks/use_rules'
 import { Lobby } from './components/Lobby'
 import { Game } from './components/Game'
+import { Replay } from './components/Replay'
+import { Chat } from './components/Chat'
 import {
   Card,
   Player_Info,
   Rank,
   Rank_Two,
   Message,
+  Replay_State,
+  Account_Session,
+  Game_End,
+  Rating_Change,
+  Spectator_Info,
+  Chat_Message,
+  Room_Listing,
+  Queue_Status,
+  Series_State,
+  Rematch_Vote,
+  Room_Access,
 } from './game/types'
+import type {
+  Card_Tracker_Payload,
+  Deal_Cards_Payload,
+  Error_Payload,
+  Game_Snapshot_Payload,
+  Hint_Suggestion,
+  Hint_Suggestions_Payload,
+  Selection_Completions_Payload,
+  Play_Made_Payload,
+  Room_State_Payload,
+  Turn_Payload,
+} from './game/protocol'
+
+const invite_params = new URLSearchParams(window.location.search)
+
+interface Session {
+  room_id: string
+  player_id: string
+  session_token: string
+}
 
-interface Deal_Cards_Payload {
-  cards: Card[]
-  level: Rank
+const session_key = 'guandan_session'
+const account_key = 'guandan_account'
+
+function load_account(): Account_Session | null {
+  const raw = localStorage.getItem(account_key)
+  if (!raw) return null
+  try {
+    return JSON.parse(raw) as Account_Session
+  } catch {
+    return null
+  }
 }
 
-interface Room_State_Payload {
-  room_id: string
-  players: Player_Info[]
-  game_active: boolean
-  your_id: string
+function save_account(account: Account_Session | null) {
+  if (account) {
+    localStorage.setItem(account_key, JSON.stringify(account))
+  } else {
+    localStorage.removeItem(account_key)
+  }
 }
 
-interface Turn_Payload {
-  player_id: string
-  seat: number
-  can_pass: boolean
+async function post_account(path: string, body: unknown, token?: string): Promise<Record<string, unknown>> {
+  const headers: Record<string, string> = { 'Content-Type': 'application/json' }
+  if (token) headers.Authorization = `Bearer ${token}`
+  const res = await fetch(`/api/accounts/${path}`, {
+    method: 'POST',
+    headers,
+    body: JSON.stringify(body),
+  })
+  if (res.status === 204) return {}
+  const data = await res.json().catch(() => ({ error: res.statusText }))
+  if (!res.ok) throw new Error(data.error ?? res.statusText)
+  return data
 }
 
-interface Play_Made_Payload {
-  player_id: string
-  seat: number
-  cards: Card[]
-  combo_type: string
-  is_pass: boolean
+function load_session(): Session | null {
+  const raw = localStorage.getItem(session_key)
+  if (!raw) return null
+  try {
+    return JSON.parse(raw) as Session
+  } catch {
+    return null
+  }
 }
 
-interface Error_Payload {
-  message: string
+function save_session(session: Session | null) {
+  if (session) {
+    localStorage.setItem(session_key, JSON.stringify(session))
+  } else {
+    localStorage.removeItem(session_key)
+  }
 }
 
 export default function App() {
-  const ws_url = import.meta.env.VITE_WS_URL
+  const [account, set_account] = useState<Account_Session | null>(load_account)
+  const [login_key, set_login_key] = useState<string | null>(null)
+  const base_ws_url = import.meta.env.VITE_WS_URL
+  const ws_url = account
+    ? `${base_ws_url}${base_ws_url.includes('?') ? '&' : '?'}token=${encodeURIComponent(account.session_token)}`
+    : base_ws_url
   const { connected, send, on } = use_websocket(ws_url)
 
   const [room_id, set_room_id] = useState<string | null>(null)
   const [players, set_players] = useState<Player_Info[]>([])
   const [game_active, set_game_active] = useState(false)
+  const [my_id, set_my_id] = useState<string | null>(null)
+  const [host_id, set_host_id] = useState<string | null>(null)
+  const [spectators, set_spectators] = useState<Spectator_Info[]>([])
+  const [is_spectator, set_is_spectator] = useState(false)
+  const [quiet_hands, set_quiet_hands] = useState(false)
+  const [rated, set_rated] = useState(true)
+  const [card_tracker, set_card_tracker] = useState(false)
+  const [chat_messages, set_chat_messages] = useState<Chat_Message[]>([])
+  const [access, set_access] = useState<Room_Access | null>(null)
+  const [room_list, set_room_list] = useState<Room_Listing[]>([])
+  const [queue_status, set_queue_status] = useState<Queue_Status | null>(null)
+  const [series, set_series] = useState<Series_State | null>(null)
+  const [rematch_votes, set_rematch_votes] = useState<Rematch_Vote[]>([])
+  const series_game = useRef(0)
 
   const [hand, set_hand] = useState<Card[]>([])
   const [level, set_level] = useState<Rank>(Rank_Two)
@@ -61,20 +137,52 @@ export default function App() {
   const [error, set_error] = useState<string | null>(null)
   const [players_map, set_players_map] = useState<Record<number, string>>({})
   const [last_play_seat, set_last_play_seat] = useState<number | null>(null)
+  const [replay, set_replay] = useState<Replay_State | null>(null)
+  const [game_end, set_game_end] = useState<Game_End | null>(null)
+  const [hints, set_hints] = useState<Hint_Suggestion[]>([])
+  const [tracker, set_tracker] = useState<Card_Tracker_Payload | null>(null)
+  const [server_completions, set_server_completions] = useState<Selection_Completions_Payload | null>(null)
 
   useEffect(() => {
     const unsub_room_state = on('room_state', (msg: Message) => {
       const payload = msg.payload as Room_State_Payload
+      const players = payload.players ?? []
       set_room_id(payload.room_id)
-      set_players(payload.players)
+      set_players(players)
       set_game_active(payload.game_active)
+      set_my_id(payload.your_id)
+      set_host_id(payload.host_id)
+      set_spectators(payload.spectators ?? [])
+      set_is_spectator(payload.is_spectator)
+      set_quiet_hands(payload.quiet_hands)
+      set_rated(payload.rated)
+      set_card_tracker(payload.card_tracker)
+      set_access({
+        visibility: payload.visibility as Room_Access['visibility'],
+        has_password: payload.has_password,
+        invite_token: payload.invite_token,
+      })
+      set_series(payload.series)
+      set_rematch_votes(payload.rematch_votes ?? [])
+      if (payload.series.game !== series_game.current) {
+        series_game.current = payload.series.game
+        set_game_end(null)
+        set_team_levels([0, 0])
+      }
+      if (payload.session_token) {
+        save_session({
+          room_id: payload.room_id,
+          player_id: payload.your_id,
+          session_token: payload.session_token,
+        })
+      }
 
-      const me = payload.players.find((p) => p.id === payload.your_id)
+      const me = players.find((p) => p.id === payload.your_id)
       if (me) {
         set_my_seat(me.seat)
       }
       const pmap: Record<number, string> = {}
-      payload.players.forEach((p) => {
+      players.forEach((p) => {
         pmap[p.seat] = p.name
       })
       set_players_map(pmap)
@@ -82,19 +190,53 @@ export default function App() {
 
     const unsub_deal = on('deal_cards', (msg: Message) => {
       const payload = msg.payload as Deal_Cards_Payload
-      set_hand(sort_cards(payload.cards, payload.level))
+      set_hand(sort_cards(payload.cards ?? [], payload.level))
       set_level(payload.level)
       set_game_active(true)
       set_table_cards([])
       set_combo_type('')
       set_selected_ids(new Set())
       set_player_card_counts([27, 27, 27, 27])
+      set_tracker(null)
+    })
+
+    const unsub_snapshot = on('game_snapshot', (msg: Message) => {
+      const payload = msg.payload as Game_Snapshot_Payload
+      const hand = payload.hand ?? []
+      if (payload.seat !== -1) {
+        set_my_seat(payload.seat)
+      }
+      set_hand(sort_cards(hand, payload.level))
+      set_level(payload.level)
+      set_team_levels(payload.team_levels)
+      set_game_active(true)
+      set_player_card_counts(payload.card_counts)
+      set_table_cards(payload.lead?.cards ?? [])
+      set_combo_type(payload.lead?.combo_type ?? '')
+      set_current_turn(payload.turn)
+      set_can_pass(payload.can_pass)
+      set_selected_ids((prev) => new Set([...prev].filter((id) => hand.some((c) => c.Id === id))))
     })
 
     const unsub_turn = on('turn', (msg: Message) => {
       const payload = msg.payload as Turn_Payload
       set_current_turn(payload.seat)
       set_can_pass(payload.can_pass)
+      set_hints([])
+    })
+
+    const unsub_completions = on('selection_completions', (msg: Message) => {
+      set_server_completions(msg.payload as Selection_Completions_Payload)
+    })
+
+    const unsub_hints = on('hint_suggestions', (msg: Message) => {
+      const suggestions = (msg.payload as Hint_Suggestions_Payload).suggestions ?? []
+      set_hints(suggestions)
+      set_selected_ids(new Set(suggestions[0]?.card_ids ?? []))
+    })
+
+    const unsub_tracker = on('card_tracker', (msg: Message) => {
+      set_tracker(msg.payload as Card_Tracker_Payload)
     })
 
     const unsub_play_made = on('play_made', (msg: Message) => {
@@ -104,14 +246,15 @@ export default function App() {
       setTimeout(() => set_last_play_seat(null), 800)
 
       if (!payload.is_pass) {
-        set_table_cards(payload.cards)
+        const cards = payload.cards ?? []
+        set_table_cards(cards)
         set_combo_type(payload.combo_type)
         set_player_card_counts((prev) => {
           const next = [...prev]
-          next[payload.seat] -= payload.cards.length
+          next[payload.seat] -= cards.length
           return next as [number, number, number, number]
         })
-        const played_ids = new Set(payload.cards.map((c) => c.Id))
+        const played_ids = new Set(cards.map((c) => c.Id))
         set_hand((prev) => prev.filter((c) => !played_ids.has(c.Id)))
       }
     })
@@ -121,46 +264,309 @@ export default function App() {
       set_team_levels(payload.new_levels)
     })
 
+    const unsub_game_end = on('game_end', (msg: Message) => {
+      set_game_end(msg.payload as Game_End)
+    })
+
     const unsub_error = on('error', (msg: Message) => {
       const payload = msg.payload as Error_Payload
+      if (payload.message === 'no seat to rejoin' || payload.message === 'room not found') {
+        save_session(null)
+      }
+      if (payload.message === 'invalid session') {
+        save_account(null)
+        set_account(null)
+      }
       set_error(payload.message)
       setTimeout(() => set_error(null), 3000)
     })
 
+    const unsub_kicked = on('kicked', (msg: Message) => {
+      const payload = msg.payload as Error_Payload
+      save_session(null)
+      set_room_id(null)
+      set_players([])
+      set_host_id(null)
+      set_chat_messages([])
+      set_error(payload.message)
+      setTimeout(() => set_error(null), 3000)
+    })
+
+    const unsub_room_closed = on('room_closed', (msg: Message) => {
+      const payload = msg.payload as { room_id: string; reason: string }
+      if (payload.reason !== 'server is restarting') {
+        save_session(null)
+      }
+      set_room_id(null)
+      set_players([])
+      set_host_id(null)
+      set_game_active(false)
+      set_is_spectator(false)
+      set_chat_messages([])
+      set_error(payload.reason)
+      setTimeout(() => set_error(null), 3000)
+    })
+
+    const unsub_replay_state = on('replay_state', (msg: Message) => {
+      set_replay(msg.payload as Replay_State)
+    })
+
+    const unsub_room_list = on('room_list', (msg: Message) => {
+      set_room_list((msg.payload as { rooms: Room_Listing[] }).rooms)
+    })
+
+    const unsub_queue_status = on('queue_status', (msg: Message) => {
+      const payload = msg.payload as Queue_Status
+      set_queue_status(payload.queued ? payload : null)
+    })
+
+    const unsub_chat = on('chat_message', (msg: Message) => {
+      const payload = msg.payload as Chat_Message
+      set_chat_messages((prev) => [...prev.slice(-99), payload])
+    })
+
     return () => {
       unsub_room_state()
       unsub_deal()
+      unsub_snapshot()
       unsub_turn()
+      unsub_hints()
+      unsub_tracker()
+      unsub_completions()
       unsub_play_made()
       unsub_hand_end()
+      unsub_game_end()
       unsub_error()
+      unsub_kicked()
+      unsub_room_closed()
+      unsub_replay_state()
+      unsub_chat()
+      unsub_room_list()
+      unsub_queue_status()
     }
   }, [on])
 
+  useEffect(() => {
+    if (!connected) return
+    const session = load_session()
+    if (!session) return
+    send({ type: 'rejoin_room', payload: session })
+  }, [connected, send])
+
   const handle_create_room = useCallback(
-    (name: string) => {
+    (name: string, visibility: 'public' | 'private', password: string) => {
       send({
         type: 'create_room',
-        payload: { player_name: name },
+        payload: { player_name: name, visibility, password },
       })
     },
     [send]
   )
 
   const handle_join_room = useCallback(
-    (room_code: string, name: string) => {
+    (room_code: string, name: string, password?: string, invite_token?: string) => {
       send({
         type: 'join_room',
-        payload: { room_id: room_code, player_name: name },
+        payload: { room_id: room_code, player_name: name, password, invite_token },
+      })
+    },
+    [send]
+  )
+
+  const handle_spectate_room = useCallback(
+    (room_code: string, name: string, password?: string, invite_token?: string) => {
+      send({
+        type: 'spectate_room',
+        payload: { room_id: room_code, player_name: name, password, invite_token },
       })
     },
     [send]
   )
 
+  const handle_list_rooms = useCallback(() => {
+    send({ type: 'list_rooms', payload: {} })
+  }, [send])
+
+  const handle_queue_join = useCallback(
+    (name: string, pair: boolean, party_code?: string) => {
+      send({ type: 'queue_join', payload: { player_name: name, pair, party_code } })
+    },
+    [send]
+  )
+
+  const handle_queue_leave = useCallback(() => {
+    send({ type: 'queue_leave', payload: {} })
+  }, [send])
+
+  const handle_set_series = useCallback(
+    (best_of: number) => {
+      send({ type: 'set_series', payload: { best_of } })
+    },
+    [send]
+  )
+
+  const handle_rematch_vote = useCallback(
+    (vote: boolean, swap_partners: boolean) => {
+      send({ type: 'rematch_vote', payload: { vote, swap_partners } })
+    },
+    [send]
+  )
+
+  const handle_room_settings = useCallback(
+    (visibility: 'public' | 'private', password?: string) => {
+      send({ type: 'room_settings', payload: { visibility, password } })
+    },
+    [send]
+  )
+
+  const handle_set_quiet = useCallback(
+    (quiet: boolean) => {
+      send({ type: 'set_quiet', payload: { quiet } })
+    },
+    [send]
+  )
+
+  const handle_set_rated = useCallback(
+    (rated: boolean) => {
+      send({ type: 'set_rated', payload: { rated } })
+    },
+    [send]
+  )
+
+  const handle_set_card_tracker = useCallback(
+    (card_tracker: boolean) => {
+      send({ type: 'set_card_tracker', payload: { card_tracker } })
+    },
+    [send]
+  )
+
+  const handle_chat = useCallback(
+    (text: string) => {
+      send({ type: 'chat', payload: { text } })
+    },
+    [send]
+  )
+
+  const handle_emote = useCallback(
+    (emote: string) => {
+      send({ type: 'emote', payload: { emote } })
+    },
+    [send]
+  )
+
   const handle_fill_bots = useCallback(() => {
     send({ type: 'fill_bots', payload: {} })
   }, [send])
 
+  const handle_choose_seat = useCallback(
+    (seat: number) => {
+      send({ type: 'choose_seat', payload: { seat } })
+    },
+    [send]
+  )
+
+  const handle_set_ready = useCallback(
+    (ready: boolean) => {
+      send({ type: 'set_ready', payload: { ready } })
+    },
+    [send]
+  )
+
+  const handle_kick_player = useCallback(
+    (player_id: string) => {
+      send({ type: 'kick_player', payload: { player_id } })
+    },
+    [send]
+  )
+
+  const handle_add_bot = useCallback(
+    (seat: number) => {
+      send({ type: 'add_bot', payload: { seat } })
+    },
+    [send]
+  )
+
+  const handle_remove_bot = useCallback(
+    (seat: number) => {
+      send({ type: 'remove_bot', payload: { seat } })
+    },
+    [send]
+  )
+
+  const handle_start_game = useCallback(() => {
+    send({ type: 'start_game', payload: {} })
+  }, [send])
+
+  const show_error = useCallback((message: string) => {
+    set_error(message)
+    setTimeout(() => set_error(null), 3000)
+  }, [])
+
+  const handle_login = useCallback(
+    (username: string, password: string) => {
+      post_account('login', { username, password, login_key: password })
+        .then((data) => {
+          const session = data as unknown as Account_Session
+          save_account(session)
+          set_account(session)
+        })
+        .catch((err: Error) => show_error(err.message))
+    },
+    [show_error]
+  )
+
+  const handle_register = useCallback(
+    (username: string, password: string) => {
+      post_account('register', { username, password })
+        .then((data) => {
+          const session = data as unknown as Account_Session & { login_key?: string }
+          save_account({ profile: session.profile, session_token: session.session_token })
+          set_account({ profile: session.profile, session_token: session.session_token })
+          set_login_key(session.login_key ?? null)
+        })
+        .catch((err: Error) => show_error(err.message))
+    },
+    [show_error]
+  )
+
+  const handle_logout = useCallback(() => {
+    if (account) {
+      post_account('logout', {}, account.session_token).catch(() => {})
+    }
+    save_account(null)
+    set_account(null)
+    set_login_key(null)
+  }, [account])
+
+  const handle_open_replay = useCallback(
+    (match_id: string, hand: number) => {
+      send({ type: 'open_replay', payload: { match_id, hand } })
+    },
+    [send]
+  )
+
+  const handle_join_replay = useCallback(
+    (replay_id: string) => {
+      send({ type: 'join_replay', payload: { replay_id } })
+    },
+    [send]
+  )
+
+  const handle_replay_control = useCallback(
+    (action: string, value?: number) => {
+      const payload: Record<string, unknown> = { action }
+      if (action === 'seek') payload.step = value
+      if (action === 'speed') payload.speed = value
+      send({ type: 'replay_control', payload })
+    },
+    [send]
+  )
+
+  const handle_leave_replay = useCallback(() => {
+    send({ type: 'leave_replay', payload: {} })
+    set_replay(null)
+  }, [send])
+
   const handle_card_click = useCallback((id: number) => {
     set_selected_ids((prev) => {
       const next = new Set(prev)
@@ -173,6 +579,29 @@ export default function App() {
     })
   }, [])
 
+  const rules = use_rules()
+  const selected_cards = hand.filter((c) => selected_ids.has(c.Id))
+  const lead_cards = can_pass ? table_cards : []
+  const play_allowed = !rules || rules.can_beat(selected_cards, lead_cards, level)
+
+  const selection_key = [...selected_ids].sort((a, b) => a - b).join(',')
+  useEffect(() => {
+    if (rules || !game_active || is_spectator || selection_key === '') return
+    send({ type: 'complete_selection', payload: { card_ids: selection_key.split(',').map(Number) } })
+  }, [rules, game_active, is_spectator, selection_key, send])
+
+  const completion_ids = new Set<number>()
+  if (selected_cards.length > 0) {
+    const completions = rules
+      ? rules.complete(hand, selected_cards, lead_cards, level)
+      : server_completions && (server_completions.card_ids ?? []).join(',') === selection_key
+        ? (server_completions.completions ?? [])
+        : []
+    for (const c of completions) {
+      for (const id of c.add_ids ?? []) completion_ids.add(id)
+    }
+  }
+
   const handle_play = useCallback(() => {
     if (selected_ids.size === 0) return
 
@@ -188,6 +617,10 @@ export default function App() {
     send({ type: 'pass', payload: {} })
   }, [send])
 
+  const handle_hint = useCallback(() => {
+    send({ type: 'hint', payload: {} })
+  }, [send])
+
   if (!connected) {
     return (
       <div style={styles.connecting}>
@@ -196,16 +629,65 @@ export default function App() {
     )
   }
 
+  if (replay) {
+    return (
+      <>
+        <Replay state={replay} on_control={handle_replay_control} on_leave={handle_leave_replay} />
+        {error && <div style={styles.error}>{error}</div>}
+      </>
+    )
+  }
+
+  const chat = room_id && (
+    <Chat messages={chat_messages} is_spectator={is_spectator} on_send={handle_chat} on_emote={handle_emote} />
+  )
+
   if (!game_active) {
     return (
       <>
         <Lobby
           room_id={room_id}
           players={players}
+          spectators={spectators}
+          is_spectator={is_spectator}
+          quiet_hands={quiet_hands}
+          rated={rated}
+          card_tracker={card_tracker}
+          access={access}
+          room_list={room_list}
+          queue_status={queue_status}
+          series={series}
+          initial_room={invite_params.get('room') ?? ''}
+          initial_invite={invite_params.get('invite') ?? ''}
+          my_id={my_id}
+          host_id={host_id}
           on_create_room={handle_create_room}
           on_join_room={handle_join_room}
+          on_spectate_room={handle_spectate_room}
+          on_set_quiet={handle_set_quiet}
+          on_set_rated={handle_set_rated}
+          on_set_card_tracker={handle_set_card_tracker}
+          on_list_rooms={handle_list_rooms}
+          on_queue_join={handle_queue_join}
+          on_queue_leave={handle_queue_leave}
+          on_room_settings={handle_room_settings}
+          on_set_series={handle_set_series}
           on_fill_bots={handle_fill_bots}
+          on_choose_seat={handle_choose_seat}
+          on_set_ready={handle_set_ready}
+          on_kick_player={handle_kick_player}
+          on_add_bot={handle_add_bot}
+          on_remove_bot={handle_remove_bot}
+          on_start_game={handle_start_game}
+          on_open_replay={handle_open_replay}
+          on_join_replay={handle_join_replay}
+          account={account}
+          login_key={login_key}
+          on_login={handle_login}
+          on_register={handle_register}
+          on_logout={handle_logout}
         />
+        {chat}
         {error && <div style={styles.error}>{error}</div>}
       </>
     )
@@ -219,7 +701,12 @@ export default function App() {
         selected_ids={selected_ids}
         on_card_click={handle_card_click}
         on_play={handle_play}
+        play_allowed={play_allowed}
+        completion_ids={completion_ids}
         on_pass={handle_pass}
+        on_hint={rated ? undefined : handle_hint}
+        hints={hints}
+        tracker={card_tracker ? tracker : null}
         table_cards={table_cards}
         combo_type={combo_type}
         current_turn={current_turn}
@@ -229,12 +716,99 @@ export default function App() {
         team_levels={team_levels}
         players_map={players_map}
         last_play_seat={last_play_seat}
+        spectating={is_spectator}
       />
+      {chat}
+      {game_end && (
+        <div style={styles.overlay}>
+          <div style={styles.result}>
+            <h2>Team {game_end.winning_team + 1} wins!</h2>
+            {game_end.series.best_of > 1 && (
+              <p>
+                Series (best of {game_end.series.best_of}): {game_end.series.wins[0]} – {game_end.series.wins[1]}
+                {game_end.series.winner >= 0 && ` · Team ${game_end.series.winner + 1} takes the series`}
+              </p>
+            )}
+            <Rating_Changes title="Ratings" changes={game_end.rating_changes} />
+            <Rating_Changes title="Partnerships" changes={game_end.partnership_changes} />
+            {!is_spectator && (
+              <Rematch_Panel
+                votes={rematch_votes}
+                players={players}
+                my_id={my_id}
+                can_swap={game_end.series.winner >= 0}
+                next_game={game_end.series.winner < 0}
+                on_vote={handle_rematch_vote}
+              />
+            )}
+            <button onClick={() => set_game_end(null)} style={styles.close}>
+              Close
+            </button>
+          </div>
+        </div>
+      )}
       {error && <div style={styles.error}>{error}</div>}
     </>
   )
 }
 
+function Rematch_Panel({
+  votes,
+  players,
+  my_id,
+  can_swap,
+  next_game,
+  on_vote,
+}: {
+  votes: Rematch_Vote[]
+  players: Player_Info[]
+  my_id: string | null
+  can_swap: boolean
+  next_game: boolean
+  on_vote: (vote: boolean, swap_partners: boolean) => void
+}) {
+  const [swap, set_swap] = useState(false)
+  const voted = votes.some((v) => v.player_id === my_id)
+  const humans = players.filter((p) => !p.is_bot && !p.is_offline).length
+
+  return (
+    <div style={{ marginBottom: 12 }}>
+      {can_swap && !voted && (
+        <label style={{ display: 'block', marginBottom: 8 }}>
+          <input type="checkbox" checked={swap} onChange={(e) => set_swap(e.target.checked)} /> Swap partners
+        </label>
+      )}
+      <button onClick={() => on_vote(!voted, can_swap && swap)} style={styles.close}>
+        {voted ? 'Cancel vote' : next_game ? 'Next game' : 'Rematch'}
+      </button>
+      <div style={{ fontSize: 12, color: '#aaa', marginTop: 6 }}>
+        {votes.length}/{humans} ready
+      </div>
+    </div>
+  )
+}
+
+function Rating_Changes({ title, changes }: { title: string; changes?: Rating_Change[] }) {
+  if (!changes || changes.length === 0) return null
+  return (
+    <div style={{ marginBottom: 12 }}>
+      <h4 style={{ margin: '8px 0' }}>{title}</h4>
+      {changes.map((c) => {
+        const delta = c.new_rating - c.old_rating
+        return (
+          <div key={c.id}>
+            {c.name}: {Math.round(c.new_rating)}{' '}
+            <span style={{ color: delta >= 0 ? '#4caf50' : '#f44336' }}>
+              ({delta >= 0 ? '+' : ''}
+              {delta.toFixed(1)})
+            </span>
+          </div>
+        )
+      })}
+    </div>
+  )
+}
+
 function sort_cards(cards: Card[], level: Rank): Card[] {
   return [...cards].sort((a, b) => {
     const va = card_sort_value(a, level)
@@ -263,6 +837,32 @@ const styles: Record<string, React.CSSProperties> = {
     color: '#fff',
     fontSize: 24,
   },
+  overlay: {
+    position: 'fixed',
+    inset: 0,
+    display: 'flex',
+    justifyContent: 'center',
+    alignItems: 'center',
+    backgroundColor: 'rgba(0, 0, 0, 0.6)',
+    zIndex: 900,
+  },
+  result: {
+    padding: 24,
+    minWidth: 280,
+    borderRadius: 12,
+    backgroundColor: '#16213e',
+    color: '#fff',
+    textAlign: 'center',
+  },
+  close: {
+    marginTop: 8,
+    padding: '8px 16px',
+    border: 'none',
+    borderRadius: 8,
+    backgroundColor: '#007bff',
+    color: '#fff',
+    cursor: 'pointer',
+  },
   error: {
     position: 'fixed',
     bottom: 20,
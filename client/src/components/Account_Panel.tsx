@@ -0,0 +1,150 @@
+import { useState } from 'react'
+import { motion } from 'framer-motion'
+import { Account_Session } from '../game/types'
+
+interface Account_Panel_Props {
+  account: Account_Session | null
+  login_key: string | null
+  on_login: (username: string, password: string) => void
+  on_register: (username: string, password: string) => void
+  on_logout: () => void
+}
+
+export function Account_Panel({ account, login_key, on_login, on_register, on_logout }: Account_Panel_Props) {
+  const [open, set_open] = useState(false)
+  const [username, set_username] = useState('')
+  const [password, set_password] = useState('')
+
+  if (account) {
+    return (
+      <div style={styles.bar}>
+        <span>
+          Signed in as <b>{account.profile.display_name}</b> (@{account.profile.username})
+        </span>
+        <button onClick={on_logout} style={styles.link}>
+          Sign out
+        </button>
+        {login_key && (
+          <div style={styles.key}>
+            Your login key (shown once, keep it safe): <code>{login_key}</code>
+          </div>
+        )}
+      </div>
+    )
+  }
+
+  if (!open) {
+    return (
+      <div style={styles.bar}>
+        <span>Playing as guest</span>
+        <button onClick={() => set_open(true)} style={styles.link}>
+          Sign in / Register
+        </button>
+      </div>
+    )
+  }
+
+  return (
+    <div style={styles.form}>
+      <input
+        type="text"
+        placeholder="Username"
+        value={username}
+        onChange={(e) => set_username(e.target.value)}
+        style={styles.input}
+      />
+      <input
+        type="password"
+        placeholder="Password or login key"
+        value={password}
+        onChange={(e) => set_password(e.target.value)}
+        style={styles.input}
+      />
+      <div style={styles.buttons}>
+        <motion.button
+          whileTap={{ scale: 0.95 }}
+          onClick={() => username.trim() && on_login(username.trim(), password)}
+          style={styles.button}
+        >
+          Sign in
+        </motion.button>
+        <motion.button
+          whileTap={{ scale: 0.95 }}
+          onClick={() => username.trim() && on_register(username.trim(), password)}
+          style={{ ...styles.button, backgroundColor: '#28a745' }}
+        >
+          Register
+        </motion.button>
+        <motion.button
+          whileTap={{ scale: 0.95 }}
+          onClick={() => set_open(false)}
+          style={{ ...styles.button, backgroundColor: '#6c757d' }}
+        >
+          Cancel
+        </motion.button>
+      </div>
+      <div style={styles.hint}>Register without a password to get a login key instead.</div>
+    </div>
+  )
+}
+
+const styles: Record<string, React.CSSProperties> = {
+  bar: {
+    display: 'flex',
+    flexWrap: 'wrap',
+    justifyContent: 'center',
+    alignItems: 'center',
+    gap: 8,
+    marginBottom: 20,
+    color: '#ccc',
+    fontSize: 14,
+  },
+  link: {
+    border: 'none',
+    background: 'none',
+    color: '#4da3ff',
+    cursor: 'pointer',
+    fontSize: 14,
+  },
+  key: {
+    width: '100%',
+    padding: 8,
+    borderRadius: 6,
+    backgroundColor: '#0f3460',
+    fontSize: 12,
+    wordBreak: 'break-all',
+  },
+  form: {
+    display: 'flex',
+    flexDirection: 'column',
+    gap: 8,
+    marginBottom: 20,
+  },
+  input: {
+    padding: '8px 12px',
+    fontSize: 14,
+    border: '2px solid #333',
+    borderRadius: 8,
+    backgroundColor: '#0f3460',
+    color: '#fff',
+    outline: 'none',
+  },
+  buttons: {
+    display: 'flex',
+    gap: 8,
+    justifyContent: 'center',
+  },
+  button: {
+    padding: '8px 16px',
+    fontSize: 14,
+    border: 'none',
+    borderRadius: 8,
+    backgroundColor: '#007bff',
+    color: '#fff',
+    cursor: 'pointer',
+  },
+  hint: {
+    color: '#666',
+    fontSize: 12,
+  },
+}
@@ -7,6 +7,7 @@ interface Card_Props {
   card: Card_Type
   level: Rank
   selected: boolean
+  highlighted?: boolean
   on_click: () => void
   size?: Card_Size
 }
@@ -16,7 +17,7 @@ const SIZE_CONFIG = {
   normal: { width: 70, height: 100, rank_font: 16, suit_font: 18, center_font: 24, corner_rank: 14, corner_suit: 12 },
 }
 
-export function Card({ card, level, selected, on_click, size = 'normal' }: Card_Props) {
+export function Card({ card, level, selected, highlighted, on_click, size = 'normal' }: Card_Props) {
   const is_joker = card.Suit === Suit_Joker
   const is_red = is_joker ? card.Rank === Rank_Red_Joker : is_red_suit(card.Suit)
   const is_wild_card = is_wild(card, level)
@@ -26,7 +27,7 @@ export function Card({ card, level, selected, on_click, size = 'normal' }: Card_
     <motion.div
       onClick={on_click}
       animate={{
-        y: selected ? -20 : 0,
+        y: selected ? -20 : highlighted ? -8 : 0,
         scale: selected ? 1.05 : 1,
       }}
       whileHover={{ scale: 1.08 }}
@@ -40,7 +41,11 @@ export function Card({ card, level, selected, on_click, size = 'normal' }: Card_
         position: 'relative',
         cursor: 'pointer',
         userSelect: 'none',
-        boxShadow: selected ? '0 8px 16px rgba(0,0,0,0.3)' : '0 2px 4px rgba(0,0,0,0.1)',
+        boxShadow: selected
+          ? '0 8px 16px rgba(0,0,0,0.3)'
+          : highlighted
+            ? '0 0 0 3px #17a2b8'
+            : '0 2px 4px rgba(0,0,0,0.1)',
         color: is_red ? '#dc3545' : '#000',
         fontWeight: 'bold',
       }}
@@ -0,0 +1,169 @@
+import { useEffect, useRef, useState } from 'react'
+import { Chat_Message, Emotes, Emote_Labels } from '../game/types'
+
+interface Chat_Props {
+  messages: Chat_Message[]
+  is_spectator: boolean
+  on_send: (text: string) => void
+  on_emote: (emote: string) => void
+}
+
+export function Chat({ messages, is_spectator, on_send, on_emote }: Chat_Props) {
+  const [open, set_open] = useState(true)
+  const [text, set_text] = useState('')
+  const list_ref = useRef<HTMLDivElement>(null)
+
+  useEffect(() => {
+    list_ref.current?.scrollTo({ top: list_ref.current.scrollHeight })
+  }, [messages, open])
+
+  const handle_send = () => {
+    if (text.trim()) {
+      on_send(text.trim())
+      set_text('')
+    }
+  }
+
+  if (!open) {
+    return (
+      <button onClick={() => set_open(true)} style={styles.toggle}>
+        Chat ({messages.length})
+      </button>
+    )
+  }
+
+  return (
+    <div style={styles.panel}>
+      <div style={styles.header}>
+        <span>{is_spectator ? 'Spectator chat' : 'Table chat'}</span>
+        <button onClick={() => set_open(false)} style={styles.close}>
+          ×
+        </button>
+      </div>
+      <div ref={list_ref} style={styles.list}>
+        {messages.map((m, i) => (
+          <div key={i} style={styles.message}>
+            {m.channel === 'spectators' && <span style={styles.channel}>[spec] </span>}
+            <span style={{ color: m.seat < 0 ? '#aaa' : m.seat % 2 === 0 ? '#2196f3' : '#e91e63', fontWeight: 'bold' }}>
+              {m.name}:
+            </span>{' '}
+            {m.emote ? <em>{Emote_Labels[m.emote] ?? m.emote}</em> : m.text}
+          </div>
+        ))}
+      </div>
+      <div style={styles.emotes}>
+        {Emotes.map((e) => (
+          <button key={e} onClick={() => on_emote(e)} style={styles.emote}>
+            {Emote_Labels[e]}
+          </button>
+        ))}
+      </div>
+      <div style={styles.input_row}>
+        <input
+          type="text"
+          value={text}
+          maxLength={200}
+          onChange={(e) => set_text(e.target.value)}
+          onKeyDown={(e) => e.key === 'Enter' && handle_send()}
+          placeholder="Say something"
+          style={styles.input}
+        />
+        <button onClick={handle_send} style={styles.send}>
+          Send
+        </button>
+      </div>
+    </div>
+  )
+}
+
+const styles: Record<string, React.CSSProperties> = {
+  toggle: {
+    position: 'fixed',
+    right: 12,
+    bottom: 12,
+    padding: '8px 14px',
+    border: 'none',
+    borderRadius: 8,
+    backgroundColor: '#16213e',
+    color: '#fff',
+    cursor: 'pointer',
+    zIndex: 800,
+  },
+  panel: {
+    position: 'fixed',
+    right: 12,
+    bottom: 12,
+    width: 280,
+    display: 'flex',
+    flexDirection: 'column',
+    backgroundColor: 'rgba(22, 33, 62, 0.95)',
+    borderRadius: 8,
+    color: '#fff',
+    fontSize: 13,
+    zIndex: 800,
+  },
+  header: {
+    display: 'flex',
+    justifyContent: 'space-between',
+    padding: '6px 10px',
+    borderBottom: '1px solid #333',
+    fontWeight: 'bold',
+  },
+  close: {
+    border: 'none',
+    background: 'none',
+    color: '#fff',
+    cursor: 'pointer',
+    fontSize: 16,
+  },
+  list: {
+    height: 180,
+    overflowY: 'auto',
+    padding: '6px 10px',
+  },
+  message: {
+    marginBottom: 4,
+    wordBreak: 'break-word',
+  },
+  channel: {
+    color: '#888',
+    fontSize: 11,
+  },
+  emotes: {
+    display: 'flex',
+    flexWrap: 'wrap',
+    gap: 4,
+    padding: '4px 10px',
+  },
+  emote: {
+    padding: '2px 6px',
+    fontSize: 11,
+    border: 'none',
+    borderRadius: 4,
+    backgroundColor: '#0f3460',
+    color: '#fff',
+    cursor: 'pointer',
+  },
+  input_row: {
+    display: 'flex',
+    gap: 4,
+    padding: '6px 10px 10px',
+  },
+  input: {
+    flex: 1,
+    padding: '6px 8px',
+    border: '1px solid #333',
+    borderRadius: 4,
+    backgroundColor: '#0f3460',
+    color: '#fff',
+    outline: 'none',
+  },
+  send: {
+    padding: '6px 10px',
+    border: 'none',
+    borderRadius: 4,
+    backgroundColor: '#007bff',
+    color: '#fff',
+    cursor: 'pointer',
+  },
+}
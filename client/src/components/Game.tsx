@@ -1,5 +1,6 @@
 import { motion } from 'framer-motion'
-import { Card as Card_Type, Rank, get_rank_symbol } from '../game/types'
+import { Card as Card_Type, Rank, Rank_Red_Joker, get_rank_symbol } from '../game/types'
+import type { Card_Tracker_Payload, Hint_Suggestion } from '../game/protocol'
 import { Hand } from './Hand'
 import { Table } from './Table'
 import { Card_Back } from './Card'
@@ -11,7 +12,12 @@ interface Game_Props {
   selected_ids: Set<number>
   on_card_click: (id: number) => void
   on_play: () => void
+  play_allowed: boolean
+  completion_ids: Set<number>
   on_pass: () => void
+  on_hint?: () => void
+  hints: Hint_Suggestion[]
+  tracker: Card_Tracker_Payload | null
   table_cards: Card_Type[]
   combo_type: string
   current_turn: number
@@ -21,6 +27,7 @@ interface Game_Props {
   team_levels: [number, number]
   players_map: Record<number, string>
   last_play_seat: number | null
+  spectating?: boolean
 }
 
 export function Game({
@@ -29,7 +36,12 @@ export function Game({
   selected_ids,
   on_card_click,
   on_play,
+  play_allowed,
+  completion_ids,
   on_pass,
+  on_hint,
+  hints,
+  tracker,
   table_cards,
   combo_type,
   current_turn,
@@ -39,8 +51,10 @@ export function Game({
   team_levels,
   players_map,
   last_play_seat,
+  spectating,
 }: Game_Props) {
-  const is_my_turn = current_turn === my_seat
+  const is_my_turn = !spectating && current_turn === my_seat
+  const can_play = is_my_turn && selected_ids.size > 0 && hand.length > 0 && play_allowed
   const relative_positions = get_relative_positions(my_seat)
   const is_mobile = use_is_mobile()
 
@@ -56,6 +70,7 @@ export function Game({
             <span style={{ marginLeft: 8, color: '#e91e63' }}>T2: {get_rank_symbol(team_levels[1] as Rank)}</span>
           </div>
         </div>
+        {tracker && <Card_Tracker_Bar tracker={tracker} my_seat={my_seat} players_map={players_map} />}
 
         <Mobile_Opponent_Bar
           positions={relative_positions}
@@ -70,10 +85,20 @@ export function Game({
         </div>
 
         <div style={mobile_styles.my_area}>
+          {spectating && (
+            <Opponent_Hand
+              count={player_card_counts[my_seat]}
+              is_turn={current_turn === my_seat}
+              just_played={last_play_seat === my_seat}
+              seat={my_seat}
+              name={players_map[my_seat]}
+            />
+          )}
           <Hand
             cards={hand}
             level={level}
             selected_ids={selected_ids}
+            completion_ids={completion_ids}
             on_card_click={on_card_click}
           />
 
@@ -81,10 +106,10 @@ export function Game({
             <motion.button
               whileTap={{ scale: 0.95 }}
               onClick={on_play}
-              disabled={!is_my_turn || selected_ids.size === 0 || hand.length === 0}
+              disabled={!can_play}
               style={{
                 ...mobile_styles.action_button,
-                backgroundColor: is_my_turn && selected_ids.size > 0 && hand.length > 0 ? '#28a745' : '#444',
+                backgroundColor: can_play ? '#28a745' : '#444',
               }}
             >
               Play
@@ -100,6 +125,19 @@ export function Game({
             >
               Pass
             </motion.button>
+            {on_hint && (
+              <motion.button
+                whileTap={{ scale: 0.95 }}
+                onClick={on_hint}
+                disabled={!is_my_turn || hand.length === 0}
+                style={{
+                  ...mobile_styles.action_button,
+                  backgroundColor: is_my_turn && hand.length > 0 ? '#6f42c1' : '#444',
+                }}
+              >
+                Hint
+              </motion.button>
+            )}
           </div>
 
           {is_my_turn && hand.length > 0 && (
@@ -111,7 +149,16 @@ export function Game({
               Your turn!
             </motion.div>
           )}
-          {hand.length === 0 && (
+          {is_my_turn && hints.length > 0 && (
+            <div style={mobile_styles.hints}>
+              {hints.map((h, i) => (
+                <div key={i}>
+                  {i + 1}. {describe_hint(h)}
+                </div>
+              ))}
+            </div>
+          )}
+          {hand.length === 0 && !spectating && (
             <motion.div
               initial={{ opacity: 0 }}
               animate={{ opacity: 1 }}
@@ -136,6 +183,7 @@ export function Game({
           <span style={{ marginLeft: 16, color: '#e91e63' }}>Team 2: {get_rank_symbol(team_levels[1] as Rank)}</span>
         </div>
       </div>
+      {tracker && <Card_Tracker_Bar tracker={tracker} my_seat={my_seat} players_map={players_map} />}
 
       <div style={styles.main_layout}>
         <div style={styles.game_area}>
@@ -178,10 +226,20 @@ export function Game({
           </div>
 
           <div style={styles.my_area}>
+          {spectating && (
+            <Opponent_Hand
+              count={player_card_counts[my_seat]}
+              is_turn={current_turn === my_seat}
+              just_played={last_play_seat === my_seat}
+              seat={my_seat}
+              name={players_map[my_seat]}
+            />
+          )}
           <Hand
             cards={hand}
             level={level}
             selected_ids={selected_ids}
+            completion_ids={completion_ids}
             on_card_click={on_card_click}
           />
 
@@ -190,10 +248,10 @@ export function Game({
               whileHover={{ scale: 1.05 }}
               whileTap={{ scale: 0.95 }}
               onClick={on_play}
-              disabled={!is_my_turn || selected_ids.size === 0 || hand.length === 0}
+              disabled={!can_play}
               style={{
                 ...styles.action_button,
-                backgroundColor: is_my_turn && selected_ids.size > 0 && hand.length > 0 ? '#28a745' : '#444',
+                backgroundColor: can_play ? '#28a745' : '#444',
               }}
             >
               Play
@@ -210,6 +268,20 @@ export function Game({
             >
               Pass
             </motion.button>
+            {on_hint && (
+              <motion.button
+                whileHover={{ scale: 1.05 }}
+                whileTap={{ scale: 0.95 }}
+                onClick={on_hint}
+                disabled={!is_my_turn || hand.length === 0}
+                style={{
+                  ...styles.action_button,
+                  backgroundColor: is_my_turn && hand.length > 0 ? '#6f42c1' : '#444',
+                }}
+              >
+                Hint
+              </motion.button>
+            )}
           </div>
 
           {is_my_turn && hand.length > 0 && (
@@ -221,7 +293,16 @@ export function Game({
               Your turn!
             </motion.div>
           )}
-          {hand.length === 0 && (
+          {is_my_turn && hints.length > 0 && (
+            <div style={styles.hints}>
+              {hints.map((h, i) => (
+                <div key={i}>
+                  {i + 1}. {describe_hint(h)}
+                </div>
+              ))}
+            </div>
+          )}
+          {hand.length === 0 && !spectating && (
             <motion.div
               initial={{ opacity: 0 }}
               animate={{ opacity: 1 }}
@@ -339,6 +420,44 @@ function Opponent_Hand({ count, is_turn, just_played, seat, vertical, name }: Op
   )
 }
 
+interface Card_Tracker_Bar_Props {
+  tracker: Card_Tracker_Payload
+  my_seat: number
+  players_map: Record<number, string>
+}
+
+function Card_Tracker_Bar({ tracker, my_seat, players_map }: Card_Tracker_Bar_Props) {
+  return (
+    <div style={styles.tracker}>
+      <span style={styles.tracker_label}>Unseen</span>
+      {(tracker.unseen ?? []).map((r) => (
+        <span
+          key={r.rank}
+          style={{
+            ...styles.tracker_rank,
+            color: r.rank === Rank_Red_Joker ? '#e91e63' : r.count === 0 ? '#555' : '#fff',
+          }}
+        >
+          {get_rank_symbol(r.rank)} {r.count}
+        </span>
+      ))}
+      <span style={styles.tracker_label}>Cards</span>
+      {tracker.card_counts.map((n, seat) =>
+        seat === my_seat ? null : (
+          <span key={seat} style={styles.tracker_rank}>
+            {players_map[seat] ?? `Seat ${seat + 1}`}: {n}
+          </span>
+        )
+      )}
+    </div>
+  )
+}
+
+function describe_hint(hint: Hint_Suggestion): string {
+  if (hint.kind === 'pass') return `Pass: ${hint.reason}`
+  return `${hint.combo_type?.replace('_', ' ')}: ${hint.reason}`
+}
+
 function get_relative_positions(my_seat: number) {
   return {
     top: (my_seat + 2) % 4,
@@ -440,6 +559,28 @@ const styles: Record<string, React.CSSProperties> = {
     fontWeight: 'bold',
     fontSize: 12,
   },
+  hints: {
+    marginTop: 6,
+    color: '#ccc',
+    fontSize: 12,
+    textAlign: 'left',
+  },
+  tracker: {
+    display: 'flex',
+    flexWrap: 'wrap',
+    gap: 8,
+    padding: '4px 12px',
+    backgroundColor: '#0f1a30',
+    fontSize: 12,
+    flexShrink: 0,
+  },
+  tracker_label: {
+    color: '#ffc107',
+    fontWeight: 'bold',
+  },
+  tracker_rank: {
+    color: '#fff',
+  },
   main_layout: {
     display: 'flex',
     flex: 1,
@@ -532,4 +673,10 @@ const mobile_styles: Record<string, React.CSSProperties> = {
     fontWeight: 'bold',
     fontSize: 11,
   },
+  hints: {
+    marginTop: 4,
+    color: '#ccc',
+    fontSize: 11,
+    textAlign: 'left',
+  },
 }
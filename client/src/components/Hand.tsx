@@ -7,10 +7,11 @@ interface Hand_Props {
   cards: Card_Type[]
   level: Rank
   selected_ids: Set<number>
+  completion_ids?: Set<number>
   on_card_click: (id: number) => void
 }
 
-export function Hand({ cards, level, selected_ids, on_card_click }: Hand_Props) {
+export function Hand({ cards, level, selected_ids, completion_ids, on_card_click }: Hand_Props) {
   const is_mobile = use_is_mobile()
   const card_width = is_mobile ? 56 : 70
   const card_height = is_mobile ? 80 : 100
@@ -57,6 +58,8 @@ export function Hand({ cards, level, selected_ids, on_card_click }: Hand_Props)
                   card={card}
                   level={level}
                   selected={selected_ids.has(card.Id)}
+                highlighted={completion_ids?.has(card.Id)}
+                  highlighted={completion_ids?.has(card.Id)}
                   on_click={() => on_card_click(card.Id)}
                   size={is_mobile ? 'small' : 'normal'}
                 />
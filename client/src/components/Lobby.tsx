@@ -1,33 +1,161 @@
 import { useState } from 'react'
 import { motion } from 'framer-motion'
-import { Player_Info } from '../game/types'
+import {
+  Account_Session,
+  Player_Info,
+  Queue_Status,
+  Room_Access,
+  Room_Listing,
+  Series_State,
+  Spectator_Info,
+} from '../game/types'
+import { Account_Panel } from './Account_Panel'
 
 interface Lobby_Props {
   room_id: string | null
   players: Player_Info[]
-  on_create_room: (name: string) => void
-  on_join_room: (room_id: string, name: string) => void
+  spectators: Spectator_Info[]
+  is_spectator: boolean
+  quiet_hands: boolean
+  rated: boolean
+  card_tracker: boolean
+  access: Room_Access | null
+  room_list: Room_Listing[]
+  queue_status: Queue_Status | null
+  series: Series_State | null
+  initial_room: string
+  initial_invite: string
+  my_id: string | null
+  host_id: string | null
+  on_create_room: (name: string, visibility: 'public' | 'private', password: string) => void
+  on_join_room: (room_id: string, name: string, password?: string, invite_token?: string) => void
+  on_spectate_room: (room_id: string, name: string, password?: string, invite_token?: string) => void
+  on_set_quiet: (quiet: boolean) => void
+  on_set_rated: (rated: boolean) => void
+  on_set_card_tracker: (card_tracker: boolean) => void
+  on_list_rooms: () => void
+  on_queue_join: (name: string, pair: boolean, party_code?: string) => void
+  on_queue_leave: () => void
+  on_room_settings: (visibility: 'public' | 'private', password?: string) => void
+  on_set_series: (best_of: number) => void
   on_fill_bots: () => void
+  on_choose_seat: (seat: number) => void
+  on_set_ready: (ready: boolean) => void
+  on_kick_player: (player_id: string) => void
+  on_add_bot: (seat: number) => void
+  on_remove_bot: (seat: number) => void
+  on_start_game: () => void
+  on_open_replay: (match_id: string, hand: number) => void
+  on_join_replay: (replay_id: string) => void
+  account: Account_Session | null
+  login_key: string | null
+  on_login: (username: string, password: string) => void
+  on_register: (username: string, password: string) => void
+  on_logout: () => void
 }
 
-export function Lobby({ room_id, players, on_create_room, on_join_room, on_fill_bots }: Lobby_Props) {
-  const [name, set_name] = useState('')
-  const [join_code, set_join_code] = useState('')
-  const [mode, set_mode] = useState<'select' | 'create' | 'join'>('select')
+export function Lobby({
+  room_id,
+  players,
+  spectators,
+  is_spectator,
+  quiet_hands,
+  rated,
+  card_tracker,
+  access,
+  room_list,
+  queue_status,
+  series,
+  initial_room,
+  initial_invite,
+  my_id,
+  host_id,
+  on_create_room,
+  on_join_room,
+  on_spectate_room,
+  on_set_quiet,
+  on_set_rated,
+  on_set_card_tracker,
+  on_list_rooms,
+  on_queue_join,
+  on_queue_leave,
+  on_room_settings,
+  on_set_series,
+  on_fill_bots,
+  on_choose_seat,
+  on_set_ready,
+  on_kick_player,
+  on_add_bot,
+  on_remove_bot,
+  on_start_game,
+  on_open_replay,
+  on_join_replay,
+  account,
+  login_key,
+  on_login,
+  on_register,
+  on_logout,
+}: Lobby_Props) {
+  const [name, set_name] = useState(account?.profile.display_name ?? '')
+  const [join_code, set_join_code] = useState(initial_room)
+  const [password, set_password] = useState('')
+  const [visibility, set_visibility] = useState<'public' | 'private'>('public')
+  const [new_password, set_new_password] = useState('')
+  const [match_id, set_match_id] = useState('')
+  const [hand_number, set_hand_number] = useState('1')
+  const [replay_code, set_replay_code] = useState('')
+  const [party_code, set_party_code] = useState('')
+  const [mode, set_mode] = useState<'select' | 'quick' | 'create' | 'join' | 'browse' | 'replay'>(
+    initial_room ? 'join' : 'select'
+  )
 
   const handle_create = () => {
     if (name.trim()) {
-      on_create_room(name.trim())
+      on_create_room(name.trim(), visibility, visibility === 'private' ? password : '')
+    }
+  }
+
+  const handle_join = (code = join_code) => {
+    if (name.trim() && code.trim()) {
+      on_join_room(code.trim(), name.trim(), password || undefined, initial_invite || undefined)
+    }
+  }
+
+  const handle_spectate = (code = join_code) => {
+    if (name.trim() && code.trim()) {
+      on_spectate_room(code.trim(), name.trim(), password || undefined, initial_invite || undefined)
     }
   }
 
-  const handle_join = () => {
-    if (name.trim() && join_code.trim()) {
-      on_join_room(join_code.trim(), name.trim())
+  const handle_browse = () => {
+    on_list_rooms()
+    set_mode('browse')
+  }
+
+  const handle_queue = (pair: boolean) => {
+    if (name.trim()) {
+      on_queue_join(name.trim(), pair, pair ? undefined : party_code.trim() || undefined)
+    }
+  }
+
+  const handle_open_replay = () => {
+    const hand = parseInt(hand_number, 10)
+    if (replay_code.trim()) {
+      on_join_replay(replay_code.trim())
+    } else if (match_id.trim() && hand > 0) {
+      on_open_replay(match_id.trim(), hand)
     }
   }
 
   if (room_id) {
+    const is_host = my_id !== null && my_id === host_id
+    const me = players.find((p) => p.id === my_id)
+    const all_ready = players.length === 4 && players.every((p) => p.is_ready || p.id === my_id)
+    const invite_link =
+      access?.visibility === 'private' && access.invite_token
+        ? `${window.location.origin}/?room=${room_id}&invite=${access.invite_token}`
+        : null
+
     return (
       <div style={styles.container}>
         <motion.div
@@ -36,7 +164,9 @@ export function Lobby({ room_id, players, on_create_room, on_join_room, on_fill_
           style={styles.card}
         >
           <h2 style={styles.title}>Room: {room_id}</h2>
-          <p style={styles.subtitle}>Waiting for players... ({players.length}/4)</p>
+          <p style={styles.subtitle}>
+            {is_spectator ? 'Watching' : 'Waiting for players...'} ({players.length}/4)
+          </p>
 
           <div style={styles.players_grid}>
             {[0, 1, 2, 3].map((seat) => {
@@ -48,35 +178,192 @@ export function Lobby({ room_id, players, on_create_room, on_join_room, on_fill_
                   initial={{ opacity: 0, scale: 0.8 }}
                   animate={{ opacity: 1, scale: 1 }}
                   transition={{ delay: seat * 0.1 }}
+                  onClick={() => !player && (is_spectator ? handle_join(room_id) : on_choose_seat(seat))}
                   style={{
                     ...styles.player_slot,
                     backgroundColor: team === 0 ? '#e3f2fd' : '#fce4ec',
                     borderColor: team === 0 ? '#2196f3' : '#e91e63',
+                    cursor: player ? 'default' : 'pointer',
                   }}
                 >
                   {player ? (
                     <>
-                      <div style={styles.player_name}>{player.name}</div>
-                      <div style={styles.player_team}>Team {team + 1}</div>
+                      <div style={styles.player_name}>
+                        {player.name}
+                        {player.is_host && ' ★'}
+                      </div>
+                      <div style={styles.player_team}>
+                        Team {team + 1} · {player.is_ready ? 'Ready' : 'Not ready'}
+                      </div>
+                      {is_host && player.id !== my_id && (
+                        <button
+                          onClick={() => (player.is_bot ? on_remove_bot(seat) : on_kick_player(player.id))}
+                          style={styles.slot_button}
+                        >
+                          {player.is_bot ? 'Remove' : 'Kick'}
+                        </button>
+                      )}
                     </>
                   ) : (
-                    <div style={styles.empty_slot}>Empty</div>
+                    <>
+                      <div style={styles.empty_slot}>Empty · click to sit</div>
+                      {is_host && (
+                        <button
+                          onClick={(e) => {
+                            e.stopPropagation()
+                            on_add_bot(seat)
+                          }}
+                          style={styles.slot_button}
+                        >
+                          Add bot
+                        </button>
+                      )}
+                    </>
                   )}
                 </motion.div>
               )
             })}
           </div>
 
-          <motion.button
-            whileHover={{ scale: 1.05 }}
-            whileTap={{ scale: 0.95 }}
-            onClick={on_fill_bots}
-            style={{ ...styles.button, backgroundColor: '#ff9800', marginBottom: 16 }}
-          >
-            Fill with Bots
-          </motion.button>
+          {spectators.length > 0 && !is_host && (
+            <p style={styles.hint}>Spectators: {spectators.map((s) => s.name).join(', ')}</p>
+          )}
+          {spectators.length > 0 && is_host && (
+            <div style={styles.hint}>
+              Spectators:
+              {spectators.map((s) => (
+                <span key={s.id}>
+                  {' '}
+                  {s.name}{' '}
+                  <button onClick={() => on_kick_player(s.id)} style={styles.slot_button}>
+                    Kick
+                  </button>
+                </span>
+              ))}
+            </div>
+          )}
+
+          {is_host && access && (
+            <div style={styles.settings}>
+              <select
+                value={access.visibility}
+                onChange={(e) => on_room_settings(e.target.value as 'public' | 'private')}
+                style={styles.select}
+              >
+                <option value="public">Public</option>
+                <option value="private">Private</option>
+              </select>
+              {access.visibility === 'private' && (
+                <>
+                  <input
+                    type="password"
+                    placeholder={access.has_password ? 'Change password' : 'Set password'}
+                    value={new_password}
+                    onChange={(e) => set_new_password(e.target.value)}
+                    style={{ ...styles.input, padding: '6px 10px', fontSize: 13 }}
+                  />
+                  <button
+                    onClick={() => {
+                      on_room_settings('private', new_password)
+                      set_new_password('')
+                    }}
+                    style={styles.slot_button}
+                  >
+                    {new_password || !access.has_password ? 'Save' : 'Clear'}
+                  </button>
+                </>
+              )}
+            </div>
+          )}
+          {invite_link && (
+            <p style={styles.hint}>
+              Invite link: <span style={{ userSelect: 'all', color: '#aaa' }}>{invite_link}</span>
+            </p>
+          )}
+
+          {is_host && (
+            <label style={styles.quiet}>
+              <input type="checkbox" checked={quiet_hands} onChange={(e) => on_set_quiet(e.target.checked)} />
+              No table chat during hands
+            </label>
+          )}
+          {!is_host && quiet_hands && <p style={styles.hint}>Table chat is off during hands</p>}
+          {is_host && (
+            <label style={styles.quiet}>
+              <input type="checkbox" checked={rated} onChange={(e) => on_set_rated(e.target.checked)} />
+              Rated (no hints)
+            </label>
+          )}
+          {!is_host && !rated && <p style={styles.hint}>Unrated: hints are on</p>}
+          {is_host && !rated && (
+            <label style={styles.quiet}>
+              <input
+                type="checkbox"
+                checked={card_tracker}
+                onChange={(e) => on_set_card_tracker(e.target.checked)}
+              />
+              Card tracker
+            </label>
+          )}
+          {!is_host && card_tracker && <p style={styles.hint}>Card tracker is on</p>}
+
+          {is_host ? (
+            <div style={styles.settings}>
+              <select
+                value={series?.best_of ?? 1}
+                onChange={(e) => on_set_series(parseInt(e.target.value, 10))}
+                style={styles.select}
+              >
+                <option value={1}>Single match</option>
+                <option value={3}>Best of 3</option>
+                <option value={5}>Best of 5</option>
+                <option value={7}>Best of 7</option>
+              </select>
+            </div>
+          ) : (
+            series &&
+            series.best_of > 1 && <p style={styles.hint}>Best of {series.best_of} series</p>
+          )}
+
+          <div style={{ ...styles.buttons, marginBottom: 16 }}>
+            {me && !is_host && (
+              <motion.button
+                whileHover={{ scale: 1.05 }}
+                whileTap={{ scale: 0.95 }}
+                onClick={() => on_set_ready(!me.is_ready)}
+                style={{ ...styles.button, backgroundColor: me.is_ready ? '#6c757d' : '#28a745' }}
+              >
+                {me.is_ready ? 'Not Ready' : 'Ready'}
+              </motion.button>
+            )}
+            {is_host && (
+              <>
+                <motion.button
+                  whileHover={{ scale: 1.05 }}
+                  whileTap={{ scale: 0.95 }}
+                  onClick={on_fill_bots}
+                  style={{ ...styles.button, backgroundColor: '#ff9800' }}
+                >
+                  Fill with Bots
+                </motion.button>
+                <motion.button
+                  whileHover={{ scale: 1.05 }}
+                  whileTap={{ scale: 0.95 }}
+                  onClick={on_start_game}
+                  disabled={!all_ready}
+                  style={{ ...styles.button, backgroundColor: all_ready ? '#28a745' : '#6c757d' }}
+                >
+                  Start Game
+                </motion.button>
+              </>
+            )}
+          </div>
 
-          <p style={styles.hint}>Share room code with friends to join</p>
+          <p style={styles.hint}>
+            {access?.visibility === 'private'
+              ? 'Share the invite link, or the room code and password'
+              : 'Share room code with friends to join'}
+          </p>
         </motion.div>
       </div>
     )
@@ -92,8 +379,52 @@ export function Lobby({ room_id, players, on_create_room, on_join_room, on_fill_
         <h1 style={styles.logo}>掼蛋</h1>
         <h2 style={styles.title}>Guan Dan</h2>
 
-        {mode === 'select' && (
+        <Account_Panel
+          account={account}
+          login_key={login_key}
+          on_login={on_login}
+          on_register={on_register}
+          on_logout={on_logout}
+        />
+
+        {queue_status && (
+          <div style={styles.form}>
+            {queue_status.waiting_for_partner ? (
+              <p>
+                Share code <strong>{queue_status.party_code}</strong> with your partner
+              </p>
+            ) : (
+              <>
+                <p>
+                  Looking for a table{queue_status.partner_name && ` with ${queue_status.partner_name}`}
+                </p>
+                <p style={styles.hint}>
+                  Position {queue_status.position} · {queue_status.queue_size} players queued · waited{' '}
+                  {queue_status.waited_secs ?? 0}s · about {queue_status.estimated_wait_secs ?? 0}s left
+                </p>
+              </>
+            )}
+            <motion.button
+              whileHover={{ scale: 1.05 }}
+              whileTap={{ scale: 0.95 }}
+              onClick={on_queue_leave}
+              style={{ ...styles.button, backgroundColor: '#dc3545' }}
+            >
+              Leave Queue
+            </motion.button>
+          </div>
+        )}
+
+        {!queue_status && mode === 'select' && (
           <div style={styles.buttons}>
+            <motion.button
+              whileHover={{ scale: 1.05 }}
+              whileTap={{ scale: 0.95 }}
+              onClick={() => set_mode('quick')}
+              style={{ ...styles.button, backgroundColor: '#fd7e14' }}
+            >
+              Quick Play
+            </motion.button>
             <motion.button
               whileHover={{ scale: 1.05 }}
               whileTap={{ scale: 0.95 }}
@@ -110,6 +441,169 @@ export function Lobby({ room_id, players, on_create_room, on_join_room, on_fill_
             >
               Join Room
             </motion.button>
+            <motion.button
+              whileHover={{ scale: 1.05 }}
+              whileTap={{ scale: 0.95 }}
+              onClick={handle_browse}
+              style={{ ...styles.button, backgroundColor: '#17a2b8' }}
+            >
+              Browse
+            </motion.button>
+            <motion.button
+              whileHover={{ scale: 1.05 }}
+              whileTap={{ scale: 0.95 }}
+              onClick={() => set_mode('replay')}
+              style={{ ...styles.button, backgroundColor: '#6f42c1' }}
+            >
+              Replay
+            </motion.button>
+          </div>
+        )}
+
+        {!queue_status && mode === 'quick' && (
+          <div style={styles.form}>
+            <input
+              type="text"
+              placeholder="Your name"
+              value={name}
+              onChange={(e) => set_name(e.target.value)}
+              style={styles.input}
+            />
+            <input
+              type="text"
+              placeholder="Partner's code (optional)"
+              value={party_code}
+              onChange={(e) => set_party_code(e.target.value.toUpperCase())}
+              style={styles.input}
+            />
+            <div style={styles.buttons}>
+              <motion.button
+                whileHover={{ scale: 1.05 }}
+                whileTap={{ scale: 0.95 }}
+                onClick={() => handle_queue(false)}
+                style={{ ...styles.button, backgroundColor: '#fd7e14' }}
+              >
+                {party_code.trim() ? 'Join Partner' : 'Play'}
+              </motion.button>
+              <motion.button
+                whileHover={{ scale: 1.05 }}
+                whileTap={{ scale: 0.95 }}
+                onClick={() => handle_queue(true)}
+                style={styles.button}
+              >
+                With a Partner
+              </motion.button>
+              <motion.button
+                whileHover={{ scale: 1.05 }}
+                whileTap={{ scale: 0.95 }}
+                onClick={() => set_mode('select')}
+                style={{ ...styles.button, backgroundColor: '#6c757d' }}
+              >
+                Back
+              </motion.button>
+            </div>
+          </div>
+        )}
+
+        {mode === 'browse' && (
+          <div style={styles.form}>
+            <input
+              type="text"
+              placeholder="Your name"
+              value={name}
+              onChange={(e) => set_name(e.target.value)}
+              style={styles.input}
+            />
+            {room_list.length === 0 && <p style={styles.hint}>No public rooms right now</p>}
+            {room_list.map((r) => (
+              <div key={r.room_id} style={styles.listing}>
+                <div style={{ textAlign: 'left' }}>
+                  <div style={{ fontWeight: 'bold' }}>
+                    {r.room_id} · {r.host_name || 'no host'}
+                  </div>
+                  <div style={{ fontSize: 12, color: '#aaa' }}>
+                    {r.seats_filled}/4 seated{r.bots > 0 && ` (${r.bots} bots)`} · {r.status}
+                    {r.spectators > 0 && ` · ${r.spectators} watching`}
+                    {r.rules.quiet_hands && ' · quiet hands'}
+                    {!r.rules.rated && ' · unrated'}
+                    {r.rules.card_tracker && ' · card tracker'}
+                  </div>
+                </div>
+                <div style={{ display: 'flex', gap: 6 }}>
+                  {r.status === 'lobby' && r.seats_filled < 4 && (
+                    <button onClick={() => handle_join(r.room_id)} style={styles.slot_button}>
+                      Join
+                    </button>
+                  )}
+                  <button onClick={() => handle_spectate(r.room_id)} style={styles.slot_button}>
+                    Watch
+                  </button>
+                </div>
+              </div>
+            ))}
+            <div style={styles.buttons}>
+              <motion.button
+                whileHover={{ scale: 1.05 }}
+                whileTap={{ scale: 0.95 }}
+                onClick={on_list_rooms}
+                style={{ ...styles.button, backgroundColor: '#17a2b8' }}
+              >
+                Refresh
+              </motion.button>
+              <motion.button
+                whileHover={{ scale: 1.05 }}
+                whileTap={{ scale: 0.95 }}
+                onClick={() => set_mode('select')}
+                style={{ ...styles.button, backgroundColor: '#6c757d' }}
+              >
+                Back
+              </motion.button>
+            </div>
+          </div>
+        )}
+
+        {mode === 'replay' && (
+          <div style={styles.form}>
+            <input
+              type="text"
+              placeholder="Match id"
+              value={match_id}
+              onChange={(e) => set_match_id(e.target.value)}
+              style={styles.input}
+            />
+            <input
+              type="number"
+              min={1}
+              placeholder="Hand"
+              value={hand_number}
+              onChange={(e) => set_hand_number(e.target.value)}
+              style={styles.input}
+            />
+            <input
+              type="text"
+              placeholder="or replay code"
+              value={replay_code}
+              onChange={(e) => set_replay_code(e.target.value)}
+              style={styles.input}
+            />
+            <div style={styles.buttons}>
+              <motion.button
+                whileHover={{ scale: 1.05 }}
+                whileTap={{ scale: 0.95 }}
+                onClick={handle_open_replay}
+                style={{ ...styles.button, backgroundColor: '#6f42c1' }}
+              >
+                Watch
+              </motion.button>
+              <motion.button
+                whileHover={{ scale: 1.05 }}
+                whileTap={{ scale: 0.95 }}
+                onClick={() => set_mode('select')}
+                style={{ ...styles.button, backgroundColor: '#6c757d' }}
+              >
+                Back
+              </motion.button>
+            </div>
           </div>
         )}
 
@@ -122,6 +616,23 @@ export function Lobby({ room_id, players, on_create_room, on_join_room, on_fill_
               onChange={(e) => set_name(e.target.value)}
               style={styles.input}
             />
+            <select
+              value={visibility}
+              onChange={(e) => set_visibility(e.target.value as 'public' | 'private')}
+              style={styles.input}
+            >
+              <option value="public">Public · listed in Browse</option>
+              <option value="private">Private · password or invite link</option>
+            </select>
+            {visibility === 'private' && (
+              <input
+                type="password"
+                placeholder="Password (optional)"
+                value={password}
+                onChange={(e) => set_password(e.target.value)}
+                style={styles.input}
+              />
+            )}
             <div style={styles.buttons}>
               <motion.button
                 whileHover={{ scale: 1.05 }}
@@ -159,15 +670,32 @@ export function Lobby({ room_id, players, on_create_room, on_join_room, on_fill_
               onChange={(e) => set_join_code(e.target.value)}
               style={styles.input}
             />
+            {!initial_invite && (
+              <input
+                type="password"
+                placeholder="Password (private rooms)"
+                value={password}
+                onChange={(e) => set_password(e.target.value)}
+                style={styles.input}
+              />
+            )}
             <div style={styles.buttons}>
               <motion.button
                 whileHover={{ scale: 1.05 }}
                 whileTap={{ scale: 0.95 }}
-                onClick={handle_join}
+                onClick={() => handle_join()}
                 style={{ ...styles.button, backgroundColor: '#28a745' }}
               >
                 Join
               </motion.button>
+              <motion.button
+                whileHover={{ scale: 1.05 }}
+                whileTap={{ scale: 0.95 }}
+                onClick={() => handle_spectate()}
+                style={{ ...styles.button, backgroundColor: '#17a2b8' }}
+              >
+                Watch
+              </motion.button>
               <motion.button
                 whileHover={{ scale: 1.05 }}
                 whileTap={{ scale: 0.95 }}
@@ -264,6 +792,48 @@ const styles: Record<string, React.CSSProperties> = {
   empty_slot: {
     color: '#999',
   },
+  slot_button: {
+    marginTop: 8,
+    padding: '4px 10px',
+    fontSize: 12,
+    border: 'none',
+    borderRadius: 4,
+    backgroundColor: '#6c757d',
+    color: '#fff',
+    cursor: 'pointer',
+  },
+  settings: {
+    display: 'flex',
+    alignItems: 'center',
+    justifyContent: 'center',
+    gap: 8,
+    marginBottom: 12,
+  },
+  select: {
+    padding: '6px 10px',
+    borderRadius: 6,
+    backgroundColor: '#0f3460',
+    color: '#fff',
+    border: '1px solid #333',
+  },
+  listing: {
+    display: 'flex',
+    justifyContent: 'space-between',
+    alignItems: 'center',
+    padding: '8px 12px',
+    borderRadius: 8,
+    backgroundColor: '#0f3460',
+    color: '#fff',
+  },
+  quiet: {
+    display: 'flex',
+    alignItems: 'center',
+    justifyContent: 'center',
+    gap: 6,
+    marginBottom: 16,
+    color: '#aaa',
+    fontSize: 13,
+  },
   hint: {
     color: '#666',
     fontSize: 12,
@@ -0,0 +1,237 @@
+import { motion } from 'framer-motion'
+import { Card as Card_Type, Rank, Replay_State, get_rank_symbol } from '../game/types'
+import { Card } from './Card'
+import { Table } from './Table'
+import { use_is_mobile } from '../hooks/use_is_mobile'
+
+interface Replay_Props {
+  state: Replay_State
+  on_control: (action: string, value?: number) => void
+  on_leave: () => void
+}
+
+const speeds = [0.5, 1, 2, 4, 8]
+
+export function Replay({ state, on_control, on_leave }: Replay_Props) {
+  const is_mobile = use_is_mobile()
+  const at_end = state.step >= state.total_steps
+  const last_seat = state.event && state.event.seat >= 0 ? state.event.seat : null
+
+  return (
+    <div style={styles.container}>
+      <div style={styles.header}>
+        <div>
+          Replay {state.replay_id} · Match {state.match_id} · Hand {state.hand}
+        </div>
+        <div>
+          Lvl: {get_rank_symbol(state.level)} · T1: {get_rank_symbol(state.team_levels[0] as Rank)} · T2:{' '}
+          {get_rank_symbol(state.team_levels[1] as Rank)}
+        </div>
+      </div>
+
+      <div style={styles.seats}>
+        {state.players.map((player, seat) => (
+          <div
+            key={seat}
+            style={{
+              ...styles.seat,
+              borderColor: seat % 2 === 0 ? '#2196f3' : '#e91e63',
+              backgroundColor: !at_end && state.current_turn === seat ? 'rgba(255, 215, 0, 0.15)' : 'transparent',
+            }}
+          >
+            <div style={styles.seat_name}>
+              {player.name || `Seat ${seat + 1}`}
+              {player.is_bot && ' (bot)'}
+            </div>
+            <div style={styles.seat_info}>
+              {state.card_counts[seat]} cards
+              {state.finish_order.includes(seat) && ` · #${state.finish_order.indexOf(seat) + 1}`}
+            </div>
+          </div>
+        ))}
+      </div>
+
+      <Table cards={state.table_cards ?? []} level={state.level} combo_type={state.combo_type} last_play_seat={last_seat} />
+
+      <div style={styles.event}>{describe_event(state)}</div>
+
+      {state.open_hands && (
+        <div style={styles.open_hands}>
+          {state.open_hands.map((cards, seat) => (
+            <div key={seat} style={styles.open_hand}>
+              <div style={styles.seat_name}>{state.players[seat].name || `Seat ${seat + 1}`}</div>
+              <div style={styles.open_cards}>
+                {cards.map((card: Card_Type) => (
+                  <Card key={card.Id} card={card} level={state.level} selected={false} on_click={() => {}} size="small" />
+                ))}
+              </div>
+            </div>
+          ))}
+        </div>
+      )}
+
+      <input
+        type="range"
+        min={1}
+        max={state.total_steps}
+        value={state.step}
+        onChange={(e) => on_control('seek', Number(e.target.value))}
+        style={styles.slider}
+      />
+      <div style={styles.step}>
+        Step {state.step} / {state.total_steps}
+      </div>
+
+      <div style={styles.controls}>
+        <motion.button whileTap={{ scale: 0.95 }} onClick={() => on_control('step_back')} style={styles.button}>
+          ⏮ Back
+        </motion.button>
+        <motion.button
+          whileTap={{ scale: 0.95 }}
+          onClick={() => on_control(state.playing ? 'pause' : 'play')}
+          style={{ ...styles.button, backgroundColor: '#28a745' }}
+        >
+          {state.playing ? '⏸ Pause' : '▶ Play'}
+        </motion.button>
+        <motion.button whileTap={{ scale: 0.95 }} onClick={() => on_control('step_forward')} style={styles.button}>
+          Forward ⏭
+        </motion.button>
+        <select
+          value={state.speed}
+          onChange={(e) => on_control('speed', Number(e.target.value))}
+          style={styles.select}
+        >
+          {speeds.map((speed) => (
+            <option key={speed} value={speed}>
+              {speed}x
+            </option>
+          ))}
+        </select>
+        <motion.button
+          whileTap={{ scale: 0.95 }}
+          onClick={on_leave}
+          style={{ ...styles.button, backgroundColor: '#6c757d', fontSize: is_mobile ? 12 : 14 }}
+        >
+          Leave
+        </motion.button>
+      </div>
+    </div>
+  )
+}
+
+function describe_event(state: Replay_State): string {
+  const ev = state.event
+  if (!ev) return ''
+  const name = ev.seat >= 0 ? state.players[ev.seat].name || `Seat ${ev.seat + 1}` : ''
+
+  switch (ev.kind) {
+    case 'deal':
+      return 'Cards dealt'
+    case 'tribute':
+      return `${name} pays tribute to ${state.players[ev.tribute!.to_seat].name}`
+    case 'play':
+      return `${name} plays ${ev.play!.combo.type}`
+    case 'pass':
+      return `${name} passes`
+    case 'hint':
+      return `${name} asks for a hint`
+    case 'finish':
+      return `${name} finishes #${ev.position}`
+    case 'level_change': {
+      const outcome = ev.level_change!
+      return `Team ${outcome.winning_team + 1} wins, +${outcome.level_advance} levels`
+    }
+  }
+  return ''
+}
+
+const styles: Record<string, React.CSSProperties> = {
+  container: {
+    display: 'flex',
+    flexDirection: 'column',
+    alignItems: 'center',
+    gap: 12,
+    minHeight: '100vh',
+    padding: 16,
+    backgroundColor: '#1a1a2e',
+    color: '#fff',
+  },
+  header: {
+    display: 'flex',
+    flexDirection: 'column',
+    alignItems: 'center',
+    gap: 4,
+    fontSize: 14,
+    color: '#ccc',
+  },
+  seats: {
+    display: 'grid',
+    gridTemplateColumns: 'repeat(4, 1fr)',
+    gap: 8,
+  },
+  seat: {
+    padding: '8px 12px',
+    borderRadius: 8,
+    border: '2px solid',
+    textAlign: 'center',
+  },
+  seat_name: {
+    fontWeight: 'bold',
+    fontSize: 14,
+  },
+  seat_info: {
+    fontSize: 12,
+    color: '#aaa',
+  },
+  event: {
+    minHeight: 20,
+    color: '#ffd700',
+  },
+  open_hands: {
+    display: 'flex',
+    flexDirection: 'column',
+    gap: 8,
+    width: '100%',
+    maxWidth: 960,
+  },
+  open_hand: {
+    display: 'flex',
+    flexDirection: 'column',
+    gap: 4,
+  },
+  open_cards: {
+    display: 'flex',
+    flexWrap: 'wrap',
+    gap: 2,
+  },
+  slider: {
+    width: '100%',
+    maxWidth: 600,
+  },
+  step: {
+    fontSize: 12,
+    color: '#aaa',
+  },
+  controls: {
+    display: 'flex',
+    gap: 8,
+    flexWrap: 'wrap',
+    justifyContent: 'center',
+  },
+  button: {
+    padding: '8px 16px',
+    fontSize: 14,
+    border: 'none',
+    borderRadius: 8,
+    backgroundColor: '#007bff',
+    color: '#fff',
+    cursor: 'pointer',
+  },
+  select: {
+    padding: '8px',
+    borderRadius: 8,
+    backgroundColor: '#0f3460',
+    color: '#fff',
+    border: '2px solid #333',
+  },
+}
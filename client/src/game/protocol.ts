@@ -0,0 +1,727 @@
+// Code generated by cmd/tsgen from server/protocol; DO NOT EDIT.
+
+export const Max_Card_Id = 107
+export const Max_Play_Cards = 27
+export const Max_Name_Length = 32
+export const Max_Text_Length = 1000
+export const Max_Request_Id_Length = 64
+export const Error_Malformed = 'malformed'
+export const Error_Invalid = 'invalid'
+export const Error_Unknown_Type = 'unknown_type'
+export const Visibility_Public = 'public'
+export const Visibility_Private = 'private'
+export const Chat_Table = 'table'
+export const Chat_Spectators = 'spectators'
+export const Replay_Play = 'play'
+export const Replay_Pause = 'pause'
+export const Replay_Step_Forward = 'step_forward'
+export const Replay_Step_Back = 'step_back'
+export const Replay_Seek = 'seek'
+export const Replay_Speed = 'speed'
+
+export type Suit = 0 | 1 | 2 | 3 | 4
+
+export const Suit_Hearts: Suit = 0
+export const Suit_Diamonds: Suit = 1
+export const Suit_Clubs: Suit = 2
+export const Suit_Spades: Suit = 3
+export const Suit_Joker: Suit = 4
+
+export type Rank = 0 | 1 | 2 | 3 | 4 | 5 | 6 | 7 | 8 | 9 | 10 | 11 | 12 | 13 | 14
+
+export const Rank_Two: Rank = 0
+export const Rank_Three: Rank = 1
+export const Rank_Four: Rank = 2
+export const Rank_Five: Rank = 3
+export const Rank_Six: Rank = 4
+export const Rank_Seven: Rank = 5
+export const Rank_Eight: Rank = 6
+export const Rank_Nine: Rank = 7
+export const Rank_Ten: Rank = 8
+export const Rank_Jack: Rank = 9
+export const Rank_Queen: Rank = 10
+export const Rank_King: Rank = 11
+export const Rank_Ace: Rank = 12
+export const Rank_Black_Joker: Rank = 13
+export const Rank_Red_Joker: Rank = 14
+
+export interface Card {
+  Suit: Suit
+  Rank: Rank
+  Id: number
+}
+
+export type Hint_Kind = 'play' | 'bomb' | 'pass'
+
+export const Hint_Play: Hint_Kind = 'play'
+export const Hint_Bomb: Hint_Kind = 'bomb'
+export const Hint_Pass: Hint_Kind = 'pass'
+
+export interface Tribute_Info {
+  From_Seat: number
+  To_Seat: number
+  Done: boolean
+}
+
+export interface Wild_Assignment {
+  card_id: number
+  rank: Rank
+  suit: Suit
+}
+
+export type Event_Kind = 'deal' | 'tribute' | 'play' | 'pass' | 'finish' | 'level_change' | 'hint'
+
+export const Event_Deal: Event_Kind = 'deal'
+export const Event_Tribute: Event_Kind = 'tribute'
+export const Event_Play: Event_Kind = 'play'
+export const Event_Pass: Event_Kind = 'pass'
+export const Event_Finish: Event_Kind = 'finish'
+export const Event_Level_Change: Event_Kind = 'level_change'
+export const Event_Hint: Event_Kind = 'hint'
+
+export interface Player {
+  id: string
+  name: string
+  username?: string
+  is_bot: boolean
+}
+
+export interface Deal {
+  seed: string
+  level: Rank
+  team_levels: [number, number]
+  leader: number
+  tributes?: Tribute_Info[]
+  hands: [Card[] | null, Card[] | null, Card[] | null, Card[] | null]
+}
+
+export interface Tribute {
+  to_seat: number
+  card: Card
+}
+
+export interface Combo {
+  type: string
+  rank_value: number
+  bomb_power?: number
+  wilds?: Wild_Assignment[]
+}
+
+export interface Play {
+  card_ids: number[] | null
+  combo: Combo
+}
+
+export interface Level_Change {
+  finish_order: number[] | null
+  winning_team: number
+  level_advance: number
+  old_level: number
+  new_levels: [number, number]
+  game_over: boolean
+}
+
+export interface Event {
+  step: number
+  at_ms: number
+  kind: Event_Kind
+  seat: number
+  deal?: Deal
+  tribute?: Tribute
+  play?: Play
+  position?: number
+  level_change?: Level_Change
+}
+
+// Complete_Selection_Payload asks which plays contain the selected cards.
+export interface Complete_Selection_Payload {
+  card_ids: number[] | null
+}
+
+// Selection_Completion is a play containing the selection; Add_Ids are the
+// cards it adds to it.
+export interface Selection_Completion {
+  combo_type: string
+  add_ids: number[] | null
+}
+
+// Selection_Completions_Payload answers complete_selection for Card_Ids,
+// fewest added cards first.
+export interface Selection_Completions_Payload {
+  card_ids: number[] | null
+  completions: Selection_Completion[] | null
+}
+
+export type Empty_Payload = Record<string, never>
+
+// Hint_Suggestion is one ranked suggestion, best first; a pass has no cards.
+export interface Hint_Suggestion {
+  kind: Hint_Kind
+  card_ids?: number[]
+  combo_type?: string
+  reason: string
+}
+
+export interface Hint_Suggestions_Payload {
+  suggestions: Hint_Suggestion[] | null
+}
+
+export type Msg_Type =
+  | 'join_room'
+  | 'create_room'
+  | 'room_state'
+  | 'game_start'
+  | 'deal_cards'
+  | 'play_cards'
+  | 'pass'
+  | 'turn'
+  | 'play_made'
+  | 'hand_end'
+  | 'tribute'
+  | 'tribute_give'
+  | 'tribute_recv'
+  | 'game_end'
+  | 'error'
+  | 'player_joined'
+  | 'player_left'
+  | 'fill_bots'
+  | 'choose_seat'
+  | 'choose_team'
+  | 'swap_seats'
+  | 'set_ready'
+  | 'kick_player'
+  | 'kicked'
+  | 'add_bot'
+  | 'remove_bot'
+  | 'start_game'
+  | 'room_closed'
+  | 'rejoin_room'
+  | 'spectate_room'
+  | 'set_quiet'
+  | 'set_rated'
+  | 'set_card_tracker'
+  | 'room_settings'
+  | 'list_rooms'
+  | 'room_list'
+  | 'ack'
+  | 'resync'
+  | 'request_snapshot'
+  | 'game_snapshot'
+  | 'hint'
+  | 'hint_suggestions'
+  | 'card_tracker'
+  | 'complete_selection'
+  | 'selection_completions'
+  | 'rematch_vote'
+  | 'set_series'
+  | 'queue_join'
+  | 'queue_leave'
+  | 'queue_status'
+  | 'chat'
+  | 'emote'
+  | 'chat_message'
+  | 'open_replay'
+  | 'join_replay'
+  | 'leave_replay'
+  | 'replay_control'
+  | 'replay_state'
+
+export const Msg_Join_Room: Msg_Type = 'join_room'
+export const Msg_Create_Room: Msg_Type = 'create_room'
+export const Msg_Room_State: Msg_Type = 'room_state'
+export const Msg_Game_Start: Msg_Type = 'game_start'
+export const Msg_Deal_Cards: Msg_Type = 'deal_cards'
+export const Msg_Play_Cards: Msg_Type = 'play_cards'
+export const Msg_Pass: Msg_Type = 'pass'
+export const Msg_Turn: Msg_Type = 'turn'
+export const Msg_Play_Made: Msg_Type = 'play_made'
+export const Msg_Hand_End: Msg_Type = 'hand_end'
+export const Msg_Tribute: Msg_Type = 'tribute'
+export const Msg_Tribute_Give: Msg_Type = 'tribute_give'
+export const Msg_Tribute_Recv: Msg_Type = 'tribute_recv'
+export const Msg_Game_End: Msg_Type = 'game_end'
+export const Msg_Error: Msg_Type = 'error'
+export const Msg_Player_Joined: Msg_Type = 'player_joined'
+export const Msg_Player_Left: Msg_Type = 'player_left'
+export const Msg_Fill_Bots: Msg_Type = 'fill_bots'
+export const Msg_Choose_Seat: Msg_Type = 'choose_seat'
+export const Msg_Choose_Team: Msg_Type = 'choose_team'
+export const Msg_Swap_Seats: Msg_Type = 'swap_seats'
+export const Msg_Set_Ready: Msg_Type = 'set_ready'
+export const Msg_Kick_Player: Msg_Type = 'kick_player'
+export const Msg_Kicked: Msg_Type = 'kicked'
+export const Msg_Add_Bot: Msg_Type = 'add_bot'
+export const Msg_Remove_Bot: Msg_Type = 'remove_bot'
+export const Msg_Start_Game: Msg_Type = 'start_game'
+export const Msg_Room_Closed: Msg_Type = 'room_closed'
+export const Msg_Rejoin_Room: Msg_Type = 'rejoin_room'
+export const Msg_Spectate_Room: Msg_Type = 'spectate_room'
+export const Msg_Set_Quiet: Msg_Type = 'set_quiet'
+export const Msg_Set_Rated: Msg_Type = 'set_rated'
+export const Msg_Set_Tracker: Msg_Type = 'set_card_tracker'
+export const Msg_Room_Settings: Msg_Type = 'room_settings'
+export const Msg_List_Rooms: Msg_Type = 'list_rooms'
+export const Msg_Room_List: Msg_Type = 'room_list'
+export const Msg_Ack: Msg_Type = 'ack'
+export const Msg_Resync: Msg_Type = 'resync'
+export const Msg_Request_Snapshot: Msg_Type = 'request_snapshot'
+export const Msg_Game_Snapshot: Msg_Type = 'game_snapshot'
+export const Msg_Hint: Msg_Type = 'hint'
+export const Msg_Hint_Suggestions: Msg_Type = 'hint_suggestions'
+export const Msg_Card_Tracker: Msg_Type = 'card_tracker'
+export const Msg_Complete_Selection: Msg_Type = 'complete_selection'
+export const Msg_Selection_Completions: Msg_Type = 'selection_completions'
+export const Msg_Rematch_Vote: Msg_Type = 'rematch_vote'
+export const Msg_Set_Series: Msg_Type = 'set_series'
+export const Msg_Queue_Join: Msg_Type = 'queue_join'
+export const Msg_Queue_Leave: Msg_Type = 'queue_leave'
+export const Msg_Queue_Status: Msg_Type = 'queue_status'
+export const Msg_Chat: Msg_Type = 'chat'
+export const Msg_Emote: Msg_Type = 'emote'
+export const Msg_Chat_Message: Msg_Type = 'chat_message'
+export const Msg_Open_Replay: Msg_Type = 'open_replay'
+export const Msg_Join_Replay: Msg_Type = 'join_replay'
+export const Msg_Leave_Replay: Msg_Type = 'leave_replay'
+export const Msg_Replay_Control: Msg_Type = 'replay_control'
+export const Msg_Replay_State: Msg_Type = 'replay_state'
+
+// Message is what the server sends. Request_Id echoes the client request an
+// ack or error answers. Seq is the number of the room's latest event, where
+// an event is a message sent to every member of the room; a client that sees
+// it jump by more than one has missed something and should resync.
+export interface Message {
+  type: Msg_Type
+  request_id?: string
+  seq?: number
+  payload: unknown
+}
+
+export interface Ack_Payload {
+  message_type: Msg_Type
+}
+
+export interface Join_Room_Payload {
+  room_id: string
+  player_name: string
+  password?: string
+  invite_token?: string
+}
+
+export interface Rejoin_Room_Payload {
+  room_id: string
+  player_id: string
+  session_token: string
+}
+
+export interface Create_Room_Payload {
+  player_name: string
+  visibility?: string
+  password?: string
+}
+
+export interface Spectate_Room_Payload {
+  room_id: string
+  player_name: string
+  password?: string
+  invite_token?: string
+}
+
+// Room_Settings_Payload changes a room's visibility. A nil password leaves it
+// unchanged and an empty one removes it.
+export interface Room_Settings_Payload {
+  visibility: string
+  password?: string
+}
+
+// Room_Rules are the table rules shown in the room list. Matches in a rated
+// room count for the ladder; hints and the card tracker are only offered in
+// unrated ones.
+export interface Room_Rules {
+  quiet_hands: boolean
+  rated: boolean
+  card_tracker: boolean
+}
+
+export interface Room_Listing {
+  room_id: string
+  host_name: string
+  seats_filled: number
+  bots: number
+  spectators: number
+  status: string
+  rules: Room_Rules
+}
+
+export interface Room_List_Payload {
+  rooms: Room_Listing[] | null
+}
+
+// Queue_Join_Payload enters quick play alone, as the first of a pair (Pair,
+// which hands back a party code) or as the partner holding that code.
+export interface Queue_Join_Payload {
+  player_name: string
+  pair?: boolean
+  party_code?: string
+}
+
+export interface Queue_Status_Payload {
+  queued: boolean
+  party_code?: string
+  waiting_for_partner?: boolean
+  partner_name?: string
+  position?: number
+  queue_size?: number
+  waited_secs?: number
+  estimated_wait_secs?: number
+  matched_room?: string
+}
+
+export interface Room_State_Payload {
+  room_id: string
+  players: Player_Info[] | null
+  spectators: Spectator_Info[] | null
+  game_active: boolean
+  status: string
+  your_id: string
+  host_id: string
+  session_token?: string
+  is_spectator: boolean
+  quiet_hands: boolean
+  rated: boolean
+  card_tracker: boolean
+  visibility: string
+  has_password: boolean
+  invite_token?: string
+  series: Series_State
+  rematch_votes?: Rematch_Vote[]
+}
+
+// Series_State counts match wins per team in a best-of-N series. Best_Of 1
+// is a single match.
+export interface Series_State {
+  best_of: number
+  wins: [number, number]
+  game: number
+  winner: number
+}
+
+export interface Rematch_Vote {
+  player_id: string
+  swap_partners: boolean
+}
+
+export interface Rematch_Vote_Payload {
+  vote: boolean
+  swap_partners: boolean
+}
+
+export interface Set_Series_Payload {
+  best_of: number
+}
+
+export interface Spectator_Info {
+  id: string
+  name: string
+  username?: string
+}
+
+export interface Player_Info {
+  id: string
+  name: string
+  seat: number
+  team: number
+  is_ready: boolean
+  is_bot: boolean
+  is_host: boolean
+  is_offline: boolean
+  username?: string
+}
+
+export interface Choose_Seat_Payload {
+  seat: number
+}
+
+export interface Choose_Team_Payload {
+  team: number
+}
+
+export interface Swap_Seats_Payload {
+  seat_a: number
+  seat_b: number
+}
+
+export interface Set_Ready_Payload {
+  ready: boolean
+}
+
+export interface Set_Quiet_Payload {
+  quiet: boolean
+}
+
+export interface Set_Rated_Payload {
+  rated: boolean
+}
+
+export interface Set_Tracker_Payload {
+  card_tracker: boolean
+}
+
+export interface Kick_Player_Payload {
+  player_id: string
+}
+
+export interface Add_Bot_Payload {
+  seat: number
+}
+
+export interface Remove_Bot_Payload {
+  seat: number
+}
+
+export interface Deal_Cards_Payload {
+  cards: Card[] | null
+  level: Rank
+}
+
+export interface Play_Cards_Payload {
+  card_ids: number[] | null
+}
+
+export interface Turn_Payload {
+  player_id: string
+  seat: number
+  lead_combo_type?: string
+  can_pass: boolean
+}
+
+export interface Play_Made_Payload {
+  player_id: string
+  seat: number
+  cards: Card[] | null
+  combo_type: string
+  is_pass: boolean
+}
+
+export interface Hand_End_Payload {
+  finish_order: string[] | null
+  winning_team: number
+  level_advance: number
+  new_levels: [number, number]
+}
+
+export interface Tribute_Payload {
+  from_seat: number
+  to_seat: number
+}
+
+export interface Tribute_Give_Payload {
+  card_id: number
+}
+
+export interface Tribute_Recv_Payload {
+  card: Card
+}
+
+export interface Game_End_Payload {
+  winning_team: number
+  final_levels: [number, number]
+  rating_changes?: Rating_Change[]
+  partnership_changes?: Rating_Change[]
+  series: Series_State
+}
+
+export interface Rating_Change {
+  id: string
+  name: string
+  old_rating: number
+  new_rating: number
+}
+
+// Error_Payload reports a failed request. Code, Message_Type and Field are
+// set when the request itself could not be decoded or validated.
+export interface Error_Payload {
+  message: string
+  code?: string
+  message_type?: Msg_Type
+  field?: string
+}
+
+export interface Room_Closed_Payload {
+  room_id: string
+  reason: string
+}
+
+export interface Chat_Payload {
+  text: string
+}
+
+export interface Emote_Payload {
+  emote: string
+}
+
+export interface Chat_Message_Payload {
+  channel: string
+  player_id: string
+  name: string
+  seat: number
+  text?: string
+  emote?: string
+  sent_at: number
+}
+
+export interface Open_Replay_Payload {
+  match_id: string
+  hand: number
+}
+
+export interface Join_Replay_Payload {
+  replay_id: string
+}
+
+export interface Replay_Control_Payload {
+  action: string
+  step?: number
+  speed?: number
+}
+
+export interface Replay_State_Payload {
+  replay_id: string
+  match_id: string
+  hand: number
+  players: [Player, Player, Player, Player]
+  step: number
+  total_steps: number
+  playing: boolean
+  speed: number
+  event?: Event
+  level: Rank
+  team_levels: [number, number]
+  current_turn: number
+  table_cards: Card[] | null
+  combo_type: string
+  card_counts: [number, number, number, number]
+  finish_order: number[] | null
+  open_hands?: [Card[] | null, Card[] | null, Card[] | null, Card[] | null]
+  outcome?: Level_Change
+}
+
+// Game_Snapshot_Payload is the hand in play as one seat sees it. Everything is
+// keyed by seat; Seat is -1 for spectators, who get no hand.
+export interface Game_Snapshot_Payload {
+  seat: number
+  phase: string
+  level: Rank
+  team_levels: [number, number]
+  hand: Card[] | null
+  card_counts: [number, number, number, number]
+  lead?: Lead_Snapshot
+  trick: Trick_Play[] | null
+  turn: number
+  can_pass: boolean
+  tributes: Tribute_Status[] | null
+  finish_order: number[] | null
+}
+
+export interface Lead_Snapshot {
+  seat: number
+  cards: Card[] | null
+  combo_type: string
+}
+
+export interface Trick_Play {
+  seat: number
+  cards?: Card[]
+  combo_type?: string
+  is_pass: boolean
+}
+
+export interface Tribute_Status {
+  from_seat: number
+  to_seat: number
+  done: boolean
+}
+
+export interface Rank_Count {
+  rank: Rank
+  count: number
+}
+
+// Card_Tracker_Payload is what one seat has not seen yet: for every rank and
+// joker, how many cards are neither in its hand nor played, and how many
+// cards each seat holds.
+export interface Card_Tracker_Payload {
+  unseen: Rank_Count[] | null
+  card_counts: [number, number, number, number]
+}
+
+// Client_Payloads maps each message a client may send to its payload.
+export interface Client_Payloads {
+  create_room: Create_Room_Payload
+  join_room: Join_Room_Payload
+  rejoin_room: Rejoin_Room_Payload
+  spectate_room: Spectate_Room_Payload
+  play_cards: Play_Cards_Payload
+  pass: Empty_Payload
+  tribute_give: Tribute_Give_Payload
+  fill_bots: Empty_Payload
+  choose_seat: Choose_Seat_Payload
+  choose_team: Choose_Team_Payload
+  swap_seats: Swap_Seats_Payload
+  set_ready: Set_Ready_Payload
+  kick_player: Kick_Player_Payload
+  add_bot: Add_Bot_Payload
+  remove_bot: Remove_Bot_Payload
+  start_game: Empty_Payload
+  set_quiet: Set_Quiet_Payload
+  set_rated: Set_Rated_Payload
+  set_card_tracker: Set_Tracker_Payload
+  room_settings: Room_Settings_Payload
+  list_rooms: Empty_Payload
+  rematch_vote: Rematch_Vote_Payload
+  set_series: Set_Series_Payload
+  queue_join: Queue_Join_Payload
+  queue_leave: Empty_Payload
+  chat: Chat_Payload
+  emote: Emote_Payload
+  open_replay: Open_Replay_Payload
+  join_replay: Join_Replay_Payload
+  leave_replay: Empty_Payload
+  replay_control: Replay_Control_Payload
+  resync: Empty_Payload
+  request_snapshot: Empty_Payload
+  hint: Empty_Payload
+  complete_selection: Complete_Selection_Payload
+}
+
+// Server_Payloads maps each message the server sends to its payload.
+export interface Server_Payloads {
+  ack: Ack_Payload
+  error: Error_Payload
+  room_state: Room_State_Payload
+  room_list: Room_List_Payload
+  room_closed: Room_Closed_Payload
+  kicked: Error_Payload
+  player_left: Player_Info
+  deal_cards: Deal_Cards_Payload
+  turn: Turn_Payload
+  play_made: Play_Made_Payload
+  tribute: Tribute_Payload
+  tribute_recv: Tribute_Recv_Payload
+  hand_end: Hand_End_Payload
+  game_end: Game_End_Payload
+  game_snapshot: Game_Snapshot_Payload
+  hint_suggestions: Hint_Suggestions_Payload
+  selection_completions: Selection_Completions_Payload
+  card_tracker: Card_Tracker_Payload
+  queue_status: Queue_Status_Payload
+  chat_message: Chat_Message_Payload
+  replay_state: Replay_State_Payload
+}
+
+export type Client_Message = {
+  [K in keyof Client_Payloads]: { type: K; request_id?: string; payload: Client_Payloads[K] }
+}[keyof Client_Payloads]
+
+export type Server_Message = {
+  [K in keyof Server_Payloads]: Omit<Message, 'type' | 'payload'> & { type: K; payload: Server_Payloads[K] }
+}[keyof Server_Payloads]
@@ -0,0 +1,61 @@
+import { Card, Rank } from './types'
+
+// Rules is the game package compiled to WebAssembly (server/cmd/wasm), so
+// the client checks plays with the same code as the server. An empty lead
+// means leading.
+export interface Rules {
+  detect(cards: Card[], level: Rank): Rules_Combination
+  can_beat(cards: Card[], lead: Card[], level: Rank): boolean
+  legal_plays(hand: Card[], lead: Card[], level: Rank): Rules_Combination[]
+  complete(hand: Card[], selected: Card[], lead: Card[], level: Rank): Rules_Completion[]
+}
+
+export interface Rules_Combination {
+  type: string
+  card_ids: number[]
+  rank_value: number
+  bomb_power: number
+}
+
+// Rules_Completion is a play containing the selected cards; add_ids are the
+// cards it adds to them.
+export interface Rules_Completion extends Rules_Combination {
+  add_ids: number[]
+}
+
+declare global {
+  // Go is defined by wasm_exec.js from the Go distribution.
+  class Go {
+    importObject: WebAssembly.Imports
+    run(instance: WebAssembly.Instance): Promise<void>
+  }
+  var guandan: Rules | undefined
+}
+
+let loading: Promise<Rules | null> | null = null
+
+// load_rules fetches and starts the module once. It resolves to null when
+// the module was not built, and callers leave checking to the server.
+export function load_rules(): Promise<Rules | null> {
+  if (!loading) loading = start().catch(() => null)
+  return loading
+}
+
+async function start(): Promise<Rules | null> {
+  const base = import.meta.env.BASE_URL
+  await load_script(base + 'wasm_exec.js')
+  const go = new Go()
+  const result = await WebAssembly.instantiateStreaming(fetch(base + 'rules.wasm'), go.importObject)
+  go.run(result.instance)
+  return globalThis.guandan ?? null
+}
+
+function load_script(src: string): Promise<void> {
+  return new Promise((resolve, reject) => {
+    const script = document.createElement('script')
+    script.src = src
+    script.onload = () => resolve()
+    script.onerror = () => reject(new Error('failed to load ' + src))
+    document.head.appendChild(script)
+  })
+}
@@ -1,47 +1,98 @@
-export type Suit = 0 | 1 | 2 | 3 | 4
-
-export const Suit_Hearts: Suit = 0
-export const Suit_Diamonds: Suit = 1
-export const Suit_Clubs: Suit = 2
-export const Suit_Spades: Suit = 3
-export const Suit_Joker: Suit = 4
-
-export type Rank = 0 | 1 | 2 | 3 | 4 | 5 | 6 | 7 | 8 | 9 | 10 | 11 | 12 | 13 | 14
-
-export const Rank_Two: Rank = 0
-export const Rank_Three: Rank = 1
-export const Rank_Four: Rank = 2
-export const Rank_Five: Rank = 3
-export const Rank_Six: Rank = 4
-export const Rank_Seven: Rank = 5
-export const Rank_Eight: Rank = 6
-export const Rank_Nine: Rank = 7
-export const Rank_Ten: Rank = 8
-export const Rank_Jack: Rank = 9
-export const Rank_Queen: Rank = 10
-export const Rank_King: Rank = 11
-export const Rank_Ace: Rank = 12
-export const Rank_Black_Joker: Rank = 13
-export const Rank_Red_Joker: Rank = 14
-
-export interface Card {
-  Suit: Suit
-  Rank: Rank
-  Id: number
-}
-
-export interface Player_Info {
+import { Card, Rank, Suit, Suit_Diamonds, Suit_Hearts } from './protocol'
+import type { Message as Protocol_Message, Game_End_Payload, Queue_Status_Payload } from './protocol'
+
+export type {
+  Card,
+  Msg_Type,
+  Player_Info,
+  Rank,
+  Rating_Change,
+  Rematch_Vote,
+  Series_State,
+  Spectator_Info,
+  Suit,
+} from './protocol'
+export {
+  Suit_Hearts,
+  Suit_Diamonds,
+  Suit_Clubs,
+  Suit_Spades,
+  Suit_Joker,
+  Rank_Two,
+  Rank_Three,
+  Rank_Four,
+  Rank_Five,
+  Rank_Six,
+  Rank_Seven,
+  Rank_Eight,
+  Rank_Nine,
+  Rank_Ten,
+  Rank_Jack,
+  Rank_Queen,
+  Rank_King,
+  Rank_Ace,
+  Rank_Black_Joker,
+  Rank_Red_Joker,
+} from './protocol'
+
+export type Queue_Status = Queue_Status_Payload
+export type Game_End = Game_End_Payload
+
+export interface Preferences {
+  card_sort?: string
+  sound: boolean
+  reduce_motion: boolean
+}
+
+export interface Profile {
   id: string
-  name: string
-  seat: number
-  team: number
-  is_ready: boolean
+  username: string
+  display_name: string
+  avatar_seed: string
+  preferences: Preferences
+  created_at: string
+}
+
+export interface Account_Session {
+  profile: Profile
+  session_token: string
+}
+
+export interface Room_Access {
+  visibility: 'public' | 'private'
+  has_password: boolean
+  invite_token?: string
 }
 
-export interface Room_State {
+export interface Room_Listing {
   room_id: string
-  players: Player_Info[]
-  game_active: boolean
+  host_name: string
+  seats_filled: number
+  bots: number
+  spectators: number
+  status: 'lobby' | 'playing' | 'finished'
+  rules: { quiet_hands: boolean }
+}
+
+export const Emotes = ['good_game', 'well_played', 'thanks', 'oops', 'nice_bomb', 'hurry_up'] as const
+
+export const Emote_Labels: Record<string, string> = {
+  good_game: 'Good game!',
+  well_played: 'Well played!',
+  thanks: 'Thanks!',
+  oops: 'Oops!',
+  nice_bomb: 'Nice bomb!',
+  hurry_up: 'Hurry up!',
+}
+
+export interface Chat_Message {
+  channel: 'table' | 'spectators'
+  player_id: string
+  name: string
+  seat: number
+  text?: string
+  emote?: string
+  sent_at: number
 }
 
 export interface Game_State {
@@ -56,28 +107,54 @@ export interface Game_State {
   team_levels: [number, number]
 }
 
-export type Msg_Type =
-  | 'join_room'
-  | 'create_room'
-  | 'room_state'
-  | 'game_start'
-  | 'deal_cards'
-  | 'play_cards'
-  | 'pass'
-  | 'turn'
-  | 'play_made'
-  | 'hand_end'
-  | 'tribute'
-  | 'tribute_give'
-  | 'tribute_recv'
-  | 'game_end'
-  | 'error'
-  | 'player_joined'
-  | 'player_left'
-  | 'fill_bots'
-
-export interface Message<T = unknown> {
-  type: Msg_Type
+export interface Replay_Player {
+  id: string
+  name: string
+  is_bot: boolean
+}
+
+export interface Level_Change {
+  finish_order: number[]
+  winning_team: number
+  level_advance: number
+  old_level: number
+  new_levels: [number, number]
+  game_over: boolean
+}
+
+export interface Replay_Event {
+  step: number
+  at_ms: number
+  kind: 'deal' | 'tribute' | 'play' | 'pass' | 'finish' | 'level_change' | 'hint'
+  seat: number
+  tribute?: { to_seat: number; card: Card }
+  play?: { card_ids: number[]; combo: { type: string; rank_value: number } }
+  position?: number
+  level_change?: Level_Change
+}
+
+export interface Replay_State {
+  replay_id: string
+  match_id: string
+  hand: number
+  players: Replay_Player[]
+  step: number
+  total_steps: number
+  playing: boolean
+  speed: number
+  event?: Replay_Event
+  level: Rank
+  team_levels: [number, number]
+  current_turn: number
+  table_cards: Card[] | null
+  combo_type: string
+  card_counts: number[]
+  finish_order: number[]
+  open_hands?: Card[][]
+  outcome?: Level_Change
+}
+
+export interface Message<T = unknown> extends Omit<Protocol_Message, 'payload'> {
   payload: T
 }
 
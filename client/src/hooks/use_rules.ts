@@ -0,0 +1,18 @@
+import { useEffect, useState } from 'react'
+import { Rules, load_rules } from '../game/rules'
+
+export function use_rules(): Rules | null {
+  const [rules, set_rules] = useState<Rules | null>(null)
+
+  useEffect(() => {
+    let active = true
+    load_rules().then((r) => {
+      if (active) set_rules(r)
+    })
+    return () => {
+      active = false
+    }
+  }, [])
+
+  return rules
+}
@@ -1,41 +1,77 @@
 import { useCallback, useEffect, useRef, useState } from 'react'
 import { Message } from '../game/types'
+import type { Room_State_Payload } from '../game/protocol'
 
 type Message_Handler = (msg: Message) => void
 
+const reconnect_delay_ms = 2000
+
 export function use_websocket(url: string) {
   const [connected, set_connected] = useState(false)
   const ws_ref = useRef<WebSocket | null>(null)
   const handlers_ref = useRef<Map<string, Message_Handler>>(new Map())
+  const last_seq_ref = useRef(0)
+  const room_ref = useRef('')
+  const next_request_ref = useRef(0)
 
   useEffect(() => {
-    const ws = new WebSocket(url)
-    ws_ref.current = ws
+    let closed = false
+    let retry: ReturnType<typeof setTimeout> | null = null
 
-    ws.onopen = () => {
-      set_connected(true)
-    }
+    const connect = () => {
+      const ws = new WebSocket(url)
+      ws_ref.current = ws
 
-    ws.onclose = () => {
-      set_connected(false)
-    }
+      ws.onopen = () => {
+        last_seq_ref.current = 0
+        room_ref.current = ''
+        set_connected(true)
+      }
 
-    ws.onmessage = (event) => {
-      const msg: Message = JSON.parse(event.data)
-      const handler = handlers_ref.current.get(msg.type)
-      if (handler) {
-        handler(msg)
+      ws.onclose = () => {
+        set_connected(false)
+        if (!closed) {
+          retry = setTimeout(connect, reconnect_delay_ms)
+        }
+      }
+
+      ws.onmessage = (event) => {
+        const msg: Message = JSON.parse(event.data)
+        // Sequence numbers are per room, so they start over in a new one.
+        if (msg.type === 'room_state') {
+          const room_id = (msg.payload as Room_State_Payload).room_id
+          if (room_id !== room_ref.current) {
+            room_ref.current = room_id
+            last_seq_ref.current = 0
+          }
+        }
+        if (msg.seq) {
+          // A jump means we missed a room event; ask for the current state.
+          if (msg.seq > last_seq_ref.current + 1 && last_seq_ref.current > 0) {
+            ws.send(JSON.stringify({ type: 'resync', payload: {} }))
+          }
+          last_seq_ref.current = msg.seq
+        }
+        const handler = handlers_ref.current.get(msg.type)
+        if (handler) {
+          handler(msg)
+        }
       }
     }
 
+    connect()
+
     return () => {
-      ws.close()
+      closed = true
+      if (retry) clearTimeout(retry)
+      ws_ref.current?.close()
     }
   }, [url])
 
   const send = useCallback((msg: Message) => {
     if (ws_ref.current && ws_ref.current.readyState === WebSocket.OPEN) {
-      ws_ref.current.send(JSON.stringify(msg))
+      next_request_ref.current++
+      ws_ref.current.send(JSON.stringify({ ...msg, request_id: `r${next_request_ref.current}` }))
     }
   }, [])
 
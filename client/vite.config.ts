@@ -9,6 +9,9 @@ export default defineConfig({
         target: 'ws://localhost:8080',
         ws: true,
       },
+      '/api': {
+        target: 'http://localhost:8081',
+      },
     },
   },
 })
@@ -0,0 +1,365 @@
+package account
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	min_username_len  = 3
+	max_username_len  = 20
+	min_password_len  = 8
+	max_display_len   = 24
+	max_sessions      = 10
+	session_token_len = 32
+)
+
+var (
+	Err_Invalid_Username    = errors.New("username must be 3-20 letters, digits, '-' or '_'")
+	Err_Username_Taken      = errors.New("username is taken")
+	Err_Weak_Password       = errors.New("password must be at least 8 characters")
+	Err_Invalid_Credentials = errors.New("invalid username or credentials")
+	Err_Invalid_Session     = errors.New("invalid session")
+	Err_Invalid_Profile     = errors.New("invalid profile")
+	Err_Not_Found           = errors.New("account not found")
+)
+
+type Preferences struct {
+	Card_Sort     string `json:"card_sort,omitempty"`
+	Sound         bool   `json:"sound"`
+	Reduce_Motion bool   `json:"reduce_motion"`
+}
+
+type Session struct {
+	Hash       string    `json:"hash"`
+	Created_At time.Time `json:"created_at"`
+}
+
+type Account struct {
+	Id           string      `json:"id"`
+	Username     string      `json:"username"`
+	Display_Name string      `json:"display_name"`
+	Avatar_Seed  string      `json:"avatar_seed"`
+	Preferences  Preferences `json:"preferences"`
+	Password     *Secret     `json:"password,omitempty"`
+	Login_Key    *Secret     `json:"login_key,omitempty"`
+	Sessions     []Session   `json:"sessions,omitempty"`
+	Created_At   time.Time   `json:"created_at"`
+}
+
+// Profile is the part of an account that is safe to hand out.
+type Profile struct {
+	Id           string      `json:"id"`
+	Username     string      `json:"username"`
+	Display_Name string      `json:"display_name"`
+	Avatar_Seed  string      `json:"avatar_seed"`
+	Preferences  Preferences `json:"preferences"`
+	Created_At   time.Time   `json:"created_at"`
+}
+
+type Profile_Update struct {
+	Display_Name *string      `json:"display_name,omitempty"`
+	Avatar_Seed  *string      `json:"avatar_seed,omitempty"`
+	Preferences  *Preferences `json:"preferences,omitempty"`
+}
+
+type Store interface {
+	Save_Account(a *Account) error
+	Load_Accounts() ([]*Account, error)
+}
+
+type Registry struct {
+	mu          sync.Mutex
+	store       Store
+	by_id       map[string]*Account
+	by_username map[string]*Account
+	sessions    map[string]*Account
+	logins      *Limiter
+}
+
+func Open(st Store) (*Registry, error) {
+	r := &Registry{
+		store:       st,
+		by_id:       make(map[string]*Account),
+		by_username: make(map[string]*Account),
+		sessions:    make(map[string]*Account),
+		logins:      New_Limiter(),
+	}
+
+	accounts, err := st.Load_Accounts()
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range accounts {
+		r.index(a)
+	}
+
+	return r, nil
+}
+
+func (a *Account) Profile() Profile {
+	return Profile{
+		Id:           a.Id,
+		Username:     a.Username,
+		Display_Name: a.Display_Name,
+		Avatar_Seed:  a.Avatar_Seed,
+		Preferences:  a.Preferences,
+		Created_At:   a.Created_At,
+	}
+}
+
+// Register creates an account. Without a password the account gets a
+// generated login key instead, which is returned once and never stored.
+func (r *Registry) Register(username string, password string, display_name string) (Profile, string, string, error) {
+	if !valid_username(username) {
+		return Profile{}, "", "", Err_Invalid_Username
+	}
+	if password != "" && len(password) < min_password_len {
+		return Profile{}, "", "", Err_Weak_Password
+	}
+
+	display_name = strings.TrimSpace(display_name)
+	if display_name == "" {
+		display_name = username
+	}
+	if len(display_name) > max_display_len {
+		return Profile{}, "", "", Err_Invalid_Profile
+	}
+
+	// A taken name fails before the costly hash, and is checked again below
+	// in case another registration took it meanwhile.
+	r.mu.Lock()
+	_, taken := r.by_username[strings.ToLower(username)]
+	r.mu.Unlock()
+	if taken {
+		return Profile{}, "", "", Err_Username_Taken
+	}
+
+	a := &Account{
+		Id:           "u_" + random_hex(12),
+		Username:     username,
+		Display_Name: display_name,
+		Avatar_Seed:  random_hex(8),
+		Created_At:   time.Now().UTC(),
+	}
+
+	login_key := ""
+	if password != "" {
+		a.Password = New_Secret(password)
+	} else {
+		login_key = random_hex(24)
+		a.Login_Key = New_Secret(login_key)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.by_username[strings.ToLower(username)]; ok {
+		return Profile{}, "", "", Err_Username_Taken
+	}
+
+	token := a.add_session()
+	if err := r.store.Save_Account(a); err != nil {
+		return Profile{}, "", "", err
+	}
+	r.index(a)
+
+	return a.Profile(), token, login_key, nil
+}
+
+// Login accepts either the account password or its login key.
+func (r *Registry) Login(username string, password string, login_key string) (Profile, string, error) {
+	r.mu.Lock()
+	a, ok := r.by_username[strings.ToLower(username)]
+	var password_secret, key_secret *Secret
+	if ok {
+		password_secret, key_secret = a.Password, a.Login_Key
+	}
+	r.mu.Unlock()
+
+	matched, checked := false, false
+	if password != "" && password_secret != nil {
+		matched, checked = password_secret.Matches(password), true
+	}
+	if !matched && login_key != "" && key_secret != nil {
+		matched, checked = key_secret.Matches(login_key), true
+	}
+	if !checked {
+		dummy_secret().Matches(password + login_key)
+	}
+	if !ok || !matched {
+		return Profile{}, "", Err_Invalid_Credentials
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token := a.add_session()
+	if err := r.store.Save_Account(a); err != nil {
+		return Profile{}, "", err
+	}
+	r.index(a)
+
+	return a.Profile(), token, nil
+}
+
+func (r *Registry) Logout(token string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	hash := hash_token(token)
+	a, ok := r.sessions[hash]
+	if !ok {
+		return Err_Invalid_Session
+	}
+
+	delete(r.sessions, hash)
+	for i, s := range a.Sessions {
+		if s.Hash == hash {
+			a.Sessions = append(a.Sessions[:i], a.Sessions[i+1:]...)
+			break
+		}
+	}
+	return r.store.Save_Account(a)
+}
+
+func (r *Registry) Authenticate(token string) (Profile, bool) {
+	if token == "" {
+		return Profile{}, false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	a, ok := r.sessions[hash_token(token)]
+	if !ok {
+		return Profile{}, false
+	}
+	return a.Profile(), true
+}
+
+func (r *Registry) Get(id string) (Profile, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	a, ok := r.by_id[id]
+	if !ok {
+		return Profile{}, false
+	}
+	return a.Profile(), true
+}
+
+func (r *Registry) Find(username string) (Profile, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	a, ok := r.by_username[strings.ToLower(username)]
+	if !ok {
+		return Profile{}, false
+	}
+	return a.Profile(), true
+}
+
+func (r *Registry) Update_Profile(id string, update Profile_Update) (Profile, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	a, ok := r.by_id[id]
+	if !ok {
+		return Profile{}, Err_Not_Found
+	}
+
+	next := *a
+	if update.Display_Name != nil {
+		name := strings.TrimSpace(*update.Display_Name)
+		if name == "" || len(name) > max_display_len {
+			return Profile{}, Err_Invalid_Profile
+		}
+		next.Display_Name = name
+	}
+	if update.Avatar_Seed != nil {
+		if *update.Avatar_Seed == "" || len(*update.Avatar_Seed) > 64 {
+			return Profile{}, Err_Invalid_Profile
+		}
+		next.Avatar_Seed = *update.Avatar_Seed
+	}
+	if update.Preferences != nil {
+		next.Preferences = *update.Preferences
+	}
+
+	if err := r.store.Save_Account(&next); err != nil {
+		return Profile{}, err
+	}
+	*a = next
+
+	return a.Profile(), nil
+}
+
+func (r *Registry) index(a *Account) {
+	r.drop_stale_sessions(a)
+	r.by_id[a.Id] = a
+	r.by_username[strings.ToLower(a.Username)] = a
+	for _, s := range a.Sessions {
+		r.sessions[s.Hash] = a
+	}
+}
+
+// add_session issues a new session token, dropping the oldest session once
+// the account holds too many.
+func (a *Account) add_session() string {
+	token := random_hex(session_token_len)
+	a.Sessions = append(a.Sessions, Session{
+		Hash:       hash_token(token),
+		Created_At: time.Now().UTC(),
+	})
+	if len(a.Sessions) > max_sessions {
+		a.Sessions = a.Sessions[len(a.Sessions)-max_sessions:]
+	}
+	return token
+}
+
+func (r *Registry) drop_stale_sessions(a *Account) {
+	for hash, owner := range r.sessions {
+		if owner != a {
+			continue
+		}
+		found := false
+		for _, s := range a.Sessions {
+			if s.Hash == hash {
+				found = true
+				break
+			}
+		}
+		if !found {
+			delete(r.sessions, hash)
+		}
+	}
+}
+
+func valid_username(name string) bool {
+	if len(name) < min_username_len || len(name) > max_username_len {
+		return false
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+func hash_token(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func random_hex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,110 @@
+package account
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+)
+
+type memory_store struct {
+	saved map[string]*Account
+}
+
+func (m *memory_store) Save_Account(a *Account) error {
+	m.saved[a.Id] = a
+	return nil
+}
+
+func (m *memory_store) Load_Accounts() ([]*Account, error) {
+	var out []*Account
+	for _, a := range m.saved {
+		out = append(out, a)
+	}
+	return out, nil
+}
+
+func TestSecretMatchesPBKDF2Vector(t *testing.T) {
+	// RFC 7914 section 11: PBKDF2-HMAC-SHA256, "passwd", "salt", 1 iteration.
+	want, _ := hex.DecodeString("55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc" +
+		"49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783")
+	s := &Secret{
+		Salt:       base64.RawStdEncoding.EncodeToString([]byte("salt")),
+		Iterations: 1,
+		Hash:       base64.RawStdEncoding.EncodeToString(want),
+	}
+
+	if !s.Matches("passwd") {
+		t.Fatal("secret does not match the RFC vector")
+	}
+	if s.Matches("passwd2") {
+		t.Fatal("secret matches the wrong password")
+	}
+}
+
+func TestLogin(t *testing.T) {
+	r, err := Open(&memory_store{saved: make(map[string]*Account)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := r.Register("ana", "correct horse", ""); err != nil {
+		t.Fatal(err)
+	}
+	_, _, key, err := r.Register("bobo", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		username  string
+		password  string
+		login_key string
+		want      error
+	}{
+		{"password", "ana", "correct horse", "", nil},
+		{"username case", "ANA", "correct horse", "", nil},
+		{"wrong password", "ana", "wrong horse", "", Err_Invalid_Credentials},
+		{"login key", "bobo", "", key, nil},
+		{"wrong login key", "bobo", "", "nope", Err_Invalid_Credentials},
+		{"password on key account", "bobo", "correct horse", "", Err_Invalid_Credentials},
+		{"unknown user", "cyra", "correct horse", "", Err_Invalid_Credentials},
+		{"nothing given", "ana", "", "", Err_Invalid_Credentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, token, err := r.Login(tt.username, tt.password, tt.login_key)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+			if err == nil && token == "" {
+				t.Fatal("no session token")
+			}
+		})
+	}
+}
+
+func TestLoginLimiter(t *testing.T) {
+	l := New_Limiter()
+	now := time.Now()
+
+	for i := 0; i < login_burst; i++ {
+		if !l.Allow(now, "addr 1", "user ana") {
+			t.Fatalf("attempt %d refused", i)
+		}
+	}
+	if l.Allow(now, "addr 1", "user bo") {
+		t.Fatal("address over its limit was allowed")
+	}
+	if l.Allow(now, "addr 2", "user ana") {
+		t.Fatal("account over its limit was allowed")
+	}
+	if !l.Allow(now, "addr 2", "user bo") {
+		t.Fatal("fresh address and account refused")
+	}
+	if !l.Allow(now.Add(login_refill), "addr 1", "user cy") {
+		t.Fatal("address refused after a refill")
+	}
+}
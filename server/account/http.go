@@ -0,0 +1,170 @@
+package account
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"guandanbtw/web"
+)
+
+type register_request struct {
+	Username     string `json:"username"`
+	Password     string `json:"password"`
+	Display_Name string `json:"display_name"`
+}
+
+type login_request struct {
+	Username  string `json:"username"`
+	Password  string `json:"password"`
+	Login_Key string `json:"login_key"`
+}
+
+type session_response struct {
+	Profile       Profile `json:"profile"`
+	Session_Token string  `json:"session_token"`
+	Login_Key     string  `json:"login_key,omitempty"`
+}
+
+func (r *Registry) Handle_Register(w http.ResponseWriter, req *http.Request) {
+	var body register_request
+	if !web.Read_JSON(w, req, &body) {
+		return
+	}
+
+	if !r.logins.Allow(time.Now(), "addr "+web.Client_Addr(req)) {
+		write_error(w, Err_Too_Many_Attempts)
+		return
+	}
+
+	profile, token, login_key, err := r.Register(body.Username, body.Password, body.Display_Name)
+	if err != nil {
+		write_error(w, err)
+		return
+	}
+
+	web.Write_JSON(w, http.StatusCreated, session_response{
+		Profile:       profile,
+		Session_Token: token,
+		Login_Key:     login_key,
+	})
+}
+
+func (r *Registry) Handle_Login(w http.ResponseWriter, req *http.Request) {
+	var body login_request
+	if !web.Read_JSON(w, req, &body) {
+		return
+	}
+
+	if !r.logins.Allow(time.Now(), "addr "+web.Client_Addr(req), "user "+strings.ToLower(body.Username)) {
+		write_error(w, Err_Too_Many_Attempts)
+		return
+	}
+
+	profile, token, err := r.Login(body.Username, body.Password, body.Login_Key)
+	if err != nil {
+		write_error(w, err)
+		return
+	}
+
+	web.Write_JSON(w, http.StatusOK, session_response{
+		Profile:       profile,
+		Session_Token: token,
+	})
+}
+
+func (r *Registry) Handle_Logout(w http.ResponseWriter, req *http.Request) {
+	if err := r.Logout(bearer_token(req)); err != nil {
+		write_error(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (r *Registry) Handle_Me(w http.ResponseWriter, req *http.Request) {
+	profile, ok := r.Authenticate(bearer_token(req))
+	if !ok {
+		write_error(w, Err_Invalid_Session)
+		return
+	}
+	web.Write_JSON(w, http.StatusOK, profile)
+}
+
+func (r *Registry) Handle_Update_Me(w http.ResponseWriter, req *http.Request) {
+	profile, ok := r.Authenticate(bearer_token(req))
+	if !ok {
+		write_error(w, Err_Invalid_Session)
+		return
+	}
+
+	var update Profile_Update
+	if !web.Read_JSON(w, req, &update) {
+		return
+	}
+
+	profile, err := r.Update_Profile(profile.Id, update)
+	if err != nil {
+		write_error(w, err)
+		return
+	}
+	web.Write_JSON(w, http.StatusOK, profile)
+}
+
+// Handle_Profile is only open to signed-in players, and each account gets
+// the login budget of lookups, so it cannot be used to list which usernames
+// exist.
+func (r *Registry) Handle_Profile(w http.ResponseWriter, req *http.Request) {
+	me, ok := r.Authenticate(bearer_token(req))
+	if !ok {
+		write_error(w, Err_Invalid_Session)
+		return
+	}
+	if !r.logins.Allow(time.Now(), "lookup "+me.Id) {
+		write_error(w, Err_Too_Many_Attempts)
+		return
+	}
+
+	profile, ok := r.Find(req.PathValue("username"))
+	if !ok {
+		write_error(w, Err_Not_Found)
+		return
+	}
+
+	web.Write_JSON(w, http.StatusOK, map[string]interface{}{
+		"username":     profile.Username,
+		"display_name": profile.Display_Name,
+		"avatar_seed":  profile.Avatar_Seed,
+		"created_at":   profile.Created_At,
+	})
+}
+
+func bearer_token(req *http.Request) string {
+	token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+func write_error(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, Err_Invalid_Username), errors.Is(err, Err_Weak_Password), errors.Is(err, Err_Invalid_Profile):
+		status = http.StatusBadRequest
+	case errors.Is(err, Err_Username_Taken):
+		status = http.StatusConflict
+	case errors.Is(err, Err_Invalid_Credentials), errors.Is(err, Err_Invalid_Session):
+		status = http.StatusUnauthorized
+	case errors.Is(err, Err_Not_Found):
+		status = http.StatusNotFound
+	case errors.Is(err, Err_Too_Many_Attempts):
+		status = http.StatusTooManyRequests
+	default:
+		log.Printf("account: %v", err)
+		err = errors.New("internal error")
+	}
+
+	web.Write_Error(w, status, err.Error())
+}
@@ -0,0 +1,73 @@
+package account
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func post_register(r *Registry, addr, username string) int {
+	req := httptest.NewRequest("POST", "/api/accounts/register", strings.NewReader(`{"username":"`+username+`","password":"longenough"}`))
+	req.RemoteAddr = addr + ":1234"
+	w := httptest.NewRecorder()
+	r.Handle_Register(w, req)
+	return w.Code
+}
+
+func TestRegisterIsRateLimited(t *testing.T) {
+	r, err := Open(&memory_store{saved: make(map[string]*Account)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if code := post_register(r, "10.0.0.1", "first"); code != http.StatusCreated {
+		t.Fatalf("first registration: %d", code)
+	}
+	for range login_burst - 1 {
+		if code := post_register(r, "10.0.0.1", "first"); code != http.StatusConflict {
+			t.Fatalf("taken name: %d, want 409", code)
+		}
+	}
+	if code := post_register(r, "10.0.0.1", "second"); code != http.StatusTooManyRequests {
+		t.Fatalf("past the budget: %d, want 429", code)
+	}
+	if code := post_register(r, "10.0.0.2", "second"); code != http.StatusCreated {
+		t.Fatalf("another address: %d, want 201", code)
+	}
+}
+
+func TestProfileNeedsSession(t *testing.T) {
+	r, err := Open(&memory_store{saved: make(map[string]*Account)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, token, _, err := r.Register("viewer", "longenough", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lookup := func(token, username string) int {
+		req := httptest.NewRequest("GET", "/api/profiles/"+username, nil)
+		req.SetPathValue("username", username)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		r.Handle_Profile(w, req)
+		return w.Code
+	}
+
+	if code := lookup("", "viewer"); code != http.StatusUnauthorized {
+		t.Fatalf("without a session: %d, want 401", code)
+	}
+	if code := lookup(token, "viewer"); code != http.StatusOK {
+		t.Fatalf("existing profile: %d", code)
+	}
+	for range login_burst - 1 {
+		lookup(token, "nobody")
+	}
+	if code := lookup(token, "viewer"); code != http.StatusTooManyRequests {
+		t.Fatalf("past the budget: %d, want 429", code)
+	}
+}
@@ -0,0 +1,77 @@
+package account
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	login_burst       = 10
+	login_refill      = 6 * time.Second
+	login_limiter_cap = 10000
+)
+
+var Err_Too_Many_Attempts = errors.New("too many attempts, try again later")
+
+// Limiter is a token bucket per key: a client address, a username, an
+// account looking up profiles or a room taking password attempts. Each
+// attempt takes a token, and a token comes back every login_refill up to
+// login_burst.
+type Limiter struct {
+	mu      sync.Mutex
+	buckets map[string]*login_bucket
+}
+
+type login_bucket struct {
+	tokens float64
+	at     time.Time
+}
+
+func New_Limiter() *Limiter {
+	return &Limiter{buckets: make(map[string]*login_bucket)}
+}
+
+// Allow takes a token from every key's bucket, and reports false without
+// taking any if one of them is empty.
+func (l *Limiter) Allow(now time.Time, keys ...string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.buckets) > login_limiter_cap {
+		l.prune(now)
+	}
+
+	buckets := make([]*login_bucket, len(keys))
+	for i, key := range keys {
+		b, ok := l.buckets[key]
+		if !ok {
+			b = &login_bucket{tokens: login_burst, at: now}
+			l.buckets[key] = b
+		}
+		b.refill(now)
+		if b.tokens < 1 {
+			return false
+		}
+		buckets[i] = b
+	}
+	for _, b := range buckets {
+		b.tokens--
+	}
+	return true
+}
+
+// prune forgets buckets that have refilled, which start over full anyway.
+func (l *Limiter) prune(now time.Time) {
+	for key, b := range l.buckets {
+		b.refill(now)
+		if b.tokens >= login_burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func (b *login_bucket) refill(now time.Time) {
+	b.tokens = min(login_burst, b.tokens+float64(now.Sub(b.at))/float64(login_refill))
+	b.at = now
+}
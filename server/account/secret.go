@@ -0,0 +1,57 @@
+package account
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"sync"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	secret_iterations = 310000
+	secret_salt_len   = 16
+	secret_key_len    = 32
+)
+
+// Secret is a PBKDF2-HMAC-SHA256 hash of a password or login key.
+type Secret struct {
+	Salt       string `json:"salt"`
+	Iterations int    `json:"iterations"`
+	Hash       string `json:"hash"`
+}
+
+// New_Secret hashes plain with a fresh salt.
+func New_Secret(plain string) *Secret {
+	salt := make([]byte, secret_salt_len)
+	rand.Read(salt)
+
+	return &Secret{
+		Salt:       base64.RawStdEncoding.EncodeToString(salt),
+		Iterations: secret_iterations,
+		Hash:       base64.RawStdEncoding.EncodeToString(pbkdf2.Key([]byte(plain), salt, secret_iterations, secret_key_len, sha256.New)),
+	}
+}
+
+// Matches reports whether plain hashes to s. A zero Secret matches nothing.
+func (s *Secret) Matches(plain string) bool {
+	salt, err := base64.RawStdEncoding.DecodeString(s.Salt)
+	if err != nil {
+		return false
+	}
+	want, err := base64.RawStdEncoding.DecodeString(s.Hash)
+	if err != nil || s.Iterations < 1 {
+		return false
+	}
+
+	got := pbkdf2.Key([]byte(plain), salt, s.Iterations, len(want), sha256.New)
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
+
+// dummy_secret is checked when there is no real secret to check, so a login
+// takes as long whether or not the username exists.
+var dummy_secret = sync.OnceValue(func() *Secret {
+	return New_Secret(random_hex(16))
+})
@@ -0,0 +1,423 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const module = "guandanbtw"
+
+// packages are checked in dependency order. Names are given out in
+// name_order, so protocol types keep their own names on a clash.
+var (
+	packages   = []string{"game", "history", "protocol"}
+	name_order = []string{"protocol", "game", "history"}
+)
+
+type message struct {
+	name     string
+	payload  *types.Named
+	required []string
+}
+
+type model struct {
+	fset     *token.FileSet
+	pkgs     map[string]*types.Package
+	docs     map[*types.TypeName]string
+	envelope *types.Named
+	client   []message
+	server   []message
+
+	// decls are the named types to emit, names their TypeScript names and
+	// enums the constants of each named basic type.
+	decls    []*types.Named
+	names    map[*types.TypeName]string
+	enums    map[*types.TypeName][]*types.Const
+	plain    []*types.Const
+	required map[*types.Named][]string
+}
+
+type module_importer struct {
+	checked  map[string]*types.Package
+	fallback types.Importer
+}
+
+func (i module_importer) Import(path string) (*types.Package, error) {
+	if pkg := i.checked[path]; pkg != nil {
+		return pkg, nil
+	}
+	return i.fallback.Import(path)
+}
+
+func load(dir string) (*model, error) {
+	m := &model{
+		fset:     token.NewFileSet(),
+		pkgs:     make(map[string]*types.Package),
+		docs:     make(map[*types.TypeName]string),
+		names:    make(map[*types.TypeName]string),
+		enums:    make(map[*types.TypeName][]*types.Const),
+		required: make(map[*types.Named][]string),
+	}
+
+	info := &types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+		Defs:  make(map[*ast.Ident]types.Object),
+		Uses:  make(map[*ast.Ident]types.Object),
+	}
+	checked := make(map[string]*types.Package)
+	conf := types.Config{Importer: module_importer{checked: checked, fallback: importer.Default()}}
+
+	var protocol_files []*ast.File
+	for _, name := range packages {
+		files, err := parse_dir(m.fset, filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		pkg, err := conf.Check(module+"/"+name, m.fset, files, info)
+		if err != nil {
+			return nil, err
+		}
+		checked[pkg.Path()] = pkg
+		m.pkgs[name] = pkg
+		collect_docs(files, info, m.docs)
+		if name == "protocol" {
+			protocol_files = files
+		}
+	}
+
+	protocol := m.pkgs["protocol"]
+	envelope, ok := protocol.Scope().Lookup("Message").Type().(*types.Named)
+	if !ok {
+		return nil, fmt.Errorf("protocol.Message is not a named type")
+	}
+	m.envelope = envelope
+
+	var err error
+	if m.client, err = read_registry(protocol_files, info, "client_payloads"); err != nil {
+		return nil, err
+	}
+	if m.server, err = read_registry(protocol_files, info, "server_payloads"); err != nil {
+		return nil, err
+	}
+	for _, msg := range m.client {
+		m.required[msg.payload] = msg.required
+	}
+	for _, msg := range m.server {
+		if _, ok := m.required[msg.payload]; ok {
+			return nil, fmt.Errorf("%s is both a client and a server payload", msg.payload.Obj().Name())
+		}
+	}
+
+	seen := make(map[*types.Named]bool)
+	m.walk(envelope, seen)
+	for _, msg := range append(slices.Clone(m.client), m.server...) {
+		m.walk(msg.payload, seen)
+	}
+	m.collect_plain(protocol)
+	m.order()
+	return m, nil
+}
+
+func parse_dir(fset *token.FileSet, dir string) ([]*ast.File, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []*ast.File
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+func collect_docs(files []*ast.File, info *types.Info, docs map[*types.TypeName]string) {
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok || gd.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				ts := spec.(*ast.TypeSpec)
+				doc := ts.Doc
+				if doc == nil && len(gd.Specs) == 1 {
+					doc = gd.Doc
+				}
+				if obj, ok := info.Defs[ts.Name].(*types.TypeName); ok && doc != nil {
+					docs[obj] = strings.TrimSpace(doc.Text())
+				}
+			}
+		}
+	}
+}
+
+// read_registry reads a map literal from Msg_Type to payload. Values are
+// either spec[T]("required", ...) calls or T{} literals.
+func read_registry(files []*ast.File, info *types.Info, name string) ([]message, error) {
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok || gd.Tok != token.VAR {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				vs := spec.(*ast.ValueSpec)
+				if len(vs.Names) != 1 || vs.Names[0].Name != name || len(vs.Values) != 1 {
+					continue
+				}
+				lit, ok := vs.Values[0].(*ast.CompositeLit)
+				if !ok {
+					return nil, fmt.Errorf("%s is not a map literal", name)
+				}
+				return registry_entries(lit, info, name)
+			}
+		}
+	}
+	return nil, fmt.Errorf("protocol.%s not found", name)
+}
+
+func registry_entries(lit *ast.CompositeLit, info *types.Info, name string) ([]message, error) {
+	var messages []message
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			return nil, fmt.Errorf("%s: expected key: value", name)
+		}
+		key := info.Types[kv.Key]
+		if key.Value == nil || key.Value.Kind() != constant.String {
+			return nil, fmt.Errorf("%s: key %s is not a constant", name, types.ExprString(kv.Key))
+		}
+
+		msg := message{name: constant.StringVal(key.Value)}
+		var payload types.Type
+		switch v := kv.Value.(type) {
+		case *ast.CallExpr:
+			index, ok := v.Fun.(*ast.IndexExpr)
+			if !ok {
+				return nil, fmt.Errorf("%s: %s: expected spec[T](...)", name, msg.name)
+			}
+			payload = info.Types[index.Index].Type
+			for _, arg := range v.Args {
+				lit, ok := arg.(*ast.BasicLit)
+				if !ok || lit.Kind != token.STRING {
+					return nil, fmt.Errorf("%s: %s: required fields must be string literals", name, msg.name)
+				}
+				field, _ := strconv.Unquote(lit.Value)
+				msg.required = append(msg.required, field)
+			}
+		case *ast.CompositeLit:
+			payload = info.Types[v].Type
+		default:
+			return nil, fmt.Errorf("%s: %s: unsupported value %s", name, msg.name, types.ExprString(kv.Value))
+		}
+
+		named, ok := payload.(*types.Named)
+		if !ok {
+			return nil, fmt.Errorf("%s: %s: payload is not a named type", name, msg.name)
+		}
+		msg.payload = named
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+func in_module(obj *types.TypeName) bool {
+	return obj.Pkg() != nil && strings.HasPrefix(obj.Pkg().Path(), module+"/")
+}
+
+// walk records every module type reachable from t.
+func (m *model) walk(t types.Type, seen map[*types.Named]bool) {
+	switch t := t.(type) {
+	case *types.Named:
+		if !in_module(t.Obj()) || seen[t] {
+			return
+		}
+		seen[t] = true
+		m.decls = append(m.decls, t)
+		if _, ok := t.Underlying().(*types.Basic); ok {
+			m.enums[t.Obj()] = enum_values(t)
+		}
+		m.walk(t.Underlying(), seen)
+	case *types.Pointer:
+		m.walk(t.Elem(), seen)
+	case *types.Slice:
+		m.walk(t.Elem(), seen)
+	case *types.Array:
+		m.walk(t.Elem(), seen)
+	case *types.Map:
+		m.walk(t.Key(), seen)
+		m.walk(t.Elem(), seen)
+	case *types.Struct:
+		for i := 0; i < t.NumFields(); i++ {
+			m.walk(t.Field(i).Type(), seen)
+		}
+	}
+}
+
+func enum_values(t *types.Named) []*types.Const {
+	var values []*types.Const
+	scope := t.Obj().Pkg().Scope()
+	for _, name := range scope.Names() {
+		c, ok := scope.Lookup(name).(*types.Const)
+		if ok && c.Exported() && types.Identical(c.Type(), t) {
+			values = append(values, c)
+		}
+	}
+	slices.SortFunc(values, func(a, b *types.Const) int { return int(a.Pos() - b.Pos()) })
+	return values
+}
+
+// collect_plain picks up the exported untyped constants of protocol, such as
+// limits and error codes.
+func (m *model) collect_plain(pkg *types.Package) {
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		c, ok := scope.Lookup(name).(*types.Const)
+		if !ok || !c.Exported() {
+			continue
+		}
+		if b, ok := c.Type().(*types.Basic); ok && b.Info()&types.IsUntyped != 0 {
+			m.plain = append(m.plain, c)
+		}
+	}
+	slices.SortFunc(m.plain, func(a, b *types.Const) int { return int(a.Pos() - b.Pos()) })
+}
+
+// order sorts declarations by package and source position and names them.
+func (m *model) order() {
+	rank := func(t *types.Named) int { return slices.Index(packages, t.Obj().Pkg().Name()) }
+	slices.SortFunc(m.decls, func(a, b *types.Named) int {
+		if ra, rb := rank(a), rank(b); ra != rb {
+			return ra - rb
+		}
+		return int(a.Obj().Pos() - b.Obj().Pos())
+	})
+
+	taken := make(map[string]bool)
+	for _, pkg := range name_order {
+		for _, t := range m.decls {
+			obj := t.Obj()
+			if obj.Pkg().Name() != pkg {
+				continue
+			}
+			name := obj.Name()
+			if taken[name] {
+				name = strings.ToUpper(pkg[:1]) + pkg[1:] + "_" + name
+			}
+			taken[name] = true
+			m.names[obj] = name
+		}
+	}
+}
+
+type json_field struct {
+	name      string
+	omitempty bool
+	as_string bool
+	field     *types.Var
+}
+
+// json_fields lists the fields encoding/json writes for a struct, with
+// embedded structs flattened.
+func json_fields(s *types.Struct) []json_field {
+	var fields []json_field
+	for i := 0; i < s.NumFields(); i++ {
+		f := s.Field(i)
+		tag := reflect.StructTag(s.Tag(i)).Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Embedded() && name == "" {
+			if inner, ok := f.Type().Underlying().(*types.Struct); ok {
+				fields = append(fields, json_fields(inner)...)
+				continue
+			}
+		}
+		if !f.Exported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name()
+		}
+		fields = append(fields, json_field{
+			name:      name,
+			omitempty: slices.Contains(strings.Split(opts, ","), "omitempty"),
+			as_string: slices.Contains(strings.Split(opts, ","), "string"),
+			field:     f,
+		})
+	}
+	return fields
+}
+
+// special maps types from outside the module that have their own JSON form.
+func special(t *types.Named) (string, bool) {
+	obj := t.Obj()
+	if obj.Pkg() == nil {
+		return "", false
+	}
+	switch obj.Pkg().Path() + "." + obj.Name() {
+	case "time.Time":
+		return "time", true
+	case "encoding/json.RawMessage":
+		return "raw", true
+	}
+	return "", false
+}
+
+// nullable reports whether a value of t can encode as null.
+func nullable(t types.Type) bool {
+	if n, ok := t.(*types.Named); ok {
+		if _, ok := special(n); ok {
+			return false
+		}
+	}
+	switch t.Underlying().(type) {
+	case *types.Pointer, *types.Slice, *types.Map, *types.Interface:
+		return true
+	}
+	return false
+}
+
+func is_bytes(t *types.Slice) bool {
+	b, ok := t.Elem().Underlying().(*types.Basic)
+	return ok && b.Kind() == types.Byte
+}
+
+func (m *model) doc(t *types.Named) string {
+	return m.docs[t.Obj()]
+}
+
+func const_value(c *types.Const) any {
+	v := c.Val()
+	switch v.Kind() {
+	case constant.String:
+		return constant.StringVal(v)
+	case constant.Bool:
+		return constant.BoolVal(v)
+	case constant.Int:
+		n, _ := constant.Int64Val(v)
+		return n
+	}
+	f, _ := constant.Float64Val(v)
+	return f
+}
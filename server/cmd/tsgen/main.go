@@ -0,0 +1,97 @@
+// Command tsgen writes the TypeScript definitions and the JSON Schema of the
+// WebSocket protocol from the protocol, game and history packages. It runs
+// through go generate in server/protocol; with -check it writes nothing and
+// fails when the checked-in files are stale.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+type output struct {
+	path string
+	data []byte
+}
+
+func main() {
+	check := flag.Bool("check", false, "fail if the generated files are out of date instead of writing them")
+	root := flag.String("root", "", "server module directory (default: found from the working directory)")
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("tsgen: ")
+
+	dir := *root
+	if dir == "" {
+		var err error
+		if dir, err = find_module(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	outputs, err := generate(dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	stale := false
+	for _, out := range outputs {
+		current, err := os.ReadFile(out.path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Fatal(err)
+		}
+		if bytes.Equal(current, out.data) {
+			continue
+		}
+		if *check {
+			fmt.Fprintf(os.Stderr, "%s is out of date; run go generate ./protocol\n", filepath.Clean(out.path))
+			stale = true
+			continue
+		}
+		if err := os.WriteFile(out.path, out.data, 0o644); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if stale {
+		os.Exit(1)
+	}
+}
+
+// generate renders every output for the server module in dir.
+func generate(dir string) ([]output, error) {
+	m, err := load(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	schema, err := emit_schema(m)
+	if err != nil {
+		return nil, err
+	}
+	return []output{
+		{path: filepath.Join(dir, "..", "client", "src", "game", "protocol.ts"), data: emit_ts(m)},
+		{path: filepath.Join(dir, "protocol", "schema.json"), data: schema},
+	}, nil
+}
+
+func find_module() (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return dir, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", errors.New("no go.mod above the working directory")
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestGeneratedFilesUpToDate fails when protocol.ts or schema.json no longer
+// match the Go types; run go generate ./protocol to refresh them.
+func TestGeneratedFilesUpToDate(t *testing.T) {
+	outputs, err := generate(filepath.Join("..", ".."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, out := range outputs {
+		current, err := os.ReadFile(out.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(current, out.data) {
+			t.Errorf("%s is out of date; run go generate ./protocol", filepath.Clean(out.path))
+		}
+	}
+}
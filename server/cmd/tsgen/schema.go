@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"go/types"
+)
+
+type object = map[string]any
+
+func emit_schema(m *model) ([]byte, error) {
+	defs := object{}
+	for _, t := range m.decls {
+		def := m.schema_decl(t)
+		if doc := m.doc(t); doc != "" {
+			def["description"] = doc
+		}
+		defs[m.names[t.Obj()]] = def
+	}
+
+	max_request_id := const_value(m.pkgs["protocol"].Scope().Lookup("Max_Request_Id_Length").(*types.Const))
+
+	var client []any
+	for _, msg := range m.client {
+		required := []string{"type"}
+		if len(msg.required) > 0 {
+			required = append(required, "payload")
+		}
+		client = append(client, object{
+			"type": "object",
+			"properties": object{
+				"type":       object{"const": msg.name},
+				"request_id": object{"type": "string", "maxLength": max_request_id},
+				"payload":    m.schema_ref(msg.payload),
+			},
+			"required":             required,
+			"additionalProperties": false,
+		})
+	}
+
+	var server []any
+	for _, msg := range m.server {
+		server = append(server, object{
+			"type": "object",
+			"properties": object{
+				"type":       object{"const": msg.name},
+				"request_id": object{"type": "string"},
+				"seq":        object{"type": "integer"},
+				"payload":    m.schema_ref(msg.payload),
+			},
+			"required":             []string{"type", "payload"},
+			"additionalProperties": false,
+		})
+	}
+
+	defs["Client_Message"] = object{"description": "A message a client may send.", "oneOf": client}
+	defs["Server_Message"] = object{"description": "A message the server sends.", "oneOf": server}
+
+	schema := object{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"title":   "Guan Dan BTW WebSocket protocol",
+		"anyOf": []any{
+			object{"$ref": "#/$defs/Client_Message"},
+			object{"$ref": "#/$defs/Server_Message"},
+		},
+		"$defs": defs,
+	}
+
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+func (m *model) schema_decl(t *types.Named) object {
+	if s, ok := t.Underlying().(*types.Struct); ok {
+		required, is_client := m.required[t]
+		properties := object{}
+		for _, f := range json_fields(s) {
+			properties[f.name] = m.schema_field(f)
+			if !is_client && !f.omitempty {
+				required = append(required, f.name)
+			}
+		}
+		def := object{
+			"type":                 "object",
+			"properties":           properties,
+			"additionalProperties": false,
+		}
+		if len(required) > 0 {
+			def["required"] = required
+		}
+		return def
+	}
+
+	def := m.schema_type(t.Underlying())
+	if values := m.enums[t.Obj()]; len(values) > 0 {
+		var enum []any
+		for _, c := range values {
+			enum = append(enum, const_value(c))
+		}
+		def["enum"] = enum
+	}
+	return def
+}
+
+func (m *model) schema_field(f json_field) object {
+	t := f.field.Type()
+	if f.as_string {
+		return object{"type": "string"}
+	}
+	if f.omitempty || !nullable(t) {
+		return m.schema_type(t)
+	}
+	return with_null(m.schema_type(t))
+}
+
+func (m *model) schema_ref(t *types.Named) object {
+	return object{"$ref": "#/$defs/" + m.names[t.Obj()]}
+}
+
+// with_null lets a schema also match null, as a nil pointer, slice or map
+// encodes.
+func with_null(s object) object {
+	if kind, ok := s["type"].(string); ok {
+		s["type"] = []string{kind, "null"}
+		return s
+	}
+	return object{"anyOf": []any{s, object{"type": "null"}}}
+}
+
+func (m *model) schema_type(t types.Type) object {
+	t = types.Unalias(t)
+	if n, ok := t.(*types.Named); ok {
+		if kind, ok := special(n); ok {
+			if kind == "time" {
+				return object{"type": "string", "format": "date-time"}
+			}
+			return object{}
+		}
+		if _, ok := m.names[n.Obj()]; ok {
+			return m.schema_ref(n)
+		}
+		return m.schema_type(n.Underlying())
+	}
+
+	switch t := t.(type) {
+	case *types.Basic:
+		switch {
+		case t.Info()&types.IsString != 0:
+			return object{"type": "string"}
+		case t.Info()&types.IsBoolean != 0:
+			return object{"type": "boolean"}
+		case t.Info()&types.IsInteger != 0:
+			return object{"type": "integer"}
+		case t.Info()&types.IsNumeric != 0:
+			return object{"type": "number"}
+		}
+	case *types.Pointer:
+		return m.schema_type(t.Elem())
+	case *types.Slice:
+		if is_bytes(t) {
+			return object{"type": "string", "contentEncoding": "base64"}
+		}
+		return object{"type": "array", "items": m.schema_elem(t.Elem())}
+	case *types.Array:
+		n := t.Len()
+		return object{"type": "array", "items": m.schema_elem(t.Elem()), "minItems": n, "maxItems": n}
+	case *types.Map:
+		return object{"type": "object", "additionalProperties": m.schema_elem(t.Elem())}
+	case *types.Struct:
+		properties := object{}
+		var required []string
+		for _, f := range json_fields(t) {
+			properties[f.name] = m.schema_field(f)
+			if !f.omitempty {
+				required = append(required, f.name)
+			}
+		}
+		def := object{"type": "object", "properties": properties, "additionalProperties": false}
+		if len(required) > 0 {
+			def["required"] = required
+		}
+		return def
+	}
+	return object{}
+}
+
+func (m *model) schema_elem(t types.Type) object {
+	if nullable(t) {
+		return with_null(m.schema_type(t))
+	}
+	return m.schema_type(t)
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/types"
+	"strconv"
+	"strings"
+)
+
+const (
+	header   = "// Code generated by cmd/tsgen from server/protocol; DO NOT EDIT.\n"
+	max_line = 100
+)
+
+func emit_ts(m *model) []byte {
+	var b bytes.Buffer
+	b.WriteString(header)
+
+	for _, c := range m.plain {
+		fmt.Fprintf(&b, "\nexport const %s = %s", c.Name(), ts_literal(const_value(c)))
+	}
+	if len(m.plain) > 0 {
+		b.WriteString("\n")
+	}
+
+	for _, t := range m.decls {
+		b.WriteString("\n")
+		write_doc(&b, m.doc(t))
+		name := m.names[t.Obj()]
+		switch u := t.Underlying().(type) {
+		case *types.Struct:
+			fields := json_fields(u)
+			if len(fields) == 0 {
+				fmt.Fprintf(&b, "export type %s = Record<string, never>\n", name)
+				continue
+			}
+			fmt.Fprintf(&b, "export interface %s {\n", name)
+			for _, f := range fields {
+				fmt.Fprintf(&b, "  %s\n", m.ts_field(f))
+			}
+			b.WriteString("}\n")
+		default:
+			values := m.enums[t.Obj()]
+			if len(values) == 0 {
+				fmt.Fprintf(&b, "export type %s = %s\n", name, m.ts_type(u))
+				continue
+			}
+			literals := make([]string, len(values))
+			for i, c := range values {
+				literals[i] = ts_literal(const_value(c))
+			}
+			if line := fmt.Sprintf("export type %s = %s", name, strings.Join(literals, " | ")); len(line) <= max_line {
+				b.WriteString(line + "\n\n")
+			} else {
+				fmt.Fprintf(&b, "export type %s =\n  | %s\n\n", name, strings.Join(literals, "\n  | "))
+			}
+			for _, c := range values {
+				fmt.Fprintf(&b, "export const %s: %s = %s\n", c.Name(), name, ts_literal(const_value(c)))
+			}
+		}
+	}
+
+	write_registry(&b, "Client_Payloads", "Client_Payloads maps each message a client may send to its payload.", m.client, m)
+	write_registry(&b, "Server_Payloads", "Server_Payloads maps each message the server sends to its payload.", m.server, m)
+
+	envelope := m.names[m.envelope.Obj()]
+	b.WriteString("\nexport type Client_Message = {\n")
+	b.WriteString("  [K in keyof Client_Payloads]: { type: K; request_id?: string; payload: Client_Payloads[K] }\n")
+	b.WriteString("}[keyof Client_Payloads]\n")
+	b.WriteString("\nexport type Server_Message = {\n")
+	fmt.Fprintf(&b, "  [K in keyof Server_Payloads]: Omit<%s, 'type' | 'payload'> & { type: K; payload: Server_Payloads[K] }\n", envelope)
+	b.WriteString("}[keyof Server_Payloads]\n")
+	return b.Bytes()
+}
+
+func write_registry(b *bytes.Buffer, name string, doc string, messages []message, m *model) {
+	b.WriteString("\n")
+	write_doc(b, doc)
+	fmt.Fprintf(b, "export interface %s {\n", name)
+	for _, msg := range messages {
+		fmt.Fprintf(b, "  %s: %s\n", msg.name, m.names[msg.payload.Obj()])
+	}
+	b.WriteString("}\n")
+}
+
+func write_doc(b *bytes.Buffer, doc string) {
+	if doc == "" {
+		return
+	}
+	for _, line := range strings.Split(doc, "\n") {
+		fmt.Fprintf(b, "// %s\n", line)
+	}
+}
+
+func ts_literal(v any) string {
+	if s, ok := v.(string); ok {
+		return "'" + strings.ReplaceAll(s, "'", `\'`) + "'"
+	}
+	return fmt.Sprint(v)
+}
+
+func (m *model) ts_field(f json_field) string {
+	t := f.field.Type()
+	expr := m.ts_type(t)
+	if f.as_string {
+		expr = "string"
+	}
+	if f.omitempty {
+		return fmt.Sprintf("%s?: %s", ts_key(f.name), expr)
+	}
+	if nullable(t) && expr != "unknown" {
+		expr += " | null"
+	}
+	return fmt.Sprintf("%s: %s", ts_key(f.name), expr)
+}
+
+func ts_key(name string) string {
+	for i, r := range name {
+		if !(r == '_' || r == '$' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || i > 0 && '0' <= r && r <= '9') {
+			return strconv.Quote(name)
+		}
+	}
+	return name
+}
+
+// ts_type is the TypeScript for a value of t, leaving out the null a nil
+// pointer, slice or map encodes as; callers add it where it can occur.
+func (m *model) ts_type(t types.Type) string {
+	t = types.Unalias(t)
+	if n, ok := t.(*types.Named); ok {
+		if kind, ok := special(n); ok {
+			if kind == "time" {
+				return "string"
+			}
+			return "unknown"
+		}
+		if name, ok := m.names[n.Obj()]; ok {
+			return name
+		}
+		return m.ts_type(n.Underlying())
+	}
+
+	switch t := t.(type) {
+	case *types.Basic:
+		switch {
+		case t.Info()&types.IsString != 0:
+			return "string"
+		case t.Info()&types.IsBoolean != 0:
+			return "boolean"
+		case t.Info()&types.IsNumeric != 0:
+			return "number"
+		}
+	case *types.Pointer:
+		return m.ts_type(t.Elem())
+	case *types.Slice:
+		if is_bytes(t) {
+			return "string"
+		}
+		return m.ts_elem(t.Elem()) + "[]"
+	case *types.Array:
+		if t.Len() > 8 {
+			return m.ts_elem(t.Elem()) + "[]"
+		}
+		elem := m.ts_type(t.Elem())
+		if nullable(t.Elem()) {
+			elem += " | null"
+		}
+		items := make([]string, t.Len())
+		for i := range items {
+			items[i] = elem
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case *types.Map:
+		return "Record<string, " + m.ts_type(t.Elem()) + ">"
+	case *types.Struct:
+		var parts []string
+		for _, f := range json_fields(t) {
+			parts = append(parts, m.ts_field(f))
+		}
+		return "{ " + strings.Join(parts, "; ") + " }"
+	}
+	return "unknown"
+}
+
+func (m *model) ts_elem(t types.Type) string {
+	expr := m.ts_type(t)
+	if nullable(t) {
+		return "(" + expr + " | null)"
+	}
+	if strings.Contains(expr, " ") && !strings.HasPrefix(expr, "{") && !strings.HasPrefix(expr, "[") {
+		return "(" + expr + ")"
+	}
+	return expr
+}
@@ -0,0 +1,100 @@
+//go:build js && wasm
+
+// Command wasm exposes the game package to the browser as a global `guandan`
+// object, so the client checks plays with the same code the server runs.
+// Cards are passed as the protocol's {Suit, Rank, Id} objects, an empty lead
+// means leading, and level is a Rank.
+//
+//	guandan.detect(cards, level)                  -> {type, card_ids, rank_value, bomb_power}
+//	guandan.can_beat(cards, lead, level)          -> boolean
+//	guandan.legal_plays(hand, lead, level)        -> [{type, card_ids, rank_value, bomb_power}]
+//	guandan.complete(hand, selected, lead, level) -> [{type, card_ids, rank_value, bomb_power, add_ids}]
+package main
+
+import (
+	"syscall/js"
+
+	"guandanbtw/game"
+)
+
+func main() {
+	js.Global().Set("guandan", js.ValueOf(map[string]any{
+		"detect":      js.FuncOf(detect),
+		"can_beat":    js.FuncOf(can_beat),
+		"legal_plays": js.FuncOf(legal_plays),
+		"complete":    js.FuncOf(complete),
+	}))
+	select {}
+}
+
+func detect(this js.Value, args []js.Value) any {
+	level := game.Rank(args[1].Int())
+	return combo_value(game.Detect_Combination(cards_arg(args[0]), level))
+}
+
+func can_beat(this js.Value, args []js.Value) any {
+	level := game.Rank(args[2].Int())
+	play := game.Detect_Combination(cards_arg(args[0]), level)
+	lead := game.Detect_Combination(cards_arg(args[1]), level)
+	if lead.Type == game.Comb_Invalid {
+		return play.Type != game.Comb_Invalid
+	}
+	return game.Can_Beat(play, lead)
+}
+
+func legal_plays(this js.Value, args []js.Value) any {
+	level := game.Rank(args[2].Int())
+	lead := game.Detect_Combination(cards_arg(args[1]), level)
+	plays := game.Legal_Plays(cards_arg(args[0]), lead, level)
+	out := make([]any, len(plays))
+	for i, p := range plays {
+		out[i] = combo_value(p)
+	}
+	return out
+}
+
+func complete(this js.Value, args []js.Value) any {
+	level := game.Rank(args[3].Int())
+	lead := game.Detect_Combination(cards_arg(args[2]), level)
+	completions := game.Complete_Selection(cards_arg(args[0]), cards_arg(args[1]), lead, level)
+	out := make([]any, len(completions))
+	for i, c := range completions {
+		v := combo_value(c.Play)
+		add := make([]any, len(c.Add))
+		for j, card := range c.Add {
+			add[j] = card.Id
+		}
+		v["add_ids"] = add
+		out[i] = v
+	}
+	return out
+}
+
+func cards_arg(v js.Value) []game.Card {
+	if v.IsUndefined() || v.IsNull() {
+		return nil
+	}
+	cards := make([]game.Card, v.Length())
+	for i := range cards {
+		c := v.Index(i)
+		cards[i] = game.Card{
+			Suit: game.Suit(c.Get("Suit").Int()),
+			Rank: game.Rank(c.Get("Rank").Int()),
+			Id:   c.Get("Id").Int(),
+		}
+	}
+	return cards
+}
+
+func combo_value(c game.Combination) map[string]any {
+	ids := make([]any, len(c.Cards))
+	for i, card := range c.Cards {
+		ids[i] = card.Id
+	}
+	return map[string]any{
+		"type":       c.Type.String(),
+		"card_ids":   ids,
+		"rank_value": c.Rank_Value,
+		"bomb_power": c.Bomb_Power,
+	}
+}
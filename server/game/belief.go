@@ -0,0 +1,267 @@
+package game
+
+import (
+	mrand "math/rand/v2"
+	"sort"
+)
+
+// How much a pass against an opponent's lead lowers the odds that the seat
+// holds each card that would have beaten it. Players keep pairs and triples
+// together more readily than they keep a single back, so a pass on a single
+// says the most.
+var pass_factor = map[Combination_Type]float64{
+	Comb_Single: 0.35,
+	Comb_Pair:   0.6,
+	Comb_Triple: 0.8,
+}
+
+// Belief is what one seat can infer about the other hands from the public
+// events of a hand: for every card it has not seen, a weight per seat for
+// how likely that seat holds it. Weights start even and are lowered by
+// passes; a zero weight rules the seat out. Hand is the seat's own cards.
+type Belief struct {
+	Seat   int
+	Level  Rank
+	Hand   []Card
+	Counts [4]int
+	Unseen []Card
+
+	weight [4]map[int]float64
+}
+
+// New_Belief starts from seat's own hand after the deal, with every other
+// card equally likely to be in any other hand.
+func New_Belief(seat int, hand []Card, level Rank) *Belief {
+	b := &Belief{Seat: seat, Level: level, Hand: append([]Card(nil), hand...)}
+	mine := make(map[int]bool, len(hand))
+	for _, c := range hand {
+		mine[c.Id] = true
+	}
+	for _, c := range New_Deck().Cards {
+		if !mine[c.Id] {
+			b.Unseen = append(b.Unseen, c)
+		}
+	}
+	for s := 0; s < 4; s++ {
+		b.Counts[s] = len(b.Unseen) / 3
+		b.weight[s] = make(map[int]float64, len(b.Unseen))
+		if s == seat {
+			continue
+		}
+		for _, c := range b.Unseen {
+			b.weight[s][c.Id] = 1
+		}
+	}
+	b.Counts[seat] = len(hand)
+	return b
+}
+
+// Clone copies b so the copy can be read on another goroutine while b keeps
+// taking observations.
+func (b *Belief) Clone() *Belief {
+	c := *b
+	c.Hand = append([]Card(nil), b.Hand...)
+	c.Unseen = append([]Card(nil), b.Unseen...)
+	for s := range c.weight {
+		c.weight[s] = make(map[int]float64, len(b.weight[s]))
+		for id, w := range b.weight[s] {
+			c.weight[s][id] = w
+		}
+	}
+	return &c
+}
+
+// Observe_Tribute moves a tribute card, which every seat sees. The giver had
+// nothing higher apart from wild cards.
+func (b *Belief) Observe_Tribute(from, to int, card Card) {
+	b.Counts[from]--
+	b.Counts[to]++
+
+	if from != b.Seat {
+		for _, c := range b.Unseen {
+			if Card_Value(c, b.Level) > Card_Value(card, b.Level) && !Is_Wild(c, b.Level) {
+				b.weight[from][c.Id] = 0
+			}
+		}
+	}
+
+	if to == b.Seat {
+		b.Hand = append(b.Hand, card)
+		b.remove(card.Id)
+		return
+	}
+	if from == b.Seat {
+		b.Hand = hand_without(b.Hand, []Card{card})
+		b.Unseen = append(b.Unseen, card)
+	}
+	for s := 0; s < 4; s++ {
+		if s != b.Seat {
+			b.weight[s][card.Id] = 0
+		}
+	}
+	b.weight[to][card.Id] = 1
+}
+
+// Observe_Play takes played cards out of the unseen pool.
+func (b *Belief) Observe_Play(seat int, cards []Card) {
+	b.Counts[seat] -= len(cards)
+	if seat == b.Seat {
+		b.Hand = hand_without(b.Hand, cards)
+		return
+	}
+	for _, c := range cards {
+		b.remove(c.Id)
+	}
+}
+
+// Observe_Pass lowers the odds of seat holding cards that beat lead. Passing
+// on a partner's lead says nothing, and only singles, pairs and triples are
+// read.
+func (b *Belief) Observe_Pass(seat int, lead Combination, lead_seat int) {
+	factor, ok := pass_factor[lead.Type]
+	if seat == b.Seat || lead_seat%2 == seat%2 || !ok {
+		return
+	}
+	for _, c := range b.Unseen {
+		if Card_Value(c, b.Level) > lead.Rank_Value {
+			b.weight[seat][c.Id] *= factor
+		}
+	}
+}
+
+func (b *Belief) remove(id int) {
+	for i, c := range b.Unseen {
+		if c.Id == id {
+			b.Unseen = append(b.Unseen[:i:i], b.Unseen[i+1:]...)
+			break
+		}
+	}
+	for s := range b.weight {
+		delete(b.weight[s], id)
+	}
+}
+
+// Probabilities gives, for every other seat, the chance that it holds each
+// unseen card. Weights are scaled until each card is in exactly one hand
+// and each hand holds its known number of cards.
+func (b *Belief) Probabilities() [4]map[int]float64 {
+	var p [4]map[int]float64
+	for s := range p {
+		p[s] = make(map[int]float64, len(b.Unseen))
+		for _, c := range b.Unseen {
+			p[s][c.Id] = b.weight[s][c.Id]
+		}
+	}
+
+	for iter := 0; iter < 50; iter++ {
+		for _, c := range b.Unseen {
+			total := 0.0
+			for s := range p {
+				total += p[s][c.Id]
+			}
+			if total > 0 {
+				for s := range p {
+					p[s][c.Id] /= total
+				}
+			}
+		}
+		for s := range p {
+			if s == b.Seat {
+				continue
+			}
+			total := 0.0
+			for _, v := range p[s] {
+				total += v
+			}
+			if total > 0 {
+				for id := range p[s] {
+					p[s][id] *= float64(b.Counts[s]) / total
+				}
+			}
+		}
+	}
+	return p
+}
+
+// Expected_Ranks is how many cards of each rank every other seat is expected
+// to hold.
+func (b *Belief) Expected_Ranks() [4]map[Rank]float64 {
+	p := b.Probabilities()
+	var out [4]map[Rank]float64
+	for s := range out {
+		out[s] = make(map[Rank]float64)
+		if s == b.Seat {
+			continue
+		}
+		for _, c := range b.Unseen {
+			if v := p[s][c.Id]; v > 0 {
+				out[s][c.Rank] += v
+			}
+		}
+	}
+	return out
+}
+
+// Sample deals the unseen cards to the other seats in their known numbers,
+// each card drawn in proportion to its weights. Cards with the fewest
+// possible holders go first; when the weights leave no room a card goes to
+// any seat with room.
+func (b *Belief) Sample(rng *mrand.Rand) [4][]Card {
+	var hands [4][]Card
+	hands[b.Seat] = append([]Card(nil), b.Hand...)
+
+	cards := append([]Card(nil), b.Unseen...)
+	rng.Shuffle(len(cards), func(i, j int) { cards[i], cards[j] = cards[j], cards[i] })
+	holders := make(map[int]int, len(cards))
+	for _, c := range cards {
+		for s := range b.weight {
+			if b.weight[s][c.Id] > 0 {
+				holders[c.Id]++
+			}
+		}
+	}
+	sort.SliceStable(cards, func(i, j int) bool { return holders[cards[i].Id] < holders[cards[j].Id] })
+
+	var room [4]int
+	for s := range room {
+		if s != b.Seat {
+			room[s] = b.Counts[s]
+		}
+	}
+
+	for _, c := range cards {
+		var odds [4]float64
+		total := 0.0
+		for s := range odds {
+			if room[s] > 0 {
+				odds[s] = b.weight[s][c.Id] * float64(room[s])
+				total += odds[s]
+			}
+		}
+		if total == 0 {
+			for s := range odds {
+				odds[s] = float64(room[s])
+				total += odds[s]
+			}
+		}
+		if total == 0 {
+			break
+		}
+
+		pick := rng.Float64() * total
+		seat := 0
+		for s := range odds {
+			if odds[s] == 0 {
+				continue
+			}
+			seat = s
+			if pick < odds[s] {
+				break
+			}
+			pick -= odds[s]
+		}
+		hands[seat] = append(hands[seat], c)
+		room[seat]--
+	}
+	return hands
+}
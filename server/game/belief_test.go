@@ -0,0 +1,157 @@
+package game
+
+import (
+	mrand "math/rand/v2"
+	"testing"
+)
+
+func test_rng(seed uint64) *mrand.Rand {
+	return mrand.New(mrand.NewPCG(seed, seed))
+}
+
+// check_sample asserts that a sampled deal keeps the seat's own hand, gives
+// every other seat its known number of cards and deals each unseen card once.
+func check_sample(t *testing.T, b *Belief, hands [4][]Card) {
+	t.Helper()
+
+	if len(hands[b.Seat]) != len(b.Hand) {
+		t.Fatalf("own hand has %d cards, want %d", len(hands[b.Seat]), len(b.Hand))
+	}
+	for i, c := range b.Hand {
+		if hands[b.Seat][i] != c {
+			t.Fatal("own hand changed")
+		}
+	}
+
+	unseen := make(map[int]bool, len(b.Unseen))
+	for _, c := range b.Unseen {
+		unseen[c.Id] = true
+	}
+	for s, hand := range hands {
+		if s == b.Seat {
+			continue
+		}
+		if len(hand) != b.Counts[s] {
+			t.Fatalf("seat %d got %d cards, want %d", s, len(hand), b.Counts[s])
+		}
+		for _, c := range hand {
+			if !unseen[c.Id] {
+				t.Fatalf("seat %d got %v, which is not unseen or was dealt twice", s, c)
+			}
+			delete(unseen, c.Id)
+		}
+	}
+	if len(unseen) != 0 {
+		t.Fatalf("%d unseen cards were not dealt", len(unseen))
+	}
+}
+
+func TestBeliefSampleKeepsHandSizes(t *testing.T) {
+	hands := Deal_From_Seed(11)
+	tests := []struct {
+		name    string
+		observe func(b *Belief)
+	}{
+		{"after the deal", func(b *Belief) {}},
+		{"after plays", func(b *Belief) {
+			b.Observe_Play(1, hands[1][:3])
+			b.Observe_Play(2, hands[2][:1])
+			b.Observe_Play(0, b.Hand[:2])
+		}},
+		{"after a tribute to the seat", func(b *Belief) {
+			b.Observe_Tribute(3, 0, hands[3][0])
+		}},
+		{"after a tribute between others", func(b *Belief) {
+			b.Observe_Tribute(1, 2, hands[1][0])
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := New_Belief(0, hands[0], Rank_Two)
+			tt.observe(b)
+			rng := test_rng(1)
+			for range 20 {
+				check_sample(t, b, b.Sample(rng))
+			}
+		})
+	}
+}
+
+func TestBeliefSampleRespectsZeroWeights(t *testing.T) {
+	hands := Deal_From_Seed(5)
+	level := Rank_Seven
+	b := New_Belief(0, hands[0], level)
+
+	// A tribute shows the giver had nothing higher than it, wild cards aside.
+	ace := Card{Suit: Suit_Spades, Rank: Rank_Ace, Id: -1}
+	for _, c := range b.Unseen {
+		if c.Rank == Rank_Ace {
+			ace = c
+			break
+		}
+	}
+	b.Observe_Tribute(1, 2, ace)
+
+	rng := test_rng(2)
+	for range 50 {
+		dealt := b.Sample(rng)
+		check_sample(t, b, dealt)
+		for _, c := range dealt[1] {
+			if Card_Value(c, level) > Card_Value(ace, level) && !Is_Wild(c, level) {
+				t.Fatalf("seat 1 was dealt %v after tributing an ace", c)
+			}
+		}
+		found := false
+		for _, c := range dealt[2] {
+			found = found || c.Id == ace.Id
+		}
+		if !found {
+			t.Fatal("the tributed card left the seat that received it")
+		}
+	}
+}
+
+func TestBeliefSampleFollowsPasses(t *testing.T) {
+	hands := Deal_From_Seed(8)
+	b := New_Belief(0, hands[0], Rank_Two)
+	lead := Combination{Type: Comb_Single, Rank_Value: rank_value(Rank_Three, Rank_Two)}
+	for range 6 {
+		b.Observe_Pass(1, lead, 0)
+	}
+
+	rng := test_rng(3)
+	high := [4]int{}
+	for range 200 {
+		dealt := b.Sample(rng)
+		for s, hand := range dealt {
+			for _, c := range hand {
+				if Card_Value(c, Rank_Two) > lead.Rank_Value {
+					high[s]++
+				}
+			}
+		}
+	}
+	if high[1] >= high[3] {
+		t.Fatalf("seat 1 passed a three six times but got %d high cards to seat 3's %d", high[1], high[3])
+	}
+}
+
+func TestBeliefClone(t *testing.T) {
+	hands := Deal_From_Seed(9)
+	b := New_Belief(2, hands[2], Rank_Two)
+	c := b.Clone()
+
+	b.Observe_Play(0, hands[0][:2])
+	b.Observe_Pass(1, Combination{Type: Comb_Single}, 0)
+	b.Observe_Play(2, hands[2][:1])
+
+	if len(c.Unseen) != 81 || len(c.Hand) != 27 || c.Counts[0] != 27 {
+		t.Fatalf("clone changed with the original: %d unseen, %d in hand", len(c.Unseen), len(c.Hand))
+	}
+	for _, card := range c.Unseen {
+		if c.weight[1][card.Id] != 1 {
+			t.Fatal("a pass on the original changed the clone's weights")
+		}
+	}
+}
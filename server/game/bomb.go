@@ -1,5 +1,21 @@
 package game
 
+import "fmt"
+
+// Bomb_Kind names a bomb from its cards, e.g. "four_jokers",
+// "straight_flush" or "5_of_a_kind", checking shapes in the order
+// detect_bomb does.
+func Bomb_Kind(cards []Card, level Rank) string {
+	switch {
+	case is_four_joker_bomb(cards):
+		return "four_jokers"
+	case is_straight_flush(cards, level):
+		return "straight_flush"
+	default:
+		return fmt.Sprintf("%d_of_a_kind", len(cards))
+	}
+}
+
 func detect_bomb(cards []Card, level Rank) Combination {
 	n := len(cards)
 	if n == 4 && is_four_joker_bomb(cards) {
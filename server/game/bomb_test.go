@@ -0,0 +1,30 @@
+package game
+
+import "testing"
+
+func TestBombKind(t *testing.T) {
+	tests := []struct {
+		cards string
+		level Rank
+		want  string
+	}{
+		{"bj bj rj rj", Rank_Two, "four_jokers"},
+		{"3s 4s 5s 6s 7s", Rank_Two, "straight_flush"},
+		{"3s 4s 5s 6s 7s 8s", Rank_Two, "straight_flush"},
+		{"3s 4s 2h 6s 7s 8s", Rank_Two, "straight_flush"},
+		{"9s 9h 9d 9c", Rank_Two, "4_of_a_kind"},
+		{"9s 9h 9d 9c 9s", Rank_Two, "5_of_a_kind"},
+		{"9s 9h 9d 9c 9s 9h 9d 9c 2h", Rank_Two, "9_of_a_kind"},
+		{"9s 9h 9d 9c 9s 9h 9d 9c 2h 2h", Rank_Two, "10_of_a_kind"},
+	}
+
+	for _, tt := range tests {
+		cards := parse_cards(t, tt.cards)
+		if combo := Detect_Combination(cards, tt.level); combo.Type != Comb_Bomb {
+			t.Fatalf("%s is not a bomb", tt.cards)
+		}
+		if got := Bomb_Kind(cards, tt.level); got != tt.want {
+			t.Errorf("Bomb_Kind(%s) = %s, want %s", tt.cards, got, tt.want)
+		}
+	}
+}
@@ -67,6 +67,20 @@ func (d *Deck) Shuffle() {
 	}
 }
 
+func (d *Deck) Shuffle_Seeded(seed uint64) {
+	rng := seeded_rand(seed)
+	for i := len(d.Cards) - 1; i > 0; i-- {
+		j := rng.IntN(i + 1)
+		d.Cards[i], d.Cards[j] = d.Cards[j], d.Cards[i]
+	}
+}
+
+func Deal_From_Seed(seed uint64) [4][]Card {
+	deck := New_Deck()
+	deck.Shuffle_Seeded(seed)
+	return deck.Deal()
+}
+
 func (d *Deck) Deal() [4][]Card {
 	var hands [4][]Card
 	for i := 0; i < 4; i++ {
@@ -14,6 +14,25 @@ const (
 	Comb_Bomb
 )
 
+var combination_type_names = map[Combination_Type]string{
+	Comb_Invalid:    "invalid",
+	Comb_Single:     "single",
+	Comb_Pair:       "pair",
+	Comb_Triple:     "triple",
+	Comb_Full_House: "full_house",
+	Comb_Straight:   "straight",
+	Comb_Tube:       "tube",
+	Comb_Plate:      "plate",
+	Comb_Bomb:       "bomb",
+}
+
+func (t Combination_Type) String() string {
+	if name, ok := combination_type_names[t]; ok {
+		return name
+	}
+	return "invalid"
+}
+
 type Combination struct {
 	Type       Combination_Type
 	Cards      []Card
@@ -0,0 +1,104 @@
+package game
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// Completion is a play that contains every selected card. Add lists the
+// cards from the rest of the hand it needs, empty when the selection is a
+// play on its own.
+type Completion struct {
+	Play Combination
+	Add  []Card
+}
+
+// Complete_Selection lists the plays in hand that contain selected and beat
+// lead (pass a Comb_Invalid lead when leading), each card set once. Plays
+// needing the fewest added cards come first.
+func Complete_Selection(hand []Card, selected []Card, lead Combination, level Rank) []Completion {
+	idx := index_hand(hand, selected, level)
+	if len(idx.keep) != len(selected) {
+		return nil
+	}
+
+	var out []Completion
+	seen := make(map[string]bool)
+	idx.candidates(func(cards []Card) {
+		cards = idx.use_kept_wilds(cards)
+		if cards == nil || !idx.holds_kept(cards) {
+			return
+		}
+		combo := Detect_Combination(cards, level)
+		if combo.Type == Comb_Invalid {
+			return
+		}
+		if lead.Type != Comb_Invalid && !Can_Beat(combo, lead) {
+			return
+		}
+
+		ids := make([]int, len(cards))
+		for i, c := range cards {
+			ids[i] = c.Id
+		}
+		slices.Sort(ids)
+		key := fmt.Sprint(ids)
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+
+		var add []Card
+		for _, c := range cards {
+			if !idx.keep[c.Id] {
+				add = append(add, c)
+			}
+		}
+		out = append(out, Completion{Play: combo, Add: add})
+	})
+
+	sort.SliceStable(out, func(i, j int) bool {
+		if len(out[i].Add) != len(out[j].Add) {
+			return len(out[i].Add) < len(out[j].Add)
+		}
+		return play_less(out[i].Play, out[j].Play)
+	})
+	return out
+}
+
+// use_kept_wilds puts kept wilds the pick left out in place of natural cards
+// that were not kept, since a wild can stand in for any of them.
+func (idx hand_index) use_kept_wilds(cards []Card) []Card {
+	if cards == nil {
+		return nil
+	}
+	in := make(map[int]bool, len(cards))
+	for _, c := range cards {
+		in[c.Id] = true
+	}
+	out := slices.Clone(cards)
+	for _, w := range idx.wilds {
+		if !idx.keep[w.Id] || in[w.Id] {
+			continue
+		}
+		i := slices.IndexFunc(out, func(c Card) bool {
+			return !idx.keep[c.Id] && !is_joker(c.Rank) && !idx.is_wild_card(c)
+		})
+		if i == -1 {
+			return nil
+		}
+		out[i] = w
+	}
+	return out
+}
+
+func (idx hand_index) holds_kept(cards []Card) bool {
+	n := 0
+	for _, c := range cards {
+		if idx.keep[c.Id] {
+			n++
+		}
+	}
+	return n == len(idx.keep)
+}
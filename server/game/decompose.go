@@ -0,0 +1,360 @@
+package game
+
+// Decomposition splits a hand into plays that together use every card.
+// Turns counts the plays that are not bombs: the leads the hand needs to go
+// out if nothing it plays is beaten. Strength grows with bombs and with
+// plays that are hard to beat and shrinks with every turn needed.
+type Decomposition struct {
+	Plays    []Combination
+	Turns    int
+	Bombs    int
+	Strength int
+}
+
+const (
+	turn_cost = 10
+	bomb_cost = -3
+)
+
+// Decompose finds the split of hand into the fewest plays, counting a bomb
+// as winning a turn back, with wild cards placed wherever they save most.
+// Straight flushes are tried first since they need particular suits; every
+// other shape only depends on how many cards of each rank are left.
+func Decompose(hand []Card, level Rank) Decomposition {
+	return New_Hand_Scorer(level).Decompose(hand)
+}
+
+// Hand_Scorer decomposes many hands at one level and keeps the solved rank
+// counts between them, so rating every legal play of a hand solves the
+// shared remainders once. It is not safe for concurrent use.
+type Hand_Scorer struct {
+	d decomposer
+}
+
+func New_Hand_Scorer(level Rank) *Hand_Scorer {
+	return &Hand_Scorer{d: decomposer{level: level, memo: make(map[uint64]decompose_step)}}
+}
+
+func (s *Hand_Scorer) Decompose(hand []Card) Decomposition {
+	d := &s.d
+	level := d.level
+
+	var naturals []Card
+	wilds := 0
+	for _, c := range hand {
+		if Is_Wild(c, level) {
+			wilds++
+		} else {
+			naturals = append(naturals, c)
+		}
+	}
+
+	flushes := straight_flush_options(hand, level)
+	best_cost, best := 0, -1
+	for i, option := range flushes {
+		counts, left, ok := count_without(naturals, wilds, option, level)
+		if !ok {
+			continue
+		}
+		cost := bomb_cost*len(option) + d.solve(counts, left)
+		if best == -1 || cost < best_cost {
+			best_cost, best = cost, i
+		}
+	}
+
+	return d.build(hand, flushes[best])
+}
+
+// Hand_Strength is the Strength of the hand's best decomposition.
+func Hand_Strength(hand []Card, level Rank) int {
+	return Decompose(hand, level).Strength
+}
+
+type rank_counts [Rank_Red_Joker + 1]int8
+
+// decompose_shape is one play by how many cards of each rank it takes, plus
+// wilds standing in for missing ones.
+type decompose_shape struct {
+	kind  Combination_Type
+	take  rank_counts
+	wilds int8
+}
+
+type decompose_step struct {
+	cost  int
+	shape decompose_shape
+}
+
+type decomposer struct {
+	level Rank
+	memo  map[uint64]decompose_step
+}
+
+func decompose_key(counts rank_counts, wilds int8) uint64 {
+	key := uint64(wilds) << 60
+	for i, c := range counts {
+		key |= uint64(c) << (4 * i)
+	}
+	return key
+}
+
+// solve returns the lowest cost of splitting counts and wilds into plays.
+// Every split puts the lowest remaining card in some play, so only plays
+// holding that card are tried.
+func (d *decomposer) solve(counts rank_counts, wilds int8) int {
+	key := decompose_key(counts, wilds)
+	if step, ok := d.memo[key]; ok {
+		return step.cost
+	}
+
+	low := Rank(-1)
+	for r := Rank_Two; r <= Rank_Red_Joker; r++ {
+		if counts[r] > 0 {
+			low = r
+			break
+		}
+	}
+
+	if low == -1 {
+		step := decompose_step{}
+		if wilds > 0 {
+			step.cost = turn_cost
+			step.shape = decompose_shape{kind: Comb_Single + Combination_Type(wilds-1), wilds: wilds}
+		}
+		d.memo[key] = step
+		return step.cost
+	}
+
+	best := decompose_step{cost: 1 << 30}
+	d.shapes(counts, wilds, low, func(s decompose_shape) {
+		next := counts
+		for r, n := range s.take {
+			next[r] -= n
+		}
+		cost := turn_cost
+		if s.kind == Comb_Bomb {
+			cost = bomb_cost
+		}
+		cost += d.solve(next, wilds-s.wilds)
+		if cost < best.cost {
+			best = decompose_step{cost: cost, shape: s}
+		}
+	})
+	d.memo[key] = best
+	return best.cost
+}
+
+// shapes lists the plays that hold a card of rank low. Wilds only fill in
+// for ranks that have run out, never next to natural cards left unused.
+func (d *decomposer) shapes(counts rank_counts, wilds int8, low Rank, emit func(decompose_shape)) {
+	if is_joker(low) {
+		if low == Rank_Black_Joker && counts[Rank_Black_Joker] == 2 && counts[Rank_Red_Joker] == 2 {
+			s := decompose_shape{kind: Comb_Bomb}
+			s.take[Rank_Black_Joker], s.take[Rank_Red_Joker] = 2, 2
+			emit(s)
+		}
+		for n := int8(1); n <= counts[low]; n++ {
+			s := decompose_shape{kind: Comb_Single + Combination_Type(n-1)}
+			s.take[low] = n
+			emit(s)
+		}
+		return
+	}
+
+	c := counts[low]
+	for nat := int8(1); nat <= c; nat++ {
+		for w := int8(0); w <= wilds; w++ {
+			if w > 0 && nat < c {
+				break
+			}
+			n := nat + w
+			s := decompose_shape{take: rank_counts{}, wilds: w}
+			s.take[low] = nat
+			switch {
+			case n <= 3:
+				s.kind = Comb_Single + Combination_Type(n-1)
+			case n <= 10:
+				s.kind = Comb_Bomb
+			default:
+				continue
+			}
+			emit(s)
+		}
+	}
+
+	for other := Rank_Two; other <= Rank_Red_Joker; other++ {
+		if other == low || counts[other] == 0 {
+			continue
+		}
+		if s, ok := take_groups(counts, wilds, []Rank{low, other}, []int8{3, 2}); ok {
+			s.kind = Comb_Full_House
+			emit(s)
+		}
+		if !is_joker(other) {
+			if s, ok := take_groups(counts, wilds, []Rank{other, low}, []int8{3, 2}); ok {
+				s.kind = Comb_Full_House
+				emit(s)
+			}
+		}
+	}
+
+	for _, run := range []struct {
+		kind           Combination_Type
+		length, copies int8
+	}{{Comb_Straight, 5, 1}, {Comb_Tube, 3, 2}, {Comb_Plate, 2, 3}} {
+		for start := 0; start+int(run.length) <= len(ace_low_order); start++ {
+			window := ace_low_order[start : start+int(run.length)]
+			holds := false
+			for _, r := range window {
+				holds = holds || r == low
+			}
+			if !holds {
+				continue
+			}
+			copies := make([]int8, len(window))
+			for i := range copies {
+				copies[i] = run.copies
+			}
+			if s, ok := take_groups(counts, wilds, window, copies); ok {
+				s.kind = run.kind
+				emit(s)
+			}
+		}
+	}
+}
+
+// take_groups takes copies[i] cards of ranks[i], natural cards first and
+// wilds for the rest. Jokers cannot be stood in for, and low must add at
+// least one natural card.
+func take_groups(counts rank_counts, wilds int8, ranks []Rank, copies []int8) (decompose_shape, bool) {
+	var s decompose_shape
+	for i, r := range ranks {
+		nat := min(counts[r], copies[i])
+		if nat == 0 && (is_joker(r) || i == 0) {
+			return s, false
+		}
+		if is_joker(r) && nat < copies[i] {
+			return s, false
+		}
+		s.take[r] = nat
+		s.wilds += copies[i] - nat
+	}
+	return s, s.wilds <= wilds
+}
+
+// straight_flush_options lists the ways to set straight flushes aside: none,
+// one, or two that share no cards.
+func straight_flush_options(hand []Card, level Rank) [][][]Card {
+	idx := index_hand(hand, nil, level)
+	var flushes [][]Card
+	for suit := Suit_Hearts; suit <= Suit_Spades; suit++ {
+		for start := 0; start+5 <= len(ace_low_order); start++ {
+			cards := idx.pick(ace_low_order[start:start+5], []int{1, 1, 1, 1, 1}, suit)
+			if cards != nil && is_straight_flush(cards, level) {
+				flushes = append(flushes, cards)
+			}
+		}
+	}
+
+	options := [][][]Card{nil}
+	for i, a := range flushes {
+		options = append(options, [][]Card{a})
+		for _, b := range flushes[i+1:] {
+			options = append(options, [][]Card{a, b})
+		}
+	}
+	return options
+}
+
+// count_without counts the natural cards and wilds left once the flushes
+// are set aside, or reports that the flushes overlap.
+func count_without(naturals []Card, wilds int, flushes [][]Card, level Rank) (rank_counts, int8, bool) {
+	used := make(map[int]bool)
+	for _, f := range flushes {
+		for _, c := range f {
+			if used[c.Id] {
+				return rank_counts{}, 0, false
+			}
+			used[c.Id] = true
+			if Is_Wild(c, level) {
+				wilds--
+			}
+		}
+	}
+	if wilds < 0 {
+		return rank_counts{}, 0, false
+	}
+
+	var counts rank_counts
+	for _, c := range naturals {
+		if !used[c.Id] {
+			counts[c.Rank]++
+		}
+	}
+	return counts, int8(wilds), true
+}
+
+// build turns the solved shapes back into plays of actual cards.
+func (d *decomposer) build(hand []Card, flushes [][]Card) Decomposition {
+	used := make(map[int]bool)
+	var out Decomposition
+	add := func(cards []Card) {
+		combo := Detect_Combination(cards, d.level)
+		out.Plays = append(out.Plays, combo)
+		if combo.Type == Comb_Bomb {
+			out.Bombs++
+			return
+		}
+		out.Turns++
+		if combo.Rank_Value >= rank_value(Rank_Ace, d.level) {
+			out.Strength += 5
+		}
+	}
+
+	for _, f := range flushes {
+		for _, c := range f {
+			used[c.Id] = true
+		}
+		add(f)
+	}
+
+	var rest []Card
+	for _, c := range hand {
+		if !used[c.Id] {
+			rest = append(rest, c)
+		}
+	}
+	pool := index_hand(rest, nil, d.level)
+
+	var counts rank_counts
+	for r, cards := range pool.natural {
+		counts[r] = int8(len(cards))
+	}
+	wilds := int8(len(pool.wilds))
+
+	for counts != (rank_counts{}) || wilds > 0 {
+		step := d.memo[decompose_key(counts, wilds)]
+		var cards []Card
+		for r, n := range step.shape.take {
+			cards = append(cards, pool.natural[Rank(r)][:n]...)
+			pool.natural[Rank(r)] = pool.natural[Rank(r)][n:]
+			counts[r] -= n
+		}
+		cards = append(cards, pool.wilds[:step.shape.wilds]...)
+		pool.wilds = pool.wilds[step.shape.wilds:]
+		wilds -= step.shape.wilds
+		add(cards)
+	}
+
+	out.Strength += 10*out.Bombs - 10*out.Turns
+	return out
+}
+
+// Strength_After is the Hand_Strength of hand once play has left it.
+func Strength_After(hand []Card, play Combination, level Rank) int {
+	return New_Hand_Scorer(level).Strength_After(hand, play)
+}
+
+func (s *Hand_Scorer) Strength_After(hand []Card, play Combination) int {
+	return s.Decompose(hand_without(hand, play.Cards)).Strength
+}
@@ -0,0 +1,137 @@
+package game
+
+import (
+	"testing"
+)
+
+func TestDecompose(t *testing.T) {
+	tests := []struct {
+		name  string
+		hand  string
+		level Rank
+		turns int
+		bombs int
+	}{
+		{"empty", "", Rank_Two, 0, 0},
+		{"single", "3h", Rank_Two, 1, 0},
+		{"pair and single", "3h 3s 9d", Rank_Two, 2, 0},
+		{"four of a kind is a bomb", "7h 7s 7d 7c", Rank_Two, 0, 1},
+		{"joker bomb", "bj bj rj rj", Rank_Two, 0, 1},
+		{"straight", "3h 4s 5d 6c 7h", Rank_Two, 1, 0},
+		{"straight flush", "3s 4s 5s 6s 7s", Rank_Two, 0, 1},
+		{"full house", "9h 9s 9d Kc Kh", Rank_Two, 1, 0},
+		{"tube", "4h 4s 5d 5c 6h 6s", Rank_Two, 1, 0},
+		{"plate", "Jh Js Jd Qc Qh Qs", Rank_Two, 1, 0},
+		{"wild completes a bomb", "8h 8s 8d 5h", Rank_Five, 0, 1},
+		{"wild completes a straight", "10h Js Qd Kc 5h", Rank_Five, 1, 0},
+		{"jokers as pairs", "bj bj rj", Rank_Two, 2, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hand := parse_cards(t, tt.hand)
+			d := Decompose(hand, tt.level)
+			check_decomposition(t, hand, d, tt.level)
+			if d.Turns != tt.turns || d.Bombs != tt.bombs {
+				t.Fatalf("got %d turns and %d bombs, want %d and %d", d.Turns, d.Bombs, tt.turns, tt.bombs)
+			}
+		})
+	}
+}
+
+// check_decomposition asserts that d uses every card of hand once in valid
+// plays and that its counts agree with its plays.
+func check_decomposition(t *testing.T, hand []Card, d Decomposition, level Rank) {
+	t.Helper()
+
+	used := make(map[int]int)
+	turns, bombs := 0, 0
+	for _, p := range d.Plays {
+		if p.Type == Comb_Invalid {
+			t.Fatalf("invalid play %v", p.Cards)
+		}
+		if got := Detect_Combination(p.Cards, level); got.Type != p.Type {
+			t.Fatalf("play %v detected as %v, recorded as %v", p.Cards, got.Type, p.Type)
+		}
+		if p.Type == Comb_Bomb {
+			bombs++
+		} else {
+			turns++
+		}
+		for _, c := range p.Cards {
+			used[c.Id]++
+		}
+	}
+	for _, c := range hand {
+		if used[c.Id] != 1 {
+			t.Fatalf("card %v used %d times", c, used[c.Id])
+		}
+		delete(used, c.Id)
+	}
+	if len(used) != 0 {
+		t.Fatalf("plays use %d cards that are not in the hand", len(used))
+	}
+	if d.Turns != turns || d.Bombs != bombs {
+		t.Fatalf("Turns %d and Bombs %d, plays give %d and %d", d.Turns, d.Bombs, turns, bombs)
+	}
+}
+
+func TestDecomposeFullHands(t *testing.T) {
+	for seed := uint64(1); seed <= 25; seed++ {
+		for _, level := range []Rank{Rank_Two, Rank_Seven, Rank_Ace} {
+			scorer := New_Hand_Scorer(level)
+			for _, hand := range Deal_From_Seed(seed) {
+				d := Decompose(hand, level)
+				check_decomposition(t, hand, d, level)
+				if d.Turns+d.Bombs > len(hand) {
+					t.Fatalf("seed %d: %d plays for %d cards", seed, d.Turns+d.Bombs, len(hand))
+				}
+
+				shared := scorer.Decompose(hand)
+				if shared.Strength != d.Strength || shared.Turns != d.Turns || shared.Bombs != d.Bombs {
+					t.Fatalf("seed %d level %v: shared scorer gave %+v, fresh gave %+v", seed, level, shared, d)
+				}
+			}
+		}
+	}
+}
+
+func TestHandScorerMatchesStrengthAfter(t *testing.T) {
+	level := Rank_Nine
+	hand := Deal_From_Seed(7)[2]
+	scorer := New_Hand_Scorer(level)
+	for _, p := range Legal_Plays(hand, Combination{Type: Comb_Invalid}, level) {
+		if got, want := scorer.Strength_After(hand, p), Strength_After(hand, p, level); got != want {
+			t.Fatalf("after %v: scorer %d, fresh %d", p.Cards, got, want)
+		}
+	}
+}
+
+func BenchmarkDecomposeFullHand(b *testing.B) {
+	hands := Deal_From_Seed(42)
+	for i := 0; i < b.N; i++ {
+		Decompose(hands[i%4], Rank_Two)
+	}
+}
+
+// BenchmarkStrengthAfterLegalPlays rates every lead from a full hand, as a
+// bot does on its turn.
+func BenchmarkStrengthAfterLegalPlays(b *testing.B) {
+	hand := Deal_From_Seed(42)[0]
+	plays := Legal_Plays(hand, Combination{Type: Comb_Invalid}, Rank_Two)
+	b.Run("fresh", func(b *testing.B) {
+		for range b.N {
+			for _, p := range plays {
+				Strength_After(hand, p, Rank_Two)
+			}
+		}
+	})
+	b.Run("scorer", func(b *testing.B) {
+		for range b.N {
+			scorer := New_Hand_Scorer(Rank_Two)
+			for _, p := range plays {
+				scorer.Strength_After(hand, p)
+			}
+		}
+	})
+}
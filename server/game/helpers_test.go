@@ -0,0 +1,49 @@
+package game
+
+import (
+	"strings"
+	"testing"
+)
+
+// parse_cards reads cards like "10h Qs bj rj", taking each from a fresh deck
+// so repeated cards get the second copy's id. Suits are h, d, c and s.
+func parse_cards(t testing.TB, spec string) []Card {
+	t.Helper()
+
+	ranks := map[string]Rank{
+		"2": Rank_Two, "3": Rank_Three, "4": Rank_Four, "5": Rank_Five,
+		"6": Rank_Six, "7": Rank_Seven, "8": Rank_Eight, "9": Rank_Nine,
+		"10": Rank_Ten, "J": Rank_Jack, "Q": Rank_Queen, "K": Rank_King, "A": Rank_Ace,
+	}
+	suits := map[byte]Suit{'h': Suit_Hearts, 'd': Suit_Diamonds, 'c': Suit_Clubs, 's': Suit_Spades}
+
+	used := make(map[int]bool)
+	take := func(suit Suit, rank Rank) Card {
+		for _, c := range New_Deck().Cards {
+			if c.Suit == suit && c.Rank == rank && !used[c.Id] {
+				used[c.Id] = true
+				return c
+			}
+		}
+		t.Fatalf("no %v of %v left in %q", rank, suit, spec)
+		return Card{}
+	}
+
+	var cards []Card
+	for _, f := range strings.Fields(spec) {
+		switch f {
+		case "bj":
+			cards = append(cards, take(Suit_Joker, Rank_Black_Joker))
+		case "rj":
+			cards = append(cards, take(Suit_Joker, Rank_Red_Joker))
+		default:
+			rank, ok := ranks[f[:len(f)-1]]
+			suit, ok2 := suits[f[len(f)-1]]
+			if !ok || !ok2 {
+				t.Fatalf("bad card %q", f)
+			}
+			cards = append(cards, take(suit, rank))
+		}
+	}
+	return cards
+}
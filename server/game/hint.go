@@ -0,0 +1,135 @@
+package game
+
+import "sort"
+
+type Hint_Kind string
+
+const (
+	Hint_Play Hint_Kind = "play"
+	Hint_Bomb Hint_Kind = "bomb"
+	Hint_Pass Hint_Kind = "pass"
+)
+
+// Hint is one suggestion for the seat to move. Play is empty for a pass.
+type Hint struct {
+	Kind   Hint_Kind
+	Play   Combination
+	Reason string
+	Score  int
+}
+
+// Suggest ranks what seat could do on its turn: the plays that beat the lead
+// (or the leads) which keep the strongest hand by play_score, the weakest
+// bomb that wins the trick, and a pass when the partner holds the trick or
+// nothing else beats it.
+func Suggest(g *Game_State, seat int) []Hint {
+	if g.Phase != Phase_Play || g.Current_Turn != seat {
+		return nil
+	}
+
+	hand := g.Hands[seat]
+	leading := g.Current_Lead.Type == Comb_Invalid
+	plays := Legal_Plays(hand, g.Current_Lead, g.Level)
+
+	var normal, bombs []Hint
+	for _, p := range plays {
+		h := Hint{Kind: Hint_Play, Play: p, Score: play_score(hand, p, g.Level)}
+		if p.Type == Comb_Bomb {
+			h.Kind = Hint_Bomb
+			bombs = append(bombs, h)
+		} else {
+			normal = append(normal, h)
+		}
+	}
+	sort.SliceStable(normal, func(i, j int) bool { return normal[i].Score > normal[j].Score })
+
+	var hints []Hint
+	partner_leads := !leading && g.Lead_Player == (seat+2)%4
+	if partner_leads {
+		hints = append(hints, Hint{Kind: Hint_Pass, Reason: "partner leads"})
+	}
+
+	for i, h := range normal {
+		if i == 2 {
+			break
+		}
+		h.Reason = "beats the lead and keeps the strongest hand"
+		if leading {
+			h.Reason = "leads and keeps the strongest hand"
+		}
+		hints = append(hints, h)
+	}
+
+	if !leading && len(bombs) > 0 {
+		h := bombs[0]
+		h.Reason = "weakest bomb that wins the trick"
+		if len(hand) == len(h.Play.Cards) {
+			h.Reason = "goes out"
+		}
+		hints = append(hints, h)
+	}
+
+	if !leading && !partner_leads && len(normal) == 0 {
+		hints = append(hints, Hint{Kind: Hint_Pass, Reason: "nothing beats the lead without a bomb"})
+	}
+
+	return hints
+}
+
+// Hand_Value scores a hand by how easily it goes out: bombs and control
+// cards (jokers, level cards) count for it, loose singles and many separate
+// ranks count against it.
+func Hand_Value(hand []Card, level Rank) int {
+	idx := index_hand(hand, nil, level)
+	value := 3 * len(idx.wilds)
+	for rank, cards := range idx.natural {
+		n := len(cards)
+		switch {
+		case n >= 4:
+			value += 10 + 2*(n-4)
+		case is_joker(rank) || rank == level:
+			value += 3 * n
+		case n == 1:
+			value -= 3
+		}
+		value--
+	}
+	return value
+}
+
+// Rank values run from 0 for a two to ace_value for an ace; the level card is
+// level_value and the black and red jokers the two values above it.
+const (
+	ace_value   = 12
+	level_value = 98
+)
+
+// play_score prefers plays that leave a strong hand and spend low cards.
+func play_score(hand []Card, play Combination, level Rank) int {
+	rest := hand_without(hand, play.Cards)
+	if len(rest) == 0 {
+		return 1000
+	}
+
+	cost := play.Rank_Value
+	if cost > ace_value {
+		// The level card and the jokers cost one step above an ace each, not
+		// their raw 98 to 100, so spending them does not drown the hand value.
+		cost = ace_value + 1 + cost - level_value
+	}
+	return 10*Hand_Value(rest, level) - cost
+}
+
+func hand_without(hand []Card, cards []Card) []Card {
+	used := make(map[int]bool, len(cards))
+	for _, c := range cards {
+		used[c.Id] = true
+	}
+	rest := make([]Card, 0, len(hand))
+	for _, c := range hand {
+		if !used[c.Id] {
+			rest = append(rest, c)
+		}
+	}
+	return rest
+}
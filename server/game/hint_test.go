@@ -0,0 +1,53 @@
+package game
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSuggestKeepsStrongestHand(t *testing.T) {
+	// Seat 0 follows a five. The six is the cheapest card that beats it, but
+	// it breaks a pair; the loose nine leaves the strongest hand.
+	g := deal_test_hands(t, Rank_Two, 3, [4]string{"6h 6d 9c Ks Kd", "3c 4c", "3d 4d", "5s 3s 4s"})
+	if _, err := g.Play(3, card_ids_of(g.Hands[3][:1])); err != nil {
+		t.Fatal(err)
+	}
+	if lead := g.Current_Lead.Cards; len(lead) != 1 || lead[0].Rank != Rank_Five {
+		t.Fatalf("lead = %v, want the five", lead)
+	}
+
+	hints := Suggest(g, 0)
+	if len(hints) == 0 {
+		t.Fatal("no hints")
+	}
+	top := hints[0]
+	if top.Kind != Hint_Play || len(top.Play.Cards) != 1 || top.Play.Cards[0].Rank != Rank_Nine {
+		t.Fatalf("top hint = %+v, want the nine", top)
+	}
+	if !strings.Contains(top.Reason, "strongest hand") {
+		t.Fatalf("reason = %q", top.Reason)
+	}
+	for _, h := range hints[1:] {
+		if h.Kind == Hint_Play && h.Score > top.Score {
+			t.Fatalf("hint %+v outscores the top hint %+v", h, top)
+		}
+	}
+}
+
+func TestSuggestPassesToPartner(t *testing.T) {
+	g := deal_test_hands(t, Rank_Two, 2, [4]string{"6h 9c", "3c 4c", "5s 3s", "3d 4d"})
+	if _, err := g.Play(2, card_ids_of(g.Hands[2][:1])); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.Pass(3); err != nil {
+		t.Fatal(err)
+	}
+
+	hints := Suggest(g, 0)
+	if len(hints) == 0 || hints[0].Kind != Hint_Pass || hints[0].Reason != "partner leads" {
+		t.Fatalf("hints = %+v, want a pass first", hints)
+	}
+	if Suggest(g, 1) != nil {
+		t.Fatal("hints for a seat whose turn it is not")
+	}
+}
@@ -0,0 +1,234 @@
+package game
+
+import "sort"
+
+// Legal_Plays lists every distinct play hand can make: one choice of cards
+// per combination type, size, rank and bomb power, preferring natural cards
+// over wilds. With a lead it keeps only the plays that beat it; pass a
+// Comb_Invalid lead when leading. Non-bombs come first, weakest first, then
+// bombs by power.
+func Legal_Plays(hand []Card, lead Combination, level Rank) []Combination {
+	idx := index_hand(hand, nil, level)
+
+	var plays []Combination
+	seen := make(map[[4]int]int)
+	add := func(cards []Card) {
+		if cards == nil {
+			return
+		}
+		combo := Detect_Combination(cards, level)
+		if combo.Type == Comb_Invalid {
+			return
+		}
+		if lead.Type != Comb_Invalid && !Can_Beat(combo, lead) {
+			return
+		}
+		key := [4]int{int(combo.Type), len(cards), combo.Rank_Value, combo.Bomb_Power}
+		if i, ok := seen[key]; ok {
+			if count_wilds(cards, level) < count_wilds(plays[i].Cards, level) {
+				plays[i] = combo
+			}
+			return
+		}
+		seen[key] = len(plays)
+		plays = append(plays, combo)
+	}
+
+	idx.candidates(add)
+	sort_plays(plays)
+	return plays
+}
+
+// candidates calls visit with one card choice for every combination shape
+// the hand can make; a choice may still fail Detect_Combination.
+func (idx hand_index) candidates(visit func([]Card)) {
+	for rank := Rank_Two; rank <= Rank_Red_Joker; rank++ {
+		max := len(idx.natural[rank])
+		if !is_joker(rank) {
+			max += len(idx.wilds)
+		}
+		for n := 1; n <= max && n <= 10; n++ {
+			visit(idx.pick([]Rank{rank}, []int{n}, -1))
+		}
+	}
+	for n := 1; n <= len(idx.wilds); n++ {
+		visit(idx.wilds[:n])
+	}
+
+	for triple := Rank_Two; triple <= Rank_Ace; triple++ {
+		for pair := Rank_Two; pair <= Rank_Red_Joker; pair++ {
+			if pair != triple {
+				visit(idx.pick([]Rank{triple, pair}, []int{3, 2}, -1))
+			}
+		}
+	}
+
+	for _, run := range []struct{ length, copies int }{{5, 1}, {3, 2}, {2, 3}} {
+		for start := 0; start+run.length <= len(ace_low_order); start++ {
+			window := ace_low_order[start : start+run.length]
+			copies := make([]int, run.length)
+			for i := range copies {
+				copies[i] = run.copies
+			}
+			cards := idx.pick(window, copies, -1)
+			if run.length == 5 {
+				cards = idx.break_flush(cards)
+				for suit := Suit_Hearts; suit <= Suit_Spades; suit++ {
+					visit(idx.pick(window, copies, suit))
+				}
+			}
+			visit(cards)
+		}
+	}
+
+	if len(idx.natural[Rank_Black_Joker]) == 2 && len(idx.natural[Rank_Red_Joker]) == 2 {
+		visit(append(append([]Card(nil), idx.natural[Rank_Black_Joker]...), idx.natural[Rank_Red_Joker]...))
+	}
+}
+
+func sort_plays(plays []Combination) {
+	sort.SliceStable(plays, func(i, j int) bool { return play_less(plays[i], plays[j]) })
+}
+
+// play_less orders non-bombs before bombs, non-bombs by type, size and rank
+// and bombs by power.
+func play_less(a, b Combination) bool {
+	if (a.Type == Comb_Bomb) != (b.Type == Comb_Bomb) {
+		return b.Type == Comb_Bomb
+	}
+	if a.Type == Comb_Bomb {
+		return a.Bomb_Power < b.Bomb_Power
+	}
+	if a.Type != b.Type {
+		return a.Type < b.Type
+	}
+	if len(a.Cards) != len(b.Cards) {
+		return len(a.Cards) < len(b.Cards)
+	}
+	return a.Rank_Value < b.Rank_Value
+}
+
+// hand_index groups a hand by rank. Kept cards sort first within their rank
+// and among the wilds, so picks use them before the rest of the hand.
+type hand_index struct {
+	natural map[Rank][]Card
+	wilds   []Card
+	keep    map[int]bool
+}
+
+func index_hand(hand []Card, keep []Card, level Rank) hand_index {
+	idx := hand_index{natural: make(map[Rank][]Card), keep: make(map[int]bool)}
+	for _, c := range keep {
+		idx.keep[c.Id] = true
+	}
+	for _, pass := range []bool{true, false} {
+		for _, c := range hand {
+			if idx.keep[c.Id] != pass {
+				continue
+			}
+			if Is_Wild(c, level) {
+				idx.wilds = append(idx.wilds, c)
+			} else {
+				idx.natural[c.Rank] = append(idx.natural[c.Rank], c)
+			}
+		}
+	}
+	return idx
+}
+
+// pick takes counts[i] cards of each ranks[i], natural ones first and wilds
+// for the rest, or nil when the hand is short. A suit of -1 takes any suit.
+// Wilds never stand in for jokers.
+func (idx hand_index) pick(ranks []Rank, counts []int, suit Suit) []Card {
+	used := make(map[int]bool)
+	var cards []Card
+	wilds := 0
+	for i, rank := range ranks {
+		need := counts[i]
+		for _, c := range idx.natural[rank] {
+			if need == 0 {
+				break
+			}
+			if used[c.Id] || suit != -1 && c.Suit != suit {
+				continue
+			}
+			used[c.Id] = true
+			cards = append(cards, c)
+			need--
+		}
+		if need > 0 && is_joker(rank) {
+			return nil
+		}
+		wilds += need
+	}
+	if wilds > len(idx.wilds) {
+		return nil
+	}
+	return append(cards, idx.wilds[:wilds]...)
+}
+
+// break_flush swaps one card of a plain straight for a same-rank card of
+// another suit, so a hand holding both still offers the straight and not
+// only the straight flush.
+func (idx hand_index) break_flush(cards []Card) []Card {
+	if cards == nil {
+		return nil
+	}
+	in := make(map[int]bool)
+	for _, c := range cards {
+		in[c.Id] = true
+	}
+	for i, c := range cards {
+		if idx.is_wild_card(c) || idx.keep[c.Id] {
+			continue
+		}
+		for _, other := range idx.natural[c.Rank] {
+			if !in[other.Id] && other.Suit != c.Suit {
+				out := append([]Card(nil), cards...)
+				out[i] = other
+				if !all_one_suit(out, idx) {
+					return out
+				}
+			}
+		}
+	}
+	return cards
+}
+
+func (idx hand_index) is_wild_card(c Card) bool {
+	for _, w := range idx.wilds {
+		if w.Id == c.Id {
+			return true
+		}
+	}
+	return false
+}
+
+func all_one_suit(cards []Card, idx hand_index) bool {
+	var suit Suit = -1
+	for _, c := range cards {
+		if idx.is_wild_card(c) {
+			continue
+		}
+		if suit == -1 {
+			suit = c.Suit
+		} else if c.Suit != suit {
+			return false
+		}
+	}
+	return true
+}
+
+func count_wilds(cards []Card, level Rank) int {
+	n := 0
+	for _, c := range cards {
+		if Is_Wild(c, level) {
+			n++
+		}
+	}
+	return n
+}
+
+func is_joker(rank Rank) bool {
+	return rank == Rank_Black_Joker || rank == Rank_Red_Joker
+}
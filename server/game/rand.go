@@ -3,6 +3,7 @@ package game
 import (
 	"crypto/rand"
 	"encoding/binary"
+	mrand "math/rand/v2"
 )
 
 func rand_int(max int) int {
@@ -10,3 +11,13 @@ func rand_int(max int) int {
 	rand.Read(b[:])
 	return int(binary.LittleEndian.Uint64(b[:]) % uint64(max))
 }
+
+func New_Seed() uint64 {
+	var b [8]byte
+	rand.Read(b[:])
+	return binary.LittleEndian.Uint64(b[:])
+}
+
+func seeded_rand(seed uint64) *mrand.Rand {
+	return mrand.New(mrand.NewPCG(seed, seed^0x9e3779b97f4a7c15))
+}
@@ -0,0 +1,287 @@
+package game
+
+import "errors"
+
+var (
+	Err_Wrong_Phase         = errors.New("not the right phase for that")
+	Err_Not_Your_Turn       = errors.New("not your turn")
+	Err_Invalid_Cards       = errors.New("invalid cards")
+	Err_Invalid_Combination = errors.New("invalid combination")
+	Err_Cannot_Beat         = errors.New("cannot beat current play")
+	Err_Cannot_Pass         = errors.New("cannot pass when leading")
+	Err_No_Tribute_Due      = errors.New("you don't need to give tribute")
+	Err_Invalid_Card        = errors.New("invalid card")
+	Err_Wild_Tribute        = errors.New("cannot tribute wild cards")
+)
+
+type Play_Result struct {
+	Combo    Combination
+	Finished bool
+	Hand     *Hand_Result
+}
+
+type Pass_Result struct {
+	Trick_Over bool
+}
+
+type Tribute_Result struct {
+	Tribute  Tribute_Info
+	Card     Card
+	All_Done bool
+}
+
+type Hand_Result struct {
+	Finish_Order  []int
+	Winning_Team  int
+	Level_Advance int
+	Old_Level     int
+	New_Levels    [2]int
+	Game_Over     bool
+}
+
+func (g *Game_State) Deal(hands [4][]Card) {
+	for i := 0; i < 4; i++ {
+		g.Hands[i] = append([]Card(nil), hands[i]...)
+	}
+
+	g.Current_Lead = Combination{Type: Comb_Invalid}
+	g.Lead_Player = g.Tribute_Leader
+	g.Pass_Count = 0
+	g.Trick = nil
+	g.Finish_Order = g.Finish_Order[:0]
+
+	if len(g.Tributes) > 0 && !g.All_Tributes_Done() {
+		g.Phase = Phase_Tribute
+		g.Current_Turn = g.Tribute_Leader
+		return
+	}
+
+	g.Phase = Phase_Play
+	g.Current_Turn = g.Tribute_Leader
+}
+
+func (g *Game_State) Play(seat int, ids []int) (Play_Result, error) {
+	if g.Phase != Phase_Play {
+		return Play_Result{}, Err_Wrong_Phase
+	}
+
+	if seat != g.Current_Turn {
+		return Play_Result{}, Err_Not_Your_Turn
+	}
+
+	cards := g.Get_Cards_By_Id(seat, ids)
+	if cards == nil {
+		return Play_Result{}, Err_Invalid_Cards
+	}
+
+	combo := Detect_Combination(cards, g.Level)
+	if combo.Type == Comb_Invalid {
+		return Play_Result{}, Err_Invalid_Combination
+	}
+
+	if g.Current_Lead.Type != Comb_Invalid && !Can_Beat(combo, g.Current_Lead) {
+		return Play_Result{}, Err_Cannot_Beat
+	}
+
+	g.Remove_Cards(seat, ids)
+	if g.Current_Lead.Type == Comb_Invalid {
+		g.Trick = nil
+	}
+	g.Trick = append(g.Trick, Trick_Play{Seat: seat, Cards: cards, Type: combo.Type})
+	g.Current_Lead = combo
+	g.Lead_Player = seat
+	g.Pass_Count = 0
+
+	result := Play_Result{Combo: combo}
+
+	if len(g.Hands[seat]) == 0 {
+		g.Finish_Order = append(g.Finish_Order, seat)
+		result.Finished = true
+
+		if g.is_hand_over() {
+			hand := g.finish_hand()
+			result.Hand = &hand
+			return result, nil
+		}
+	}
+
+	g.advance_turn()
+	return result, nil
+}
+
+func (g *Game_State) Pass(seat int) (Pass_Result, error) {
+	if g.Phase != Phase_Play {
+		return Pass_Result{}, Err_Wrong_Phase
+	}
+
+	if seat != g.Current_Turn {
+		return Pass_Result{}, Err_Not_Your_Turn
+	}
+
+	if g.Current_Lead.Type == Comb_Invalid {
+		return Pass_Result{}, Err_Cannot_Pass
+	}
+
+	g.Pass_Count++
+	g.Trick = append(g.Trick, Trick_Play{Seat: seat, Pass: true})
+
+	needed := 4 - len(g.Finish_Order)
+	if !g.Is_Finished(g.Lead_Player) {
+		needed--
+	}
+
+	if g.Pass_Count < needed {
+		g.advance_turn()
+		return Pass_Result{}, nil
+	}
+
+	next_leader := g.Lead_Player
+	if g.Is_Finished(next_leader) {
+		next_leader = (next_leader + 2) % 4
+	}
+
+	g.Current_Lead = Combination{Type: Comb_Invalid}
+	g.Current_Turn = next_leader
+	g.Pass_Count = 0
+	g.Trick = nil
+
+	return Pass_Result{Trick_Over: true}, nil
+}
+
+func (g *Game_State) Give_Tribute(seat int, card_id int) (Tribute_Result, error) {
+	if g.Phase != Phase_Tribute {
+		return Tribute_Result{}, Err_Wrong_Phase
+	}
+
+	info := g.Get_Tribute_Info(seat)
+	if info == nil {
+		return Tribute_Result{}, Err_No_Tribute_Due
+	}
+
+	card := g.Get_Card_By_Id(seat, card_id)
+	if card == nil {
+		return Tribute_Result{}, Err_Invalid_Card
+	}
+
+	if Is_Wild(*card, g.Level) {
+		return Tribute_Result{}, Err_Wild_Tribute
+	}
+
+	given := *card
+	to_seat := info.To_Seat
+
+	g.Remove_Cards(seat, []int{card_id})
+	g.Hands[to_seat] = append(g.Hands[to_seat], given)
+	g.Mark_Tribute_Done(seat)
+
+	result := Tribute_Result{
+		Tribute: Tribute_Info{From_Seat: seat, To_Seat: to_seat, Done: true},
+		Card:    given,
+	}
+
+	if g.All_Tributes_Done() {
+		g.Phase = Phase_Play
+		g.Current_Turn = g.Tribute_Leader
+		result.All_Done = true
+	}
+
+	return result, nil
+}
+
+func (g *Game_State) Is_Finished(seat int) bool {
+	for _, s := range g.Finish_Order {
+		if s == seat {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *Game_State) advance_turn() {
+	for i := 1; i <= 4; i++ {
+		next := (g.Current_Turn + i) % 4
+		if !g.Is_Finished(next) {
+			g.Current_Turn = next
+			return
+		}
+	}
+}
+
+func (g *Game_State) is_hand_over() bool {
+	if len(g.Finish_Order) < 2 {
+		return false
+	}
+
+	if g.Finish_Order[0]%2 == g.Finish_Order[1]%2 {
+		return true
+	}
+
+	return len(g.Finish_Order) >= 3
+}
+
+func (g *Game_State) finish_hand() Hand_Result {
+	winning_team := g.Finish_Order[0] % 2
+	level_advance := g.calculate_level_advance()
+
+	old_level := g.Team_Levels[winning_team]
+	new_level := old_level + level_advance
+	if new_level > 12 {
+		new_level = 12
+	}
+	g.Team_Levels[winning_team] = new_level
+
+	result := Hand_Result{
+		Finish_Order:  append([]int(nil), g.Finish_Order...),
+		Winning_Team:  winning_team,
+		Level_Advance: level_advance,
+		Old_Level:     old_level,
+		New_Levels:    g.Team_Levels,
+	}
+
+	if new_level >= 12 && Rank(old_level) == Rank_Ace {
+		g.Phase = Phase_End
+		result.Game_Over = true
+		return result
+	}
+
+	g.Setup_Tributes()
+	g.Level = Rank(g.Team_Levels[g.Tribute_Leader%2])
+	g.Phase = Phase_Deal
+
+	return result
+}
+
+func (g *Game_State) calculate_level_advance() int {
+	if len(g.Finish_Order) < 2 {
+		return 0
+	}
+
+	first_team := g.Finish_Order[0] % 2
+
+	for i, seat := range g.Finish_Order {
+		if seat%2 != first_team {
+			partner_pos := -1
+			for j, s := range g.Finish_Order {
+				if s%2 == first_team && j != 0 {
+					partner_pos = j
+					break
+				}
+			}
+
+			if partner_pos == -1 {
+				partner_pos = 3
+			}
+
+			switch {
+			case i == 3 && partner_pos == 1:
+				return 4
+			case i == 2 && partner_pos == 1:
+				return 2
+			default:
+				return 1
+			}
+		}
+	}
+
+	return 4
+}
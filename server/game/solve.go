@@ -0,0 +1,287 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	mrand "math/rand/v2"
+	"sort"
+)
+
+var (
+	Err_Solve_Budget = errors.New("position too large to solve")
+	Err_Solve_Line   = errors.New("no move reaches the solved value")
+)
+
+// Solve_Move is one turn of a solved line; Play is empty for a pass.
+type Solve_Move struct {
+	Seat int
+	Pass bool
+	Play Combination
+}
+
+// Solution is how a hand ends when every seat plays perfectly with all hands
+// open: the winning team, the finishing order and the line that gets there.
+type Solution struct {
+	Winning_Team  int
+	Finish_Order  []int
+	Level_Advance int
+	Line          []Solve_Move
+	Nodes         int
+}
+
+// solve_bound is past the largest level advance, a double win's 4.
+const solve_bound = 5
+
+const (
+	solve_exact int8 = iota
+	solve_lower
+	solve_upper
+)
+
+type solve_entry struct {
+	value int
+	bound int8
+}
+
+type solver struct {
+	max_nodes int
+	nodes     int
+	table     map[string]solve_entry
+}
+
+// Solve plays out a hand in progress by alpha-beta search over Play and Pass,
+// so partners, jiefeng (the partner of a player who went out on the last
+// play takes the lead) and bombs follow the engine's rules. Team 0 maximizes
+// its level advance and team 1 minimizes it. Plays that differ only in which
+// copies of equal cards they use are searched once, as in Legal_Plays. It
+// gives up with Err_Solve_Budget after max_nodes positions.
+func Solve(g *Game_State, max_nodes int) (Solution, error) {
+	if g.Phase != Phase_Play {
+		return Solution{}, Err_Wrong_Phase
+	}
+
+	s := &solver{max_nodes: max_nodes, table: make(map[string]solve_entry)}
+	value := s.search(g, -solve_bound, solve_bound)
+	if s.nodes > s.max_nodes {
+		return Solution{}, Err_Solve_Budget
+	}
+
+	var out Solution
+	pos := g
+	for {
+		var next *Game_State
+		var hand *Hand_Result
+		for _, m := range solve_moves(pos) {
+			child, result := solve_apply(pos, m)
+			child_value := 0
+			if result != nil {
+				child_value = solve_value(result)
+			} else {
+				child_value = s.search(child, -solve_bound, solve_bound)
+			}
+			if s.nodes > s.max_nodes {
+				return Solution{}, Err_Solve_Budget
+			}
+			if child_value == value {
+				out.Line = append(out.Line, m)
+				next, hand = child, result
+				break
+			}
+		}
+		if next == nil {
+			return Solution{}, Err_Solve_Line
+		}
+		if hand != nil {
+			out.Winning_Team = hand.Winning_Team
+			out.Finish_Order = hand.Finish_Order
+			out.Level_Advance = hand.Level_Advance
+			break
+		}
+		pos = next
+	}
+
+	out.Nodes = s.nodes
+	return out, nil
+}
+
+// Solve_Sampled chooses a move for the seat to move when the other hands are
+// hidden: it deals them from b several times, solves each deal with every
+// hand open and plays the first move that most deals agree on. It reports
+// false when no deal could be solved within max_nodes.
+func Solve_Sampled(g *Game_State, b *Belief, samples, max_nodes int, rng *mrand.Rand) (Solve_Move, bool) {
+	votes := make(map[string]int)
+	moves := make(map[string]Solve_Move)
+	best := ""
+	for i := 0; i < samples; i++ {
+		deal := g.Clone()
+		deal.Hands = b.Sample(rng)
+		solution, err := Solve(deal, max_nodes)
+		if err != nil || len(solution.Line) == 0 {
+			continue
+		}
+		m := solution.Line[0]
+		key := "pass"
+		if !m.Pass {
+			ids := make([]int, len(m.Play.Cards))
+			for j, c := range m.Play.Cards {
+				ids[j] = c.Id
+			}
+			sort.Ints(ids)
+			key = fmt.Sprint(ids)
+		}
+		votes[key]++
+		moves[key] = m
+		if best == "" || votes[key] > votes[best] {
+			best = key
+		}
+	}
+	return moves[best], best != ""
+}
+
+func (s *solver) search(g *Game_State, alpha, beta int) int {
+	s.nodes++
+	if s.nodes > s.max_nodes {
+		return 0
+	}
+
+	key := solve_key(g)
+	if e, ok := s.table[key]; ok {
+		switch e.bound {
+		case solve_exact:
+			return e.value
+		case solve_lower:
+			alpha = max(alpha, e.value)
+		case solve_upper:
+			beta = min(beta, e.value)
+		}
+		if alpha >= beta {
+			return e.value
+		}
+	}
+
+	low, high := alpha, beta
+	maximizing := g.Current_Turn%2 == 0
+	best := solve_bound
+	if maximizing {
+		best = -solve_bound
+	}
+
+	for _, m := range solve_moves(g) {
+		child, result := solve_apply(g, m)
+		var value int
+		if result != nil {
+			value = solve_value(result)
+		} else {
+			value = s.search(child, alpha, beta)
+		}
+		if maximizing {
+			best = max(best, value)
+			alpha = max(alpha, value)
+		} else {
+			best = min(best, value)
+			beta = min(beta, value)
+		}
+		if alpha >= beta {
+			break
+		}
+	}
+
+	if s.nodes > s.max_nodes {
+		return 0
+	}
+
+	bound := solve_exact
+	if best <= low {
+		bound = solve_upper
+	} else if best >= high {
+		bound = solve_lower
+	}
+	s.table[key] = solve_entry{value: best, bound: bound}
+	return best
+}
+
+// solve_value scores a finished hand for team 0.
+func solve_value(result *Hand_Result) int {
+	if result.Winning_Team == 0 {
+		return result.Level_Advance
+	}
+	return -result.Level_Advance
+}
+
+// solve_moves lists the turns open to the seat to move, best guesses first:
+// going out, then a pass when the partner holds the trick, then bigger plays
+// before smaller ones and bombs last.
+func solve_moves(g *Game_State) []Solve_Move {
+	seat := g.Current_Turn
+	hand := g.Hands[seat]
+	plays := Legal_Plays(hand, g.Current_Lead, g.Level)
+
+	sort.SliceStable(plays, func(i, j int) bool {
+		a, b := plays[i], plays[j]
+		if (len(a.Cards) == len(hand)) != (len(b.Cards) == len(hand)) {
+			return len(a.Cards) == len(hand)
+		}
+		if (a.Type == Comb_Bomb) != (b.Type == Comb_Bomb) {
+			return b.Type == Comb_Bomb
+		}
+		if a.Type != Comb_Bomb && len(a.Cards) != len(b.Cards) {
+			return len(a.Cards) > len(b.Cards)
+		}
+		return play_less(a, b)
+	})
+
+	var moves []Solve_Move
+	can_pass := g.Current_Lead.Type != Comb_Invalid
+	partner_leads := can_pass && g.Lead_Player%2 == seat%2
+	if partner_leads {
+		moves = append(moves, Solve_Move{Seat: seat, Pass: true})
+	}
+	for _, p := range plays {
+		moves = append(moves, Solve_Move{Seat: seat, Play: p})
+	}
+	if can_pass && !partner_leads {
+		moves = append(moves, Solve_Move{Seat: seat, Pass: true})
+	}
+	return moves
+}
+
+// solve_apply makes a move on a copy of g. The Hand_Result is set when the
+// move ends the hand.
+func solve_apply(g *Game_State, m Solve_Move) (*Game_State, *Hand_Result) {
+	next := g.Clone()
+	if m.Pass {
+		next.Pass(m.Seat)
+		return next, nil
+	}
+
+	ids := make([]int, len(m.Play.Cards))
+	for i, c := range m.Play.Cards {
+		ids[i] = c.Id
+	}
+	result, _ := next.Play(m.Seat, ids)
+	return next, result.Hand
+}
+
+// solve_key identifies a position by the cards left in each hand, whose turn
+// it is, the trick in progress and who has gone out. Hands only lose cards,
+// so a given set of cards always appears in the same order.
+func solve_key(g *Game_State) string {
+	key := make([]byte, 0, 64)
+	for _, hand := range g.Hands {
+		for _, c := range hand {
+			key = append(key, byte(c.Id))
+		}
+		key = append(key, 0xff)
+	}
+	key = append(key, byte(g.Current_Turn))
+	if g.Current_Lead.Type != Comb_Invalid {
+		lead := g.Current_Lead
+		key = append(key, byte(g.Lead_Player), byte(g.Pass_Count), byte(lead.Type), byte(len(lead.Cards)),
+			byte(lead.Rank_Value), byte(lead.Bomb_Power>>8), byte(lead.Bomb_Power))
+	}
+	key = append(key, 0xff)
+	for _, seat := range g.Finish_Order {
+		key = append(key, byte(seat))
+	}
+	return string(key)
+}
@@ -0,0 +1,158 @@
+package game
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// deal_test_hands deals the given hands, one card list per seat, and starts
+// play with leader to move.
+func deal_test_hands(t *testing.T, level Rank, leader int, hands [4]string) *Game_State {
+	t.Helper()
+
+	cards := parse_cards(t, strings.Join(hands[:], " "))
+	var dealt [4][]Card
+	for i, h := range hands {
+		n := len(strings.Fields(h))
+		dealt[i], cards = cards[:n], cards[n:]
+	}
+
+	g := New_Game_State()
+	g.Level = level
+	g.Tribute_Leader = leader
+	g.Deal(dealt)
+	return g
+}
+
+func TestSolve(t *testing.T) {
+	tests := []struct {
+		name    string
+		leader  int
+		hands   [4]string
+		team    int
+		advance int
+		order   []int
+	}{
+		{
+			name:    "jiefeng to a double win",
+			hands:   [4]string{"rj", "4h 5h", "3h", "6h 7h"},
+			team:    0,
+			advance: 4,
+			order:   []int{0, 2},
+		},
+		{
+			name:    "opponents hold the jokers",
+			hands:   [4]string{"3h 5d", "rj", "4h 6d", "bj"},
+			team:    1,
+			advance: 4,
+			order:   []int{1, 3},
+		},
+		{
+			name:    "opponent goes out before the bomb",
+			leader:  1,
+			hands:   [4]string{"9h 9s 9d 9c", "Ah 3d", "4d", "5d"},
+			team:    0,
+			advance: 1,
+			order:   []int{2, 3, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := deal_test_hands(t, Rank_Two, tt.leader, tt.hands)
+			solution, err := Solve(g, 100000)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if solution.Winning_Team != tt.team || solution.Level_Advance != tt.advance || !slices.Equal(solution.Finish_Order, tt.order) {
+				t.Fatalf("got team %d advance %d order %v, want %d %d %v",
+					solution.Winning_Team, solution.Level_Advance, solution.Finish_Order, tt.team, tt.advance, tt.order)
+			}
+			check_solve_line(t, g, solution)
+		})
+	}
+}
+
+// check_solve_line plays the solved line through the engine and checks that
+// it ends the hand the way the solution says.
+func check_solve_line(t *testing.T, g *Game_State, solution Solution) {
+	t.Helper()
+
+	pos := g.Clone()
+	var hand *Hand_Result
+	for i, m := range solution.Line {
+		if hand != nil {
+			t.Fatalf("line goes on after the hand ended at move %d", i)
+		}
+		if m.Seat != pos.Current_Turn {
+			t.Fatalf("move %d is by seat %d, but seat %d is to move", i, m.Seat, pos.Current_Turn)
+		}
+		if m.Pass {
+			if _, err := pos.Pass(m.Seat); err != nil {
+				t.Fatalf("move %d: %v", i, err)
+			}
+			continue
+		}
+		result, err := pos.Play(m.Seat, card_ids_of(m.Play.Cards))
+		if err != nil {
+			t.Fatalf("move %d: %v", i, err)
+		}
+		hand = result.Hand
+	}
+	if hand == nil {
+		t.Fatal("line does not end the hand")
+	}
+	if hand.Winning_Team != solution.Winning_Team || hand.Level_Advance != solution.Level_Advance {
+		t.Fatalf("line ends with team %d advancing %d, solution says %d and %d",
+			hand.Winning_Team, hand.Level_Advance, solution.Winning_Team, solution.Level_Advance)
+	}
+}
+
+func card_ids_of(cards []Card) []int {
+	ids := make([]int, len(cards))
+	for i, c := range cards {
+		ids[i] = c.Id
+	}
+	return ids
+}
+
+func TestSolveErrors(t *testing.T) {
+	full := New_Game_State()
+	full.Deal(Deal_From_Seed(3))
+
+	waiting := New_Game_State()
+
+	tests := []struct {
+		name string
+		g    *Game_State
+		want error
+	}{
+		{"over budget", full, Err_Solve_Budget},
+		{"not in play", waiting, Err_Wrong_Phase},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Solve(tt.g, 1000); !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSolveDoesNotChangeTheGame(t *testing.T) {
+	g := deal_test_hands(t, Rank_Two, 0, [4]string{"3h 5d", "rj", "4h 6d", "bj"})
+	before := g.Clone()
+	if _, err := Solve(g, 100000); err != nil {
+		t.Fatal(err)
+	}
+	for i := range g.Hands {
+		if !slices.Equal(g.Hands[i], before.Hands[i]) {
+			t.Fatalf("seat %d hand changed", i)
+		}
+	}
+	if g.Current_Turn != before.Current_Turn || g.Phase != before.Phase {
+		t.Fatal("turn or phase changed")
+	}
+}
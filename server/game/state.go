@@ -10,12 +10,32 @@ const (
 	Phase_End
 )
 
+var game_phase_names = map[Game_Phase]string{
+	Phase_Waiting: "waiting",
+	Phase_Deal:    "deal",
+	Phase_Play:    "play",
+	Phase_Tribute: "tribute",
+	Phase_End:     "end",
+}
+
+func (p Game_Phase) String() string {
+	return game_phase_names[p]
+}
+
 type Tribute_Info struct {
 	From_Seat int
 	To_Seat   int
 	Done      bool
 }
 
+// Trick_Play is one turn of the trick in progress; a pass has no cards.
+type Trick_Play struct {
+	Seat  int
+	Cards []Card
+	Type  Combination_Type
+	Pass  bool
+}
+
 type Game_State struct {
 	Phase          Game_Phase
 	Level          Rank
@@ -25,6 +45,7 @@ type Game_State struct {
 	Current_Lead   Combination
 	Lead_Player    int
 	Pass_Count     int
+	Trick          []Trick_Play
 	Finish_Order   []int
 	Tributes       []Tribute_Info
 	Tribute_Leader int
@@ -39,6 +60,18 @@ func New_Game_State() *Game_State {
 	}
 }
 
+// Clone copies g deeply enough that neither copy sees the other's moves.
+func (g *Game_State) Clone() *Game_State {
+	c := *g
+	for i := range c.Hands {
+		c.Hands[i] = append([]Card(nil), g.Hands[i]...)
+	}
+	c.Finish_Order = append([]int(nil), g.Finish_Order...)
+	c.Trick = append([]Trick_Play(nil), g.Trick...)
+	c.Tributes = append([]Tribute_Info(nil), g.Tributes...)
+	return &c
+}
+
 func (g *Game_State) Get_Cards_By_Id(seat int, ids []int) []Card {
 	id_set := make(map[int]bool)
 	for _, id := range ids {
@@ -94,29 +127,18 @@ func (g *Game_State) Setup_Tributes() {
 	first := g.Finish_Order[0]
 	winning_team := first % 2
 
+	order := append([]int(nil), g.Finish_Order...)
+	for s := 0; s < 4; s++ {
+		if !g.Is_Finished(s) {
+			order = append(order, s)
+		}
+	}
+
 	var last_loser int = -1
 	var second_last_loser int = -1
 
 	for i := 3; i >= 0; i-- {
-		seat := -1
-		if i < len(g.Finish_Order) {
-			seat = g.Finish_Order[i]
-		} else {
-			for s := 0; s < 4; s++ {
-				found := false
-				for _, f := range g.Finish_Order {
-					if f == s {
-						found = true
-						break
-					}
-				}
-				if !found {
-					seat = s
-					break
-				}
-			}
-		}
-
+		seat := order[i]
 		if seat%2 != winning_team {
 			if last_loser == -1 {
 				last_loser = seat
@@ -171,7 +193,7 @@ func (g *Game_State) Get_Tribute_Info(seat int) *Tribute_Info {
 
 func (g *Game_State) Mark_Tribute_Done(seat int) {
 	for i := range g.Tributes {
-		if g.Tributes[i].From_Seat == seat {
+		if g.Tributes[i].From_Seat == seat && !g.Tributes[i].Done {
 			g.Tributes[i].Done = true
 			break
 		}
@@ -186,14 +208,3 @@ func (g *Game_State) All_Tributes_Done() bool {
 	}
 	return true
 }
-
-func (g *Game_State) Reset_Hand() {
-	g.Current_Lead = Combination{Type: Comb_Invalid}
-	g.Lead_Player = 0
-	g.Pass_Count = 0
-	g.Finish_Order = g.Finish_Order[:0]
-	g.Tributes = nil
-
-	winning_team := g.Tribute_Leader % 2
-	g.Level = Rank(g.Team_Levels[winning_team])
-}
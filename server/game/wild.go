@@ -0,0 +1,160 @@
+package game
+
+type Wild_Assignment struct {
+	Card_Id int  `json:"card_id"`
+	Rank    Rank `json:"rank"`
+	Suit    Suit `json:"suit"`
+}
+
+var ace_low_order = []Rank{
+	Rank_Ace, Rank_Two, Rank_Three, Rank_Four, Rank_Five,
+	Rank_Six, Rank_Seven, Rank_Eight, Rank_Nine, Rank_Ten,
+	Rank_Jack, Rank_Queen, Rank_King, Rank_Ace,
+}
+
+func Assign_Wilds(combo Combination, level Rank) []Wild_Assignment {
+	non_wild, wild := separate_wilds(combo.Cards, level)
+	if len(wild) == 0 {
+		return nil
+	}
+
+	if len(non_wild) == 0 {
+		return wilds_as_themselves(wild)
+	}
+
+	switch combo.Type {
+	case Comb_Pair, Comb_Triple:
+		return fill_wilds(wild, []Rank{non_wild[0].Rank}, []int{len(combo.Cards) - 1}, non_wild, Suit_Hearts)
+	case Comb_Full_House:
+		return assign_full_house_wilds(combo, non_wild, wild, level)
+	case Comb_Straight:
+		return fill_run_wilds(wild, non_wild, combo.Rank_Value, 5, 1, Suit_Hearts)
+	case Comb_Tube:
+		return fill_run_wilds(wild, non_wild, combo.Rank_Value, 3, 2, Suit_Hearts)
+	case Comb_Plate:
+		return fill_run_wilds(wild, non_wild, combo.Rank_Value, 2, 3, Suit_Hearts)
+	case Comb_Bomb:
+		if is_straight_flush(combo.Cards, level) {
+			return assign_straight_flush_wilds(combo.Cards, non_wild, wild)
+		}
+		for _, c := range non_wild {
+			if c.Rank != Rank_Black_Joker && c.Rank != Rank_Red_Joker {
+				return fill_wilds(wild, []Rank{c.Rank}, []int{len(combo.Cards)}, non_wild, Suit_Hearts)
+			}
+		}
+	}
+
+	return wilds_as_themselves(wild)
+}
+
+func assign_full_house_wilds(combo Combination, non_wild, wild []Card, level Rank) []Wild_Assignment {
+	counts := count_ranks(non_wild)
+
+	var triple_rank Rank = -1
+	for rank := range counts {
+		if rank_value(rank, level) == combo.Rank_Value {
+			triple_rank = rank
+			break
+		}
+	}
+	if triple_rank == -1 {
+		return wilds_as_themselves(wild)
+	}
+
+	ranks := []Rank{triple_rank}
+	sizes := []int{3}
+	for rank := range counts {
+		if rank != triple_rank {
+			ranks = append(ranks, rank)
+			sizes = append(sizes, 2)
+			break
+		}
+	}
+
+	return fill_wilds(wild, ranks, sizes, non_wild, Suit_Hearts)
+}
+
+func assign_straight_flush_wilds(cards, non_wild, wild []Card) []Wild_Assignment {
+	present := make(map[Rank]bool)
+	for _, c := range non_wild {
+		present[c.Rank] = true
+	}
+
+	n := len(cards)
+	for start := 0; start <= len(ace_low_order)-n; start++ {
+		var missing []Rank
+		for i := 0; i < n; i++ {
+			if !present[ace_low_order[start+i]] {
+				missing = append(missing, ace_low_order[start+i])
+			}
+		}
+		if len(missing) > len(wild) {
+			continue
+		}
+
+		sizes := make([]int, len(missing))
+		for i := range sizes {
+			sizes[i] = 1
+		}
+		return fill_wilds(wild, missing, sizes, nil, non_wild[0].Suit)
+	}
+
+	return wilds_as_themselves(wild)
+}
+
+func fill_run_wilds(wild, non_wild []Card, rank_value int, length int, copies int, suit Suit) []Wild_Assignment {
+	window := run_window(rank_value, length)
+	if window == nil {
+		return wilds_as_themselves(wild)
+	}
+
+	sizes := make([]int, len(window))
+	for i := range sizes {
+		sizes[i] = copies
+	}
+
+	return fill_wilds(wild, window, sizes, non_wild, suit)
+}
+
+func run_window(rank_value int, length int) []Rank {
+	natural_order := ace_low_order[1:]
+	if rank_value < 0 || rank_value >= len(natural_order) {
+		return nil
+	}
+	highest := natural_order[rank_value]
+
+	for end := length - 1; end < len(ace_low_order); end++ {
+		if ace_low_order[end] == highest {
+			return ace_low_order[end-length+1 : end+1]
+		}
+	}
+	return nil
+}
+
+func fill_wilds(wild []Card, ranks []Rank, sizes []int, non_wild []Card, suit Suit) []Wild_Assignment {
+	counts := count_ranks(non_wild)
+
+	var out []Wild_Assignment
+	next := 0
+	for i, rank := range ranks {
+		for need := sizes[i] - counts[rank]; need > 0 && next < len(wild); need-- {
+			out = append(out, Wild_Assignment{Card_Id: wild[next].Id, Rank: rank, Suit: suit})
+			next++
+		}
+		counts[rank] = sizes[i]
+	}
+
+	for ; next < len(wild); next++ {
+		out = append(out, Wild_Assignment{Card_Id: wild[next].Id, Rank: wild[next].Rank, Suit: wild[next].Suit})
+	}
+
+	return out
+}
+
+func wilds_as_themselves(wild []Card) []Wild_Assignment {
+	out := make([]Wild_Assignment, len(wild))
+	for i, c := range wild {
+		out[i] = Wild_Assignment{Card_Id: c.Id, Rank: c.Rank, Suit: c.Suit}
+	}
+	return out
+}
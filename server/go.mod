@@ -1,3 +1,7 @@
 module guandanbtw
-go 1.23
+
+go 1.23.0
+
 require github.com/gorilla/websocket v1.5.3
+
+require golang.org/x/crypto v0.39.0
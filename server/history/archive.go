@@ -0,0 +1,33 @@
+package history
+
+import "fmt"
+
+// Archive is where finished hands are kept, by match id and hand number.
+type Archive interface {
+	List_Matches() ([]string, error)
+	List_Hands(match_id string) ([]int, error)
+	Load_Hand(match_id string, hand int) (*Hand_Log, error)
+}
+
+// Load_All hands every archived hand to add, match by match.
+func Load_All(src Archive, add func(*Hand_Log)) error {
+	matches, err := src.List_Matches()
+	if err != nil {
+		return err
+	}
+
+	for _, match_id := range matches {
+		hands, err := src.List_Hands(match_id)
+		if err != nil {
+			return err
+		}
+		for _, hand := range hands {
+			l, err := src.Load_Hand(match_id, hand)
+			if err != nil {
+				return fmt.Errorf("match %s hand %d: %w", match_id, hand, err)
+			}
+			add(l)
+		}
+	}
+	return nil
+}
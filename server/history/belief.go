@@ -0,0 +1,42 @@
+package history
+
+import (
+	"fmt"
+
+	"guandanbtw/game"
+)
+
+// Belief_At replays the log up to step and returns what seat can infer about
+// the other hands from the events it saw.
+func (l *Hand_Log) Belief_At(seat int, step int) (*game.Belief, error) {
+	if step < 0 || step > len(l.Events) {
+		return nil, fmt.Errorf("history: step %d out of range", step)
+	}
+
+	var b *game.Belief
+	r := New_Replayer(l)
+	for r.step < step {
+		ev := &l.Events[r.step]
+		state := r.state
+		switch {
+		case ev.Kind == Event_Tribute && b != nil && ev.Tribute != nil:
+			b.Observe_Tribute(ev.Seat, ev.Tribute.To_Seat, ev.Tribute.Card)
+		case ev.Kind == Event_Play && b != nil && ev.Play != nil:
+			b.Observe_Play(ev.Seat, state.Get_Cards_By_Id(ev.Seat, ev.Play.Card_Ids))
+		case ev.Kind == Event_Pass && b != nil:
+			b.Observe_Pass(ev.Seat, state.Current_Lead, state.Lead_Player)
+		}
+
+		if err := r.Next(); err != nil {
+			return nil, err
+		}
+		if ev.Kind == Event_Deal {
+			b = game.New_Belief(seat, ev.Deal.Hands[seat], ev.Deal.Level)
+		}
+	}
+
+	if b == nil {
+		return nil, fmt.Errorf("history: no deal before step %d", step)
+	}
+	return b, nil
+}
@@ -0,0 +1,190 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"guandanbtw/game"
+)
+
+const (
+	Format_Name    = "guandan-hand"
+	Format_Version = 1
+)
+
+type Event_Kind string
+
+const (
+	Event_Deal         Event_Kind = "deal"
+	Event_Tribute      Event_Kind = "tribute"
+	Event_Play         Event_Kind = "play"
+	Event_Pass         Event_Kind = "pass"
+	Event_Finish       Event_Kind = "finish"
+	Event_Level_Change Event_Kind = "level_change"
+	Event_Hint         Event_Kind = "hint"
+)
+
+type Player struct {
+	Id       string `json:"id"`
+	Name     string `json:"name"`
+	Username string `json:"username,omitempty"`
+	Is_Bot   bool   `json:"is_bot"`
+}
+
+type Header struct {
+	Format     string    `json:"format"`
+	Version    int       `json:"version"`
+	Match_Id   string    `json:"match_id"`
+	Room_Id    string    `json:"room_id"`
+	Hand       int       `json:"hand"`
+	Players    [4]Player `json:"players"`
+	Started_At time.Time `json:"started_at"`
+}
+
+type Deal struct {
+	Seed        uint64              `json:"seed,string"`
+	Level       game.Rank           `json:"level"`
+	Team_Levels [2]int              `json:"team_levels"`
+	Leader      int                 `json:"leader"`
+	Tributes    []game.Tribute_Info `json:"tributes,omitempty"`
+	Hands       [4][]game.Card      `json:"hands"`
+}
+
+type Tribute struct {
+	To_Seat int       `json:"to_seat"`
+	Card    game.Card `json:"card"`
+}
+
+type Combo struct {
+	Type       string                 `json:"type"`
+	Rank_Value int                    `json:"rank_value"`
+	Bomb_Power int                    `json:"bomb_power,omitempty"`
+	Wilds      []game.Wild_Assignment `json:"wilds,omitempty"`
+}
+
+type Play struct {
+	Card_Ids []int `json:"card_ids"`
+	Combo    Combo `json:"combo"`
+}
+
+type Level_Change struct {
+	Finish_Order  []int  `json:"finish_order"`
+	Winning_Team  int    `json:"winning_team"`
+	Level_Advance int    `json:"level_advance"`
+	Old_Level     int    `json:"old_level"`
+	New_Levels    [2]int `json:"new_levels"`
+	Game_Over     bool   `json:"game_over"`
+}
+
+type Event struct {
+	Step         int           `json:"step"`
+	At_Ms        int64         `json:"at_ms"`
+	Kind         Event_Kind    `json:"kind"`
+	Seat         int           `json:"seat"`
+	Deal         *Deal         `json:"deal,omitempty"`
+	Tribute      *Tribute      `json:"tribute,omitempty"`
+	Play         *Play         `json:"play,omitempty"`
+	Position     int           `json:"position,omitempty"`
+	Level_Change *Level_Change `json:"level_change,omitempty"`
+}
+
+type Hand_Log struct {
+	Header Header  `json:"header"`
+	Events []Event `json:"events"`
+}
+
+func New_Hand_Log(match_id string, room_id string, hand int, players [4]Player) *Hand_Log {
+	return &Hand_Log{
+		Header: Header{
+			Format:     Format_Name,
+			Version:    Format_Version,
+			Match_Id:   match_id,
+			Room_Id:    room_id,
+			Hand:       hand,
+			Players:    players,
+			Started_At: time.Now().UTC(),
+		},
+	}
+}
+
+func (l *Hand_Log) Add(ev Event) Event {
+	ev.Step = len(l.Events)
+	ev.At_Ms = time.Since(l.Header.Started_At).Milliseconds()
+	l.Events = append(l.Events, ev)
+	return ev
+}
+
+func (l *Hand_Log) Outcome() *Level_Change {
+	for i := len(l.Events) - 1; i >= 0; i-- {
+		if l.Events[i].Kind == Event_Level_Change {
+			return l.Events[i].Level_Change
+		}
+	}
+	return nil
+}
+
+func Combo_Record(combo game.Combination, level game.Rank) Combo {
+	return Combo{
+		Type:       combo.Type.String(),
+		Rank_Value: combo.Rank_Value,
+		Bomb_Power: combo.Bomb_Power,
+		Wilds:      game.Assign_Wilds(combo, level),
+	}
+}
+
+func (l *Hand_Log) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(&l.Header); err != nil {
+		return err
+	}
+	for i := range l.Events {
+		if err := enc.Encode(&l.Events[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func Read(r io.Reader) (*Hand_Log, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4<<20)
+
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("history: empty hand log")
+	}
+
+	var l Hand_Log
+	if err := json.Unmarshal(scanner.Bytes(), &l.Header); err != nil {
+		return nil, fmt.Errorf("history: header: %w", err)
+	}
+	if l.Header.Format != Format_Name {
+		return nil, fmt.Errorf("history: unknown format %q", l.Header.Format)
+	}
+	if l.Header.Version < 1 || l.Header.Version > Format_Version {
+		return nil, fmt.Errorf("history: unsupported version %d", l.Header.Version)
+	}
+
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var ev Event
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			return nil, fmt.Errorf("history: event %d: %w", len(l.Events), err)
+		}
+		if ev.Step != len(l.Events) {
+			return nil, fmt.Errorf("history: event %d has step %d", len(l.Events), ev.Step)
+		}
+		l.Events = append(l.Events, ev)
+	}
+
+	return &l, scanner.Err()
+}
@@ -0,0 +1,325 @@
+package history
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"guandanbtw/game"
+)
+
+// recorded is a hand played live on g, logged the way a room logs it, with
+// the live state after every event.
+type recorded struct {
+	log    *Hand_Log
+	states []*game.Game_State
+}
+
+// play_hand deals from seed and plays the hand out: the leader leads its
+// first legal play, and a seat follows with its smallest beating play unless
+// its partner holds the trick. A tribute due gives the first card allowed.
+func play_hand(t *testing.T, g *game.Game_State, seed uint64, hand int) recorded {
+	t.Helper()
+
+	rec := recorded{log: New_Hand_Log("match", "ROOM", hand, [4]Player{})}
+	add := func(ev Event) {
+		rec.log.Add(ev)
+		rec.states = append(rec.states, g.Clone())
+	}
+
+	hands := game.Deal_From_Seed(seed)
+	rec.states = append(rec.states, nil)
+	deal := &Deal{
+		Seed:        seed,
+		Level:       g.Level,
+		Team_Levels: g.Team_Levels,
+		Leader:      g.Tribute_Leader,
+		Tributes:    append([]game.Tribute_Info(nil), g.Tributes...),
+		Hands:       hands,
+	}
+	g.Deal(hands)
+	add(Event{Kind: Event_Deal, Seat: -1, Deal: deal})
+
+	for g.Phase == game.Phase_Tribute {
+		for _, tr := range g.Tributes {
+			if tr.Done {
+				continue
+			}
+			for _, c := range g.Hands[tr.From_Seat] {
+				if game.Is_Wild(c, g.Level) {
+					continue
+				}
+				result, err := g.Give_Tribute(tr.From_Seat, c.Id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				add(Event{Kind: Event_Tribute, Seat: tr.From_Seat, Tribute: &Tribute{To_Seat: result.Tribute.To_Seat, Card: result.Card}})
+				break
+			}
+		}
+	}
+
+	for g.Phase == game.Phase_Play {
+		seat := g.Current_Turn
+		leading := g.Current_Lead.Type == game.Comb_Invalid
+		plays := game.Legal_Plays(g.Hands[seat], g.Current_Lead, g.Level)
+		if !leading && (len(plays) == 0 || g.Lead_Player%2 == seat%2) {
+			if _, err := g.Pass(seat); err != nil {
+				t.Fatal(err)
+			}
+			add(Event{Kind: Event_Pass, Seat: seat})
+			continue
+		}
+
+		level := g.Level
+		ids := card_ids(plays[0].Cards)
+		result, err := g.Play(seat, ids)
+		if err != nil {
+			t.Fatal(err)
+		}
+		add(Event{Kind: Event_Play, Seat: seat, Play: &Play{Card_Ids: ids, Combo: Combo_Record(result.Combo, level)}})
+		if result.Finished {
+			add(Event{Kind: Event_Finish, Seat: seat, Position: len(g.Finish_Order)})
+		}
+		if h := result.Hand; h != nil {
+			add(Event{Kind: Event_Level_Change, Seat: -1, Level_Change: &Level_Change{
+				Finish_Order:  h.Finish_Order,
+				Winning_Team:  h.Winning_Team,
+				Level_Advance: h.Level_Advance,
+				Old_Level:     h.Old_Level,
+				New_Levels:    h.New_Levels,
+				Game_Over:     h.Game_Over,
+			}})
+		}
+	}
+	return rec
+}
+
+func card_ids(cards []game.Card) []int {
+	ids := make([]int, len(cards))
+	for i, c := range cards {
+		ids[i] = c.Id
+	}
+	return ids
+}
+
+// two_hands plays two hands in a row, so the second one opens with tribute
+// unless the losers hold both red jokers.
+func two_hands(t *testing.T) []recorded {
+	g := game.New_Game_State()
+	first := play_hand(t, g, 21, 1)
+	second := play_hand(t, g, 22, 2)
+	return []recorded{first, second}
+}
+
+func write_log(t *testing.T, l *Hand_Log) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := l.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestWriteReadRoundTrip(t *testing.T) {
+	for _, rec := range two_hands(t) {
+		data := write_log(t, rec.log)
+		got, err := Read(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Header != rec.log.Header || len(got.Events) != len(rec.log.Events) {
+			t.Fatalf("read header %+v with %d events, wrote %+v with %d",
+				got.Header, len(got.Events), rec.log.Header, len(rec.log.Events))
+		}
+		if again := write_log(t, got); !bytes.Equal(again, data) {
+			t.Fatal("writing the read log gives different bytes")
+		}
+		if err := got.Verify(); err != nil {
+			t.Fatalf("read log does not verify: %v", err)
+		}
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	header := `{"format":"guandan-hand","version":1,"match_id":"m","room_id":"R","hand":1}`
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"empty", "", "empty hand log"},
+		{"other format", `{"format":"chess","version":1}`, "unknown format"},
+		{"newer version", `{"format":"guandan-hand","version":99}`, "unsupported version"},
+		{"step gap", header + "\n" + `{"step":1,"kind":"pass","seat":0}`, "has step 1"},
+		{"bad event", header + "\n{", "event 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Read(strings.NewReader(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestStateAtMatchesLiveGame(t *testing.T) {
+	for h, rec := range two_hands(t) {
+		n := len(rec.log.Events)
+		for _, step := range []int{1, 2, n / 3, n / 2, n - 2, n - 1, n} {
+			got, err := rec.log.State_At(step)
+			if err != nil {
+				t.Fatalf("hand %d step %d: %v", h+1, step, err)
+			}
+			want := rec.states[step]
+			if got.Phase != want.Phase || got.Current_Turn != want.Current_Turn || got.Lead_Player != want.Lead_Player ||
+				got.Level != want.Level || got.Team_Levels != want.Team_Levels {
+				t.Fatalf("hand %d step %d: replay is at phase %v turn %d, live game at %v turn %d",
+					h+1, step, got.Phase, got.Current_Turn, want.Phase, want.Current_Turn)
+			}
+			for seat := range got.Hands {
+				if !same_cards(got.Hands[seat], want.Hands[seat]) {
+					t.Fatalf("hand %d step %d: seat %d holds %d cards in the replay, %d live",
+						h+1, step, seat, len(got.Hands[seat]), len(want.Hands[seat]))
+				}
+			}
+			if !same_ints(got.Finish_Order, want.Finish_Order) {
+				t.Fatalf("hand %d step %d: finish order %v, live %v", h+1, step, got.Finish_Order, want.Finish_Order)
+			}
+		}
+	}
+}
+
+func same_cards(a, b []game.Card) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := make(map[int]bool, len(a))
+	for _, c := range a {
+		seen[c.Id] = true
+	}
+	for _, c := range b {
+		if !seen[c.Id] {
+			return false
+		}
+	}
+	return true
+}
+
+func same_ints(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestStateAtOutOfRange(t *testing.T) {
+	l := two_hands(t)[0].log
+	for _, step := range []int{-1, len(l.Events) + 1} {
+		if _, err := l.State_At(step); err == nil {
+			t.Fatalf("step %d: no error", step)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(l *Hand_Log)
+		want   string
+	}{
+		{"untouched", func(l *Hand_Log) {}, ""},
+		{"hands do not match the seed", func(l *Hand_Log) {
+			d := l.Events[0].Deal
+			d.Hands[0][0], d.Hands[1][0] = d.Hands[1][0], d.Hands[0][0]
+		}, "hands do not match seed"},
+		{"winning team changed", func(l *Hand_Log) {
+			lc := l.Outcome()
+			lc.Winning_Team = 1 - lc.Winning_Team
+		}, "does not match recorded"},
+		{"level advance changed", func(l *Hand_Log) {
+			l.Outcome().Level_Advance++
+		}, "does not match recorded"},
+		{"finish order changed", func(l *Hand_Log) {
+			lc := l.Outcome()
+			lc.Finish_Order[0], lc.Finish_Order[1] = lc.Finish_Order[1], lc.Finish_Order[0]
+		}, "does not match recorded"},
+		{"combo changed", func(l *Hand_Log) {
+			for i := range l.Events {
+				if p := l.Events[i].Play; p != nil {
+					p.Combo.Rank_Value++
+					return
+				}
+			}
+		}, "play detected as"},
+		{"outcome never reached", func(l *Hand_Log) {
+			lc := l.Outcome()
+			l.Events = l.Events[:2]
+			l.Events = append(l.Events, Event{Step: 2, Kind: Event_Level_Change, Seat: -1, Level_Change: lc})
+		}, "level change before the hand ended"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := two_hands(t)[0].log
+			tt.tamper(l)
+			err := l.Verify()
+			if tt.want == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestBeliefAt(t *testing.T) {
+	for h, rec := range two_hands(t) {
+		n := len(rec.log.Events)
+		for _, step := range []int{1, n / 2, n - 1} {
+			live := rec.states[step]
+			for seat := range 4 {
+				b, err := rec.log.Belief_At(seat, step)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !same_cards(b.Hand, live.Hands[seat]) {
+					t.Fatalf("hand %d step %d: seat %d belief holds %d cards, the seat %d", h+1, step, seat, len(b.Hand), len(live.Hands[seat]))
+				}
+
+				// Every unseen card is still in some other hand, and nothing
+				// else is.
+				var others []game.Card
+				for s, hand := range live.Hands {
+					if b.Counts[s] != len(hand) {
+						t.Fatalf("hand %d step %d: seat %d count %d, live %d", h+1, step, s, b.Counts[s], len(hand))
+					}
+					if s != seat {
+						others = append(others, hand...)
+					}
+				}
+				if !same_cards(b.Unseen, others) {
+					t.Fatalf("hand %d step %d: seat %d has %d unseen cards, others hold %d", h+1, step, seat, len(b.Unseen), len(others))
+				}
+			}
+		}
+	}
+
+	l := two_hands(t)[0].log
+	if _, err := l.Belief_At(0, 0); err == nil {
+		t.Fatal("belief before the deal")
+	}
+	if _, err := l.Belief_At(0, len(l.Events)+1); err == nil {
+		t.Fatal("belief past the end")
+	}
+}
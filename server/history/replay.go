@@ -0,0 +1,178 @@
+package history
+
+import (
+	"fmt"
+	"slices"
+
+	"guandanbtw/game"
+)
+
+type Replayer struct {
+	log   *Hand_Log
+	state *game.Game_State
+	step  int
+	hand  *game.Hand_Result
+}
+
+func New_Replayer(l *Hand_Log) *Replayer {
+	return &Replayer{log: l}
+}
+
+func (r *Replayer) Step() int {
+	return r.step
+}
+
+func (r *Replayer) State() *game.Game_State {
+	return r.state
+}
+
+func (r *Replayer) Done() bool {
+	return r.step >= len(r.log.Events)
+}
+
+func (r *Replayer) Next() error {
+	if r.Done() {
+		return fmt.Errorf("history: no event after step %d", r.step)
+	}
+
+	ev := &r.log.Events[r.step]
+	if err := r.apply(ev); err != nil {
+		return fmt.Errorf("history: step %d (%s): %w", ev.Step, ev.Kind, err)
+	}
+
+	r.step++
+	return nil
+}
+
+func (l *Hand_Log) State_At(step int) (*game.Game_State, error) {
+	if step < 0 || step > len(l.Events) {
+		return nil, fmt.Errorf("history: step %d out of range", step)
+	}
+
+	r := New_Replayer(l)
+	for r.step < step {
+		if err := r.Next(); err != nil {
+			return nil, err
+		}
+	}
+	return r.state, nil
+}
+
+func (l *Hand_Log) Verify() error {
+	r := New_Replayer(l)
+	for !r.Done() {
+		if err := r.Next(); err != nil {
+			return err
+		}
+	}
+
+	if outcome := l.Outcome(); outcome != nil && r.hand == nil {
+		return fmt.Errorf("history: recorded outcome was never reached")
+	}
+	return nil
+}
+
+func (r *Replayer) apply(ev *Event) error {
+	if ev.Kind != Event_Deal && r.state == nil {
+		return fmt.Errorf("event before deal")
+	}
+
+	switch ev.Kind {
+	case Event_Deal:
+		return r.apply_deal(ev)
+	case Event_Tribute:
+		if ev.Tribute == nil {
+			return fmt.Errorf("missing tribute")
+		}
+		result, err := r.state.Give_Tribute(ev.Seat, ev.Tribute.Card.Id)
+		if err != nil {
+			return err
+		}
+		if result.Tribute.To_Seat != ev.Tribute.To_Seat {
+			return fmt.Errorf("tribute went to seat %d, recorded %d", result.Tribute.To_Seat, ev.Tribute.To_Seat)
+		}
+	case Event_Play:
+		if ev.Play == nil {
+			return fmt.Errorf("missing play")
+		}
+		level := r.state.Level
+		result, err := r.state.Play(ev.Seat, ev.Play.Card_Ids)
+		if err != nil {
+			return err
+		}
+		got := Combo_Record(result.Combo, level)
+		if got.Type != ev.Play.Combo.Type || got.Rank_Value != ev.Play.Combo.Rank_Value || got.Bomb_Power != ev.Play.Combo.Bomb_Power {
+			return fmt.Errorf("play detected as %s/%d, recorded %s/%d", got.Type, got.Rank_Value, ev.Play.Combo.Type, ev.Play.Combo.Rank_Value)
+		}
+		r.hand = result.Hand
+	case Event_Pass:
+		if _, err := r.state.Pass(ev.Seat); err != nil {
+			return err
+		}
+	case Event_Finish:
+		order := r.state.Finish_Order
+		if r.hand != nil {
+			order = r.hand.Finish_Order
+		}
+		if ev.Position < 1 || ev.Position > len(order) || order[ev.Position-1] != ev.Seat {
+			return fmt.Errorf("seat %d did not finish in position %d", ev.Seat, ev.Position)
+		}
+	case Event_Level_Change:
+		return r.check_level_change(ev)
+	case Event_Hint:
+	default:
+		return fmt.Errorf("unknown event kind %q", ev.Kind)
+	}
+
+	return nil
+}
+
+func (r *Replayer) apply_deal(ev *Event) error {
+	if ev.Deal == nil {
+		return fmt.Errorf("missing deal")
+	}
+	deal := ev.Deal
+
+	if deal.Seed != 0 {
+		seeded := game.Deal_From_Seed(deal.Seed)
+		for i := range seeded {
+			if !slices.Equal(seeded[i], deal.Hands[i]) {
+				return fmt.Errorf("hands do not match seed")
+			}
+		}
+	}
+
+	g := game.New_Game_State()
+	g.Level = deal.Level
+	g.Team_Levels = deal.Team_Levels
+	g.Tribute_Leader = deal.Leader
+	for _, t := range deal.Tributes {
+		t.Done = false
+		g.Tributes = append(g.Tributes, t)
+	}
+	g.Deal(deal.Hands)
+
+	r.state = g
+	r.hand = nil
+	return nil
+}
+
+func (r *Replayer) check_level_change(ev *Event) error {
+	want := ev.Level_Change
+	if want == nil {
+		return fmt.Errorf("missing level change")
+	}
+	if r.hand == nil {
+		return fmt.Errorf("level change before the hand ended")
+	}
+
+	got := r.hand
+	if !slices.Equal(got.Finish_Order, want.Finish_Order) ||
+		got.Winning_Team != want.Winning_Team ||
+		got.Level_Advance != want.Level_Advance ||
+		got.New_Levels != want.New_Levels ||
+		got.Game_Over != want.Game_Over {
+		return fmt.Errorf("replayed outcome %+v does not match recorded %+v", *got, *want)
+	}
+	return nil
+}
@@ -1,10 +1,20 @@
 package main
 
 import (
+	"context"
+	"guandanbtw/account"
+	"guandanbtw/matches"
+	"guandanbtw/rating"
 	"guandanbtw/room"
+	"guandanbtw/stats"
+	"guandanbtw/store"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 )
 
 func main() {
@@ -13,13 +23,127 @@ func main() {
 		port = "8080"
 	}
 
-	hub := room.New_Hub()
+	room_config := room.Default_Room_Config()
+	env_duration("ROOM_EMPTY_TIMEOUT", &room_config.Empty_Timeout)
+	env_duration("ROOM_OFFLINE_TIMEOUT", &room_config.Offline_Timeout)
+	env_duration("ROOM_IDLE_TIMEOUT", &room_config.Idle_Timeout)
+	queue_config := room.Default_Queue_Config()
+	env_duration("QUEUE_BOT_WAIT", &queue_config.Bot_Wait)
+	delivery_config := room.Default_Delivery_Config()
+	switch policy := room.Delivery_Policy(os.Getenv("DELIVERY_POLICY")); policy {
+	case "":
+	case room.Delivery_Resync, room.Delivery_Disconnect:
+		delivery_config.Policy = policy
+	default:
+		log.Printf("invalid DELIVERY_POLICY %q", policy)
+	}
+	if words := os.Getenv("CHAT_BLOCKED_WORDS"); words != "" {
+		room_config.Chat.Filter = room.New_Word_Filter(strings.Split(words, ","))
+	}
+
+	data_dir := os.Getenv("DATA_DIR")
+	if data_dir == "" {
+		data_dir = "data"
+	}
+
+	disk, err := store.Open_Disk(data_dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	accounts, err := account.Open(disk)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ratings, err := rating.Open(disk)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	tracker := stats.New_Tracker()
+	if err := tracker.Load(disk); err != nil {
+		log.Fatal(err)
+	}
+
+	index := matches.New_Index()
+	if err := index.Load(disk); err != nil {
+		log.Fatal(err)
+	}
+
+	hub := room.New_Hub(room.Hub_Options{
+		Rooms:    room_config,
+		Store:    disk,
+		Accounts: accounts,
+		Ratings:  ratings,
+		Stats:    tracker,
+		Matches:  index,
+		Queue:    queue_config,
+		Delivery: delivery_config,
+	})
+	restored, err := hub.Restore()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if restored > 0 {
+		log.Printf("restored %d rooms from %s", restored, data_dir)
+	}
 	go hub.Run()
 
 	http.HandleFunc("/ws", hub.Handle_Websocket)
+	http.HandleFunc("POST /api/accounts/register", accounts.Handle_Register)
+	http.HandleFunc("POST /api/accounts/login", accounts.Handle_Login)
+	http.HandleFunc("POST /api/accounts/logout", accounts.Handle_Logout)
+	http.HandleFunc("GET /api/accounts/me", accounts.Handle_Me)
+	http.HandleFunc("PATCH /api/accounts/me", accounts.Handle_Update_Me)
+	http.HandleFunc("GET /api/profiles/{username}", accounts.Handle_Profile)
+	http.HandleFunc("GET /api/leaderboard/players", ratings.Handle_Players)
+	http.HandleFunc("GET /api/leaderboard/partnerships", ratings.Handle_Partnerships)
+	http.HandleFunc("GET /api/stats/players", tracker.Handle_Players)
+	http.HandleFunc("GET /api/stats/players.csv", tracker.Handle_CSV)
+	http.HandleFunc("GET /api/stats/players/{player}", tracker.Handle_Player)
+	http.HandleFunc("GET /stats", tracker.Handle_Page)
+	http.HandleFunc("GET /api/rooms", hub.Handle_Rooms)
+	http.HandleFunc("GET /metrics", hub.Handle_Metrics)
+	http.HandleFunc("GET /api/matches", index.Handle_List)
+	http.HandleFunc("GET /api/matches/{match_id}", index.Handle_Match)
+	http.HandleFunc("GET /api/replays/{match_id}", hub.Handle_Replay_List)
+	http.HandleFunc("GET /api/replays/{match_id}/{hand}", hub.Handle_Replay)
+	http.HandleFunc("GET /api/replays/{match_id}/{hand}/solve", hub.Handle_Replay_Solve)
 
 	http.Handle("/", http.FileServer(http.Dir("../client/dist")))
 
-	log.Println("server starting on :" + port)
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+	server := &http.Server{Addr: ":" + port}
+
+	go func() {
+		log.Println("server starting on :" + port)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	<-signals
+
+	log.Println("server shutting down")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	server.Shutdown(ctx)
+	hub.Shutdown()
+	disk.Close()
+}
+
+func env_duration(name string, target *time.Duration) {
+	value := os.Getenv(name)
+	if value == "" {
+		return
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("invalid %s %q: %v", name, value, err)
+		return
+	}
+	*target = d
 }
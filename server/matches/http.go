@@ -0,0 +1,63 @@
+package matches
+
+import (
+	"net/http"
+	"time"
+
+	"guandanbtw/web"
+)
+
+func (x *Index) Handle_List(w http.ResponseWriter, r *http.Request) {
+	f := Filter{
+		Player: r.URL.Query().Get("player"),
+		Offset: web.Query_Int(r, "offset", 0, 0, 1<<30),
+		Limit:  web.Query_Int(r, "limit", 20, 1, 100),
+	}
+
+	var ok bool
+	if f.From, ok = query_time(w, r, "from", false); !ok {
+		return
+	}
+	if f.To, ok = query_time(w, r, "to", true); !ok {
+		return
+	}
+
+	matches, total := x.List(f)
+	web.Write_JSON(w, http.StatusOK, map[string]interface{}{
+		"matches": matches,
+		"total":   total,
+		"offset":  f.Offset,
+		"limit":   f.Limit,
+	})
+}
+
+func (x *Index) Handle_Match(w http.ResponseWriter, r *http.Request) {
+	m, ok := x.Get(r.PathValue("match_id"))
+	if !ok {
+		web.Write_Error(w, http.StatusNotFound, "match not found")
+		return
+	}
+	web.Write_JSON(w, http.StatusOK, m)
+}
+
+// query_time accepts RFC 3339 timestamps or plain dates. A plain date used as
+// an upper bound covers the whole day.
+func query_time(w http.ResponseWriter, r *http.Request, name string, end_of_day bool) (time.Time, bool) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return time.Time{}, true
+	}
+
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, true
+	}
+	t, err := time.Parse(time.DateOnly, v)
+	if err != nil {
+		web.Write_Error(w, http.StatusBadRequest, "invalid "+name+": use YYYY-MM-DD or RFC 3339")
+		return time.Time{}, false
+	}
+	if end_of_day {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, true
+}
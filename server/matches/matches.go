@@ -0,0 +1,228 @@
+package matches
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"guandanbtw/game"
+	"guandanbtw/history"
+)
+
+// Match summarises a match from its archived hands. Teams are taken from the
+// latest hand, since seats can change hands between bots and players.
+type Match struct {
+	Id           string               `json:"id"`
+	Room_Id      string               `json:"room_id"`
+	Started_At   time.Time            `json:"started_at"`
+	Ended_At     time.Time            `json:"ended_at"`
+	Finished     bool                 `json:"finished"`
+	Teams        [2][2]history.Player `json:"teams"`
+	Winning_Team int                  `json:"winning_team"`
+	Final_Levels [2]int               `json:"final_levels"`
+	Hands        []Hand               `json:"hands"`
+	Replays      string               `json:"replays"`
+}
+
+type Hand struct {
+	Hand          int       `json:"hand"`
+	Started_At    time.Time `json:"started_at"`
+	Level         game.Rank `json:"level"`
+	Levels_Before [2]int    `json:"levels_before"`
+	Levels_After  [2]int    `json:"levels_after"`
+	Finish_Order  []int     `json:"finish_order"`
+	Winning_Team  int       `json:"winning_team"`
+	Level_Advance int       `json:"level_advance"`
+	Double_Win    bool      `json:"double_win"`
+	Tributes      []Tribute `json:"tributes"`
+	Replay        string    `json:"replay"`
+}
+
+type Tribute struct {
+	From_Seat int       `json:"from_seat"`
+	To_Seat   int       `json:"to_seat"`
+	Card      game.Card `json:"card"`
+}
+
+// Filter selects finished matches. Player matches an account id or username,
+// and From/To bound the time the match ended.
+type Filter struct {
+	Player string
+	From   time.Time
+	To     time.Time
+	Offset int
+	Limit  int
+}
+
+type entry struct {
+	match   Match
+	players map[string]bool
+}
+
+type Index struct {
+	mu      sync.Mutex
+	matches map[string]*entry
+}
+
+func New_Index() *Index {
+	return &Index{matches: make(map[string]*entry)}
+}
+
+// Load indexes every archived hand.
+func (x *Index) Load(src history.Archive) error {
+	if err := history.Load_All(src, x.Add); err != nil {
+		return fmt.Errorf("matches: %w", err)
+	}
+	return nil
+}
+
+func (x *Index) Add(l *history.Hand_Log) {
+	outcome := l.Outcome()
+	if outcome == nil || len(l.Events) == 0 || l.Events[0].Deal == nil {
+		return
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	e, ok := x.matches[l.Header.Match_Id]
+	if !ok {
+		e = &entry{
+			match: Match{
+				Id:      l.Header.Match_Id,
+				Room_Id: l.Header.Room_Id,
+				Replays: "/api/replays/" + l.Header.Match_Id,
+			},
+			players: make(map[string]bool),
+		}
+		x.matches[l.Header.Match_Id] = e
+	}
+
+	m := &e.match
+	for _, h := range m.Hands {
+		if h.Hand == l.Header.Hand {
+			return
+		}
+	}
+
+	hand := summarise(l, outcome)
+	m.Hands = append(m.Hands, hand)
+	sort.Slice(m.Hands, func(i, j int) bool { return m.Hands[i].Hand < m.Hands[j].Hand })
+
+	for _, p := range l.Header.Players {
+		if p.Is_Bot {
+			continue
+		}
+		e.players[strings.ToLower(p.Id)] = true
+		if p.Username != "" {
+			e.players[strings.ToLower(p.Username)] = true
+		}
+	}
+
+	ended_at := l.Header.Started_At.Add(time.Duration(l.Events[len(l.Events)-1].At_Ms) * time.Millisecond)
+	if m.Started_At.IsZero() || l.Header.Started_At.Before(m.Started_At) {
+		m.Started_At = l.Header.Started_At
+	}
+	if ended_at.After(m.Ended_At) {
+		m.Ended_At = ended_at
+	}
+
+	if m.Hands[len(m.Hands)-1].Hand == l.Header.Hand {
+		for i, p := range l.Header.Players {
+			m.Teams[i%2][i/2] = p
+		}
+		m.Winning_Team = outcome.Winning_Team
+		m.Final_Levels = outcome.New_Levels
+		m.Finished = outcome.Game_Over
+	}
+}
+
+// List returns the finished matches that pass the filter, newest first, along
+// with the number of matches before pagination.
+func (x *Index) List(f Filter) ([]Match, int) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	player := strings.ToLower(f.Player)
+
+	var found []*Match
+	for _, e := range x.matches {
+		m := &e.match
+		if !m.Finished {
+			continue
+		}
+		if player != "" && !e.players[player] {
+			continue
+		}
+		if !f.From.IsZero() && m.Ended_At.Before(f.From) {
+			continue
+		}
+		if !f.To.IsZero() && !m.Ended_At.Before(f.To) {
+			continue
+		}
+		found = append(found, m)
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if !found[i].Ended_At.Equal(found[j].Ended_At) {
+			return found[i].Ended_At.After(found[j].Ended_At)
+		}
+		return found[i].Id < found[j].Id
+	})
+
+	total := len(found)
+	start := min(f.Offset, total)
+	end := min(start+f.Limit, total)
+
+	page := make([]Match, 0, end-start)
+	for _, m := range found[start:end] {
+		page = append(page, m.copy())
+	}
+	return page, total
+}
+
+func (x *Index) Get(match_id string) (Match, bool) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	e, ok := x.matches[match_id]
+	if !ok {
+		return Match{}, false
+	}
+	return e.match.copy(), true
+}
+
+func summarise(l *history.Hand_Log, outcome *history.Level_Change) Hand {
+	deal := l.Events[0].Deal
+	hand := Hand{
+		Hand:          l.Header.Hand,
+		Started_At:    l.Header.Started_At,
+		Level:         deal.Level,
+		Levels_Before: deal.Team_Levels,
+		Levels_After:  outcome.New_Levels,
+		Finish_Order:  outcome.Finish_Order,
+		Winning_Team:  outcome.Winning_Team,
+		Level_Advance: outcome.Level_Advance,
+		Double_Win:    len(outcome.Finish_Order) >= 2 && outcome.Finish_Order[0]%2 == outcome.Finish_Order[1]%2,
+		Tributes:      []Tribute{},
+		Replay:        fmt.Sprintf("/api/replays/%s/%d", l.Header.Match_Id, l.Header.Hand),
+	}
+
+	for _, ev := range l.Events {
+		if ev.Kind == history.Event_Tribute && ev.Tribute != nil {
+			hand.Tributes = append(hand.Tributes, Tribute{
+				From_Seat: ev.Seat,
+				To_Seat:   ev.Tribute.To_Seat,
+				Card:      ev.Tribute.Card,
+			})
+		}
+	}
+	return hand
+}
+
+func (m *Match) copy() Match {
+	c := *m
+	c.Hands = append([]Hand(nil), m.Hands...)
+	return c
+}
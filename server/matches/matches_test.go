@@ -0,0 +1,191 @@
+package matches
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"guandanbtw/history"
+)
+
+var (
+	ann = history.Player{Id: "u_ann", Name: "Ann", Username: "ann"}
+	bo  = history.Player{Id: "u_bo", Name: "Bo", Username: "Bo"}
+	cy  = history.Player{Id: "g_cy", Name: "Cy"}
+	bot = history.Player{Id: "bot_1", Name: "Bot", Is_Bot: true}
+)
+
+// hand_log builds an archived hand of match_id that ends at end.
+func hand_log(match_id string, hand int, players [4]history.Player, end time.Time, game_over bool) *history.Hand_Log {
+	start := end.Add(-10 * time.Minute)
+	return &history.Hand_Log{
+		Header: history.Header{Match_Id: match_id, Room_Id: "ROOM", Hand: hand, Players: players, Started_At: start},
+		Events: []history.Event{
+			{Kind: history.Event_Deal, Seat: -1, Deal: &history.Deal{Level: 2}},
+			{
+				Step:  1,
+				At_Ms: end.Sub(start).Milliseconds(),
+				Kind:  history.Event_Level_Change,
+				Seat:  -1,
+				Level_Change: &history.Level_Change{
+					Finish_Order: []int{0, 2, 1, 3},
+					New_Levels:   [2]int{5, 2},
+					Game_Over:    game_over,
+				},
+			},
+		},
+	}
+}
+
+func at(s string) time.Time {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func test_index() *Index {
+	x := New_Index()
+	x.Add(hand_log("m1", 1, [4]history.Player{ann, bot, bot, bot}, at("2026-03-01T10:00:00Z"), true))
+	x.Add(hand_log("m2", 1, [4]history.Player{bo, cy, bot, bot}, at("2026-03-02T23:30:00Z"), true))
+	x.Add(hand_log("m3", 1, [4]history.Player{ann, bo, bot, bot}, at("2026-03-03T00:30:00Z"), true))
+	x.Add(hand_log("m4", 1, [4]history.Player{ann, bot, bot, bot}, at("2026-03-03T12:00:00Z"), false))
+	x.Add(hand_log("m5", 2, [4]history.Player{cy, bot, bot, bot}, at("2026-03-04T09:00:00Z"), true))
+	x.Add(hand_log("m5", 1, [4]history.Player{cy, bot, bot, bot}, at("2026-03-04T08:00:00Z"), false))
+	return x
+}
+
+func ids(matches []Match) []string {
+	var out []string
+	for _, m := range matches {
+		out = append(out, m.Id)
+	}
+	return out
+}
+
+func TestListFilters(t *testing.T) {
+	x := test_index()
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{"all finished matches, newest first", Filter{}, []string{"m5", "m3", "m2", "m1"}},
+		{"by username", Filter{Player: "ann"}, []string{"m3", "m1"}},
+		{"username ignores case", Filter{Player: "BO"}, []string{"m3", "m2"}},
+		{"by account id", Filter{Player: "u_bo"}, []string{"m3", "m2"}},
+		{"by guest id", Filter{Player: "g_cy"}, []string{"m5", "m2"}},
+		{"bots are not players", Filter{Player: "bot_1"}, nil},
+		{"unknown player", Filter{Player: "nobody"}, nil},
+		{"from is inclusive", Filter{From: at("2026-03-02T23:30:00Z")}, []string{"m5", "m3", "m2"}},
+		{"to is exclusive", Filter{To: at("2026-03-03T00:30:00Z")}, []string{"m2", "m1"}},
+		{"from and to", Filter{From: at("2026-03-02T00:00:00Z"), To: at("2026-03-04T00:00:00Z")}, []string{"m3", "m2"}},
+		{"player and dates", Filter{Player: "ann", From: at("2026-03-02T00:00:00Z")}, []string{"m3"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.filter.Limit = 20
+			got, total := x.List(tt.filter)
+			if !slices.Equal(ids(got), tt.want) || total != len(tt.want) {
+				t.Fatalf("List = %v (total %d), want %v", ids(got), total, tt.want)
+			}
+		})
+	}
+}
+
+func TestListPagination(t *testing.T) {
+	x := test_index()
+
+	tests := []struct {
+		offset int
+		limit  int
+		want   []string
+	}{
+		{0, 2, []string{"m5", "m3"}},
+		{2, 2, []string{"m2", "m1"}},
+		{3, 2, []string{"m1"}},
+		{4, 2, nil},
+		{10, 2, nil},
+		{1, 100, []string{"m3", "m2", "m1"}},
+	}
+
+	for _, tt := range tests {
+		got, total := x.List(Filter{Offset: tt.offset, Limit: tt.limit})
+		if !slices.Equal(ids(got), tt.want) || total != 4 {
+			t.Errorf("offset %d limit %d: got %v (total %d), want %v (total 4)", tt.offset, tt.limit, ids(got), total, tt.want)
+		}
+	}
+}
+
+func TestAddSummarisesHands(t *testing.T) {
+	x := test_index()
+	x.Add(hand_log("m5", 2, [4]history.Player{cy, bot, bot, bot}, at("2026-03-04T09:00:00Z"), true))
+
+	m, ok := x.Get("m5")
+	if !ok {
+		t.Fatal("m5 not indexed")
+	}
+	if len(m.Hands) != 2 || m.Hands[0].Hand != 1 || m.Hands[1].Hand != 2 {
+		t.Fatalf("hands = %+v, want 1 and 2 once each", m.Hands)
+	}
+	if !m.Finished || !m.Started_At.Equal(at("2026-03-04T07:50:00Z")) || !m.Ended_At.Equal(at("2026-03-04T09:00:00Z")) {
+		t.Fatalf("match = %+v", m)
+	}
+	if !m.Hands[0].Double_Win || m.Teams[0][0].Id != cy.Id {
+		t.Fatalf("first hand = %+v, teams = %+v", m.Hands[0], m.Teams)
+	}
+	if _, ok := x.Get("missing"); ok {
+		t.Fatal("found a match that was never added")
+	}
+}
+
+func TestHandleList(t *testing.T) {
+	x := test_index()
+
+	tests := []struct {
+		query  string
+		status int
+		want   []string
+	}{
+		{"", http.StatusOK, []string{"m5", "m3", "m2", "m1"}},
+		{"?player=ann", http.StatusOK, []string{"m3", "m1"}},
+		// A plain date as the upper bound covers the whole day, so the
+		// match ending at 23:30 on the 2nd is in.
+		{"?to=2026-03-02", http.StatusOK, []string{"m2", "m1"}},
+		{"?from=2026-03-03", http.StatusOK, []string{"m5", "m3"}},
+		{"?from=2026-03-02&to=2026-03-02", http.StatusOK, []string{"m2"}},
+		{"?to=2026-03-02T23:00:00Z", http.StatusOK, []string{"m1"}},
+		{"?limit=1&offset=1", http.StatusOK, []string{"m3"}},
+		{"?from=yesterday", http.StatusBadRequest, nil},
+		{"?to=2026-13-01", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		x.Handle_List(w, httptest.NewRequest(http.MethodGet, "/api/matches"+tt.query, nil))
+		if w.Code != tt.status {
+			t.Errorf("%q: status = %d, want %d", tt.query, w.Code, tt.status)
+			continue
+		}
+		if tt.status != http.StatusOK {
+			continue
+		}
+
+		var body struct {
+			Matches []Match `json:"matches"`
+			Total   int     `json:"total"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatalf("%q: %v", tt.query, err)
+		}
+		if !slices.Equal(ids(body.Matches), tt.want) {
+			t.Errorf("%q: matches = %v, want %v", tt.query, ids(body.Matches), tt.want)
+		}
+	}
+}
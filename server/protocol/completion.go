@@ -0,0 +1,40 @@
+package protocol
+
+import "guandanbtw/game"
+
+// Complete_Selection_Payload asks which plays contain the selected cards.
+type Complete_Selection_Payload struct {
+	Card_Ids []int `json:"card_ids"`
+}
+
+// Selection_Completion is a play containing the selection; Add_Ids are the
+// cards it adds to it.
+type Selection_Completion struct {
+	Combo_Type string `json:"combo_type"`
+	Add_Ids    []int  `json:"add_ids"`
+}
+
+// Selection_Completions_Payload answers complete_selection for Card_Ids,
+// fewest added cards first.
+type Selection_Completions_Payload struct {
+	Card_Ids    []int                  `json:"card_ids"`
+	Completions []Selection_Completion `json:"completions"`
+}
+
+func Project_Completions(card_ids []int, completions []game.Completion) Selection_Completions_Payload {
+	payload := Selection_Completions_Payload{
+		Card_Ids:    card_ids,
+		Completions: make([]Selection_Completion, 0, len(completions)),
+	}
+	for _, c := range completions {
+		add := make([]int, len(c.Add))
+		for i, card := range c.Add {
+			add[i] = card.Id
+		}
+		payload.Completions = append(payload.Completions, Selection_Completion{
+			Combo_Type: c.Play.Type.String(),
+			Add_Ids:    add,
+		})
+	}
+	return payload
+}
@@ -0,0 +1,428 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	Max_Card_Id     = 107
+	Max_Play_Cards  = 27
+	Max_Name_Length = 32
+	Max_Text_Length = 1000
+
+	Max_Request_Id_Length = 64
+	max_id_length         = 64
+	max_secret_bytes      = 128
+)
+
+const (
+	Error_Malformed    = "malformed"
+	Error_Invalid      = "invalid"
+	Error_Unknown_Type = "unknown_type"
+)
+
+// Client_Message is a message as read off the wire. Its payload is decoded
+// only once the type is known.
+type Client_Message struct {
+	Type       Msg_Type        `json:"type"`
+	Request_Id string          `json:"request_id,omitempty"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// Payload is implemented by everything a client may send.
+type Payload interface {
+	Validate() error
+}
+
+// Decode_Error says which message and, where known, which field was wrong.
+type Decode_Error struct {
+	Type   Msg_Type
+	Code   string
+	Field  string
+	Reason string
+}
+
+func (e *Decode_Error) Error() string {
+	if e.Field != "" {
+		return fmt.Sprintf("%s: %s: %s", e.Type, e.Field, e.Reason)
+	}
+	return fmt.Sprintf("%s: %s", e.Type, e.Reason)
+}
+
+// Field_Error is returned by Validate and names the bad field.
+type Field_Error struct {
+	Field  string
+	Reason string
+}
+
+func (e *Field_Error) Error() string {
+	return e.Field + ": " + e.Reason
+}
+
+func field_error(field string, format string, args ...any) error {
+	return &Field_Error{Field: field, Reason: fmt.Sprintf(format, args...)}
+}
+
+type Empty_Payload struct{}
+
+func (Empty_Payload) Validate() error { return nil }
+
+type payload_spec struct {
+	new      func() Payload
+	required []string
+}
+
+func spec[T any, P interface {
+	*T
+	Payload
+}](required ...string) payload_spec {
+	return payload_spec{new: func() Payload { return P(new(T)) }, required: required}
+}
+
+var client_payloads = map[Msg_Type]payload_spec{
+	Msg_Create_Room:        spec[Create_Room_Payload](),
+	Msg_Join_Room:          spec[Join_Room_Payload]("room_id"),
+	Msg_Rejoin_Room:        spec[Rejoin_Room_Payload]("room_id", "player_id", "session_token"),
+	Msg_Spectate_Room:      spec[Spectate_Room_Payload]("room_id"),
+	Msg_Play_Cards:         spec[Play_Cards_Payload]("card_ids"),
+	Msg_Pass:               spec[Empty_Payload](),
+	Msg_Tribute_Give:       spec[Tribute_Give_Payload]("card_id"),
+	Msg_Fill_Bots:          spec[Empty_Payload](),
+	Msg_Choose_Seat:        spec[Choose_Seat_Payload]("seat"),
+	Msg_Choose_Team:        spec[Choose_Team_Payload]("team"),
+	Msg_Swap_Seats:         spec[Swap_Seats_Payload]("seat_a", "seat_b"),
+	Msg_Set_Ready:          spec[Set_Ready_Payload]("ready"),
+	Msg_Kick_Player:        spec[Kick_Player_Payload]("player_id"),
+	Msg_Add_Bot:            spec[Add_Bot_Payload]("seat"),
+	Msg_Remove_Bot:         spec[Remove_Bot_Payload]("seat"),
+	Msg_Start_Game:         spec[Empty_Payload](),
+	Msg_Set_Quiet:          spec[Set_Quiet_Payload]("quiet"),
+	Msg_Set_Rated:          spec[Set_Rated_Payload]("rated"),
+	Msg_Set_Tracker:        spec[Set_Tracker_Payload]("card_tracker"),
+	Msg_Room_Settings:      spec[Room_Settings_Payload]("visibility"),
+	Msg_List_Rooms:         spec[Empty_Payload](),
+	Msg_Rematch_Vote:       spec[Rematch_Vote_Payload]("vote"),
+	Msg_Set_Series:         spec[Set_Series_Payload]("best_of"),
+	Msg_Queue_Join:         spec[Queue_Join_Payload](),
+	Msg_Queue_Leave:        spec[Empty_Payload](),
+	Msg_Chat:               spec[Chat_Payload]("text"),
+	Msg_Emote:              spec[Emote_Payload]("emote"),
+	Msg_Open_Replay:        spec[Open_Replay_Payload]("match_id", "hand"),
+	Msg_Join_Replay:        spec[Join_Replay_Payload]("replay_id"),
+	Msg_Leave_Replay:       spec[Empty_Payload](),
+	Msg_Replay_Control:     spec[Replay_Control_Payload]("action"),
+	Msg_Resync:             spec[Empty_Payload](),
+	Msg_Request_Snapshot:   spec[Empty_Payload](),
+	Msg_Hint:               spec[Empty_Payload](),
+	Msg_Complete_Selection: spec[Complete_Selection_Payload]("card_ids"),
+}
+
+// Decode strictly decodes a client payload: unknown fields, missing required
+// fields, wrong types and trailing data are all rejected, and the result is
+// validated.
+func Decode(msg_type Msg_Type, raw json.RawMessage) (Payload, error) {
+	spec, ok := client_payloads[msg_type]
+	if !ok {
+		return nil, &Decode_Error{Type: msg_type, Code: Error_Unknown_Type, Reason: "unknown message type"}
+	}
+
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		raw = []byte("{}")
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, &Decode_Error{Type: msg_type, Code: Error_Malformed, Reason: "payload must be an object"}
+	}
+	for _, name := range spec.required {
+		if v, ok := fields[name]; !ok || bytes.Equal(v, []byte("null")) {
+			return nil, &Decode_Error{Type: msg_type, Code: Error_Malformed, Field: name, Reason: "is required"}
+		}
+	}
+
+	payload := spec.new()
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(payload); err != nil {
+		return nil, decode_failure(msg_type, err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, &Decode_Error{Type: msg_type, Code: Error_Malformed, Reason: "trailing data after payload"}
+	}
+
+	if err := payload.Validate(); err != nil {
+		out := &Decode_Error{Type: msg_type, Code: Error_Invalid, Reason: err.Error()}
+		var fe *Field_Error
+		if errors.As(err, &fe) {
+			out.Field, out.Reason = fe.Field, fe.Reason
+		}
+		return nil, out
+	}
+	return payload, nil
+}
+
+func decode_failure(msg_type Msg_Type, err error) error {
+	out := &Decode_Error{Type: msg_type, Code: Error_Malformed, Reason: err.Error()}
+
+	var type_err *json.UnmarshalTypeError
+	if errors.As(err, &type_err) {
+		out.Field = type_err.Field
+		out.Reason = fmt.Sprintf("must be %s, not %s", type_err.Type, type_err.Value)
+	}
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		out.Field = strings.Trim(field, `"`)
+		out.Reason = "is not allowed"
+	}
+	return out
+}
+
+func validate_name(field string, name string) error {
+	if name == "" {
+		return nil
+	}
+	if strings.TrimSpace(name) == "" {
+		return field_error(field, "must not be blank")
+	}
+	if utf8.RuneCountInString(name) > Max_Name_Length {
+		return field_error(field, "must be at most %d characters", Max_Name_Length)
+	}
+	if strings.IndexFunc(name, unicode.IsControl) != -1 {
+		return field_error(field, "must not contain control characters")
+	}
+	return nil
+}
+
+func validate_id(field string, id string) error {
+	if strings.TrimSpace(id) == "" {
+		return field_error(field, "must not be empty")
+	}
+	if len(id) > max_id_length {
+		return field_error(field, "must be at most %d bytes", max_id_length)
+	}
+	return nil
+}
+
+func validate_secret(field string, secret string) error {
+	if len(secret) > max_secret_bytes {
+		return field_error(field, "must be at most %d bytes", max_secret_bytes)
+	}
+	return nil
+}
+
+func validate_seat(field string, seat int) error {
+	if seat < 0 || seat > 3 {
+		return field_error(field, "must be between 0 and 3")
+	}
+	return nil
+}
+
+func validate_card_id(field string, id int) error {
+	if id < 0 || id > Max_Card_Id {
+		return field_error(field, "must be between 0 and %d", Max_Card_Id)
+	}
+	return nil
+}
+
+func (p *Create_Room_Payload) Validate() error {
+	if err := validate_name("player_name", p.Player_Name); err != nil {
+		return err
+	}
+	if p.Visibility != "" && p.Visibility != Visibility_Public && p.Visibility != Visibility_Private {
+		return field_error("visibility", "must be %q or %q", Visibility_Public, Visibility_Private)
+	}
+	return validate_secret("password", p.Password)
+}
+
+func (p *Join_Room_Payload) Validate() error {
+	if err := validate_id("room_id", p.Room_Id); err != nil {
+		return err
+	}
+	if err := validate_name("player_name", p.Player_Name); err != nil {
+		return err
+	}
+	if err := validate_secret("password", p.Password); err != nil {
+		return err
+	}
+	return validate_secret("invite_token", p.Invite_Token)
+}
+
+func (p *Rejoin_Room_Payload) Validate() error {
+	if err := validate_id("room_id", p.Room_Id); err != nil {
+		return err
+	}
+	if err := validate_id("player_id", p.Player_Id); err != nil {
+		return err
+	}
+	return validate_id("session_token", p.Session_Token)
+}
+
+func (p *Spectate_Room_Payload) Validate() error {
+	if err := validate_id("room_id", p.Room_Id); err != nil {
+		return err
+	}
+	if err := validate_name("player_name", p.Player_Name); err != nil {
+		return err
+	}
+	if err := validate_secret("password", p.Password); err != nil {
+		return err
+	}
+	return validate_secret("invite_token", p.Invite_Token)
+}
+
+func (p *Play_Cards_Payload) Validate() error {
+	if len(p.Card_Ids) == 0 {
+		return field_error("card_ids", "must not be empty")
+	}
+	if len(p.Card_Ids) > Max_Play_Cards {
+		return field_error("card_ids", "must hold at most %d cards", Max_Play_Cards)
+	}
+
+	seen := make(map[int]bool, len(p.Card_Ids))
+	for _, id := range p.Card_Ids {
+		if err := validate_card_id("card_ids", id); err != nil {
+			return err
+		}
+		if seen[id] {
+			return field_error("card_ids", "card %d is listed twice", id)
+		}
+		seen[id] = true
+	}
+	return nil
+}
+
+func (p *Tribute_Give_Payload) Validate() error {
+	return validate_card_id("card_id", p.Card_Id)
+}
+
+func (p *Choose_Seat_Payload) Validate() error {
+	return validate_seat("seat", p.Seat)
+}
+
+func (p *Choose_Team_Payload) Validate() error {
+	if p.Team != 0 && p.Team != 1 {
+		return field_error("team", "must be 0 or 1")
+	}
+	return nil
+}
+
+func (p *Swap_Seats_Payload) Validate() error {
+	if err := validate_seat("seat_a", p.Seat_A); err != nil {
+		return err
+	}
+	if err := validate_seat("seat_b", p.Seat_B); err != nil {
+		return err
+	}
+	if p.Seat_A == p.Seat_B {
+		return field_error("seat_b", "must differ from seat_a")
+	}
+	return nil
+}
+
+func (p *Set_Ready_Payload) Validate() error { return nil }
+
+func (p *Set_Quiet_Payload) Validate() error { return nil }
+
+func (p *Set_Rated_Payload) Validate() error { return nil }
+
+func (p *Set_Tracker_Payload) Validate() error { return nil }
+
+func (p *Complete_Selection_Payload) Validate() error {
+	return (&Play_Cards_Payload{Card_Ids: p.Card_Ids}).Validate()
+}
+
+func (p *Kick_Player_Payload) Validate() error {
+	return validate_id("player_id", p.Player_Id)
+}
+
+func (p *Add_Bot_Payload) Validate() error {
+	return validate_seat("seat", p.Seat)
+}
+
+func (p *Remove_Bot_Payload) Validate() error {
+	return validate_seat("seat", p.Seat)
+}
+
+func (p *Room_Settings_Payload) Validate() error {
+	if p.Visibility != Visibility_Public && p.Visibility != Visibility_Private {
+		return field_error("visibility", "must be %q or %q", Visibility_Public, Visibility_Private)
+	}
+	if p.Password != nil {
+		return validate_secret("password", *p.Password)
+	}
+	return nil
+}
+
+func (p *Rematch_Vote_Payload) Validate() error { return nil }
+
+func (p *Set_Series_Payload) Validate() error {
+	if p.Best_Of < 1 || p.Best_Of%2 == 0 {
+		return field_error("best_of", "must be a positive odd number")
+	}
+	return nil
+}
+
+func (p *Queue_Join_Payload) Validate() error {
+	if err := validate_name("player_name", p.Player_Name); err != nil {
+		return err
+	}
+	if p.Pair && p.Party_Code != "" {
+		return field_error("party_code", "cannot be combined with pair")
+	}
+	if len(p.Party_Code) > max_id_length {
+		return field_error("party_code", "must be at most %d bytes", max_id_length)
+	}
+	return nil
+}
+
+func (p *Chat_Payload) Validate() error {
+	if strings.TrimSpace(p.Text) == "" {
+		return field_error("text", "must not be empty")
+	}
+	if utf8.RuneCountInString(p.Text) > Max_Text_Length {
+		return field_error("text", "must be at most %d characters", Max_Text_Length)
+	}
+	return nil
+}
+
+func (p *Emote_Payload) Validate() error {
+	return validate_id("emote", p.Emote)
+}
+
+func (p *Open_Replay_Payload) Validate() error {
+	if err := validate_id("match_id", p.Match_Id); err != nil {
+		return err
+	}
+	if p.Hand < 1 {
+		return field_error("hand", "must be at least 1")
+	}
+	return nil
+}
+
+func (p *Join_Replay_Payload) Validate() error {
+	return validate_id("replay_id", p.Replay_Id)
+}
+
+func (p *Replay_Control_Payload) Validate() error {
+	switch p.Action {
+	case Replay_Play, Replay_Pause, Replay_Step_Forward, Replay_Step_Back:
+	case Replay_Seek:
+		if p.Step < 0 {
+			return field_error("step", "must not be negative")
+		}
+	case Replay_Speed:
+		if p.Speed <= 0 {
+			return field_error("speed", "must be positive")
+		}
+	default:
+		return field_error("action", "unknown replay action %q", p.Action)
+	}
+	return nil
+}
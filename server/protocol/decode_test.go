@@ -0,0 +1,73 @@
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		kind    Msg_Type
+		payload string
+		want    Payload
+		code    string
+		field   string
+	}{
+		{"play", Msg_Play_Cards, `{"card_ids":[1,2,3]}`, &Play_Cards_Payload{Card_Ids: []int{1, 2, 3}}, "", ""},
+		{"empty payload", Msg_Pass, ``, &Empty_Payload{}, "", ""},
+		{"null payload", Msg_Pass, `null`, &Empty_Payload{}, "", ""},
+		{"seat zero", Msg_Choose_Seat, `{"seat":0}`, &Choose_Seat_Payload{Seat: 0}, "", ""},
+		{"unknown type", "make_coffee", `{}`, nil, Error_Unknown_Type, ""},
+		{"not an object", Msg_Play_Cards, `[1,2]`, nil, Error_Malformed, ""},
+		{"missing field", Msg_Choose_Seat, `{}`, nil, Error_Malformed, "seat"},
+		{"null field", Msg_Choose_Seat, `{"seat":null}`, nil, Error_Malformed, "seat"},
+		{"unknown field", Msg_Choose_Seat, `{"seat":1,"extra":true}`, nil, Error_Malformed, "extra"},
+		{"wrong type", Msg_Choose_Seat, `{"seat":"1"}`, nil, Error_Malformed, "seat"},
+		{"trailing data", Msg_Choose_Seat, `{"seat":1} {}`, nil, Error_Malformed, ""},
+		{"unknown field on empty", Msg_Pass, `{"x":1}`, nil, Error_Malformed, "x"},
+		{"seat out of range", Msg_Choose_Seat, `{"seat":4}`, nil, Error_Invalid, "seat"},
+		{"no cards", Msg_Play_Cards, `{"card_ids":[]}`, nil, Error_Invalid, "card_ids"},
+		{"card out of range", Msg_Play_Cards, `{"card_ids":[108]}`, nil, Error_Invalid, "card_ids"},
+		{"card twice", Msg_Play_Cards, `{"card_ids":[5,5]}`, nil, Error_Invalid, "card_ids"},
+		{"blank chat", Msg_Chat, `{"text":"   "}`, nil, Error_Invalid, "text"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decode(tt.kind, json.RawMessage(tt.payload))
+			if tt.code == "" {
+				if err != nil {
+					t.Fatalf("Decode: %v", err)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Fatalf("Decode = %#v, want %#v", got, tt.want)
+				}
+				return
+			}
+
+			var de *Decode_Error
+			if !errors.As(err, &de) {
+				t.Fatalf("err = %v, want a Decode_Error", err)
+			}
+			if de.Code != tt.code || de.Field != tt.field {
+				t.Fatalf("err = %+v, want code %q field %q", de, tt.code, tt.field)
+			}
+		})
+	}
+}
+
+func TestEveryPayloadSpecDecodes(t *testing.T) {
+	for kind, spec := range client_payloads {
+		if spec.new() == nil {
+			t.Errorf("%s: spec makes no payload", kind)
+		}
+		for _, field := range spec.required {
+			if _, err := Decode(kind, json.RawMessage(`{}`)); err == nil {
+				t.Errorf("%s: decoded without required field %s", kind, field)
+			}
+		}
+	}
+}
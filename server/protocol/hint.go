@@ -0,0 +1,30 @@
+package protocol
+
+import "guandanbtw/game"
+
+// Hint_Suggestion is one ranked suggestion, best first; a pass has no cards.
+type Hint_Suggestion struct {
+	Kind       game.Hint_Kind `json:"kind"`
+	Card_Ids   []int          `json:"card_ids,omitempty"`
+	Combo_Type string         `json:"combo_type,omitempty"`
+	Reason     string         `json:"reason"`
+}
+
+type Hint_Suggestions_Payload struct {
+	Suggestions []Hint_Suggestion `json:"suggestions"`
+}
+
+func Project_Hints(hints []game.Hint) Hint_Suggestions_Payload {
+	payload := Hint_Suggestions_Payload{Suggestions: make([]Hint_Suggestion, 0, len(hints))}
+	for _, h := range hints {
+		s := Hint_Suggestion{Kind: h.Kind, Reason: h.Reason}
+		if h.Kind != game.Hint_Pass {
+			s.Combo_Type = h.Play.Type.String()
+			for _, c := range h.Play.Cards {
+				s.Card_Ids = append(s.Card_Ids, c.Id)
+			}
+		}
+		payload.Suggestions = append(payload.Suggestions, s)
+	}
+	return payload
+}
@@ -1,6 +1,11 @@
 package protocol
 
-import "guandanbtw/game"
+//go:generate go run ../cmd/tsgen
+
+import (
+	"guandanbtw/game"
+	"guandanbtw/history"
+)
 
 type Msg_Type string
 
@@ -23,35 +28,279 @@ const (
 	Msg_Player_Joined Msg_Type = "player_joined"
 	Msg_Player_Left   Msg_Type = "player_left"
 	Msg_Fill_Bots     Msg_Type = "fill_bots"
+	Msg_Choose_Seat   Msg_Type = "choose_seat"
+	Msg_Choose_Team   Msg_Type = "choose_team"
+	Msg_Swap_Seats    Msg_Type = "swap_seats"
+	Msg_Set_Ready     Msg_Type = "set_ready"
+	Msg_Kick_Player   Msg_Type = "kick_player"
+	Msg_Kicked        Msg_Type = "kicked"
+	Msg_Add_Bot       Msg_Type = "add_bot"
+	Msg_Remove_Bot    Msg_Type = "remove_bot"
+	Msg_Start_Game    Msg_Type = "start_game"
+	Msg_Room_Closed   Msg_Type = "room_closed"
+	Msg_Rejoin_Room   Msg_Type = "rejoin_room"
+	Msg_Spectate_Room Msg_Type = "spectate_room"
+	Msg_Set_Quiet     Msg_Type = "set_quiet"
+	Msg_Set_Rated     Msg_Type = "set_rated"
+	Msg_Set_Tracker   Msg_Type = "set_card_tracker"
+	Msg_Room_Settings Msg_Type = "room_settings"
+	Msg_List_Rooms    Msg_Type = "list_rooms"
+	Msg_Room_List     Msg_Type = "room_list"
+
+	Msg_Ack    Msg_Type = "ack"
+	Msg_Resync Msg_Type = "resync"
+
+	Msg_Request_Snapshot Msg_Type = "request_snapshot"
+	Msg_Game_Snapshot    Msg_Type = "game_snapshot"
+
+	Msg_Hint             Msg_Type = "hint"
+	Msg_Hint_Suggestions Msg_Type = "hint_suggestions"
+	Msg_Card_Tracker     Msg_Type = "card_tracker"
+
+	Msg_Complete_Selection    Msg_Type = "complete_selection"
+	Msg_Selection_Completions Msg_Type = "selection_completions"
+
+	Msg_Rematch_Vote Msg_Type = "rematch_vote"
+	Msg_Set_Series   Msg_Type = "set_series"
+
+	Msg_Queue_Join   Msg_Type = "queue_join"
+	Msg_Queue_Leave  Msg_Type = "queue_leave"
+	Msg_Queue_Status Msg_Type = "queue_status"
+
+	Msg_Chat         Msg_Type = "chat"
+	Msg_Emote        Msg_Type = "emote"
+	Msg_Chat_Message Msg_Type = "chat_message"
+
+	Msg_Open_Replay    Msg_Type = "open_replay"
+	Msg_Join_Replay    Msg_Type = "join_replay"
+	Msg_Leave_Replay   Msg_Type = "leave_replay"
+	Msg_Replay_Control Msg_Type = "replay_control"
+	Msg_Replay_State   Msg_Type = "replay_state"
 )
 
+// Message is what the server sends. Request_Id echoes the client request an
+// ack or error answers. Seq is the number of the room's latest event, where
+// an event is a message sent to every member of the room; a client that sees
+// it jump by more than one has missed something and should resync.
 type Message struct {
-	Type    Msg_Type    `json:"type"`
-	Payload interface{} `json:"payload"`
+	Type       Msg_Type    `json:"type"`
+	Request_Id string      `json:"request_id,omitempty"`
+	Seq        int64       `json:"seq,omitempty"`
+	Payload    interface{} `json:"payload"`
+}
+
+// server_payloads records the payload each server message carries. Nothing
+// reads it at run time; cmd/tsgen uses it to type the messages the client
+// receives, so keep it in step with what the room package sends.
+var server_payloads = map[Msg_Type]any{
+	Msg_Ack:                   Ack_Payload{},
+	Msg_Error:                 Error_Payload{},
+	Msg_Room_State:            Room_State_Payload{},
+	Msg_Room_List:             Room_List_Payload{},
+	Msg_Room_Closed:           Room_Closed_Payload{},
+	Msg_Kicked:                Error_Payload{},
+	Msg_Player_Left:           Player_Info{},
+	Msg_Deal_Cards:            Deal_Cards_Payload{},
+	Msg_Turn:                  Turn_Payload{},
+	Msg_Play_Made:             Play_Made_Payload{},
+	Msg_Tribute:               Tribute_Payload{},
+	Msg_Tribute_Recv:          Tribute_Recv_Payload{},
+	Msg_Hand_End:              Hand_End_Payload{},
+	Msg_Game_End:              Game_End_Payload{},
+	Msg_Game_Snapshot:         Game_Snapshot_Payload{},
+	Msg_Hint_Suggestions:      Hint_Suggestions_Payload{},
+	Msg_Selection_Completions: Selection_Completions_Payload{},
+	Msg_Card_Tracker:          Card_Tracker_Payload{},
+	Msg_Queue_Status:          Queue_Status_Payload{},
+	Msg_Chat_Message:          Chat_Message_Payload{},
+	Msg_Replay_State:          Replay_State_Payload{},
+}
+
+type Ack_Payload struct {
+	Message_Type Msg_Type `json:"message_type"`
 }
 
 type Join_Room_Payload struct {
-	Room_Id     string `json:"room_id"`
-	Player_Name string `json:"player_name"`
+	Room_Id      string `json:"room_id"`
+	Player_Name  string `json:"player_name"`
+	Password     string `json:"password,omitempty"`
+	Invite_Token string `json:"invite_token,omitempty"`
 }
 
+type Rejoin_Room_Payload struct {
+	Room_Id       string `json:"room_id"`
+	Player_Id     string `json:"player_id"`
+	Session_Token string `json:"session_token"`
+}
+
+const (
+	Visibility_Public  = "public"
+	Visibility_Private = "private"
+)
+
 type Create_Room_Payload struct {
 	Player_Name string `json:"player_name"`
+	Visibility  string `json:"visibility,omitempty"`
+	Password    string `json:"password,omitempty"`
+}
+
+type Spectate_Room_Payload struct {
+	Room_Id      string `json:"room_id"`
+	Player_Name  string `json:"player_name"`
+	Password     string `json:"password,omitempty"`
+	Invite_Token string `json:"invite_token,omitempty"`
+}
+
+// Room_Settings_Payload changes a room's visibility. A nil password leaves it
+// unchanged and an empty one removes it.
+type Room_Settings_Payload struct {
+	Visibility string  `json:"visibility"`
+	Password   *string `json:"password,omitempty"`
+}
+
+// Room_Rules are the table rules shown in the room list. Matches in a rated
+// room count for the ladder; hints and the card tracker are only offered in
+// unrated ones.
+type Room_Rules struct {
+	Quiet_Hands  bool `json:"quiet_hands"`
+	Rated        bool `json:"rated"`
+	Card_Tracker bool `json:"card_tracker"`
+}
+
+type Room_Listing struct {
+	Room_Id      string     `json:"room_id"`
+	Host_Name    string     `json:"host_name"`
+	Seats_Filled int        `json:"seats_filled"`
+	Bots         int        `json:"bots"`
+	Spectators   int        `json:"spectators"`
+	Status       string     `json:"status"`
+	Rules        Room_Rules `json:"rules"`
+}
+
+type Room_List_Payload struct {
+	Rooms []Room_Listing `json:"rooms"`
+}
+
+// Queue_Join_Payload enters quick play alone, as the first of a pair (Pair,
+// which hands back a party code) or as the partner holding that code.
+type Queue_Join_Payload struct {
+	Player_Name string `json:"player_name"`
+	Pair        bool   `json:"pair,omitempty"`
+	Party_Code  string `json:"party_code,omitempty"`
+}
+
+type Queue_Status_Payload struct {
+	Queued              bool   `json:"queued"`
+	Party_Code          string `json:"party_code,omitempty"`
+	Waiting_For_Partner bool   `json:"waiting_for_partner,omitempty"`
+	Partner_Name        string `json:"partner_name,omitempty"`
+	Position            int    `json:"position,omitempty"`
+	Queue_Size          int    `json:"queue_size,omitempty"`
+	Waited_Secs         int    `json:"waited_secs,omitempty"`
+	Estimated_Wait_Secs int    `json:"estimated_wait_secs,omitempty"`
+	Matched_Room        string `json:"matched_room,omitempty"`
 }
 
 type Room_State_Payload struct {
-	Room_Id     string        `json:"room_id"`
-	Players     []Player_Info `json:"players"`
-	Game_Active bool          `json:"game_active"`
-	Your_Id     string        `json:"your_id"`
+	Room_Id       string           `json:"room_id"`
+	Players       []Player_Info    `json:"players"`
+	Spectators    []Spectator_Info `json:"spectators"`
+	Game_Active   bool             `json:"game_active"`
+	Status        string           `json:"status"`
+	Your_Id       string           `json:"your_id"`
+	Host_Id       string           `json:"host_id"`
+	Session_Token string           `json:"session_token,omitempty"`
+	Is_Spectator  bool             `json:"is_spectator"`
+	Quiet_Hands   bool             `json:"quiet_hands"`
+	Rated         bool             `json:"rated"`
+	Card_Tracker  bool             `json:"card_tracker"`
+	Visibility    string           `json:"visibility"`
+	Has_Password  bool             `json:"has_password"`
+	Invite_Token  string           `json:"invite_token,omitempty"`
+	Series        Series_State     `json:"series"`
+	Rematch_Votes []Rematch_Vote   `json:"rematch_votes,omitempty"`
 }
 
-type Player_Info struct {
+// Series_State counts match wins per team in a best-of-N series. Best_Of 1
+// is a single match.
+type Series_State struct {
+	Best_Of int    `json:"best_of"`
+	Wins    [2]int `json:"wins"`
+	Game    int    `json:"game"`
+	Winner  int    `json:"winner"`
+}
+
+type Rematch_Vote struct {
+	Player_Id     string `json:"player_id"`
+	Swap_Partners bool   `json:"swap_partners"`
+}
+
+type Rematch_Vote_Payload struct {
+	Vote          bool `json:"vote"`
+	Swap_Partners bool `json:"swap_partners"`
+}
+
+type Set_Series_Payload struct {
+	Best_Of int `json:"best_of"`
+}
+
+type Spectator_Info struct {
 	Id       string `json:"id"`
 	Name     string `json:"name"`
-	Seat     int    `json:"seat"`
-	Team     int    `json:"team"`
-	Is_Ready bool   `json:"is_ready"`
+	Username string `json:"username,omitempty"`
+}
+
+type Player_Info struct {
+	Id         string `json:"id"`
+	Name       string `json:"name"`
+	Seat       int    `json:"seat"`
+	Team       int    `json:"team"`
+	Is_Ready   bool   `json:"is_ready"`
+	Is_Bot     bool   `json:"is_bot"`
+	Is_Host    bool   `json:"is_host"`
+	Is_Offline bool   `json:"is_offline"`
+	Username   string `json:"username,omitempty"`
+}
+
+type Choose_Seat_Payload struct {
+	Seat int `json:"seat"`
+}
+
+type Choose_Team_Payload struct {
+	Team int `json:"team"`
+}
+
+type Swap_Seats_Payload struct {
+	Seat_A int `json:"seat_a"`
+	Seat_B int `json:"seat_b"`
+}
+
+type Set_Ready_Payload struct {
+	Ready bool `json:"ready"`
+}
+
+type Set_Quiet_Payload struct {
+	Quiet bool `json:"quiet"`
+}
+
+type Set_Rated_Payload struct {
+	Rated bool `json:"rated"`
+}
+
+type Set_Tracker_Payload struct {
+	Card_Tracker bool `json:"card_tracker"`
+}
+
+type Kick_Player_Payload struct {
+	Player_Id string `json:"player_id"`
+}
+
+type Add_Bot_Payload struct {
+	Seat int `json:"seat"`
+}
+
+type Remove_Bot_Payload struct {
+	Seat int `json:"seat"`
 }
 
 type Deal_Cards_Payload struct {
@@ -64,10 +313,10 @@ type Play_Cards_Payload struct {
 }
 
 type Turn_Payload struct {
-	Player_Id       string           `json:"player_id"`
-	Seat            int              `json:"seat"`
-	Lead_Combo_Type game.Combination `json:"lead_combo_type,omitempty"`
-	Can_Pass        bool             `json:"can_pass"`
+	Player_Id       string `json:"player_id"`
+	Seat            int    `json:"seat"`
+	Lead_Combo_Type string `json:"lead_combo_type,omitempty"`
+	Can_Pass        bool   `json:"can_pass"`
 }
 
 type Play_Made_Payload struct {
@@ -99,10 +348,102 @@ type Tribute_Recv_Payload struct {
 }
 
 type Game_End_Payload struct {
-	Winning_Team int    `json:"winning_team"`
-	Final_Levels [2]int `json:"final_levels"`
+	Winning_Team        int             `json:"winning_team"`
+	Final_Levels        [2]int          `json:"final_levels"`
+	Rating_Changes      []Rating_Change `json:"rating_changes,omitempty"`
+	Partnership_Changes []Rating_Change `json:"partnership_changes,omitempty"`
+	Series              Series_State    `json:"series"`
+}
+
+type Rating_Change struct {
+	Id         string  `json:"id"`
+	Name       string  `json:"name"`
+	Old_Rating float64 `json:"old_rating"`
+	New_Rating float64 `json:"new_rating"`
 }
 
+// Error_Payload reports a failed request. Code, Message_Type and Field are
+// set when the request itself could not be decoded or validated.
 type Error_Payload struct {
-	Message string `json:"message"`
+	Message      string   `json:"message"`
+	Code         string   `json:"code,omitempty"`
+	Message_Type Msg_Type `json:"message_type,omitempty"`
+	Field        string   `json:"field,omitempty"`
+}
+
+type Room_Closed_Payload struct {
+	Room_Id string `json:"room_id"`
+	Reason  string `json:"reason"`
+}
+
+// Chat channels. Seated players talk on the table channel, which spectators can
+// read; spectators talk among themselves on their own channel.
+const (
+	Chat_Table      = "table"
+	Chat_Spectators = "spectators"
+)
+
+var Emotes = []string{"good_game", "well_played", "thanks", "oops", "nice_bomb", "hurry_up"}
+
+type Chat_Payload struct {
+	Text string `json:"text"`
+}
+
+type Emote_Payload struct {
+	Emote string `json:"emote"`
+}
+
+type Chat_Message_Payload struct {
+	Channel   string `json:"channel"`
+	Player_Id string `json:"player_id"`
+	Name      string `json:"name"`
+	Seat      int    `json:"seat"`
+	Text      string `json:"text,omitempty"`
+	Emote     string `json:"emote,omitempty"`
+	Sent_At   int64  `json:"sent_at"`
+}
+
+type Open_Replay_Payload struct {
+	Match_Id string `json:"match_id"`
+	Hand     int    `json:"hand"`
+}
+
+type Join_Replay_Payload struct {
+	Replay_Id string `json:"replay_id"`
+}
+
+const (
+	Replay_Play         = "play"
+	Replay_Pause        = "pause"
+	Replay_Step_Forward = "step_forward"
+	Replay_Step_Back    = "step_back"
+	Replay_Seek         = "seek"
+	Replay_Speed        = "speed"
+)
+
+type Replay_Control_Payload struct {
+	Action string  `json:"action"`
+	Step   int     `json:"step,omitempty"`
+	Speed  float64 `json:"speed,omitempty"`
+}
+
+type Replay_State_Payload struct {
+	Replay_Id    string                `json:"replay_id"`
+	Match_Id     string                `json:"match_id"`
+	Hand         int                   `json:"hand"`
+	Players      [4]history.Player     `json:"players"`
+	Step         int                   `json:"step"`
+	Total_Steps  int                   `json:"total_steps"`
+	Playing      bool                  `json:"playing"`
+	Speed        float64               `json:"speed"`
+	Event        *history.Event        `json:"event,omitempty"`
+	Level        game.Rank             `json:"level"`
+	Team_Levels  [2]int                `json:"team_levels"`
+	Current_Turn int                   `json:"current_turn"`
+	Table_Cards  []game.Card           `json:"table_cards"`
+	Combo_Type   string                `json:"combo_type"`
+	Card_Counts  [4]int                `json:"card_counts"`
+	Finish_Order []int                 `json:"finish_order"`
+	Open_Hands   *[4][]game.Card       `json:"open_hands,omitempty"`
+	Outcome      *history.Level_Change `json:"outcome,omitempty"`
 }
@@ -0,0 +1,81 @@
+package protocol
+
+import "guandanbtw/game"
+
+// Game_Snapshot_Payload is the hand in play as one seat sees it. Everything is
+// keyed by seat; Seat is -1 for spectators, who get no hand.
+type Game_Snapshot_Payload struct {
+	Seat         int              `json:"seat"`
+	Phase        string           `json:"phase"`
+	Level        game.Rank        `json:"level"`
+	Team_Levels  [2]int           `json:"team_levels"`
+	Hand         []game.Card      `json:"hand"`
+	Card_Counts  [4]int           `json:"card_counts"`
+	Lead         *Lead_Snapshot   `json:"lead,omitempty"`
+	Trick        []Trick_Play     `json:"trick"`
+	Turn         int              `json:"turn"`
+	Can_Pass     bool             `json:"can_pass"`
+	Tributes     []Tribute_Status `json:"tributes"`
+	Finish_Order []int            `json:"finish_order"`
+}
+
+type Lead_Snapshot struct {
+	Seat       int         `json:"seat"`
+	Cards      []game.Card `json:"cards"`
+	Combo_Type string      `json:"combo_type"`
+}
+
+type Trick_Play struct {
+	Seat       int         `json:"seat"`
+	Cards      []game.Card `json:"cards,omitempty"`
+	Combo_Type string      `json:"combo_type,omitempty"`
+	Is_Pass    bool        `json:"is_pass"`
+}
+
+type Tribute_Status struct {
+	From_Seat int  `json:"from_seat"`
+	To_Seat   int  `json:"to_seat"`
+	Done      bool `json:"done"`
+}
+
+// Project_Snapshot projects the game state onto what seat is allowed to see.
+func Project_Snapshot(g *game.Game_State, seat int) Game_Snapshot_Payload {
+	snap := Game_Snapshot_Payload{
+		Seat:         seat,
+		Phase:        g.Phase.String(),
+		Level:        g.Level,
+		Team_Levels:  g.Team_Levels,
+		Hand:         []game.Card{},
+		Trick:        []Trick_Play{},
+		Turn:         g.Current_Turn,
+		Can_Pass:     g.Phase == game.Phase_Play && g.Current_Lead.Type != game.Comb_Invalid,
+		Tributes:     []Tribute_Status{},
+		Finish_Order: append([]int{}, g.Finish_Order...),
+	}
+
+	if seat >= 0 && seat < 4 {
+		snap.Hand = append(snap.Hand, g.Hands[seat]...)
+	}
+	for i, hand := range g.Hands {
+		snap.Card_Counts[i] = len(hand)
+	}
+	if g.Current_Lead.Type != game.Comb_Invalid {
+		snap.Lead = &Lead_Snapshot{
+			Seat:       g.Lead_Player,
+			Cards:      g.Current_Lead.Cards,
+			Combo_Type: g.Current_Lead.Type.String(),
+		}
+	}
+	for _, p := range g.Trick {
+		play := Trick_Play{Seat: p.Seat, Is_Pass: p.Pass}
+		if !p.Pass {
+			play.Cards = p.Cards
+			play.Combo_Type = p.Type.String()
+		}
+		snap.Trick = append(snap.Trick, play)
+	}
+	for _, t := range g.Tributes {
+		snap.Tributes = append(snap.Tributes, Tribute_Status{From_Seat: t.From_Seat, To_Seat: t.To_Seat, Done: t.Done})
+	}
+	return snap
+}
@@ -0,0 +1,122 @@
+package protocol
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+
+	"guandanbtw/game"
+)
+
+// snapshot_game deals a hand and plays into it: the leader leads, the next
+// seat passes.
+func snapshot_game(t *testing.T) (*game.Game_State, game.Combination, int) {
+	t.Helper()
+	g := game.New_Game_State()
+	g.Deal(game.Deal_From_Seed(7))
+	if g.Phase != game.Phase_Play {
+		t.Fatalf("phase = %v after the first deal", g.Phase)
+	}
+
+	leader := g.Current_Turn
+	lead := game.Legal_Plays(g.Hands[leader], game.Combination{}, g.Level)[0]
+	ids := make([]int, len(lead.Cards))
+	for i, c := range lead.Cards {
+		ids[i] = c.Id
+	}
+	if _, err := g.Play(leader, ids); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.Pass(g.Current_Turn); err != nil {
+		t.Fatal(err)
+	}
+	return g, lead, leader
+}
+
+func TestProjectSnapshot(t *testing.T) {
+	g, lead, leader := snapshot_game(t)
+
+	tests := []struct {
+		name string
+		seat int
+	}{
+		{"seat 0", 0},
+		{"seat 1", 1},
+		{"seat 2", 2},
+		{"seat 3", 3},
+		{"spectator", -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seat := tt.seat
+			snap := Project_Snapshot(g, seat)
+
+			if snap.Seat != seat || snap.Phase != g.Phase.String() || snap.Level != g.Level || snap.Turn != g.Current_Turn {
+				t.Fatalf("snapshot header = %+v", snap)
+			}
+			if seat == -1 {
+				if snap.Hand == nil || len(snap.Hand) != 0 {
+					t.Fatalf("spectator hand = %v, want empty", snap.Hand)
+				}
+				data, _ := json.Marshal(snap)
+				if !strings.Contains(string(data), `"hand":[]`) {
+					t.Fatalf("spectator hand encodes as %s", data)
+				}
+			} else if !slices.Equal(card_ids(snap.Hand), card_ids(g.Hands[seat])) {
+				t.Fatalf("seat %d sees hand %v, want its own %v", seat, card_ids(snap.Hand), card_ids(g.Hands[seat]))
+			}
+
+			for i := range g.Hands {
+				want := 27
+				if i == leader {
+					want -= len(lead.Cards)
+				}
+				if snap.Card_Counts[i] != want {
+					t.Errorf("card count of seat %d = %d, want %d", i, snap.Card_Counts[i], want)
+				}
+			}
+
+			if snap.Lead == nil || snap.Lead.Seat != leader || snap.Lead.Combo_Type != lead.Type.String() ||
+				!slices.Equal(card_ids(snap.Lead.Cards), card_ids(lead.Cards)) {
+				t.Fatalf("lead = %+v", snap.Lead)
+			}
+			want_trick := []Trick_Play{
+				{Seat: leader, Cards: lead.Cards, Combo_Type: lead.Type.String()},
+				{Seat: (leader + 1) % 4, Is_Pass: true},
+			}
+			if len(snap.Trick) != len(want_trick) {
+				t.Fatalf("trick = %+v, want %+v", snap.Trick, want_trick)
+			}
+			for i, p := range snap.Trick {
+				w := want_trick[i]
+				if p.Seat != w.Seat || p.Is_Pass != w.Is_Pass || p.Combo_Type != w.Combo_Type || !slices.Equal(card_ids(p.Cards), card_ids(w.Cards)) {
+					t.Errorf("trick play %d = %+v, want %+v", i, p, w)
+				}
+			}
+			if !snap.Can_Pass {
+				t.Error("can_pass is false with a lead on the table")
+			}
+		})
+	}
+}
+
+func TestProjectSnapshotCopiesHand(t *testing.T) {
+	g, _, _ := snapshot_game(t)
+	want := card_ids(g.Hands[2])
+
+	snap := Project_Snapshot(g, 2)
+	snap.Hand[0] = game.Card{Id: -1}
+	if !slices.Equal(card_ids(g.Hands[2]), want) {
+		t.Fatal("changing the snapshot's hand changed the game")
+	}
+}
+
+func card_ids(cards []game.Card) []int {
+	ids := make([]int, len(cards))
+	for i, c := range cards {
+		ids[i] = c.Id
+	}
+	return ids
+}
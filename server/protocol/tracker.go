@@ -0,0 +1,28 @@
+package protocol
+
+import "guandanbtw/game"
+
+type Rank_Count struct {
+	Rank  game.Rank `json:"rank"`
+	Count int       `json:"count"`
+}
+
+// Card_Tracker_Payload is what one seat has not seen yet: for every rank and
+// joker, how many cards are neither in its hand nor played, and how many
+// cards each seat holds.
+type Card_Tracker_Payload struct {
+	Unseen      []Rank_Count `json:"unseen"`
+	Card_Counts [4]int       `json:"card_counts"`
+}
+
+func Project_Card_Tracker(b *game.Belief) Card_Tracker_Payload {
+	payload := Card_Tracker_Payload{Card_Counts: b.Counts}
+	var counts [game.Rank_Red_Joker + 1]int
+	for _, c := range b.Unseen {
+		counts[c.Rank]++
+	}
+	for rank, n := range counts {
+		payload.Unseen = append(payload.Unseen, Rank_Count{Rank: game.Rank(rank), Count: n})
+	}
+	return payload
+}
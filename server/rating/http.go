@@ -0,0 +1,30 @@
+package rating
+
+import (
+	"net/http"
+
+	"guandanbtw/web"
+)
+
+const (
+	default_leaderboard_size = 50
+	max_leaderboard_size     = 500
+)
+
+func (l *Ladder) Handle_Players(w http.ResponseWriter, r *http.Request) {
+	limit := web.Query_Int(r, "limit", default_leaderboard_size, 1, max_leaderboard_size)
+	min_games := web.Query_Int(r, "min_games", 1, 0, 1<<20)
+
+	web.Write_JSON(w, http.StatusOK, map[string]interface{}{
+		"players": l.Players(min_games, limit),
+	})
+}
+
+func (l *Ladder) Handle_Partnerships(w http.ResponseWriter, r *http.Request) {
+	limit := web.Query_Int(r, "limit", default_leaderboard_size, 1, max_leaderboard_size)
+	min_games := web.Query_Int(r, "min_games", 1, 0, 1<<20)
+
+	web.Write_JSON(w, http.StatusOK, map[string]interface{}{
+		"partnerships": l.Partnerships(min_games, limit),
+	})
+}
@@ -0,0 +1,370 @@
+package rating
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"guandanbtw/history"
+)
+
+const (
+	Initial_Rating = 1500.0
+
+	// Bots and guests play at a fixed rating and are never updated.
+	Fixed_Rating = 1500.0
+
+	base_k            = 32.0
+	provisional_k     = 48.0
+	provisional_games = 10
+
+	// A win by the full twelve levels counts half again as much as a win by
+	// one, and each extra double win over the opponents adds a tenth.
+	level_span        = 12.0
+	margin_weight     = 0.5
+	double_win_weight = 0.1
+	max_multiplier    = 2.0
+)
+
+var Err_Already_Rated = errors.New("rating: match already rated")
+
+type Player struct {
+	Id         string    `json:"id"`
+	Username   string    `json:"username"`
+	Name       string    `json:"name"`
+	Rating     float64   `json:"rating"`
+	Games      int       `json:"games"`
+	Wins       int       `json:"wins"`
+	Updated_At time.Time `json:"updated_at"`
+}
+
+type Partnership struct {
+	Key        string    `json:"key"`
+	Player_Ids [2]string `json:"player_ids"`
+	Usernames  [2]string `json:"usernames"`
+	Rating     float64   `json:"rating"`
+	Games      int       `json:"games"`
+	Wins       int       `json:"wins"`
+	Updated_At time.Time `json:"updated_at"`
+}
+
+type Snapshot struct {
+	Players      map[string]*Player      `json:"players"`
+	Partnerships map[string]*Partnership `json:"partnerships"`
+	Rated        map[string]time.Time    `json:"rated"`
+}
+
+type Store interface {
+	Save_Ratings(s *Snapshot) error
+	Load_Ratings() (*Snapshot, error)
+}
+
+// Seat is a player in a match. Only seats with an account username are rated.
+type Seat struct {
+	Id       string
+	Username string
+	Name     string
+	Is_Bot   bool
+}
+
+type Match struct {
+	Id           string
+	Seats        [4]Seat
+	Winning_Team int
+	Final_Levels [2]int
+	Double_Wins  [2]int
+}
+
+type Change struct {
+	Id         string  `json:"id"`
+	Name       string  `json:"name"`
+	Old_Rating float64 `json:"old_rating"`
+	New_Rating float64 `json:"new_rating"`
+}
+
+type Result struct {
+	Players      []Change
+	Partnerships []Change
+}
+
+type Ladder struct {
+	mu    sync.Mutex
+	store Store
+	snap  *Snapshot
+}
+
+func Open(st Store) (*Ladder, error) {
+	snap, err := st.Load_Ratings()
+	if err != nil {
+		return nil, err
+	}
+	if snap == nil {
+		snap = &Snapshot{}
+	}
+	if snap.Players == nil {
+		snap.Players = make(map[string]*Player)
+	}
+	if snap.Partnerships == nil {
+		snap.Partnerships = make(map[string]*Partnership)
+	}
+	if snap.Rated == nil {
+		snap.Rated = make(map[string]time.Time)
+	}
+
+	return &Ladder{store: st, snap: snap}, nil
+}
+
+func (s Seat) rated() bool {
+	return s.Username != "" && !s.Is_Bot
+}
+
+// Match_From_Hands rebuilds a finished match from its archived hand logs.
+func Match_From_Hands(match_id string, logs []*history.Hand_Log) (Match, bool) {
+	if len(logs) == 0 {
+		return Match{}, false
+	}
+
+	last := logs[len(logs)-1]
+	outcome := last.Outcome()
+	if outcome == nil || !outcome.Game_Over {
+		return Match{}, false
+	}
+
+	m := Match{
+		Id:           match_id,
+		Winning_Team: outcome.Winning_Team,
+		Final_Levels: outcome.New_Levels,
+	}
+	for i, p := range last.Header.Players {
+		m.Seats[i] = Seat{Id: p.Id, Username: p.Username, Name: p.Name, Is_Bot: p.Is_Bot}
+	}
+
+	for _, l := range logs {
+		o := l.Outcome()
+		if o == nil || len(o.Finish_Order) < 2 {
+			continue
+		}
+		if o.Finish_Order[0]%2 == o.Finish_Order[1]%2 {
+			m.Double_Wins[o.Winning_Team]++
+		}
+	}
+
+	return m, true
+}
+
+func (l *Ladder) Record(m Match) (Result, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.snap.Rated[m.Id]; ok {
+		return Result{}, Err_Already_Rated
+	}
+
+	any_rated := false
+	for _, s := range m.Seats {
+		any_rated = any_rated || s.rated()
+	}
+	if !any_rated {
+		return Result{}, nil
+	}
+
+	now := time.Now().UTC()
+	mult := multiplier(m)
+
+	var team_rating [2]float64
+	for i, s := range m.Seats {
+		team_rating[i%2] += l.seat_rating(s) / 2
+	}
+
+	var partnerships [2]*Partnership
+	for team := 0; team < 2; team++ {
+		a, b := m.Seats[team], m.Seats[team+2]
+		if a.rated() && b.rated() {
+			partnerships[team] = l.partnership(a, b)
+		}
+	}
+
+	var result Result
+	for i, s := range m.Seats {
+		if !s.rated() {
+			continue
+		}
+
+		team := i % 2
+		p := l.player(s)
+		old := p.Rating
+		p.Rating += k_factor(p.Games) * mult * (score(team, m) - expected(team_rating[team], team_rating[1-team]))
+		p.Rating = round(p.Rating)
+		p.Games++
+		if team == m.Winning_Team {
+			p.Wins++
+		}
+		p.Username = s.Username
+		p.Name = s.Name
+		p.Updated_At = now
+
+		result.Players = append(result.Players, Change{Id: p.Id, Name: s.Name, Old_Rating: old, New_Rating: p.Rating})
+	}
+
+	var partnership_rating [2]float64
+	for team, p := range partnerships {
+		partnership_rating[team] = team_rating[team]
+		if p != nil {
+			partnership_rating[team] = p.Rating
+		}
+	}
+	for team, p := range partnerships {
+		if p == nil {
+			continue
+		}
+
+		old := p.Rating
+		p.Rating += k_factor(p.Games) * mult * (score(team, m) - expected(partnership_rating[team], partnership_rating[1-team]))
+		p.Rating = round(p.Rating)
+		p.Games++
+		if team == m.Winning_Team {
+			p.Wins++
+		}
+		p.Updated_At = now
+
+		result.Partnerships = append(result.Partnerships, Change{
+			Id:         p.Key,
+			Name:       m.Seats[team].Name + " & " + m.Seats[team+2].Name,
+			Old_Rating: old,
+			New_Rating: p.Rating,
+		})
+	}
+
+	l.snap.Rated[m.Id] = now
+	if err := l.store.Save_Ratings(l.snap); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+func (l *Ladder) Players(min_games int, limit int) []Player {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var players []Player
+	for _, p := range l.snap.Players {
+		if p.Games >= min_games {
+			players = append(players, *p)
+		}
+	}
+	sort.Slice(players, func(i, j int) bool {
+		if players[i].Rating != players[j].Rating {
+			return players[i].Rating > players[j].Rating
+		}
+		return players[i].Username < players[j].Username
+	})
+
+	if len(players) > limit {
+		players = players[:limit]
+	}
+	return players
+}
+
+func (l *Ladder) Partnerships(min_games int, limit int) []Partnership {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var partnerships []Partnership
+	for _, p := range l.snap.Partnerships {
+		if p.Games >= min_games {
+			partnerships = append(partnerships, *p)
+		}
+	}
+	sort.Slice(partnerships, func(i, j int) bool {
+		if partnerships[i].Rating != partnerships[j].Rating {
+			return partnerships[i].Rating > partnerships[j].Rating
+		}
+		return partnerships[i].Key < partnerships[j].Key
+	})
+
+	if len(partnerships) > limit {
+		partnerships = partnerships[:limit]
+	}
+	return partnerships
+}
+
+func (l *Ladder) Player(id string) (Player, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	p, ok := l.snap.Players[id]
+	if !ok {
+		return Player{}, false
+	}
+	return *p, true
+}
+
+func (l *Ladder) seat_rating(s Seat) float64 {
+	if !s.rated() {
+		return Fixed_Rating
+	}
+	if p, ok := l.snap.Players[s.Id]; ok {
+		return p.Rating
+	}
+	return Initial_Rating
+}
+
+func (l *Ladder) player(s Seat) *Player {
+	p, ok := l.snap.Players[s.Id]
+	if !ok {
+		p = &Player{Id: s.Id, Rating: Initial_Rating}
+		l.snap.Players[s.Id] = p
+	}
+	return p
+}
+
+func (l *Ladder) partnership(a Seat, b Seat) *Partnership {
+	ids := []string{a.Id, b.Id}
+	usernames := map[string]string{a.Id: a.Username, b.Id: b.Username}
+	slices.Sort(ids)
+	key := ids[0] + "+" + ids[1]
+
+	p, ok := l.snap.Partnerships[key]
+	if !ok {
+		p = &Partnership{Key: key, Rating: Initial_Rating}
+		l.snap.Partnerships[key] = p
+	}
+	p.Player_Ids = [2]string{ids[0], ids[1]}
+	p.Usernames = [2]string{usernames[ids[0]], usernames[ids[1]]}
+	return p
+}
+
+func multiplier(m Match) float64 {
+	winner, loser := m.Winning_Team, 1-m.Winning_Team
+
+	margin := float64(max(m.Final_Levels[winner]-m.Final_Levels[loser], 0))
+	doubles := float64(max(m.Double_Wins[winner]-m.Double_Wins[loser], 0))
+
+	return min(1+margin_weight*margin/level_span+double_win_weight*doubles, max_multiplier)
+}
+
+func k_factor(games int) float64 {
+	if games < provisional_games {
+		return provisional_k
+	}
+	return base_k
+}
+
+func expected(rating float64, opponent float64) float64 {
+	return 1 / (1 + math.Pow(10, (opponent-rating)/400))
+}
+
+func score(team int, m Match) float64 {
+	if team == m.Winning_Team {
+		return 1
+	}
+	return 0
+}
+
+func round(rating float64) float64 {
+	return math.Round(rating*10) / 10
+}
@@ -0,0 +1,269 @@
+package rating
+
+import (
+	"errors"
+	"testing"
+)
+
+type memory_store struct {
+	snap  *Snapshot
+	saves int
+}
+
+func (s *memory_store) Save_Ratings(snap *Snapshot) error {
+	s.saves++
+	return nil
+}
+
+func (s *memory_store) Load_Ratings() (*Snapshot, error) {
+	return s.snap, nil
+}
+
+func human(id string) Seat {
+	return Seat{Id: id, Username: id, Name: id}
+}
+
+func bot(id string) Seat {
+	return Seat{Id: id, Name: id, Is_Bot: true}
+}
+
+func guest(id string) Seat {
+	return Seat{Id: id, Name: id}
+}
+
+func TestRecord(t *testing.T) {
+	humans := [4]Seat{human("a"), human("b"), human("c"), human("d")}
+
+	tests := []struct {
+		name         string
+		existing     []*Player
+		seats        [4]Seat
+		winner       int
+		levels       [2]int
+		double_wins  [2]int
+		players      map[string]float64
+		partnerships map[string]float64
+	}{
+		{
+			name:         "even match by one level",
+			seats:        humans,
+			levels:       [2]int{3, 2},
+			players:      map[string]float64{"a": 1525, "b": 1475, "c": 1525, "d": 1475},
+			partnerships: map[string]float64{"a+c": 1525, "b+d": 1475},
+		},
+		{
+			name:         "no margin",
+			seats:        humans,
+			winner:       1,
+			levels:       [2]int{2, 2},
+			players:      map[string]float64{"a": 1476, "b": 1524, "c": 1476, "d": 1524},
+			partnerships: map[string]float64{"a+c": 1476, "b+d": 1524},
+		},
+		{
+			name:         "full margin counts half again",
+			seats:        humans,
+			levels:       [2]int{14, 2},
+			players:      map[string]float64{"a": 1536, "b": 1464, "c": 1536, "d": 1464},
+			partnerships: map[string]float64{"a+c": 1536, "b+d": 1464},
+		},
+		{
+			name:         "double wins over the losers add a tenth each",
+			seats:        humans,
+			levels:       [2]int{2, 2},
+			double_wins:  [2]int{3, 1},
+			players:      map[string]float64{"a": 1528.8, "b": 1471.2, "c": 1528.8, "d": 1471.2},
+			partnerships: map[string]float64{"a+c": 1528.8, "b+d": 1471.2},
+		},
+		{
+			name:         "multiplier is capped",
+			seats:        humans,
+			levels:       [2]int{14, 2},
+			double_wins:  [2]int{8, 0},
+			players:      map[string]float64{"a": 1548, "b": 1452, "c": 1548, "d": 1452},
+			partnerships: map[string]float64{"a+c": 1548, "b+d": 1452},
+		},
+		{
+			name: "established player against newcomers",
+			existing: []*Player{
+				{Id: "a", Username: "a", Rating: 1700, Games: 20},
+			},
+			seats:        humans,
+			levels:       [2]int{2, 2},
+			players:      map[string]float64{"a": 1711.5, "b": 1482.7, "c": 1517.3, "d": 1482.7},
+			partnerships: map[string]float64{"a+c": 1524, "b+d": 1476},
+		},
+		{
+			name:         "bots are fixed and unrated",
+			seats:        [4]Seat{human("a"), bot("b"), human("c"), bot("d")},
+			levels:       [2]int{2, 2},
+			players:      map[string]float64{"a": 1524, "c": 1524},
+			partnerships: map[string]float64{"a+c": 1524},
+		},
+		{
+			// A guest's id may match an account, but the guest still plays
+			// at the fixed rating and leaves the account alone.
+			name: "guests are fixed and unrated",
+			existing: []*Player{
+				{Id: "b", Username: "b", Rating: 1900, Games: 50},
+			},
+			seats:   [4]Seat{human("a"), guest("b"), guest("c"), human("d")},
+			levels:  [2]int{2, 2},
+			players: map[string]float64{"a": 1524, "b": 1900, "d": 1476},
+		},
+		{
+			name:    "partnership key does not depend on seat order",
+			seats:   [4]Seat{human("z"), bot("b"), human("m"), bot("d")},
+			levels:  [2]int{2, 2},
+			players: map[string]float64{"m": 1524, "z": 1524},
+			partnerships: map[string]float64{
+				"m+z": 1524,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snap := &Snapshot{Players: make(map[string]*Player)}
+			for _, p := range tt.existing {
+				snap.Players[p.Id] = p
+			}
+			st := &memory_store{snap: snap}
+			l, err := Open(st)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			m := Match{Id: "m1", Seats: tt.seats, Winning_Team: tt.winner, Final_Levels: tt.levels, Double_Wins: tt.double_wins}
+			result, err := l.Record(m)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(l.snap.Players) != len(tt.players) {
+				t.Errorf("ladder holds %d players, want %d", len(l.snap.Players), len(tt.players))
+			}
+			for id, want := range tt.players {
+				p, ok := l.Player(id)
+				if !ok || p.Rating != want {
+					t.Errorf("player %s rating = %v (found %v), want %v", id, p.Rating, ok, want)
+				}
+			}
+			if len(l.snap.Partnerships) != len(tt.partnerships) {
+				t.Errorf("ladder holds %d partnerships, want %d", len(l.snap.Partnerships), len(tt.partnerships))
+			}
+			for key, want := range tt.partnerships {
+				p, ok := l.snap.Partnerships[key]
+				if !ok || p.Rating != want {
+					t.Errorf("partnership %s = %+v, want rating %v", key, p, want)
+				}
+			}
+
+			changed := 0
+			for _, s := range tt.seats {
+				if s.rated() {
+					changed++
+				}
+			}
+			if len(result.Players) != changed || len(result.Partnerships) != len(tt.partnerships) {
+				t.Errorf("result has %d player and %d partnership changes, want %d and %d",
+					len(result.Players), len(result.Partnerships), changed, len(tt.partnerships))
+			}
+			for _, c := range result.Players {
+				if c.New_Rating != tt.players[c.Id] {
+					t.Errorf("change for %s = %v, want %v", c.Id, c.New_Rating, tt.players[c.Id])
+				}
+			}
+			if st.saves != 1 {
+				t.Errorf("saved %d times, want 1", st.saves)
+			}
+		})
+	}
+}
+
+func TestRecordCounts(t *testing.T) {
+	l, err := Open(&memory_store{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seats := [4]Seat{human("a"), human("b"), human("c"), human("d")}
+	for i, winner := range []int{0, 0, 1} {
+		m := Match{Id: []string{"m1", "m2", "m3"}[i], Seats: seats, Winning_Team: winner, Final_Levels: [2]int{2, 2}}
+		if _, err := l.Record(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	a, _ := l.Player("a")
+	if a.Games != 3 || a.Wins != 2 {
+		t.Errorf("a has %d games and %d wins, want 3 and 2", a.Games, a.Wins)
+	}
+	p := l.snap.Partnerships["b+d"]
+	if p.Games != 3 || p.Wins != 1 || p.Player_Ids != [2]string{"b", "d"} || p.Usernames != [2]string{"b", "d"} {
+		t.Errorf("partnership b+d = %+v", p)
+	}
+}
+
+func TestRecordProvisional(t *testing.T) {
+	snap := &Snapshot{Players: map[string]*Player{
+		"a": {Id: "a", Rating: 1500, Games: provisional_games - 1},
+		"b": {Id: "b", Rating: 1500, Games: provisional_games},
+	}}
+	l, err := Open(&memory_store{snap: snap})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := Match{Id: "m1", Seats: [4]Seat{human("a"), human("b"), bot("c"), bot("d")}, Final_Levels: [2]int{2, 2}}
+	if _, err := l.Record(m); err != nil {
+		t.Fatal(err)
+	}
+
+	// Both teams average 1500, so each moves by half its k factor.
+	if a, _ := l.Player("a"); a.Rating != 1500+provisional_k/2 {
+		t.Errorf("provisional player rating = %v", a.Rating)
+	}
+	if b, _ := l.Player("b"); b.Rating != 1500-base_k/2 {
+		t.Errorf("established player rating = %v", b.Rating)
+	}
+}
+
+func TestRecordAlreadyRated(t *testing.T) {
+	st := &memory_store{}
+	l, err := Open(st)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := Match{Id: "m1", Seats: [4]Seat{human("a"), human("b"), human("c"), human("d")}, Final_Levels: [2]int{3, 2}}
+	if _, err := l.Record(m); err != nil {
+		t.Fatal(err)
+	}
+	result, err := l.Record(m)
+	if !errors.Is(err, Err_Already_Rated) {
+		t.Fatalf("second record err = %v, want Err_Already_Rated", err)
+	}
+	if len(result.Players) != 0 || st.saves != 1 {
+		t.Errorf("second record changed %d players and saved %d times", len(result.Players), st.saves)
+	}
+	if a, _ := l.Player("a"); a.Games != 1 {
+		t.Errorf("a has %d games after a repeated record", a.Games)
+	}
+}
+
+func TestRecordWithoutRatedSeats(t *testing.T) {
+	st := &memory_store{}
+	l, err := Open(st)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := Match{Id: "m1", Seats: [4]Seat{guest("a"), bot("b"), guest("c"), bot("d")}}
+	result, err := l.Record(m)
+	if err != nil || len(result.Players) != 0 || len(result.Partnerships) != 0 {
+		t.Fatalf("record = %+v, %v; want nothing", result, err)
+	}
+	if st.saves != 0 || len(l.snap.Players) != 0 {
+		t.Errorf("saved %d times with %d players", st.saves, len(l.snap.Players))
+	}
+}
@@ -0,0 +1,211 @@
+package room
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"guandanbtw/account"
+	"guandanbtw/protocol"
+	"guandanbtw/web"
+)
+
+// Room codes avoid 0/O and 1/I so they can be read aloud and typed back.
+const (
+	room_code_alphabet = "23456789ABCDEFGHJKLMNPQRSTUVWXYZ"
+	room_code_length   = 6
+)
+
+var (
+	err_room_private   = errors.New("room is private")
+	err_wrong_password = errors.New("wrong password")
+)
+
+// Join_Action carries the password already checked on the client's
+// goroutine, since hashing it would hold up the room: checked is the room
+// secret it matched, if any.
+type Join_Action struct {
+	client   *Client
+	password string
+	invite   string
+	checked  *account.Secret
+}
+
+type Room_Settings struct {
+	Private  bool
+	Password string
+}
+
+func generate_room_code() string {
+	b := make([]byte, room_code_length)
+	rand.Read(b)
+	for i := range b {
+		b[i] = room_code_alphabet[int(b[i])%len(room_code_alphabet)]
+	}
+	return string(b)
+}
+
+// normalize_room_code accepts codes typed in lower case or with separators.
+func normalize_room_code(code string) string {
+	code = strings.ToUpper(code)
+	return strings.NewReplacer(" ", "", "-", "").Replace(code)
+}
+
+func (r *Room) set_password(password string) {
+	r.set_secret(room_secret(password))
+}
+
+func (r *Room) set_secret(secret *account.Secret) {
+	r.password.Store(secret)
+}
+
+// room_secret hashes a room password with the account KDF. It is slow on
+// purpose, so it runs on the goroutine of the client that sent it.
+func room_secret(password string) *account.Secret {
+	if password == "" {
+		return nil
+	}
+	return account.New_Secret(password)
+}
+
+// check_password runs on the client's goroutine before a join is handed to
+// the room, and records which secret the password matched. Each guess costs
+// a hash, so guesses are limited per client address and per room.
+func (r *Room) check_password(action *Join_Action) error {
+	secret := r.password.Load()
+	if secret == nil || action.password == "" {
+		return nil
+	}
+	if !r.hub.passwords.Allow(time.Now(), "addr "+action.client.addr, "room "+r.id) {
+		return account.Err_Too_Many_Attempts
+	}
+	if secret.Matches(action.password) {
+		action.checked = secret
+	}
+	return nil
+}
+
+func (r *Room) check_access(action Join_Action) error {
+	if !r.private {
+		return nil
+	}
+	if action.invite != "" && subtle.ConstantTimeCompare([]byte(action.invite), []byte(r.invite)) == 1 {
+		return nil
+	}
+	secret := r.password.Load()
+	if secret == nil || action.password == "" {
+		return err_room_private
+	}
+	if action.checked != secret {
+		return err_wrong_password
+	}
+	return nil
+}
+
+func (r *Room) handle_room_settings(client *Client, settings protocol.Room_Settings_Payload, secret *account.Secret) {
+	if !r.check_host_in_lobby(client) {
+		return
+	}
+
+	switch settings.Visibility {
+	case protocol.Visibility_Public:
+		r.private = false
+	case protocol.Visibility_Private:
+		r.private = true
+	default:
+		client.send_error("invalid visibility")
+		return
+	}
+	if settings.Password != nil {
+		r.set_secret(secret)
+	}
+
+	r.broadcast_room_state()
+}
+
+func (r *Room) visibility() string {
+	if r.private {
+		return protocol.Visibility_Private
+	}
+	return protocol.Visibility_Public
+}
+
+// publish keeps the hub's listing of this room current. Private rooms are
+// never listed.
+func (r *Room) publish() {
+	if listing, ok := r.listing(); ok {
+		r.hub.set_listing(listing)
+	} else {
+		r.hub.remove_listing(r.id)
+	}
+}
+
+func (r *Room) listing() (protocol.Room_Listing, bool) {
+	if r.private || r.status == Status_Closed {
+		return protocol.Room_Listing{}, false
+	}
+
+	listing := protocol.Room_Listing{
+		Room_Id:    r.id,
+		Spectators: len(r.spectators),
+		Status:     r.status.String(),
+		Rules:      protocol.Room_Rules{Quiet_Hands: r.quiet_hands, Rated: !r.casual, Card_Tracker: r.tracking()},
+	}
+	if r.host != nil {
+		listing.Host_Name = r.host.name
+	}
+	for _, c := range r.clients {
+		if c == nil {
+			continue
+		}
+		listing.Seats_Filled++
+		if c.is_bot {
+			listing.Bots++
+		}
+	}
+	return listing, true
+}
+
+func (h *Hub) set_listing(listing protocol.Room_Listing) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.listings[listing.Room_Id] = listing
+}
+
+func (h *Hub) remove_listing(id string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.listings, id)
+}
+
+// Room_Listings returns public rooms, open lobbies first.
+func (h *Hub) Room_Listings() []protocol.Room_Listing {
+	h.mu.RLock()
+	rooms := make([]protocol.Room_Listing, 0, len(h.listings))
+	for _, l := range h.listings {
+		rooms = append(rooms, l)
+	}
+	h.mu.RUnlock()
+
+	sort.Slice(rooms, func(i, j int) bool {
+		a, b := rooms[i], rooms[j]
+		if (a.Status == Status_Lobby.String()) != (b.Status == Status_Lobby.String()) {
+			return a.Status == Status_Lobby.String()
+		}
+		if a.Seats_Filled != b.Seats_Filled {
+			return a.Seats_Filled > b.Seats_Filled
+		}
+		return a.Room_Id < b.Room_Id
+	})
+	return rooms
+}
+
+func (h *Hub) Handle_Rooms(w http.ResponseWriter, r *http.Request) {
+	web.Write_JSON(w, http.StatusOK, protocol.Room_List_Payload{Rooms: h.Room_Listings()})
+}
@@ -0,0 +1,117 @@
+package room
+
+import (
+	"testing"
+
+	"guandanbtw/account"
+)
+
+func password_room(id string, hub *Hub) *Room {
+	return &Room{id: id, hub: hub, private: true, invite: "invite-token"}
+}
+
+func TestCheckAccess(t *testing.T) {
+	r := password_room("TEST", New_Hub(Hub_Options{}))
+	r.set_password("hunter22")
+
+	tests := []struct {
+		name     string
+		private  bool
+		password string
+		invite   string
+		want     error
+	}{
+		{"public room", false, "", "", nil},
+		{"invite", true, "", "invite-token", nil},
+		{"password", true, "hunter22", "", nil},
+		{"wrong invite, right password", true, "hunter22", "nope", nil},
+		{"wrong password", true, "hunter23", "", err_wrong_password},
+		{"nothing given", true, "", "", err_room_private},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r.private = tt.private
+			action := Join_Action{client: new_client("c1", nil), password: tt.password, invite: tt.invite}
+			r.check_password(&action)
+			if err := r.check_access(action); err != tt.want {
+				t.Fatalf("check_access = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckAccessAfterPasswordChange(t *testing.T) {
+	r := password_room("TEST", New_Hub(Hub_Options{}))
+	r.set_password("hunter22")
+
+	action := Join_Action{client: new_client("c1", nil), password: "hunter22"}
+	r.check_password(&action)
+	r.set_password("hunter22")
+	if err := r.check_access(action); err != err_wrong_password {
+		t.Fatalf("a password checked against the old secret was let in: %v", err)
+	}
+}
+
+func TestLegacyPasswordOnlyTakesInvite(t *testing.T) {
+	r := password_room("TEST", New_Hub(Hub_Options{}))
+	r.set_secret(&account.Secret{})
+
+	action := Join_Action{client: new_client("c1", nil), password: "anything"}
+	r.check_password(&action)
+	if err := r.check_access(action); err != err_wrong_password {
+		t.Fatalf("check_access = %v, want %v", err, err_wrong_password)
+	}
+	if err := r.check_access(Join_Action{invite: "invite-token"}); err != nil {
+		t.Fatalf("invite refused: %v", err)
+	}
+}
+
+func TestPasswordAttemptsLimited(t *testing.T) {
+	hub := New_Hub(Hub_Options{})
+	r := password_room("LOCKED", hub)
+	r.set_password("hunter22")
+	other := password_room("OTHER", hub)
+	other.set_password("hunter22")
+
+	guesser := new_client("c1", nil)
+	guesser.addr = "10.0.0.1"
+	guesses := 0
+	for ; guesses < 100; guesses++ {
+		if err := r.check_password(&Join_Action{client: guesser, password: "guess"}); err != nil {
+			if err != account.Err_Too_Many_Attempts {
+				t.Fatalf("check_password = %v", err)
+			}
+			break
+		}
+	}
+	if guesses == 0 || guesses == 100 {
+		t.Fatalf("refused after %d guesses", guesses)
+	}
+
+	action := Join_Action{client: guesser, password: "hunter22"}
+	if err := r.check_password(&action); err != account.Err_Too_Many_Attempts || action.checked != nil {
+		t.Fatalf("right password after the budget ran out: %v, checked %v", err, action.checked != nil)
+	}
+
+	// The room's own budget is spent, so another address is refused too,
+	// while the guesser's address is refused at any room.
+	friend := new_client("c2", nil)
+	friend.addr = "10.0.0.2"
+	if err := r.check_password(&Join_Action{client: friend, password: "hunter22"}); err != account.Err_Too_Many_Attempts {
+		t.Fatalf("another address at the locked room: %v", err)
+	}
+	if err := other.check_password(&Join_Action{client: guesser, password: "hunter22"}); err != account.Err_Too_Many_Attempts {
+		t.Fatalf("the guesser at another room: %v", err)
+	}
+
+	action = Join_Action{client: friend, password: "hunter22"}
+	if err := other.check_password(&action); err != nil || action.checked == nil {
+		t.Fatalf("a fresh address at a fresh room: %v", err)
+	}
+
+	// Invites never hash, so they are not limited.
+	if err := r.check_password(&Join_Action{client: guesser, invite: "invite-token"}); err != nil {
+		t.Fatalf("invite join: %v", err)
+	}
+}
@@ -0,0 +1,35 @@
+package room
+
+import (
+	"log"
+
+	"guandanbtw/game"
+)
+
+// observe passes one public event to every seat's belief. The beliefs start
+// at the deal and take each tribute, play and pass as it happens, so bots
+// and card trackers never have to replay the hand log.
+func (r *Room) observe(see func(b *game.Belief)) {
+	for _, b := range r.beliefs {
+		if b != nil {
+			see(b)
+		}
+	}
+}
+
+// rebuild_beliefs replays the hand log once, for a room restored in the
+// middle of a hand.
+func (r *Room) rebuild_beliefs() {
+	r.beliefs = [4]*game.Belief{}
+	if r.hand_log == nil || r.game == nil || r.game.Phase != game.Phase_Play && r.game.Phase != game.Phase_Tribute {
+		return
+	}
+	for seat := range r.beliefs {
+		b, err := r.hand_log.Belief_At(seat, len(r.hand_log.Events))
+		if err != nil {
+			log.Printf("room %s: belief for seat %d: %v", r.id, seat, err)
+			continue
+		}
+		r.beliefs[seat] = b
+	}
+}
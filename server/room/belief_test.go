@@ -0,0 +1,75 @@
+package room
+
+import (
+	"math"
+	"testing"
+
+	"guandanbtw/game"
+)
+
+func TestBeliefsFollowTheHandLog(t *testing.T) {
+	r := new_test_room(t)
+	players := seat_test_players(r)
+	r.start_game()
+
+	// Lead the lowest single each trick and pass around it until someone goes
+	// out or enough has happened.
+	for move := 0; move < 40 && r.game.Phase == game.Phase_Play; move++ {
+		seat := r.game.Current_Turn
+		if r.game.Current_Lead.Type == game.Comb_Invalid || move%3 == 1 {
+			plays := game.Legal_Plays(r.game.Hands[seat], r.game.Current_Lead, r.game.Level)
+			if len(plays) > 0 {
+				r.handle_play(Play_Action{client: players[seat], card_ids: card_ids(plays[0].Cards)})
+				continue
+			}
+		}
+		r.handle_pass(players[seat])
+	}
+
+	for seat, b := range r.beliefs {
+		want, err := r.hand_log.Belief_At(seat, len(r.hand_log.Events))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b.Counts != want.Counts || len(b.Unseen) != len(want.Unseen) || len(b.Hand) != len(want.Hand) {
+			t.Fatalf("seat %d: counts %v unseen %d, replay gives %v and %d",
+				seat, b.Counts, len(b.Unseen), want.Counts, len(want.Unseen))
+		}
+		got_p, want_p := b.Probabilities(), want.Probabilities()
+		for s := range got_p {
+			for id, p := range want_p[s] {
+				if math.Abs(got_p[s][id]-p) > 1e-9 {
+					t.Fatalf("seat %d: P(seat %d holds %d) = %v, replay gives %v", seat, s, id, got_p[s][id], p)
+				}
+			}
+		}
+	}
+
+	restored := new_test_room(t)
+	restored.game, restored.hand_log = r.game, r.hand_log
+	restored.rebuild_beliefs()
+	for seat := range restored.beliefs {
+		if restored.beliefs[seat] == nil || restored.beliefs[seat].Counts != r.beliefs[seat].Counts {
+			t.Fatalf("seat %d belief was not rebuilt", seat)
+		}
+	}
+}
+
+func TestStaleBotMoveIsDropped(t *testing.T) {
+	r := new_test_room(t)
+	seat_test_players(r)
+	r.start_game()
+	seat := r.game.Current_Turn
+	r.seat_bot(seat)
+
+	hand := len(r.game.Hands[seat])
+	r.handle_bot_turn(Bot_Turn_Action{seat: seat, thought: true, log: r.hand_log, step: len(r.hand_log.Events) - 1})
+	if len(r.game.Hands[seat]) != hand || r.game.Current_Turn != seat {
+		t.Fatal("the bot acted on a position that had moved on")
+	}
+
+	r.handle_bot_turn(Bot_Turn_Action{seat: seat, thought: true, log: r.hand_log, step: len(r.hand_log.Events)})
+	if r.game.Current_Turn == seat {
+		t.Fatal("the bot did not act on the current position")
+	}
+}
@@ -0,0 +1,185 @@
+package room
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"guandanbtw/protocol"
+)
+
+const max_spectators = 20
+
+type Chat_Config struct {
+	Max_Length int
+	Burst      int
+	Refill     time.Duration
+	Filter     *Word_Filter
+}
+
+func Default_Chat_Config() Chat_Config {
+	return Chat_Config{
+		Max_Length: 200,
+		Burst:      5,
+		Refill:     2 * time.Second,
+	}
+}
+
+// Word_Filter masks blocked words, matched case-insensitively as whole words.
+type Word_Filter struct {
+	pattern *regexp.Regexp
+}
+
+func New_Word_Filter(words []string) *Word_Filter {
+	var quoted []string
+	for _, w := range words {
+		if w = strings.TrimSpace(w); w != "" {
+			quoted = append(quoted, regexp.QuoteMeta(w))
+		}
+	}
+	if len(quoted) == 0 {
+		return nil
+	}
+	return &Word_Filter{pattern: regexp.MustCompile(`(?i)\b(` + strings.Join(quoted, "|") + `)\b`)}
+}
+
+func (f *Word_Filter) Clean(text string) string {
+	if f == nil {
+		return text
+	}
+	return f.pattern.ReplaceAllStringFunc(text, func(w string) string {
+		return strings.Repeat("*", utf8.RuneCountInString(w))
+	})
+}
+
+type Chat_Action struct {
+	client *Client
+	text   string
+	emote  string
+}
+
+func (r *Room) handle_spectate(action Join_Action) {
+	client := action.client
+	if r.get_seat(client) != -1 || r.is_spectator(client) {
+		client.send_error("already in this room")
+		return
+	}
+	if err := r.check_access(action); err != nil {
+		client.send_error(err.Error())
+		return
+	}
+	if len(r.spectators) >= max_spectators {
+		client.send_error("too many spectators")
+		return
+	}
+
+	r.spectators = append(r.spectators, client)
+	client.set_room(r)
+	r.broadcast_room_state()
+	r.send_snapshot(client)
+}
+
+func (r *Room) remove_spectator(client *Client) bool {
+	i := slices.Index(r.spectators, client)
+	if i == -1 {
+		return false
+	}
+	r.spectators = slices.Delete(r.spectators, i, i+1)
+	return true
+}
+
+func (r *Room) is_spectator(client *Client) bool {
+	return slices.Contains(r.spectators, client)
+}
+
+func (r *Room) handle_set_quiet(client *Client, quiet bool) {
+	if !r.check_host_in_lobby(client) {
+		return
+	}
+
+	r.quiet_hands = quiet
+	r.broadcast_room_state()
+}
+
+func (r *Room) handle_chat(action Chat_Action) {
+	client := action.client
+	seat := r.get_seat(client)
+	channel := protocol.Chat_Table
+	if seat == -1 {
+		if !r.is_spectator(client) {
+			client.send_error("not in this room")
+			return
+		}
+		channel = protocol.Chat_Spectators
+	}
+
+	if seat != -1 && r.quiet_hands && r.status == Status_Playing {
+		client.send_error("chat is off during hands at this table")
+		return
+	}
+
+	msg := protocol.Chat_Message_Payload{
+		Channel:   channel,
+		Player_Id: client.id,
+		Name:      client.name,
+		Seat:      seat,
+	}
+
+	if action.emote != "" {
+		if !slices.Contains(protocol.Emotes, action.emote) {
+			client.send_error("unknown emote")
+			return
+		}
+		msg.Emote = action.emote
+	} else {
+		text := strings.TrimSpace(action.text)
+		if text == "" {
+			return
+		}
+		if utf8.RuneCountInString(text) > r.config.Chat.Max_Length {
+			client.send_error("message is too long")
+			return
+		}
+		msg.Text = r.config.Chat.Filter.Clean(text)
+	}
+
+	now := time.Now()
+	if !client.take_chat_token(now, r.config.Chat) {
+		client.send_error("you are sending messages too fast")
+		return
+	}
+	msg.Sent_At = now.UnixMilli()
+
+	out := &protocol.Message{Type: protocol.Msg_Chat_Message, Payload: msg}
+	if channel == protocol.Chat_Table {
+		r.seq++
+		for _, c := range r.clients {
+			if c != nil && !c.is_bot {
+				r.send(c, out)
+			}
+		}
+	}
+	for _, c := range r.spectators {
+		r.send(c, out)
+	}
+}
+
+// take_chat_token spends one message from a bucket that holds Burst messages
+// and refills one every Refill.
+func (c *Client) take_chat_token(now time.Time, config Chat_Config) bool {
+	if c.chat_at.IsZero() {
+		c.chat_tokens = float64(config.Burst)
+	} else if config.Refill > 0 {
+		c.chat_tokens += float64(now.Sub(c.chat_at)) / float64(config.Refill)
+	}
+	c.chat_tokens = min(c.chat_tokens, float64(config.Burst))
+	c.chat_at = now
+
+	if c.chat_tokens < 1 {
+		return false
+	}
+	c.chat_tokens--
+	return true
+}
@@ -2,6 +2,9 @@ package room
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -17,13 +20,40 @@ const (
 )
 
 type Client struct {
-	id     string
-	name   string
-	room   *Room
-	conn   *websocket.Conn
-	send   chan []byte
-	mu     sync.Mutex
-	is_bot bool
+	id       string
+	name     string
+	username string
+	addr     string
+	room     *Room
+	replay   *Replay_Room
+	conn     *websocket.Conn
+	send     chan []byte
+	mu       sync.Mutex
+	queued   int64
+	token    string
+	is_bot   bool
+	is_ready bool
+	offline  bool
+
+	// conn_id names the connection in metrics. The delivery state is guarded
+	// by mu: dropped counts messages lost to a full queue, behind marks a
+	// client waiting to be caught up, and cut_off one that was disconnected
+	// for falling behind.
+	conn_id  int64
+	delivery Delivery_Config
+	dropped  int64
+	resyncs  int
+	behind   bool
+	cut_off  bool
+
+	chat_tokens float64
+	chat_at     time.Time
+
+	// request is the id of the client message being handled, rejected whether
+	// it has drawn an error, and touched the actors it was handed to.
+	request  string
+	rejected bool
+	touched  []actor
 }
 
 func new_client(id string, conn *websocket.Conn) *Client {
@@ -38,18 +68,69 @@ var bot_names = []string{"Bot Alice", "Bot Bob", "Bot Charlie"}
 
 func new_bot(id string, name string) *Client {
 	return &Client{
-		id:     id,
-		name:   name,
-		send:   make(chan []byte, 256),
-		is_bot: true,
+		id:       id,
+		name:     name,
+		send:     make(chan []byte, 256),
+		is_bot:   true,
+		is_ready: true,
+	}
+}
+
+func new_offline_client(id string, name string, token string) *Client {
+	return &Client{
+		id:      id,
+		name:    name,
+		token:   token,
+		send:    make(chan []byte, 256),
+		offline: true,
+	}
+}
+
+// The room is set by room goroutines and read by the client's own, so it is
+// kept under mu.
+func (c *Client) current_room() *Room {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.room
+}
+
+func (c *Client) set_room(r *Room) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.room = r
+}
+
+// The replay room is kept under mu for the same reason.
+func (c *Client) current_replay() *Replay_Room {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.replay
+}
+
+func (c *Client) set_replay(rr *Replay_Room) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.replay = rr
+}
+
+// clear_replay leaves rr unless the client has already moved on to another.
+func (c *Client) clear_replay(rr *Replay_Room) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.replay == rr {
+		c.replay = nil
 	}
 }
 
 func (c *Client) read_pump(hub *Hub) {
 	defer func() {
-		if c.room != nil {
-			c.room.leave <- c
+		if room := c.current_room(); room != nil {
+			send_to_room(room, room.leave, c)
 		}
+		if replay := c.current_replay(); replay != nil {
+			send_to_replay(replay, replay.leave, c)
+		}
+		send_to_queue(hub.queue, hub.queue.leave, c)
 		hub.unregister <- c
 		c.conn.Close()
 	}()
@@ -67,13 +148,30 @@ func (c *Client) read_pump(hub *Hub) {
 			break
 		}
 
-		var msg protocol.Message
+		var msg protocol.Client_Message
 		if err := json.Unmarshal(data, &msg); err != nil {
-			c.send_error("invalid message format")
+			c.send_message(&protocol.Message{
+				Type: protocol.Msg_Error,
+				Payload: protocol.Error_Payload{
+					Message: "invalid message format",
+					Code:    protocol.Error_Malformed,
+				},
+			})
 			continue
 		}
 
-		c.handle_message(hub, &msg)
+		c.begin_request(msg.Request_Id)
+		if len(msg.Request_Id) > protocol.Max_Request_Id_Length {
+			c.send_decode_error(&protocol.Decode_Error{
+				Type:   msg.Type,
+				Code:   protocol.Error_Malformed,
+				Field:  "request_id",
+				Reason: fmt.Sprintf("must be at most %d bytes", protocol.Max_Request_Id_Length),
+			})
+		} else {
+			c.handle_message(hub, &msg)
+		}
+		c.end_request(msg.Type)
 	}
 }
 
@@ -111,93 +209,316 @@ func (c *Client) write_pump() {
 	}
 }
 
-func (c *Client) handle_message(hub *Hub, msg *protocol.Message) {
-	switch msg.Type {
-	case protocol.Msg_Create_Room:
-		c.handle_create_room(hub, msg)
-	case protocol.Msg_Join_Room:
-		c.handle_join_room(hub, msg)
-	case protocol.Msg_Play_Cards:
-		c.handle_play_cards(msg)
+func (c *Client) handle_message(hub *Hub, msg *protocol.Client_Message) {
+	decoded, err := protocol.Decode(msg.Type, msg.Payload)
+	if err != nil {
+		c.send_decode_error(err)
+		return
+	}
+
+	switch payload := decoded.(type) {
+	case *protocol.Create_Room_Payload:
+		c.handle_create_room(hub, payload)
+	case *protocol.Join_Room_Payload:
+		c.handle_join_room(hub, payload)
+	case *protocol.Rejoin_Room_Payload:
+		c.handle_rejoin_room(hub, payload)
+	case *protocol.Play_Cards_Payload:
+		c.handle_play_cards(payload)
+	case *protocol.Tribute_Give_Payload:
+		c.handle_tribute_give(payload)
+	case *protocol.Choose_Seat_Payload:
+		c.send_lobby_action(Lobby_Action{kind: msg.Type, seat: payload.Seat})
+	case *protocol.Choose_Team_Payload:
+		c.send_lobby_action(Lobby_Action{kind: msg.Type, team: payload.Team})
+	case *protocol.Swap_Seats_Payload:
+		c.send_lobby_action(Lobby_Action{kind: msg.Type, seat: payload.Seat_A, other_seat: payload.Seat_B})
+	case *protocol.Set_Ready_Payload:
+		c.send_lobby_action(Lobby_Action{kind: msg.Type, ready: payload.Ready})
+	case *protocol.Kick_Player_Payload:
+		c.send_lobby_action(Lobby_Action{kind: msg.Type, player_id: payload.Player_Id})
+	case *protocol.Add_Bot_Payload:
+		c.send_lobby_action(Lobby_Action{kind: msg.Type, seat: payload.Seat})
+	case *protocol.Remove_Bot_Payload:
+		c.send_lobby_action(Lobby_Action{kind: msg.Type, seat: payload.Seat})
+	case *protocol.Set_Quiet_Payload:
+		c.send_lobby_action(Lobby_Action{kind: msg.Type, quiet: payload.Quiet})
+	case *protocol.Set_Rated_Payload:
+		c.send_lobby_action(Lobby_Action{kind: msg.Type, rated: payload.Rated})
+	case *protocol.Set_Tracker_Payload:
+		c.send_lobby_action(Lobby_Action{kind: msg.Type, tracker: payload.Card_Tracker})
+	case *protocol.Room_Settings_Payload:
+		action := Lobby_Action{kind: msg.Type, settings: *payload}
+		if payload.Password != nil {
+			action.secret = room_secret(*payload.Password)
+		}
+		c.send_lobby_action(action)
+	case *protocol.Set_Series_Payload:
+		c.send_lobby_action(Lobby_Action{kind: msg.Type, best_of: payload.Best_Of})
+	case *protocol.Complete_Selection_Payload:
+		c.handle_complete_selection(payload)
+	case *protocol.Rematch_Vote_Payload:
+		c.send_rematch_vote(Rematch_Action{vote: payload.Vote, swap: payload.Swap_Partners})
+	case *protocol.Spectate_Room_Payload:
+		c.handle_spectate_room(hub, payload)
+	case *protocol.Queue_Join_Payload:
+		c.handle_queue_join(hub, payload)
+	case *protocol.Chat_Payload:
+		c.send_chat(Chat_Action{text: payload.Text})
+	case *protocol.Emote_Payload:
+		c.send_chat(Chat_Action{emote: payload.Emote})
+	case *protocol.Open_Replay_Payload:
+		c.handle_open_replay(hub, payload)
+	case *protocol.Join_Replay_Payload:
+		c.handle_join_replay(hub, payload)
+	case *protocol.Replay_Control_Payload:
+		c.handle_replay_control(payload)
+	case *protocol.Empty_Payload:
+		c.handle_command(hub, msg.Type)
+	}
+}
+
+// handle_command runs the messages that carry no payload.
+func (c *Client) handle_command(hub *Hub, kind protocol.Msg_Type) {
+	switch kind {
 	case protocol.Msg_Pass:
 		c.handle_pass()
-	case protocol.Msg_Tribute_Give:
-		c.handle_tribute_give(msg)
 	case protocol.Msg_Fill_Bots:
 		c.handle_fill_bots()
+	case protocol.Msg_Start_Game:
+		c.send_lobby_action(Lobby_Action{kind: kind})
+	case protocol.Msg_List_Rooms:
+		c.send_message(&protocol.Message{
+			Type:    protocol.Msg_Room_List,
+			Payload: protocol.Room_List_Payload{Rooms: hub.Room_Listings()},
+		})
+	case protocol.Msg_Queue_Leave:
+		deliver(c, hub.queue.actor(), hub.queue.leave, c)
+	case protocol.Msg_Leave_Replay:
+		c.leave_replay()
+	case protocol.Msg_Resync:
+		c.handle_resync()
+	case protocol.Msg_Request_Snapshot:
+		c.handle_request_snapshot()
+	case protocol.Msg_Hint:
+		c.handle_hint()
+	}
+}
+
+func (c *Client) send_lobby_action(action Lobby_Action) {
+	action.client = c
+	room := c.current_room()
+	if room == nil || !deliver(c, room.actor(), room.lobby, action) {
+		c.send_error("not in a room")
 	}
 }
 
 func (c *Client) handle_fill_bots() {
-	if c.room == nil {
-		return
+	room := c.current_room()
+	if room == nil || !deliver(c, room.actor(), room.fill_bots, c) {
+		c.send_error("not in a room")
 	}
-	c.room.fill_bots <- c
 }
 
-func (c *Client) handle_create_room(hub *Hub, msg *protocol.Message) {
-	payload_bytes, _ := json.Marshal(msg.Payload)
-	var payload protocol.Create_Room_Payload
-	json.Unmarshal(payload_bytes, &payload)
+func (c *Client) handle_create_room(hub *Hub, payload *protocol.Create_Room_Payload) {
+	if !c.set_name(protocol.Msg_Create_Room, payload.Player_Name) {
+		return
+	}
+	deliver(c, hub.queue.actor(), hub.queue.leave, c)
+	room := hub.create_room(Room_Settings{
+		Private:  payload.Visibility == protocol.Visibility_Private,
+		Password: payload.Password,
+	})
+	deliver(c, room.actor(), room.join, Join_Action{client: c, invite: room.invite})
+}
 
-	c.name = payload.Player_Name
-	room := hub.create_room()
-	room.join <- c
+func (c *Client) handle_join_room(hub *Hub, payload *protocol.Join_Room_Payload) {
+	if !c.set_name(protocol.Msg_Join_Room, payload.Player_Name) {
+		return
+	}
+	room := hub.get_room(payload.Room_Id)
+	if room == nil {
+		c.send_error("room not found")
+		return
+	}
+	deliver(c, hub.queue.actor(), hub.queue.leave, c)
+	action := Join_Action{client: c, password: payload.Password, invite: payload.Invite_Token}
+	if err := room.check_password(&action); err != nil {
+		c.send_error(err.Error())
+		return
+	}
+	if !deliver(c, room.actor(), room.join, action) {
+		c.send_error("room not found")
+	}
 }
 
-func (c *Client) handle_join_room(hub *Hub, msg *protocol.Message) {
-	payload_bytes, _ := json.Marshal(msg.Payload)
-	var payload protocol.Join_Room_Payload
-	json.Unmarshal(payload_bytes, &payload)
+func (c *Client) handle_spectate_room(hub *Hub, payload *protocol.Spectate_Room_Payload) {
+	if c.current_room() != nil {
+		c.send_error("already in a room")
+		return
+	}
 
-	c.name = payload.Player_Name
+	if !c.set_name(protocol.Msg_Spectate_Room, payload.Player_Name) {
+		return
+	}
+	deliver(c, hub.queue.actor(), hub.queue.leave, c)
 	room := hub.get_room(payload.Room_Id)
 	if room == nil {
 		c.send_error("room not found")
 		return
 	}
-	room.join <- c
+	action := Join_Action{client: c, password: payload.Password, invite: payload.Invite_Token}
+	if err := room.check_password(&action); err != nil {
+		c.send_error(err.Error())
+		return
+	}
+	if !deliver(c, room.actor(), room.spectate, action) {
+		c.send_error("room not found")
+	}
 }
 
-func (c *Client) handle_play_cards(msg *protocol.Message) {
-	if c.room == nil {
+func (c *Client) handle_queue_join(hub *Hub, payload *protocol.Queue_Join_Payload) {
+	if c.current_room() != nil {
+		c.send_error("already in a room")
+		return
+	}
+
+	if !c.set_name(protocol.Msg_Queue_Join, payload.Player_Name) {
 		return
 	}
+	deliver(c, hub.queue.actor(), hub.queue.join, Queue_Action{client: c, payload: *payload})
+}
 
-	payload_bytes, _ := json.Marshal(msg.Payload)
-	var payload protocol.Play_Cards_Payload
-	json.Unmarshal(payload_bytes, &payload)
+func (c *Client) send_rematch_vote(action Rematch_Action) {
+	action.client = c
+	room := c.current_room()
+	if room == nil || !deliver(c, room.actor(), room.rematch, action) {
+		c.send_error("not in a room")
+	}
+}
 
-	c.room.play <- Play_Action{
-		client:   c,
-		card_ids: payload.Card_Ids,
+func (c *Client) send_chat(action Chat_Action) {
+	action.client = c
+	room := c.current_room()
+	if room == nil || !deliver(c, room.actor(), room.chat, action) {
+		c.send_error("not in a room")
 	}
 }
 
-func (c *Client) handle_pass() {
-	if c.room == nil {
+// set_name keeps the account display name unless the player typed another
+// name for this table. Guests have no name to fall back on and must give one.
+func (c *Client) set_name(kind protocol.Msg_Type, name string) bool {
+	if name != "" || c.username == "" {
+		c.name = strings.TrimSpace(name)
+	}
+	if c.name == "" {
+		c.send_decode_error(&protocol.Decode_Error{
+			Type:   kind,
+			Code:   protocol.Error_Invalid,
+			Field:  "player_name",
+			Reason: "is required",
+		})
+		return false
+	}
+	return true
+}
+
+func (c *Client) handle_rejoin_room(hub *Hub, payload *protocol.Rejoin_Room_Payload) {
+	room := hub.get_room(payload.Room_Id)
+	if room == nil {
+		c.send_error("room not found")
+		return
+	}
+
+	sent := deliver(c, room.actor(), room.rejoin, Rejoin_Action{
+		client:    c,
+		player_id: payload.Player_Id,
+		token:     payload.Session_Token,
+	})
+	if !sent {
+		c.send_error("room not found")
+	}
+}
+
+func (c *Client) handle_open_replay(hub *Hub, payload *protocol.Open_Replay_Payload) {
+	replay, err := hub.open_replay(payload.Match_Id, payload.Hand)
+	if err != nil {
+		c.send_error("replay not found")
 		return
 	}
 
-	c.room.pass <- c
+	c.leave_replay()
+	deliver(c, replay.actor(), replay.join, c)
 }
 
-func (c *Client) handle_tribute_give(msg *protocol.Message) {
-	if c.room == nil {
+func (c *Client) handle_join_replay(hub *Hub, payload *protocol.Join_Replay_Payload) {
+	replay := hub.get_replay(payload.Replay_Id)
+	if replay == nil || replay == c.current_replay() {
+		if replay == nil {
+			c.send_error("replay not found")
+		}
 		return
 	}
 
-	payload_bytes, _ := json.Marshal(msg.Payload)
-	var payload protocol.Tribute_Give_Payload
-	json.Unmarshal(payload_bytes, &payload)
+	c.leave_replay()
+	if !deliver(c, replay.actor(), replay.join, c) {
+		c.send_error("replay not found")
+	}
+}
+
+func (c *Client) leave_replay() {
+	if replay := c.current_replay(); replay != nil {
+		deliver(c, replay.actor(), replay.leave, c)
+	}
+}
+
+func (c *Client) handle_replay_control(payload *protocol.Replay_Control_Payload) {
+	action := Replay_Control_Action{
+		client: c,
+		action: payload.Action,
+		step:   payload.Step,
+		speed:  payload.Speed,
+	}
+	replay := c.current_replay()
+	if replay == nil || !deliver(c, replay.actor(), replay.control, action) {
+		c.send_error("not watching a replay")
+	}
+}
+
+func (c *Client) handle_play_cards(payload *protocol.Play_Cards_Payload) {
+	action := Play_Action{client: c, card_ids: payload.Card_Ids}
+	room := c.current_room()
+	if room == nil || !deliver(c, room.actor(), room.play, action) {
+		c.send_error("not in a room")
+	}
+}
 
-	c.room.tribute <- Tribute_Action{
-		client:  c,
-		card_id: payload.Card_Id,
+func (c *Client) handle_pass() {
+	room := c.current_room()
+	if room == nil || !deliver(c, room.actor(), room.pass, c) {
+		c.send_error("not in a room")
 	}
 }
 
+func (c *Client) handle_tribute_give(payload *protocol.Tribute_Give_Payload) {
+	action := Tribute_Action{client: c, card_id: payload.Card_Id}
+	room := c.current_room()
+	if room == nil || !deliver(c, room.actor(), room.tribute, action) {
+		c.send_error("not in a room")
+	}
+}
+
+// send_message queues a message for the write pump, counting it and any that
+// are dropped because the buffer is full.
 func (c *Client) send_message(msg *protocol.Message) {
+	if c.offline || c.is_bot {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.queued++
 	data, err := json.Marshal(msg)
 	if err != nil {
 		return
@@ -206,14 +527,118 @@ func (c *Client) send_message(msg *protocol.Message) {
 	select {
 	case c.send <- data:
 	default:
+		c.dropped_message()
 	}
 }
 
 func (c *Client) send_error(message string) {
-	c.send_message(&protocol.Message{
-		Type: protocol.Msg_Error,
-		Payload: protocol.Error_Payload{
-			Message: message,
-		},
-	})
+	c.reject(protocol.Error_Payload{Message: message})
+}
+
+// reject sends an error that answers the request being handled, if any.
+func (c *Client) reject(payload protocol.Error_Payload) {
+	c.mu.Lock()
+	id := c.request
+	c.rejected = true
+	c.mu.Unlock()
+
+	c.send_message(&protocol.Message{Type: protocol.Msg_Error, Request_Id: id, Payload: payload})
+}
+
+func (c *Client) begin_request(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.request = id
+	c.rejected = false
+	c.touched = c.touched[:0]
+}
+
+// end_request waits for every actor the message was handed to, then acks it
+// unless one of them rejected it. Messages without an id get neither.
+func (c *Client) end_request(kind protocol.Msg_Type) {
+	if c.request != "" {
+		for _, a := range c.touched {
+			a.settle()
+		}
+	}
+
+	c.mu.Lock()
+	id, rejected := c.request, c.rejected
+	c.request = ""
+	c.mu.Unlock()
+
+	if id != "" && !rejected {
+		c.send_message(&protocol.Message{
+			Type:       protocol.Msg_Ack,
+			Request_Id: id,
+			Payload:    protocol.Ack_Payload{Message_Type: kind},
+		})
+	}
+}
+
+// actor is a goroutine that handles one message at a time: a room, replay
+// room or the queue.
+type actor struct {
+	sync chan struct{}
+	done chan struct{}
+}
+
+// settle returns once the actor has finished everything sent before it; the
+// actor only takes the sync after the previous message is handled.
+func (a actor) settle() {
+	select {
+	case a.sync <- struct{}{}:
+	case <-a.done:
+	}
+}
+
+// deliver hands value to an actor on behalf of the current request.
+func deliver[T any](c *Client, a actor, ch chan T, value T) bool {
+	c.touched = append(c.touched, a)
+	select {
+	case ch <- value:
+		return true
+	case <-a.done:
+		return false
+	}
+}
+
+func (c *Client) handle_resync() {
+	room := c.current_room()
+	if room == nil || !deliver(c, room.actor(), room.resync, c) {
+		c.send_error("not in a room")
+	}
+}
+
+func (c *Client) handle_request_snapshot() {
+	room := c.current_room()
+	if room == nil || !deliver(c, room.actor(), room.snapshot, c) {
+		c.send_error("not in a room")
+	}
+}
+
+func (c *Client) handle_complete_selection(payload *protocol.Complete_Selection_Payload) {
+	room := c.current_room()
+	if room == nil || !deliver(c, room.actor(), room.complete, Play_Action{client: c, card_ids: payload.Card_Ids}) {
+		c.send_error("not in a room")
+	}
+}
+
+func (c *Client) handle_hint() {
+	room := c.current_room()
+	if room == nil || !deliver(c, room.actor(), room.hint, c) {
+		c.send_error("not in a room")
+	}
+}
+
+func (c *Client) send_decode_error(err error) {
+	payload := protocol.Error_Payload{Message: err.Error(), Code: protocol.Error_Malformed}
+	var de *protocol.Decode_Error
+	if errors.As(err, &de) {
+		payload.Code = de.Code
+		payload.Message_Type = de.Type
+		payload.Field = de.Field
+	}
+	c.reject(payload)
 }
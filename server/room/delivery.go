@@ -0,0 +1,158 @@
+package room
+
+import (
+	"cmp"
+	"net/http"
+	"slices"
+	"time"
+
+	"guandanbtw/web"
+)
+
+// Delivery_Policy decides what happens to a client whose send queue
+// overflowed and lost a message.
+type Delivery_Policy string
+
+const (
+	// Delivery_Resync resends the room and game snapshot once the backlog has
+	// drained, and falls back to disconnecting after Max_Resyncs catch-ups.
+	Delivery_Resync Delivery_Policy = "resync"
+	// Delivery_Disconnect closes the connection on the first drop; a seated
+	// player rejoins with their session and gets a snapshot.
+	Delivery_Disconnect Delivery_Policy = "disconnect"
+)
+
+type Delivery_Config struct {
+	Policy         Delivery_Policy
+	Max_Resyncs    int
+	Check_Interval time.Duration
+}
+
+func Default_Delivery_Config() Delivery_Config {
+	return Delivery_Config{
+		Policy:         Delivery_Resync,
+		Max_Resyncs:    3,
+		Check_Interval: time.Second,
+	}
+}
+
+// dropped_message is called with c.mu held when the send queue was full.
+func (c *Client) dropped_message() {
+	c.dropped++
+	if c.delivery.Policy == Delivery_Disconnect || c.resyncs >= c.delivery.Max_Resyncs {
+		c.disconnect()
+		return
+	}
+	c.behind = true
+}
+
+func (c *Client) disconnect() {
+	if c.cut_off || c.conn == nil {
+		return
+	}
+	c.cut_off = true
+	c.conn.Close()
+}
+
+func (c *Client) was_cut_off() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cut_off
+}
+
+// take_catch_up reports whether the client lost messages and has since
+// drained at least half its queue, so a resync will fit.
+func (c *Client) take_catch_up() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.behind || c.cut_off || len(c.send) > cap(c.send)/2 {
+		return false
+	}
+	c.behind = false
+	c.resyncs++
+	return true
+}
+
+// catch_up resyncs members of the room that fell behind.
+func (r *Room) catch_up() {
+	for _, c := range r.clients {
+		if c != nil && !c.is_bot && c.take_catch_up() {
+			r.handle_resync(c)
+		}
+	}
+	for _, c := range r.spectators {
+		if c.take_catch_up() {
+			r.handle_resync(c)
+		}
+	}
+}
+
+type Client_Metrics struct {
+	Conn           int64 `json:"conn"`
+	Queue_Depth    int   `json:"queue_depth"`
+	Queue_Capacity int   `json:"queue_capacity"`
+	Sent           int64 `json:"sent"`
+	Dropped        int64 `json:"dropped"`
+	Resyncs        int   `json:"resyncs"`
+	Behind         bool  `json:"behind"`
+}
+
+// Metrics covers live connections; the totals also count closed ones.
+type Metrics struct {
+	Connections     int              `json:"connections"`
+	Rooms           int              `json:"rooms"`
+	Replays         int              `json:"replays"`
+	Max_Queue_Depth int              `json:"max_queue_depth"`
+	Dropped_Total   int64            `json:"dropped_total"`
+	Resyncs_Total   int64            `json:"resyncs_total"`
+	Disconnects     int64            `json:"disconnects"`
+	Clients         []Client_Metrics `json:"clients"`
+}
+
+func (c *Client) metrics() Client_Metrics {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return Client_Metrics{
+		Conn:           c.conn_id,
+		Queue_Depth:    len(c.send),
+		Queue_Capacity: cap(c.send),
+		Sent:           c.queued - c.dropped,
+		Dropped:        c.dropped,
+		Resyncs:        c.resyncs,
+		Behind:         c.behind,
+	}
+}
+
+func (h *Hub) Metrics() Metrics {
+	h.mu.RLock()
+	m := Metrics{
+		Rooms:         len(h.rooms),
+		Replays:       len(h.replays),
+		Dropped_Total: h.closed.dropped,
+		Resyncs_Total: h.closed.resyncs,
+		Disconnects:   h.closed.disconnects,
+	}
+	clients := make([]*Client, 0, len(h.clients))
+	for c := range h.clients {
+		clients = append(clients, c)
+	}
+	h.mu.RUnlock()
+
+	m.Connections = len(clients)
+	m.Clients = make([]Client_Metrics, 0, len(clients))
+	for _, c := range clients {
+		cm := c.metrics()
+		m.Max_Queue_Depth = max(m.Max_Queue_Depth, cm.Queue_Depth)
+		m.Dropped_Total += cm.Dropped
+		m.Resyncs_Total += int64(cm.Resyncs)
+		m.Clients = append(m.Clients, cm)
+	}
+	slices.SortFunc(m.Clients, func(a, b Client_Metrics) int { return cmp.Compare(b.Queue_Depth, a.Queue_Depth) })
+	return m
+}
+
+func (h *Hub) Handle_Metrics(w http.ResponseWriter, r *http.Request) {
+	web.Write_JSON(w, http.StatusOK, h.Metrics())
+}
@@ -0,0 +1,92 @@
+package room
+
+import (
+	"guandanbtw/game"
+	"guandanbtw/history"
+	"guandanbtw/protocol"
+)
+
+func (r *Room) handle_set_rated(client *Client, rated bool) {
+	if !r.check_host_in_lobby(client) {
+		return
+	}
+
+	r.casual = !rated
+	r.broadcast_room_state()
+}
+
+// hint_cache holds the last suggestions, which stay valid until the next event
+// lands in the hand log.
+type hint_cache struct {
+	log     *history.Hand_Log
+	events  int
+	payload protocol.Hint_Suggestions_Payload
+}
+
+// handle_hint answers the seat to move with ranked suggestions. The first hint
+// on a turn is recorded in the hand log so it shows up in the player's stats;
+// asking again on the same turn resends it without logging or searching.
+func (r *Room) handle_hint(client *Client) {
+	if !r.casual {
+		client.send_error("hints are off in rated rooms")
+		return
+	}
+	seat := r.get_seat(client)
+	if seat == -1 {
+		client.send_error("not seated in this room")
+		return
+	}
+	if r.game == nil || r.status != Status_Playing {
+		client.send_error("no game in progress")
+		return
+	}
+	if r.game.Phase != game.Phase_Play || r.game.Current_Turn != seat {
+		client.send_error(game.Err_Not_Your_Turn.Error())
+		return
+	}
+
+	if r.hand_log == nil || r.last_hint.log != r.hand_log || r.last_hint.events != len(r.hand_log.Events) {
+		r.record(history.Event{Kind: history.Event_Hint, Seat: seat})
+		r.last_hint = hint_cache{payload: protocol.Project_Hints(game.Suggest(r.game, seat))}
+		if r.hand_log != nil {
+			r.last_hint.log = r.hand_log
+			r.last_hint.events = len(r.hand_log.Events)
+		}
+	}
+	r.send(client, &protocol.Message{
+		Type:    protocol.Msg_Hint_Suggestions,
+		Payload: r.last_hint.payload,
+	})
+}
+
+// handle_complete_selection lists the plays a seated player could make with
+// the cards they have selected. The current lead counts unless the player
+// holds the trick themselves and so leads next.
+func (r *Room) handle_complete_selection(action Play_Action) {
+	client := action.client
+	seat := r.get_seat(client)
+	if seat == -1 {
+		client.send_error("not seated in this room")
+		return
+	}
+	if r.game == nil || r.status != Status_Playing || r.game.Phase != game.Phase_Play {
+		client.send_error("no game in progress")
+		return
+	}
+
+	selected := r.game.Get_Cards_By_Id(seat, action.card_ids)
+	if selected == nil {
+		client.send_error(game.Err_Invalid_Cards.Error())
+		return
+	}
+
+	lead := r.game.Current_Lead
+	if r.game.Lead_Player == seat {
+		lead = game.Combination{Type: game.Comb_Invalid}
+	}
+	completions := game.Complete_Selection(r.game.Hands[seat], selected, lead, r.game.Level)
+	r.send(client, &protocol.Message{
+		Type:    protocol.Msg_Selection_Completions,
+		Payload: protocol.Project_Completions(action.card_ids, completions),
+	})
+}
@@ -0,0 +1,46 @@
+package room
+
+import (
+	"testing"
+
+	"guandanbtw/game"
+	"guandanbtw/history"
+	"guandanbtw/protocol"
+)
+
+func count_hints(l *history.Hand_Log) int {
+	n := 0
+	for _, ev := range l.Events {
+		if ev.Kind == history.Event_Hint {
+			n++
+		}
+	}
+	return n
+}
+
+func TestRepeatedHintIsLoggedOnce(t *testing.T) {
+	r := new_test_room(t)
+	players := seat_test_players(r)
+	r.casual = true
+	r.start_game()
+	if r.game.Phase != game.Phase_Play {
+		t.Fatalf("phase = %v, want play", r.game.Phase)
+	}
+	client := players[r.game.Current_Turn]
+
+	for range 3 {
+		r.handle_hint(client)
+	}
+	if n := count_hints(r.hand_log); n != 1 {
+		t.Fatalf("%d hint events after three requests on one turn, want 1", n)
+	}
+	if got := drain(client); len(got) == 0 || got[len(got)-1].Type != protocol.Msg_Hint_Suggestions {
+		t.Fatalf("last message %+v, want hint_suggestions", got)
+	}
+
+	r.record(history.Event{Kind: history.Event_Pass, Seat: (r.game.Current_Turn + 1) % 4})
+	r.handle_hint(client)
+	if n := count_hints(r.hand_log); n != 2 {
+		t.Fatalf("%d hint events after the log moved on, want 2", n)
+	}
+}
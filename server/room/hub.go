@@ -4,15 +4,45 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"github.com/gorilla/websocket"
+	"guandanbtw/account"
+	"guandanbtw/matches"
+	"guandanbtw/protocol"
+	"guandanbtw/rating"
+	"guandanbtw/stats"
+	"guandanbtw/store"
+	"guandanbtw/web"
 	"net/http"
 	"sync"
+	"sync/atomic"
 )
 
 type Hub struct {
-	rooms      map[string]*Room
-	register   chan *Client
-	unregister chan *Client
-	mu         sync.RWMutex
+	rooms       map[string]*Room
+	replays     map[string]*Replay_Room
+	listings    map[string]protocol.Room_Listing
+	room_config Room_Config
+	store       store.Store
+	accounts    *account.Registry
+	ratings     *rating.Ladder
+	stats       *stats.Tracker
+	matches     *matches.Index
+	queue       *Queue
+	solver      *replay_solver
+	passwords   *account.Limiter
+	delivery    Delivery_Config
+	clients     map[*Client]bool
+	conn_seq    int64
+	closed      closed_totals
+	register    chan *Client
+	unregister  chan *Client
+	mu          sync.RWMutex
+}
+
+// closed_totals keeps the delivery counters of connections that are gone.
+type closed_totals struct {
+	dropped     int64
+	resyncs     int64
+	disconnects int64
 }
 
 var upgrader = websocket.Upgrader{
@@ -23,23 +53,66 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
-func New_Hub() *Hub {
-	return &Hub{
-		rooms:      make(map[string]*Room),
-		register:   make(chan *Client),
-		unregister: make(chan *Client),
+// Hub_Options wires the hub to its services. Everything but Rooms may be nil.
+type Hub_Options struct {
+	Rooms    Room_Config
+	Store    store.Store
+	Accounts *account.Registry
+	Ratings  *rating.Ladder
+	Stats    *stats.Tracker
+	Matches  *matches.Index
+	Queue    Queue_Config
+	Delivery Delivery_Config
+}
+
+func New_Hub(opts Hub_Options) *Hub {
+	if opts.Queue == (Queue_Config{}) {
+		opts.Queue = Default_Queue_Config()
+	}
+	if opts.Delivery == (Delivery_Config{}) {
+		opts.Delivery = Default_Delivery_Config()
 	}
+
+	h := &Hub{
+		rooms:       make(map[string]*Room),
+		replays:     make(map[string]*Replay_Room),
+		listings:    make(map[string]protocol.Room_Listing),
+		room_config: opts.Rooms,
+		store:       opts.Store,
+		accounts:    opts.Accounts,
+		ratings:     opts.Ratings,
+		stats:       opts.Stats,
+		matches:     opts.Matches,
+		solver:      new_replay_solver(),
+		passwords:   account.New_Limiter(),
+		delivery:    opts.Delivery,
+		clients:     make(map[*Client]bool),
+		register:    make(chan *Client),
+		unregister:  make(chan *Client),
+	}
+	h.queue = new_queue(h, opts.Queue)
+	return h
 }
 
 func (h *Hub) Run() {
+	go h.queue.run()
+
 	for {
 		select {
 		case client := <-h.register:
-			_ = client
+			h.mu.Lock()
+			h.clients[client] = true
+			h.mu.Unlock()
 		case client := <-h.unregister:
-			if client.room != nil {
-				client.room.leave <- client
+			m := client.metrics()
+			h.mu.Lock()
+			delete(h.clients, client)
+			h.closed.dropped += m.Dropped
+			h.closed.resyncs += int64(m.Resyncs)
+			if client.was_cut_off() {
+				h.closed.disconnects++
 			}
+			h.mu.Unlock()
 		}
 	}
 }
@@ -51,18 +124,42 @@ func (h *Hub) Handle_Websocket(w http.ResponseWriter, r *http.Request) {
 	}
 
 	client := new_client(generate_id(), conn)
+	client.addr = web.Client_Addr(r)
+	client.delivery = h.delivery
+	client.conn_id = atomic.AddInt64(&h.conn_seq, 1)
+
+	invalid_session := false
+	if token := r.URL.Query().Get("token"); token != "" && h.accounts != nil {
+		if profile, ok := h.accounts.Authenticate(token); ok {
+			client.id = profile.Id
+			client.name = profile.Display_Name
+			client.username = profile.Username
+		} else {
+			invalid_session = true
+		}
+	}
 
 	h.register <- client
+	if invalid_session {
+		client.send_error("invalid session")
+	}
 
 	go client.write_pump()
 	go client.read_pump(h)
 }
 
-func (h *Hub) create_room() *Room {
+func (h *Hub) create_room(settings Room_Settings) *Room {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	room := new_room(generate_room_code())
+	code := generate_room_code()
+	for h.rooms[code] != nil {
+		code = generate_room_code()
+	}
+
+	room := new_room(code, h, h.room_config)
+	room.private = settings.Private
+	room.set_password(settings.Password)
 	h.rooms[room.id] = room
 	go room.run()
 
@@ -73,7 +170,10 @@ func (h *Hub) get_room(id string) *Room {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
-	return h.rooms[id]
+	if room := h.rooms[id]; room != nil {
+		return room
+	}
+	return h.rooms[normalize_room_code(id)]
 }
 
 func (h *Hub) delete_room(id string) {
@@ -81,16 +181,64 @@ func (h *Hub) delete_room(id string) {
 	defer h.mu.Unlock()
 
 	delete(h.rooms, id)
+	delete(h.listings, id)
 }
 
-func generate_id() string {
-	b := make([]byte, 16)
-	rand.Read(b)
-	return hex.EncodeToString(b)
+func (h *Hub) Restore() (int, error) {
+	if h.store == nil {
+		return 0, nil
+	}
+
+	records, err := h.store.Load_Rooms()
+	if err != nil {
+		return 0, err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, rec := range records {
+		if Room_Status(rec.Status) == Status_Closed {
+			continue
+		}
+
+		room := restore_room(h, rec)
+		h.rooms[room.id] = room
+		if listing, ok := room.listing(); ok {
+			h.listings[room.id] = listing
+		}
+		room.trigger_bot_turn_if_needed()
+		go room.run()
+	}
+
+	return len(h.rooms), nil
 }
 
-func generate_room_code() string {
-	b := make([]byte, 3)
+func (h *Hub) Shutdown() {
+	h.mu.RLock()
+	rooms := make([]*Room, 0, len(h.rooms))
+	for _, room := range h.rooms {
+		rooms = append(rooms, room)
+	}
+	replays := make([]*Replay_Room, 0, len(h.replays))
+	for _, replay := range h.replays {
+		replays = append(replays, replay)
+	}
+	h.mu.RUnlock()
+
+	h.queue.stop()
+	for _, room := range rooms {
+		room.stop()
+		<-room.done
+	}
+	for _, replay := range replays {
+		replay.stop()
+		<-replay.done
+	}
+}
+
+func generate_id() string {
+	b := make([]byte, 16)
 	rand.Read(b)
 	return hex.EncodeToString(b)
 }
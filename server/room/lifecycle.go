@@ -0,0 +1,151 @@
+package room
+
+import (
+	"time"
+
+	"guandanbtw/protocol"
+)
+
+type Room_Status int
+
+const (
+	Status_Lobby Room_Status = iota
+	Status_Playing
+	Status_Finished
+	Status_Closed
+)
+
+func (s Room_Status) String() string {
+	switch s {
+	case Status_Lobby:
+		return "lobby"
+	case Status_Playing:
+		return "playing"
+	case Status_Finished:
+		return "finished"
+	case Status_Closed:
+		return "closed"
+	}
+	return "unknown"
+}
+
+// Room_Config times out rooms. A room whose seated players have all
+// dropped gets Offline_Timeout to see one of them reconnect, which also
+// covers every room restored after a restart; a room with no humans seated
+// at all closes after Empty_Timeout.
+type Room_Config struct {
+	Empty_Timeout   time.Duration
+	Offline_Timeout time.Duration
+	Idle_Timeout    time.Duration
+	Sweep_Interval  time.Duration
+	Chat            Chat_Config
+}
+
+func Default_Room_Config() Room_Config {
+	return Room_Config{
+		Empty_Timeout:   2 * time.Minute,
+		Offline_Timeout: 10 * time.Minute,
+		Idle_Timeout:    30 * time.Minute,
+		Sweep_Interval:  15 * time.Second,
+		Chat:            Default_Chat_Config(),
+	}
+}
+
+func (r *Room) sweep(now time.Time) bool {
+	connected, seated := r.humans()
+	if connected > 0 {
+		r.empty_since = time.Time{}
+	} else if r.empty_since.IsZero() {
+		r.empty_since = now
+	}
+
+	timeout := r.config.Empty_Timeout
+	if seated > 0 {
+		timeout = max(timeout, r.config.Offline_Timeout)
+	}
+	if !r.empty_since.IsZero() && now.Sub(r.empty_since) >= timeout {
+		r.close("room is empty", true)
+		return true
+	}
+
+	if now.Sub(r.last_activity) >= r.config.Idle_Timeout {
+		r.close("room was idle for too long", true)
+		return true
+	}
+
+	return false
+}
+
+func (r *Room) close(reason string, discard bool) {
+	if discard {
+		r.status = Status_Closed
+		r.discard()
+	} else {
+		r.persist()
+	}
+
+	r.seq++
+	for i, c := range r.clients {
+		if c == nil {
+			continue
+		}
+
+		if !c.is_bot && !c.offline {
+			r.send(c, &protocol.Message{
+				Type: protocol.Msg_Room_Closed,
+				Payload: protocol.Room_Closed_Payload{
+					Room_Id: r.id,
+					Reason:  reason,
+				},
+			})
+		}
+		c.set_room(nil)
+		r.clients[i] = nil
+	}
+	for _, c := range r.spectators {
+		r.send(c, &protocol.Message{
+			Type: protocol.Msg_Room_Closed,
+			Payload: protocol.Room_Closed_Payload{
+				Room_Id: r.id,
+				Reason:  reason,
+			},
+		})
+		c.set_room(nil)
+	}
+	r.spectators = nil
+
+	close(r.done)
+	r.hub.delete_room(r.id)
+}
+
+func (r *Room) stop() {
+	r.stop_once.Do(func() {
+		close(r.shutdown)
+	})
+}
+
+// humans counts the human players seated, and those of them connected.
+func (r *Room) humans() (connected, seated int) {
+	for _, c := range r.clients {
+		if c != nil && !c.is_bot {
+			seated++
+			if !c.offline {
+				connected++
+			}
+		}
+	}
+	return connected, seated
+}
+
+func (r *Room) actor() actor {
+	return actor{sync: r.sync, done: r.done}
+}
+
+func send_to_room[T any](r *Room, ch chan T, value T) bool {
+	select {
+	case ch <- value:
+		return true
+	case <-r.done:
+		return false
+	}
+}
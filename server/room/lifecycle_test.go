@@ -0,0 +1,50 @@
+package room
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSweepTimeouts(t *testing.T) {
+	config := Default_Room_Config()
+	tests := []struct {
+		name   string
+		seat   func(r *Room)
+		after  time.Duration
+		closes bool
+	}{
+		{"connected players stay", func(r *Room) { seat_test_players(r) }, time.Hour / 4, false},
+		{"empty room closes", func(r *Room) {}, config.Empty_Timeout, true},
+		{"only bots closes", func(r *Room) { r.seat_bot(0) }, config.Empty_Timeout, true},
+		{"offline players get longer", func(r *Room) {
+			for _, c := range seat_test_players(r) {
+				c.offline = true
+			}
+		}, config.Empty_Timeout, false},
+		{"offline players time out", func(r *Room) {
+			for _, c := range seat_test_players(r) {
+				c.offline = true
+			}
+		}, config.Offline_Timeout, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := New_Hub(Hub_Options{Rooms: config})
+			r := new_room("TEST", hub, config)
+			tt.seat(r)
+
+			start := time.Now()
+			r.last_activity = start.Add(tt.after)
+			if r.sweep(start) {
+				t.Fatal("closed on the first sweep")
+			}
+			if got := r.sweep(start.Add(tt.after)); got != tt.closes {
+				t.Fatalf("closed = %v after %v, want %v", got, tt.after, tt.closes)
+			}
+			if !tt.closes {
+				close(r.done)
+			}
+		})
+	}
+}
@@ -0,0 +1,284 @@
+package room
+
+import (
+	"fmt"
+
+	"guandanbtw/protocol"
+)
+
+func (r *Room) handle_lobby(action Lobby_Action) {
+	if r.get_seat(action.client) == -1 {
+		action.client.send_error("you are not seated")
+		return
+	}
+
+	if r.status == Status_Playing {
+		action.client.send_error("game already in progress")
+		return
+	}
+
+	switch action.kind {
+	case protocol.Msg_Choose_Seat:
+		r.handle_choose_seat(action.client, action.seat)
+	case protocol.Msg_Choose_Team:
+		r.handle_choose_team(action.client, action.team)
+	case protocol.Msg_Swap_Seats:
+		r.handle_swap_seats(action.client, action.seat, action.other_seat)
+	case protocol.Msg_Set_Ready:
+		r.handle_set_ready(action.client, action.ready)
+	case protocol.Msg_Kick_Player:
+		r.handle_kick_player(action.client, action.player_id)
+	case protocol.Msg_Add_Bot:
+		r.handle_add_bot(action.client, action.seat)
+	case protocol.Msg_Remove_Bot:
+		r.handle_remove_bot(action.client, action.seat)
+	case protocol.Msg_Start_Game:
+		r.handle_start_game(action.client)
+	case protocol.Msg_Set_Quiet:
+		r.handle_set_quiet(action.client, action.quiet)
+	case protocol.Msg_Set_Rated:
+		r.handle_set_rated(action.client, action.rated)
+	case protocol.Msg_Set_Tracker:
+		r.handle_set_card_tracker(action.client, action.tracker)
+	case protocol.Msg_Set_Series:
+		r.handle_set_series(action.client, action.best_of)
+	case protocol.Msg_Room_Settings:
+		r.handle_room_settings(action.client, action.settings, action.secret)
+	}
+}
+
+func (r *Room) handle_choose_seat(client *Client, seat int) {
+	if !valid_seat(seat) {
+		client.send_error("invalid seat")
+		return
+	}
+
+	current := r.get_seat(client)
+	if current == seat {
+		return
+	}
+
+	if r.clients[seat] != nil {
+		client.send_error("seat is taken")
+		return
+	}
+
+	r.clients[current] = nil
+	r.clients[seat] = client
+	client.is_ready = false
+
+	r.broadcast_room_state()
+}
+
+func (r *Room) handle_choose_team(client *Client, team int) {
+	if team != 0 && team != 1 {
+		client.send_error("invalid team")
+		return
+	}
+
+	if r.get_seat(client)%2 == team {
+		return
+	}
+
+	for seat := team; seat < 4; seat += 2 {
+		if r.clients[seat] == nil {
+			r.handle_choose_seat(client, seat)
+			return
+		}
+	}
+
+	client.send_error("team is full")
+}
+
+func (r *Room) handle_swap_seats(client *Client, seat_a int, seat_b int) {
+	if !r.check_host_in_lobby(client) {
+		return
+	}
+
+	if !valid_seat(seat_a) || !valid_seat(seat_b) || seat_a == seat_b {
+		client.send_error("invalid seat")
+		return
+	}
+
+	r.clients[seat_a], r.clients[seat_b] = r.clients[seat_b], r.clients[seat_a]
+	for _, seat := range []int{seat_a, seat_b} {
+		if c := r.clients[seat]; c != nil && !c.is_bot {
+			c.is_ready = false
+		}
+	}
+
+	r.broadcast_room_state()
+}
+
+func (r *Room) handle_set_ready(client *Client, ready bool) {
+	if client.is_ready == ready {
+		return
+	}
+
+	client.is_ready = ready
+	r.broadcast_room_state()
+}
+
+func (r *Room) handle_kick_player(client *Client, player_id string) {
+	if !r.check_host_in_lobby(client) {
+		return
+	}
+
+	if player_id == client.id {
+		client.send_error("cannot kick yourself")
+		return
+	}
+
+	kicked := &protocol.Message{
+		Type: protocol.Msg_Kicked,
+		Payload: protocol.Error_Payload{
+			Message: "you were removed from the room",
+		},
+	}
+	for i, c := range r.clients {
+		if c == nil || c.id != player_id {
+			continue
+		}
+
+		r.send(c, kicked)
+		r.vacate(i)
+		r.broadcast_room_state()
+		if r.status == Status_Finished {
+			r.check_rematch()
+		}
+		return
+	}
+	for _, c := range r.spectators {
+		if c.id != player_id {
+			continue
+		}
+
+		r.remove_spectator(c)
+		c.set_room(nil)
+		r.send(c, kicked)
+		r.broadcast_room_state()
+		return
+	}
+
+	client.send_error("player not found")
+}
+
+func (r *Room) handle_add_bot(client *Client, seat int) {
+	if !r.check_host_in_lobby(client) {
+		return
+	}
+
+	if !valid_seat(seat) {
+		client.send_error("invalid seat")
+		return
+	}
+
+	if r.clients[seat] != nil {
+		client.send_error("seat is taken")
+		return
+	}
+
+	r.seat_bot(seat)
+	r.broadcast_room_state()
+}
+
+func (r *Room) handle_remove_bot(client *Client, seat int) {
+	if !r.check_host_in_lobby(client) {
+		return
+	}
+
+	if !valid_seat(seat) || r.clients[seat] == nil || !r.clients[seat].is_bot {
+		client.send_error("no bot in that seat")
+		return
+	}
+
+	r.vacate(seat)
+	r.broadcast_room_state()
+	if r.status == Status_Finished {
+		r.check_rematch()
+	}
+}
+
+// handle_start_game only starts the first match. Between matches the next
+// one starts from the rematch vote, which keeps the series and the votes
+// straight.
+func (r *Room) handle_start_game(client *Client) {
+	if !r.check_host_in_lobby(client) {
+		return
+	}
+	if r.status == Status_Finished {
+		client.send_error("vote for a rematch to play again")
+		return
+	}
+
+	if !r.is_full() {
+		client.send_error("all four seats must be filled")
+		return
+	}
+
+	for _, c := range r.clients {
+		if c != client && !c.is_ready {
+			client.send_error("not everyone is ready")
+			return
+		}
+	}
+
+	client.is_ready = true
+	r.broadcast_room_state()
+	r.start_game()
+}
+
+func (r *Room) check_host_in_lobby(client *Client) bool {
+	if client != r.host {
+		client.send_error("only the host can do that")
+		return false
+	}
+
+	if r.status == Status_Playing {
+		client.send_error("game already in progress")
+		return false
+	}
+
+	return true
+}
+
+func (r *Room) next_host() *Client {
+	for _, c := range r.clients {
+		if c != nil && !c.is_bot {
+			return c
+		}
+	}
+	return nil
+}
+
+func (r *Room) seat_bot(seat int) {
+	bot := new_bot(generate_id(), r.next_bot_name())
+	bot.set_room(r)
+	r.clients[seat] = bot
+}
+
+func (r *Room) next_bot_name() string {
+	used := make(map[string]bool)
+	for _, c := range r.clients {
+		if c != nil && c.is_bot {
+			used[c.name] = true
+		}
+	}
+
+	for _, name := range bot_names {
+		if !used[name] {
+			return name
+		}
+	}
+
+	for i := len(bot_names) + 1; ; i++ {
+		name := fmt.Sprintf("Bot %d", i)
+		if !used[name] {
+			return name
+		}
+	}
+}
+
+func valid_seat(seat int) bool {
+	return seat >= 0 && seat < 4
+}
@@ -0,0 +1,68 @@
+package room
+
+import (
+	"testing"
+
+	"guandanbtw/protocol"
+)
+
+func TestKickClearsTheSeat(t *testing.T) {
+	r := new_test_room(t)
+	players := seat_test_players(r)
+	r.status = Status_Finished
+	r.rematch_votes[1] = false
+	r.rematch_votes[2] = true
+
+	r.handle_lobby(Lobby_Action{client: players[0], kind: protocol.Msg_Kick_Player, player_id: players[2].id})
+
+	if r.clients[2] != nil {
+		t.Fatal("the kicked player kept their seat")
+	}
+	if players[2].current_room() != nil {
+		t.Fatal("the kicked player still points at the room")
+	}
+	if _, ok := r.rematch_votes[2]; ok {
+		t.Fatal("the kicked player's rematch vote was kept")
+	}
+	if got := drain(players[2]); len(got) == 0 || got[0].Type != protocol.Msg_Kicked {
+		t.Fatalf("kicked player got %+v, want kicked", got)
+	}
+}
+
+func TestRemoveBotClearsTheSeat(t *testing.T) {
+	r := new_test_room(t)
+	players := seat_test_players(r)
+	r.clients[3] = nil
+	r.seat_bot(3)
+	bot := r.clients[3]
+	r.rematch_votes[3] = false
+
+	r.handle_lobby(Lobby_Action{client: players[0], kind: protocol.Msg_Remove_Bot, seat: 3})
+
+	if r.clients[3] != nil || bot.current_room() != nil {
+		t.Fatal("the bot was not fully removed")
+	}
+	if _, ok := r.rematch_votes[3]; ok {
+		t.Fatal("the seat's rematch vote was kept")
+	}
+}
+
+func TestKickSpectator(t *testing.T) {
+	r := new_test_room(t)
+	players := seat_test_players(r)
+	spectator := new_client(generate_id(), nil)
+	spectator.set_room(r)
+	r.spectators = append(r.spectators, spectator)
+
+	players[0].begin_request("r1")
+	r.handle_lobby(Lobby_Action{client: players[0], kind: protocol.Msg_Kick_Player, player_id: spectator.id})
+	if players[0].rejected {
+		t.Fatal("kicking a spectator was rejected")
+	}
+	if r.is_spectator(spectator) || spectator.current_room() != nil {
+		t.Fatal("the spectator is still in the room")
+	}
+	if got := drain(spectator); len(got) == 0 || got[0].Type != protocol.Msg_Kicked {
+		t.Fatalf("spectator got %+v, want kicked", got)
+	}
+}
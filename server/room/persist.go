@@ -0,0 +1,250 @@
+package room
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"guandanbtw/account"
+	"guandanbtw/history"
+	"guandanbtw/protocol"
+	"guandanbtw/rating"
+	"guandanbtw/store"
+)
+
+func (r *Room) room_record() *store.Room_Record {
+	rec := &store.Room_Record{
+		Id:           r.id,
+		Status:       int(r.status),
+		Game:         r.game,
+		Match_Id:     r.match_id,
+		Hand_Number:  r.hand_number,
+		Hand_Log:     r.hand_log,
+		Event_Seq:    r.event_seq,
+		Quiet_Hands:  r.quiet_hands,
+		Casual:       r.casual,
+		Card_Tracker: r.card_tracker,
+		Access: &store.Room_Access{
+			Private:      r.private,
+			Password:     r.password.Load(),
+			Invite_Token: r.invite,
+		},
+		Series: &store.Series_Record{
+			Best_Of: r.best_of,
+			Wins:    r.series_wins,
+			Game:    r.series_game,
+		},
+		Updated_At: time.Now(),
+	}
+
+	if r.host != nil {
+		rec.Host_Id = r.host.id
+	}
+
+	for i, c := range r.clients {
+		if c == nil {
+			continue
+		}
+		rec.Seats[i] = &store.Seat_Record{
+			Player_Id: c.id,
+			Name:      c.name,
+			Token:     c.token,
+			Username:  c.username,
+			Is_Bot:    c.is_bot,
+			Is_Ready:  c.is_ready,
+		}
+	}
+
+	return rec
+}
+
+func (r *Room) persist() {
+	if r.hub.store == nil {
+		return
+	}
+
+	if err := r.hub.store.Save_Room(r.room_record()); err != nil {
+		log.Printf("room %s: save failed: %v", r.id, err)
+	}
+}
+
+func (r *Room) log_event(kind string, seat int, data interface{}) {
+	if r.hub.store == nil {
+		return
+	}
+
+	r.event_seq++
+	ev := &store.Event{
+		Seq:  r.event_seq,
+		Time: time.Now(),
+		Type: kind,
+		Seat: seat,
+	}
+
+	if data != nil {
+		raw, err := json.Marshal(data)
+		if err != nil {
+			log.Printf("room %s: encode %s event: %v", r.id, kind, err)
+			return
+		}
+		ev.Data = raw
+	}
+
+	if err := r.hub.store.Append_Event(r.id, ev); err != nil {
+		log.Printf("room %s: append event failed: %v", r.id, err)
+	}
+}
+
+func (r *Room) archive_hand() {
+	if r.hub.store == nil || r.hand_log == nil {
+		return
+	}
+
+	if err := r.hub.store.Save_Hand(r.hand_log); err != nil {
+		log.Printf("room %s: archive hand %d failed: %v", r.id, r.hand_number, err)
+	}
+	if r.hub.stats != nil {
+		r.hub.stats.Add(r.hand_log)
+	}
+	if r.hub.matches != nil {
+		r.hub.matches.Add(r.hand_log)
+	}
+}
+
+// Game_End_Action carries a finished match's game end, with its rating
+// changes filled in, back to the room.
+type Game_End_Action struct {
+	match_id string
+	payload  protocol.Game_End_Payload
+}
+
+// rate_match feeds the finished match, rebuilt from its archived hands, into
+// the ladder and then announces the game end. Loading the hands reads every
+// one from disk, so it runs off the room goroutine.
+func (r *Room) rate_match(payload protocol.Game_End_Payload) {
+	if r.casual || r.hub.store == nil || r.hub.ratings == nil {
+		r.broadcast(&protocol.Message{Type: protocol.Msg_Game_End, Payload: payload})
+		return
+	}
+
+	match_id := r.match_id
+	go func() {
+		if changes := r.hub.rate(r.id, match_id); changes != nil {
+			payload.Rating_Changes = rating_changes(changes.Players)
+			payload.Partnership_Changes = rating_changes(changes.Partnerships)
+		}
+		send_to_room(r, r.game_end, Game_End_Action{match_id: match_id, payload: payload})
+	}()
+}
+
+func (r *Room) handle_game_end(action Game_End_Action) {
+	if action.match_id != r.match_id {
+		return
+	}
+	r.broadcast(&protocol.Message{Type: protocol.Msg_Game_End, Payload: action.payload})
+}
+
+func (h *Hub) rate(room_id string, match_id string) *rating.Result {
+	hands, err := h.store.List_Hands(match_id)
+	if err != nil {
+		log.Printf("room %s: list hands for rating: %v", room_id, err)
+		return nil
+	}
+
+	logs := make([]*history.Hand_Log, 0, len(hands))
+	for _, hand := range hands {
+		l, err := h.store.Load_Hand(match_id, hand)
+		if err != nil {
+			log.Printf("room %s: load hand %d for rating: %v", room_id, hand, err)
+			return nil
+		}
+		logs = append(logs, l)
+	}
+
+	match, ok := rating.Match_From_Hands(match_id, logs)
+	if !ok {
+		return nil
+	}
+
+	result, err := h.ratings.Record(match)
+	if err != nil {
+		log.Printf("room %s: rate match %s: %v", room_id, match_id, err)
+		return nil
+	}
+	return &result
+}
+
+func rating_changes(changes []rating.Change) []protocol.Rating_Change {
+	var out []protocol.Rating_Change
+	for _, c := range changes {
+		out = append(out, protocol.Rating_Change{
+			Id:         c.Id,
+			Name:       c.Name,
+			Old_Rating: c.Old_Rating,
+			New_Rating: c.New_Rating,
+		})
+	}
+	return out
+}
+
+func (r *Room) discard() {
+	if r.hub.store == nil {
+		return
+	}
+
+	if err := r.hub.store.Delete_Room(r.id); err != nil {
+		log.Printf("room %s: delete failed: %v", r.id, err)
+	}
+}
+
+func restore_room(hub *Hub, rec *store.Room_Record) *Room {
+	r := new_room(rec.Id, hub, hub.room_config)
+	r.status = Room_Status(rec.Status)
+	r.game = rec.Game
+	r.match_id = rec.Match_Id
+	r.hand_number = rec.Hand_Number
+	r.hand_log = rec.Hand_Log
+	r.event_seq = rec.Event_Seq
+	r.quiet_hands = rec.Quiet_Hands
+	r.casual = rec.Casual
+	r.card_tracker = rec.Card_Tracker
+	if s := rec.Series; s != nil && s.Best_Of > 0 {
+		r.best_of = s.Best_Of
+		r.series_wins = s.Wins
+		r.series_game = s.Game
+	}
+	if a := rec.Access; a != nil {
+		r.private = a.Private
+		r.set_secret(a.Password)
+		if a.Password == nil && a.Password_Hash != "" {
+			r.set_secret(&account.Secret{})
+		}
+		if a.Invite_Token != "" {
+			r.invite = a.Invite_Token
+		}
+	}
+
+	for i, seat := range rec.Seats {
+		if seat == nil {
+			continue
+		}
+
+		var c *Client
+		if seat.Is_Bot {
+			c = new_bot(seat.Player_Id, seat.Name)
+		} else {
+			c = new_offline_client(seat.Player_Id, seat.Name, seat.Token)
+		}
+		c.is_ready = seat.Is_Ready
+		c.username = seat.Username
+		c.set_room(r)
+		r.clients[i] = c
+
+		if c.id == rec.Host_Id {
+			r.host = c
+		}
+	}
+	r.rebuild_beliefs()
+
+	return r
+}
@@ -0,0 +1,49 @@
+package room
+
+import (
+	"testing"
+	"time"
+
+	"guandanbtw/game"
+	"guandanbtw/store"
+)
+
+// TestPersistWhilePlaying saves the room after every move while the store's
+// delayed writes run, so go test -race catches a snapshot that is encoded
+// while the room changes it.
+func TestPersistWhilePlaying(t *testing.T) {
+	disk, err := store.Open_Disk(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub := New_Hub(Hub_Options{Rooms: Default_Room_Config(), Store: disk})
+	r := new_room("PERSIST", hub, hub.room_config)
+	t.Cleanup(func() { close(r.done) })
+	players := seat_test_players(r)
+	r.start_game()
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) && r.game.Phase == game.Phase_Play {
+		seat := r.game.Current_Turn
+		plays := game.Legal_Plays(r.game.Hands[seat], r.game.Current_Lead, r.game.Level)
+		if r.game.Current_Lead.Type == game.Comb_Invalid || len(plays) > 0 && seat%2 == 0 {
+			r.handle_play(Play_Action{client: players[seat], card_ids: card_ids(plays[0].Cards)})
+		} else {
+			r.handle_pass(players[seat])
+		}
+		r.persist()
+		time.Sleep(10 * time.Millisecond)
+	}
+	r.persist()
+
+	if err := disk.Close(); err != nil {
+		t.Fatal(err)
+	}
+	rooms, err := disk.Load_Rooms()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rooms) != 1 || rooms[0].Hand_Log == nil || len(rooms[0].Hand_Log.Events) != len(r.hand_log.Events) {
+		t.Fatalf("loaded %d rooms; the last snapshot does not hold the whole hand", len(rooms))
+	}
+}
@@ -0,0 +1,393 @@
+package room
+
+import (
+	"math"
+	"slices"
+	"sort"
+	"time"
+
+	"guandanbtw/protocol"
+	"guandanbtw/rating"
+)
+
+// Queue_Config tunes quick play. The rating window starts at Rating_Window
+// and widens by Window_Growth per second waited; once the oldest entry has
+// waited Bot_Wait its table is completed with bots.
+type Queue_Config struct {
+	Tick          time.Duration
+	Bot_Wait      time.Duration
+	Status_Every  time.Duration
+	Rating_Window float64
+	Window_Growth float64
+}
+
+func Default_Queue_Config() Queue_Config {
+	return Queue_Config{
+		Tick:          time.Second,
+		Bot_Wait:      45 * time.Second,
+		Status_Every:  5 * time.Second,
+		Rating_Window: 100,
+		Window_Growth: 10,
+	}
+}
+
+type Queue_Action struct {
+	client  *Client
+	payload protocol.Queue_Join_Payload
+}
+
+// queue_entry is a solo player or a pre-formed pair that is seated together
+// as partners.
+type queue_entry struct {
+	members    []*Client
+	party_code string
+	rating     float64
+	joined_at  time.Time
+
+	sent_position int
+	sent_at       time.Time
+}
+
+type Queue struct {
+	hub     *Hub
+	config  Queue_Config
+	entries []*queue_entry
+	parties map[string]*queue_entry
+
+	// avg_wait is a moving average of how long matched players waited.
+	avg_wait time.Duration
+	formed   int
+
+	join     chan Queue_Action
+	leave    chan *Client
+	sync     chan struct{}
+	shutdown chan struct{}
+	done     chan struct{}
+}
+
+func new_queue(hub *Hub, config Queue_Config) *Queue {
+	return &Queue{
+		hub:      hub,
+		config:   config,
+		parties:  make(map[string]*queue_entry),
+		join:     make(chan Queue_Action),
+		leave:    make(chan *Client),
+		sync:     make(chan struct{}),
+		shutdown: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+func (q *Queue) run() {
+	defer close(q.done)
+
+	ticker := time.NewTicker(q.config.Tick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case action := <-q.join:
+			q.handle_join(action, time.Now())
+		case client := <-q.leave:
+			q.handle_leave(client)
+		case <-q.sync:
+		case now := <-ticker.C:
+			for q.form_table(now) {
+			}
+			q.send_statuses(now, false)
+		case <-q.shutdown:
+			return
+		}
+	}
+}
+
+func (q *Queue) stop() {
+	close(q.shutdown)
+	<-q.done
+}
+
+func (q *Queue) actor() actor {
+	return actor{sync: q.sync, done: q.done}
+}
+
+func send_to_queue[T any](q *Queue, ch chan T, value T) bool {
+	select {
+	case ch <- value:
+		return true
+	case <-q.done:
+		return false
+	}
+}
+
+func (q *Queue) handle_join(action Queue_Action, now time.Time) {
+	client := action.client
+	if q.find(client) != nil {
+		return
+	}
+
+	code := normalize_room_code(action.payload.Party_Code)
+	if code != "" {
+		entry := q.parties[code]
+		if entry == nil {
+			client.send_error("party not found")
+			return
+		}
+		delete(q.parties, code)
+		entry.members = append(entry.members, client)
+		q.enqueue(entry, now)
+		return
+	}
+
+	entry := &queue_entry{members: []*Client{client}}
+	if action.payload.Pair {
+		entry.party_code = generate_room_code()
+		for q.parties[entry.party_code] != nil {
+			entry.party_code = generate_room_code()
+		}
+		q.parties[entry.party_code] = entry
+		q.send_status(entry, now)
+		return
+	}
+	q.enqueue(entry, now)
+}
+
+func (q *Queue) enqueue(entry *queue_entry, now time.Time) {
+	entry.joined_at = now
+	entry.rating = 0
+	for _, c := range entry.members {
+		entry.rating += q.rating_of(c)
+	}
+	entry.rating /= float64(len(entry.members))
+
+	q.entries = append(q.entries, entry)
+	q.send_statuses(now, true)
+}
+
+// handle_leave drops the client's whole entry; a partner left behind is told
+// why.
+func (q *Queue) handle_leave(client *Client) {
+	entry := q.find(client)
+	if entry == nil {
+		return
+	}
+
+	delete(q.parties, entry.party_code)
+	if i := slices.Index(q.entries, entry); i != -1 {
+		q.entries = slices.Delete(q.entries, i, i+1)
+	}
+	for _, c := range entry.members {
+		if c != client {
+			c.send_error("your partner left the queue")
+		}
+		c.send_message(&protocol.Message{Type: protocol.Msg_Queue_Status, Payload: protocol.Queue_Status_Payload{}})
+	}
+}
+
+func (q *Queue) find(client *Client) *queue_entry {
+	for _, e := range q.entries {
+		if slices.Contains(e.members, client) {
+			return e
+		}
+	}
+	for _, e := range q.parties {
+		if slices.Contains(e.members, client) {
+			return e
+		}
+	}
+	return nil
+}
+
+func (q *Queue) rating_of(client *Client) float64 {
+	if client.username == "" || q.hub.ratings == nil {
+		return rating.Initial_Rating
+	}
+	if p, ok := q.hub.ratings.Player(client.id); ok {
+		return p.Rating
+	}
+	return rating.Initial_Rating
+}
+
+func (q *Queue) window(entry *queue_entry, now time.Time) float64 {
+	return q.config.Rating_Window + q.config.Window_Growth*now.Sub(entry.joined_at).Seconds()
+}
+
+// form_table tries to seat the oldest entry with the closest-rated entries
+// inside its window. Pairs are taken whole, so a table is two pairs, a pair
+// and two solos, or four solos.
+func (q *Queue) form_table(now time.Time) bool {
+	for _, anchor := range q.entries {
+		window := q.window(anchor, now)
+		candidates := make([]*queue_entry, 0, len(q.entries))
+		for _, e := range q.entries {
+			if e != anchor && math.Abs(e.rating-anchor.rating) <= window {
+				candidates = append(candidates, e)
+			}
+		}
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return math.Abs(candidates[i].rating-anchor.rating) < math.Abs(candidates[j].rating-anchor.rating)
+		})
+
+		picked := []*queue_entry{anchor}
+		open := 4 - len(anchor.members)
+		for _, e := range candidates {
+			if open == 0 {
+				break
+			}
+			if len(e.members) <= open {
+				picked = append(picked, e)
+				open -= len(e.members)
+			}
+		}
+
+		if open == 0 || now.Sub(anchor.joined_at) >= q.config.Bot_Wait {
+			q.seat(picked, now)
+			return true
+		}
+	}
+	return false
+}
+
+func (q *Queue) seat(picked []*queue_entry, now time.Time) {
+	for _, e := range picked {
+		q.entries = slices.DeleteFunc(q.entries, func(x *queue_entry) bool { return x == e })
+
+		waited := now.Sub(e.joined_at)
+		if q.formed == 0 {
+			q.avg_wait = waited
+		} else {
+			q.avg_wait = (q.avg_wait*7 + waited*3) / 10
+		}
+		q.formed++
+	}
+
+	room := q.hub.create_room(Room_Settings{Private: true})
+	seats := arrange_teams(picked, q.rating_of)
+	send_to_room(room, room.matched, seats)
+
+	for _, e := range picked {
+		for _, c := range e.members {
+			c.send_message(&protocol.Message{
+				Type:    protocol.Msg_Queue_Status,
+				Payload: protocol.Queue_Status_Payload{Matched_Room: room.id},
+			})
+		}
+	}
+}
+
+// arrange_teams keeps pairs together and balances solos so the two team
+// totals stay close. Team 0 sits at seats 0 and 2, team 1 at 1 and 3; nil
+// seats are for bots.
+func arrange_teams(picked []*queue_entry, rating_of func(*Client) float64) [4]*Client {
+	var teams [2][]*Client
+	var totals [2]float64
+	var solos []*Client
+
+	for _, e := range picked {
+		if len(e.members) < 2 {
+			solos = append(solos, e.members...)
+			continue
+		}
+		t := 0
+		if len(teams[0]) > 0 {
+			t = 1
+		}
+		teams[t] = append(teams[t], e.members...)
+	}
+
+	sort.SliceStable(solos, func(i, j int) bool { return rating_of(solos[i]) > rating_of(solos[j]) })
+	for _, c := range solos {
+		t := 0
+		if len(teams[0]) == 2 || (len(teams[1]) < 2 && totals[1] < totals[0]) {
+			t = 1
+		}
+		teams[t] = append(teams[t], c)
+		totals[t] += rating_of(c)
+	}
+
+	var seats [4]*Client
+	for t, team := range teams {
+		for i, c := range team {
+			seats[t+2*i] = c
+		}
+	}
+	return seats
+}
+
+// send_statuses tells each entry its place in line. Unless forced, an entry
+// only hears again when its position moves or Status_Every has passed.
+func (q *Queue) send_statuses(now time.Time, force bool) {
+	for i, e := range q.entries {
+		if !force && e.sent_position == i+1 && now.Sub(e.sent_at) < q.config.Status_Every {
+			continue
+		}
+		e.sent_position = i + 1
+		q.send_status(e, now)
+	}
+}
+
+func (q *Queue) send_status(e *queue_entry, now time.Time) {
+	players := 0
+	for _, x := range q.entries {
+		players += len(x.members)
+	}
+
+	for _, c := range e.members {
+		status := protocol.Queue_Status_Payload{
+			Queued:              true,
+			Party_Code:          e.party_code,
+			Waiting_For_Partner: len(e.members) == 1 && e.party_code != "",
+		}
+		for _, m := range e.members {
+			if m != c {
+				status.Partner_Name = m.name
+			}
+		}
+		if !status.Waiting_For_Partner {
+			waited := now.Sub(e.joined_at)
+			status.Position = e.sent_position
+			status.Queue_Size = players
+			status.Waited_Secs = int(waited.Seconds())
+			status.Estimated_Wait_Secs = int(q.estimate(waited).Seconds())
+		}
+		c.send_message(&protocol.Message{Type: protocol.Msg_Queue_Status, Payload: status})
+	}
+	e.sent_at = now
+}
+
+// estimate is the average wait of recent matches, capped by the bot fill
+// deadline, less the time already waited.
+func (q *Queue) estimate(waited time.Duration) time.Duration {
+	expected := q.config.Bot_Wait
+	if q.formed > 0 {
+		expected = min(q.avg_wait, q.config.Bot_Wait)
+	}
+	return max(expected-waited, 0)
+}
+
+// handle_matched seats a table formed by the queue and starts it straight
+// away.
+func (r *Room) handle_matched(seats [4]*Client) {
+	for seat, c := range seats {
+		if c == nil {
+			r.seat_bot(seat)
+			continue
+		}
+
+		r.clients[seat] = c
+		c.set_room(r)
+		c.is_ready = true
+		c.token = generate_id()
+		if r.host == nil {
+			r.host = c
+		}
+		r.log_event("join", seat, protocol.Player_Info{
+			Id:   c.id,
+			Name: c.name,
+			Seat: seat,
+			Team: seat % 2,
+		})
+	}
+
+	r.broadcast_room_state()
+	r.start_game()
+}
@@ -0,0 +1,79 @@
+package room
+
+import (
+	"testing"
+	"time"
+
+	"guandanbtw/game"
+	"guandanbtw/protocol"
+	"guandanbtw/rating"
+	"guandanbtw/store"
+)
+
+// TestGameEndRatedOffRoom finishes a match and checks that the game end is
+// announced only once the ladder has rated it, from a result posted back to
+// the room rather than computed in end_hand.
+func TestGameEndRatedOffRoom(t *testing.T) {
+	disk, err := store.Open_Disk(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { disk.Close() })
+	ladder, err := rating.Open(disk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub := New_Hub(Hub_Options{Rooms: Default_Room_Config(), Store: disk, Ratings: ladder})
+	r := new_room("RATED", hub, hub.room_config)
+	t.Cleanup(func() { close(r.done) })
+	players := seat_test_players(r)
+	for i, c := range players {
+		c.username = []string{"ann", "bo", "cy", "di"}[i]
+	}
+	r.start_game()
+	for _, c := range players {
+		drain(c)
+	}
+
+	r.end_hand(&game.Hand_Result{
+		Finish_Order: []int{0, 2, 1, 3},
+		Winning_Team: 0,
+		Old_Level:    13,
+		New_Levels:   [2]int{14, 2},
+		Game_Over:    true,
+	})
+	for _, c := range players {
+		for _, m := range drain(c) {
+			if m.Type == protocol.Msg_Game_End {
+				t.Fatal("game end sent before the match was rated")
+			}
+		}
+	}
+
+	var action Game_End_Action
+	select {
+	case action = <-r.game_end:
+	case <-time.After(5 * time.Second):
+		t.Fatal("rating never posted back to the room")
+	}
+	if len(action.payload.Rating_Changes) != 4 || len(action.payload.Partnership_Changes) != 2 {
+		t.Fatalf("game end holds %d rating and %d partnership changes, want 4 and 2",
+			len(action.payload.Rating_Changes), len(action.payload.Partnership_Changes))
+	}
+	if p, ok := ladder.Player(players[0].id); !ok || p.Rating <= rating.Initial_Rating {
+		t.Fatalf("winner rating = %+v", p)
+	}
+
+	r.handle_game_end(action)
+	got := drain(players[1])
+	if len(got) != 1 || got[0].Type != protocol.Msg_Game_End {
+		t.Fatalf("sent %v, want a game end", got)
+	}
+
+	// A result for a match the room has left behind is dropped.
+	r.match_id = generate_id()
+	r.handle_game_end(action)
+	if got := drain(players[1]); len(got) != 0 {
+		t.Fatalf("stale game end sent %v", got)
+	}
+}
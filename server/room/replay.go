@@ -0,0 +1,441 @@
+package room
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"guandanbtw/game"
+	"guandanbtw/history"
+	"guandanbtw/protocol"
+	"guandanbtw/web"
+)
+
+const (
+	replay_solve_nodes   = 200000
+	replay_base_interval = time.Second
+	replay_min_speed     = 0.25
+	replay_max_speed     = 8
+)
+
+type Replay_Control_Action struct {
+	client *Client
+	action string
+	step   int
+	speed  float64
+}
+
+// Replay_Room streams a stored hand to any number of viewers. Viewers share
+// the playback position, so anyone in a review session can pause or step.
+type Replay_Room struct {
+	id         string
+	hub        *Hub
+	log        *history.Hand_Log
+	open_hands [4][]game.Card
+	step       int
+	playing    bool
+	speed      float64
+	viewers    map[*Client]bool
+	join       chan *Client
+	leave      chan *Client
+	control    chan Replay_Control_Action
+	sync       chan struct{}
+	shutdown   chan struct{}
+	done       chan struct{}
+	stop_once  sync.Once
+}
+
+func new_replay_room(id string, hub *Hub, l *history.Hand_Log) (*Replay_Room, error) {
+	if len(l.Events) == 0 {
+		return nil, errors.New("replay: hand has no events")
+	}
+	if err := l.Verify(); err != nil {
+		return nil, err
+	}
+
+	rr := &Replay_Room{
+		id:       id,
+		hub:      hub,
+		log:      l,
+		step:     1,
+		speed:    1,
+		viewers:  make(map[*Client]bool),
+		join:     make(chan *Client),
+		leave:    make(chan *Client),
+		control:  make(chan Replay_Control_Action),
+		sync:     make(chan struct{}),
+		shutdown: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	start, err := l.State_At(rr.play_start())
+	if err != nil {
+		return nil, err
+	}
+	for i := range start.Hands {
+		rr.open_hands[i] = append([]game.Card(nil), start.Hands[i]...)
+	}
+
+	return rr, nil
+}
+
+// play_start is the step after the deal and any tributes, when every player
+// holds the hand they actually played.
+func (rr *Replay_Room) play_start() int {
+	step := 1
+	for step < len(rr.log.Events) && rr.log.Events[step].Kind == history.Event_Tribute {
+		step++
+	}
+	return step
+}
+
+func (rr *Replay_Room) run() {
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case client := <-rr.join:
+			rr.viewers[client] = true
+			client.set_replay(rr)
+			rr.send_state(client)
+		case client := <-rr.leave:
+			delete(rr.viewers, client)
+			client.clear_replay(rr)
+			if len(rr.viewers) == 0 {
+				rr.close()
+				return
+			}
+			continue
+		case action := <-rr.control:
+			if !rr.viewers[action.client] {
+				continue
+			}
+			rr.handle_control(action)
+			rr.broadcast_state()
+		case <-rr.sync:
+			continue
+		case <-timer.C:
+			rr.step++
+			if rr.step >= len(rr.log.Events) {
+				rr.playing = false
+			}
+			rr.broadcast_state()
+		case <-rr.shutdown:
+			rr.close()
+			return
+		}
+
+		timer.Stop()
+		if rr.playing {
+			timer.Reset(time.Duration(float64(replay_base_interval) / rr.speed))
+		}
+	}
+}
+
+func (rr *Replay_Room) handle_control(action Replay_Control_Action) {
+	last := len(rr.log.Events)
+
+	switch action.action {
+	case protocol.Replay_Play:
+		if rr.step >= last {
+			rr.step = 1
+		}
+		rr.playing = true
+	case protocol.Replay_Pause:
+		rr.playing = false
+	case protocol.Replay_Step_Forward:
+		rr.playing = false
+		if rr.step < last {
+			rr.step++
+		}
+	case protocol.Replay_Step_Back:
+		rr.playing = false
+		if rr.step > 1 {
+			rr.step--
+		}
+	case protocol.Replay_Seek:
+		rr.step = min(max(action.step, 1), last)
+		if rr.step >= last {
+			rr.playing = false
+		}
+	case protocol.Replay_Speed:
+		rr.speed = min(max(action.speed, replay_min_speed), replay_max_speed)
+	default:
+		action.client.send_error("unknown replay action")
+	}
+}
+
+func (rr *Replay_Room) state_payload() (protocol.Replay_State_Payload, error) {
+	state, err := rr.log.State_At(rr.step)
+	if err != nil {
+		return protocol.Replay_State_Payload{}, err
+	}
+
+	payload := protocol.Replay_State_Payload{
+		Replay_Id:    rr.id,
+		Match_Id:     rr.log.Header.Match_Id,
+		Hand:         rr.log.Header.Hand,
+		Players:      rr.log.Header.Players,
+		Step:         rr.step,
+		Total_Steps:  len(rr.log.Events),
+		Playing:      rr.playing,
+		Speed:        rr.speed,
+		Event:        &rr.log.Events[rr.step-1],
+		Level:        state.Level,
+		Team_Levels:  state.Team_Levels,
+		Current_Turn: state.Current_Turn,
+		Finish_Order: state.Finish_Order,
+	}
+
+	if state.Current_Lead.Type != game.Comb_Invalid {
+		payload.Table_Cards = state.Current_Lead.Cards
+		payload.Combo_Type = state.Current_Lead.Type.String()
+	}
+	for i, hand := range state.Hands {
+		payload.Card_Counts[i] = len(hand)
+	}
+
+	if rr.step >= len(rr.log.Events) {
+		payload.Open_Hands = &rr.open_hands
+		payload.Outcome = rr.log.Outcome()
+	}
+
+	return payload, nil
+}
+
+func (rr *Replay_Room) send_state(client *Client) {
+	payload, err := rr.state_payload()
+	if err != nil {
+		client.send_error(err.Error())
+		return
+	}
+	client.send_message(&protocol.Message{Type: protocol.Msg_Replay_State, Payload: payload})
+}
+
+func (rr *Replay_Room) broadcast_state() {
+	payload, err := rr.state_payload()
+	if err != nil {
+		log.Printf("replay %s: step %d: %v", rr.id, rr.step, err)
+		return
+	}
+
+	msg := &protocol.Message{Type: protocol.Msg_Replay_State, Payload: payload}
+	for client := range rr.viewers {
+		client.send_message(msg)
+	}
+}
+
+func (rr *Replay_Room) stop() {
+	rr.stop_once.Do(func() {
+		close(rr.shutdown)
+	})
+}
+
+func (rr *Replay_Room) close() {
+	for client := range rr.viewers {
+		client.clear_replay(rr)
+	}
+	rr.viewers = nil
+	close(rr.done)
+	rr.hub.delete_replay(rr.id)
+}
+
+func (rr *Replay_Room) actor() actor {
+	return actor{sync: rr.sync, done: rr.done}
+}
+
+func send_to_replay[T any](rr *Replay_Room, ch chan T, value T) bool {
+	select {
+	case ch <- value:
+		return true
+	case <-rr.done:
+		return false
+	}
+}
+
+func (h *Hub) open_replay(match_id string, hand int) (*Replay_Room, error) {
+	if h.store == nil {
+		return nil, os.ErrNotExist
+	}
+
+	l, err := h.store.Load_Hand(match_id, hand)
+	if err != nil {
+		return nil, err
+	}
+
+	rr, err := new_replay_room("", h, l)
+	if err != nil {
+		return nil, err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	rr.id = generate_room_code()
+	for h.replays[rr.id] != nil {
+		rr.id = generate_room_code()
+	}
+	h.replays[rr.id] = rr
+	go rr.run()
+
+	return rr, nil
+}
+
+func (h *Hub) get_replay(id string) *Replay_Room {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.replays[id]
+}
+
+func (h *Hub) delete_replay(id string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.replays, id)
+}
+
+func (h *Hub) Handle_Replay_List(w http.ResponseWriter, r *http.Request) {
+	if h.store == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	hands, err := h.store.List_Hands(r.PathValue("match_id"))
+	if err != nil {
+		write_store_error(w, err)
+		return
+	}
+
+	web.Write_JSON(w, http.StatusOK, map[string]interface{}{
+		"match_id": r.PathValue("match_id"),
+		"hands":    hands,
+	})
+}
+
+func (h *Hub) Handle_Replay(w http.ResponseWriter, r *http.Request) {
+	if h.store == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	hand, err := strconv.Atoi(r.PathValue("hand"))
+	if err != nil {
+		web.Write_Error(w, http.StatusBadRequest, "invalid hand number")
+		return
+	}
+
+	l, err := h.store.Load_Hand(r.PathValue("match_id"), hand)
+	if err != nil {
+		write_store_error(w, err)
+		return
+	}
+
+	web.Write_JSON(w, http.StatusOK, l)
+}
+
+type replay_solution struct {
+	Step          int           `json:"step"`
+	Winning_Team  int           `json:"winning_team"`
+	Finish_Order  []int         `json:"finish_order"`
+	Level_Advance int           `json:"level_advance"`
+	Line          []replay_move `json:"line"`
+	Nodes         int           `json:"nodes"`
+}
+
+type replay_move struct {
+	Seat       int    `json:"seat"`
+	Pass       bool   `json:"pass,omitempty"`
+	Card_Ids   []int  `json:"card_ids,omitempty"`
+	Combo_Type string `json:"combo_type,omitempty"`
+}
+
+// Handle_Replay_Solve solves a stored hand from the position after step
+// events with every hand open. Results are cached by match, hand and step,
+// and only a few solves run at once.
+func (h *Hub) Handle_Replay_Solve(w http.ResponseWriter, r *http.Request) {
+	if h.store == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	hand, err := strconv.Atoi(r.PathValue("hand"))
+	if err != nil {
+		web.Write_Error(w, http.StatusBadRequest, "invalid hand number")
+		return
+	}
+	step, err := strconv.Atoi(r.URL.Query().Get("step"))
+	if err != nil {
+		web.Write_Error(w, http.StatusBadRequest, "invalid step")
+		return
+	}
+
+	l, err := h.store.Load_Hand(r.PathValue("match_id"), hand)
+	if err != nil {
+		write_store_error(w, err)
+		return
+	}
+
+	state, err := l.State_At(step)
+	if err != nil {
+		web.Write_Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if state == nil || state.Phase != game.Phase_Play {
+		web.Write_Error(w, http.StatusUnprocessableEntity, "the hand is not in play at that step")
+		return
+	}
+
+	key := replay_solve_key{match_id: r.PathValue("match_id"), hand: hand, step: step}
+	out, err := h.solver.solve(key, func() (replay_solution, error) {
+		return solve_replay(state, step)
+	})
+	if errors.Is(err, err_solver_busy) {
+		web.Write_Error(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	if err != nil {
+		web.Write_Error(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	web.Write_JSON(w, http.StatusOK, out)
+}
+
+func solve_replay(state *game.Game_State, step int) (replay_solution, error) {
+	solution, err := game.Solve(state, replay_solve_nodes)
+	if err != nil {
+		return replay_solution{}, err
+	}
+
+	out := replay_solution{
+		Step:          step,
+		Winning_Team:  solution.Winning_Team,
+		Finish_Order:  solution.Finish_Order,
+		Level_Advance: solution.Level_Advance,
+		Line:          make([]replay_move, len(solution.Line)),
+		Nodes:         solution.Nodes,
+	}
+	for i, m := range solution.Line {
+		out.Line[i] = replay_move{Seat: m.Seat, Pass: m.Pass}
+		if !m.Pass {
+			out.Line[i].Card_Ids = card_ids(m.Play.Cards)
+			out.Line[i].Combo_Type = m.Play.Type.String()
+		}
+	}
+	return out, nil
+}
+
+func write_store_error(w http.ResponseWriter, err error) {
+	if errors.Is(err, os.ErrNotExist) {
+		web.Write_Error(w, http.StatusNotFound, "not found")
+		return
+	}
+	log.Printf("store: %v", err)
+	web.Write_Error(w, http.StatusInternalServerError, "internal error")
+}
@@ -0,0 +1,75 @@
+package room
+
+import (
+	"errors"
+	"sync"
+)
+
+const (
+	replay_solve_slots  = 2
+	replay_solve_cached = 256
+)
+
+var err_solver_busy = errors.New("the solver is busy, try again shortly")
+
+type replay_solve_key struct {
+	match_id string
+	hand     int
+	step     int
+}
+
+// replay_solve_call is one solve, shared by every request for its key. done
+// closes once out and err are set.
+type replay_solve_call struct {
+	done chan struct{}
+	out  replay_solution
+	err  error
+}
+
+// replay_solver runs at most replay_solve_slots solves at once and keeps the
+// last replay_solve_cached results. A stored hand never changes, so neither
+// does the solution from one of its steps.
+type replay_solver struct {
+	slots chan struct{}
+	mu    sync.Mutex
+	calls map[replay_solve_key]*replay_solve_call
+	order []replay_solve_key
+}
+
+func new_replay_solver() *replay_solver {
+	return &replay_solver{
+		slots: make(chan struct{}, replay_solve_slots),
+		calls: make(map[replay_solve_key]*replay_solve_call),
+	}
+}
+
+// solve returns the cached result for key, waits for a solve of key already
+// running, or runs solve in a free slot. With every slot taken it fails with
+// err_solver_busy and caches nothing.
+func (s *replay_solver) solve(key replay_solve_key, solve func() (replay_solution, error)) (replay_solution, error) {
+	s.mu.Lock()
+	if call, ok := s.calls[key]; ok {
+		s.mu.Unlock()
+		<-call.done
+		return call.out, call.err
+	}
+	select {
+	case s.slots <- struct{}{}:
+	default:
+		s.mu.Unlock()
+		return replay_solution{}, err_solver_busy
+	}
+	call := &replay_solve_call{done: make(chan struct{})}
+	s.calls[key] = call
+	s.order = append(s.order, key)
+	if len(s.order) > replay_solve_cached {
+		delete(s.calls, s.order[0])
+		s.order = s.order[1:]
+	}
+	s.mu.Unlock()
+
+	call.out, call.err = solve()
+	<-s.slots
+	close(call.done)
+	return call.out, call.err
+}
@@ -0,0 +1,72 @@
+package room
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestReplaySolverCachesByKey(t *testing.T) {
+	s := new_replay_solver()
+	runs := 0
+	solve := func() (replay_solution, error) {
+		runs++
+		return replay_solution{Step: runs}, nil
+	}
+
+	a := replay_solve_key{match_id: "m", hand: 1, step: 4}
+	b := replay_solve_key{match_id: "m", hand: 1, step: 5}
+	for _, key := range []replay_solve_key{a, a, b, a} {
+		if _, err := s.solve(key, solve); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if runs != 2 {
+		t.Fatalf("solved %d times for two keys", runs)
+	}
+	if out, _ := s.solve(a, solve); out.Step != 1 {
+		t.Fatalf("cached step %d, want the first result", out.Step)
+	}
+}
+
+func TestReplaySolverBusy(t *testing.T) {
+	s := new_replay_solver()
+	release := make(chan struct{})
+	started := make(chan struct{}, replay_solve_slots)
+	for i := range replay_solve_slots {
+		go s.solve(replay_solve_key{hand: i}, func() (replay_solution, error) {
+			started <- struct{}{}
+			<-release
+			return replay_solution{}, nil
+		})
+	}
+	for range replay_solve_slots {
+		<-started
+	}
+
+	_, err := s.solve(replay_solve_key{hand: replay_solve_slots}, func() (replay_solution, error) {
+		t.Fatal("ran a solve with every slot taken")
+		return replay_solution{}, nil
+	})
+	if !errors.Is(err, err_solver_busy) {
+		t.Fatalf("err = %v, want busy", err)
+	}
+	close(release)
+
+	if _, err := s.solve(replay_solve_key{hand: 0}, nil); err != nil {
+		t.Fatalf("waiting on a running solve: %v", err)
+	}
+}
+
+func TestReplaySolverEvictsOldest(t *testing.T) {
+	s := new_replay_solver()
+	ok := func() (replay_solution, error) { return replay_solution{}, nil }
+	for i := range replay_solve_cached + 1 {
+		s.solve(replay_solve_key{step: i}, ok)
+	}
+	if len(s.calls) != replay_solve_cached {
+		t.Fatalf("%d cached, want %d", len(s.calls), replay_solve_cached)
+	}
+	if _, ok := s.calls[replay_solve_key{step: 0}]; ok {
+		t.Fatal("the oldest result was kept")
+	}
+}
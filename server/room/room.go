@@ -1,9 +1,14 @@
 package room
 
 import (
+	mrand "math/rand/v2"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"guandanbtw/account"
 	"guandanbtw/game"
+	"guandanbtw/history"
 	"guandanbtw/protocol"
 )
 
@@ -17,124 +22,419 @@ type Tribute_Action struct {
 	card_id int
 }
 
+type Lobby_Action struct {
+	client     *Client
+	kind       protocol.Msg_Type
+	seat       int
+	other_seat int
+	team       int
+	player_id  string
+	ready      bool
+	quiet      bool
+	rated      bool
+	tracker    bool
+	settings   protocol.Room_Settings_Payload
+	secret     *account.Secret
+	best_of    int
+}
+
+type Rejoin_Action struct {
+	client    *Client
+	player_id string
+	token     string
+}
+
 type Room struct {
-	id        string
-	clients   [4]*Client
-	game      *game.Game_State
-	join      chan *Client
-	leave     chan *Client
-	play      chan Play_Action
-	pass      chan *Client
-	tribute   chan Tribute_Action
-	fill_bots chan *Client
-	bot_turn  chan int
-}
-
-func new_room(id string) *Room {
+	id            string
+	hub           *Hub
+	config        Room_Config
+	status        Room_Status
+	clients       [4]*Client
+	spectators    []*Client
+	host          *Client
+	quiet_hands   bool
+	casual        bool
+	card_tracker  bool
+	private       bool
+	password      atomic.Pointer[account.Secret]
+	invite        string
+	game          *game.Game_State
+	last_activity time.Time
+	empty_since   time.Time
+	event_seq     int
+	seq           int64
+	match_id      string
+	hand_number   int
+	hand_log      *history.Hand_Log
+	beliefs       [4]*game.Belief
+	last_hint     hint_cache
+	best_of       int
+	series_wins   [2]int
+	series_game   int
+	rematch_votes map[int]bool
+	join          chan Join_Action
+	rejoin        chan Rejoin_Action
+	spectate      chan Join_Action
+	matched       chan [4]*Client
+	leave         chan *Client
+	chat          chan Chat_Action
+	rematch       chan Rematch_Action
+	resync        chan *Client
+	snapshot      chan *Client
+	hint          chan *Client
+	complete      chan Play_Action
+	sync          chan struct{}
+	play          chan Play_Action
+	pass          chan *Client
+	tribute       chan Tribute_Action
+	fill_bots     chan *Client
+	lobby         chan Lobby_Action
+	bot_turn      chan Bot_Turn_Action
+	game_end      chan Game_End_Action
+	shutdown      chan struct{}
+	done          chan struct{}
+	stop_once     sync.Once
+}
+
+func new_room(id string, hub *Hub, config Room_Config) *Room {
 	return &Room{
-		id:        id,
-		join:      make(chan *Client),
-		leave:    make(chan *Client),
-		play:      make(chan Play_Action),
-		pass:      make(chan *Client),
-		tribute:   make(chan Tribute_Action),
-		fill_bots: make(chan *Client),
-		bot_turn:  make(chan int),
+		id:            id,
+		hub:           hub,
+		config:        config,
+		status:        Status_Lobby,
+		last_activity: time.Now(),
+		invite:        generate_id(),
+		best_of:       1,
+		rematch_votes: make(map[int]bool),
+		join:          make(chan Join_Action),
+		rejoin:        make(chan Rejoin_Action),
+		spectate:      make(chan Join_Action),
+		matched:       make(chan [4]*Client),
+		leave:         make(chan *Client),
+		chat:          make(chan Chat_Action),
+		rematch:       make(chan Rematch_Action),
+		resync:        make(chan *Client),
+		snapshot:      make(chan *Client),
+		hint:          make(chan *Client),
+		complete:      make(chan Play_Action),
+		sync:          make(chan struct{}),
+		play:          make(chan Play_Action),
+		pass:          make(chan *Client),
+		tribute:       make(chan Tribute_Action),
+		fill_bots:     make(chan *Client),
+		lobby:         make(chan Lobby_Action),
+		bot_turn:      make(chan Bot_Turn_Action),
+		game_end:      make(chan Game_End_Action),
+		shutdown:      make(chan struct{}),
+		done:          make(chan struct{}),
 	}
 }
 
 func (r *Room) run() {
+	ticker := time.NewTicker(r.config.Sweep_Interval)
+	defer ticker.Stop()
+	catch_up := time.NewTicker(r.hub.delivery.Check_Interval)
+	defer catch_up.Stop()
+
 	for {
 		select {
-		case client := <-r.join:
-			r.handle_join(client)
+		case action := <-r.join:
+			r.handle_join(action)
+		case action := <-r.rejoin:
+			r.handle_rejoin(action)
+		case action := <-r.spectate:
+			r.handle_spectate(action)
+		case seats := <-r.matched:
+			r.handle_matched(seats)
 		case client := <-r.leave:
 			r.handle_leave(client)
+		case action := <-r.chat:
+			r.handle_chat(action)
+			r.last_activity = time.Now()
+			continue
+		case client := <-r.resync:
+			r.handle_resync(client)
+		case client := <-r.snapshot:
+			r.handle_request_snapshot(client)
+			continue
+		case client := <-r.hint:
+			r.handle_hint(client)
+		case action := <-r.complete:
+			r.handle_complete_selection(action)
+			continue
+		case <-r.sync:
+			continue
+		case action := <-r.rematch:
+			r.handle_rematch_vote(action)
 		case action := <-r.play:
 			r.handle_play(action)
 		case client := <-r.pass:
 			r.handle_pass(client)
 		case action := <-r.tribute:
 			r.handle_tribute(action)
-		case <-r.fill_bots:
-			r.handle_fill_bots()
-		case seat := <-r.bot_turn:
-			r.handle_bot_turn(seat)
+		case client := <-r.fill_bots:
+			r.handle_fill_bots(client)
+		case action := <-r.lobby:
+			r.handle_lobby(action)
+		case action := <-r.bot_turn:
+			r.handle_bot_turn(action)
+			r.persist()
+			r.publish()
+			continue
+		case action := <-r.game_end:
+			r.handle_game_end(action)
+			continue
+		case now := <-ticker.C:
+			if r.sweep(now) {
+				return
+			}
+			continue
+		case <-catch_up.C:
+			r.catch_up()
+			continue
+		case <-r.shutdown:
+			r.close("server is restarting", false)
+			return
 		}
+
+		r.last_activity = time.Now()
+		r.persist()
+		r.publish()
 	}
 }
 
-func (r *Room) handle_join(client *Client) {
+func (r *Room) handle_join(action Join_Action) {
+	client := action.client
+	if client.username != "" {
+		for _, c := range r.clients {
+			if c != nil && c.username == client.username {
+				r.handle_rejoin(Rejoin_Action{client: client, player_id: c.id})
+				return
+			}
+		}
+	}
+
+	if !r.is_spectator(client) {
+		if err := r.check_access(action); err != nil {
+			client.send_error(err.Error())
+			return
+		}
+	}
+
+	if r.status == Status_Playing {
+		client.send_error("game already in progress")
+		return
+	}
+
 	seat := r.find_empty_seat()
 	if seat == -1 {
 		client.send_error("room is full")
 		return
 	}
 
+	r.remove_spectator(client)
+	r.clients[seat] = client
+	client.set_room(r)
+	client.is_ready = false
+	client.token = generate_id()
+
+	if r.host == nil {
+		r.host = client
+	}
+
+	r.log_event("join", seat, protocol.Player_Info{
+		Id:   client.id,
+		Name: client.name,
+		Seat: seat,
+		Team: seat % 2,
+	})
+	r.broadcast_room_state()
+}
+
+func (r *Room) handle_rejoin(action Rejoin_Action) {
+	client := action.client
+
+	seat := -1
+	for i, c := range r.clients {
+		if c == nil || c.is_bot || c.id != action.player_id {
+			continue
+		}
+		if c.token != "" && c.token == action.token || c.username != "" && c.username == client.username {
+			seat = i
+			break
+		}
+	}
+
+	if seat == -1 {
+		client.send_error("no seat to rejoin")
+		return
+	}
+
+	old := r.clients[seat]
+	old.set_room(nil)
+
+	client.id = old.id
+	client.name = old.name
+	client.token = old.token
+	client.is_ready = old.is_ready
+	client.set_room(r)
 	r.clients[seat] = client
-	client.room = r
 
+	if r.host == old || r.host == nil {
+		r.host = client
+	}
+
+	r.log_event("rejoin", seat, nil)
 	r.broadcast_room_state()
+	r.send_snapshot(client)
+}
 
-	if r.is_full() {
-		r.start_game()
+// handle_resync resends the room and the hand in play after the client
+// noticed a gap in sequence numbers.
+func (r *Room) handle_resync(client *Client) {
+	seat := r.get_seat(client)
+	if seat == -1 && !r.is_spectator(client) {
+		client.send_error("not in this room")
+		return
 	}
+
+	r.send(client, r.room_state(client))
+	r.send_snapshot(client)
+}
+
+// send_snapshot brings a returning player or spectator up to date on the
+// hand in play, reminding a seat that still owes tribute.
+func (r *Room) send_snapshot(client *Client) {
+	if r.game == nil || r.status != Status_Playing {
+		return
+	}
+
+	seat := r.get_seat(client)
+	r.send(client, &protocol.Message{
+		Type:    protocol.Msg_Game_Snapshot,
+		Payload: protocol.Project_Snapshot(r.game, seat),
+	})
+	if seat != -1 {
+		r.send_card_tracker(client, seat)
+	}
+
+	if seat == -1 || r.game.Phase != game.Phase_Tribute {
+		return
+	}
+	if t := r.game.Get_Tribute_Info(seat); t != nil {
+		r.send(client, &protocol.Message{
+			Type: protocol.Msg_Tribute,
+			Payload: protocol.Tribute_Payload{
+				From_Seat: t.From_Seat,
+				To_Seat:   t.To_Seat,
+			},
+		})
+	}
+}
+
+func (r *Room) handle_request_snapshot(client *Client) {
+	if r.get_seat(client) == -1 && !r.is_spectator(client) {
+		client.send_error("not in this room")
+		return
+	}
+	if r.game == nil || r.status != Status_Playing {
+		client.send_error("no game in progress")
+		return
+	}
+	r.send_snapshot(client)
 }
 
 func (r *Room) handle_leave(client *Client) {
-	for i := range 4 {
-		if r.clients[i] == client {
-			r.clients[i] = nil
-			break
-		}
+	if r.remove_spectator(client) {
+		client.set_room(nil)
+		r.broadcast_room_state()
+		return
+	}
+
+	seat := r.get_seat(client)
+	if seat == -1 {
+		return
+	}
+
+	r.log_event("leave", seat, nil)
+
+	if r.status == Status_Playing && !client.is_bot {
+		client.offline = true
+		r.broadcast(&protocol.Message{
+			Type: protocol.Msg_Player_Left,
+			Payload: protocol.Player_Info{
+				Id:         client.id,
+				Name:       client.name,
+				Seat:       seat,
+				Team:       seat % 2,
+				Is_Offline: true,
+			},
+		})
+		r.broadcast_room_state()
+		return
+	}
+
+	r.vacate(seat)
+	r.broadcast_room_state()
+	if r.status == Status_Finished {
+		r.check_rematch()
+	}
+}
+
+// vacate takes a player out of their seat for good and hands the host role
+// on if they held it.
+func (r *Room) vacate(seat int) {
+	client := r.clients[seat]
+	r.clients[seat] = nil
+	client.set_room(nil)
+	delete(r.rematch_votes, seat)
+
+	if r.host == client {
+		r.host = r.next_host()
 	}
-	client.room = nil
 
 	r.broadcast(&protocol.Message{
 		Type: protocol.Msg_Player_Left,
 		Payload: protocol.Player_Info{
-			Id:   client.id,
-			Name: client.name,
+			Id:     client.id,
+			Name:   client.name,
+			Seat:   seat,
+			Team:   seat % 2,
+			Is_Bot: client.is_bot,
 		},
 	})
-
-	r.broadcast_room_state()
 }
 
 func (r *Room) handle_play(action Play_Action) {
 	if r.game == nil {
+		action.client.send_error("no game in progress")
 		return
 	}
 
 	seat := r.get_seat(action.client)
-	if seat == -1 || seat != r.game.Current_Turn {
+	if seat == -1 {
 		action.client.send_error("not your turn")
 		return
 	}
 
 	cards := r.game.Get_Cards_By_Id(seat, action.card_ids)
-	if cards == nil {
-		action.client.send_error("invalid cards")
-		return
-	}
+	level := r.game.Level
 
-	combo := game.Detect_Combination(cards, r.game.Level)
-	if combo.Type == game.Comb_Invalid {
-		action.client.send_error("invalid combination")
+	result, err := r.game.Play(seat, action.card_ids)
+	if err != nil {
+		action.client.send_error(err.Error())
 		return
 	}
 
-	if r.game.Current_Lead.Type != game.Comb_Invalid {
-		if !game.Can_Beat(combo, r.game.Current_Lead) {
-			action.client.send_error("cannot beat current play")
-			return
-		}
-	}
-
-	r.game.Remove_Cards(seat, action.card_ids)
-	r.game.Current_Lead = combo
-	r.game.Lead_Player = seat
-	r.game.Pass_Count = 0
+	r.observe(func(b *game.Belief) { b.Observe_Play(seat, cards) })
+	r.record(history.Event{
+		Kind: history.Event_Play,
+		Seat: seat,
+		Play: &history.Play{
+			Card_Ids: action.card_ids,
+			Combo:    history.Combo_Record(result.Combo, level),
+		},
+	})
 
 	r.broadcast(&protocol.Message{
 		Type: protocol.Msg_Play_Made,
@@ -142,38 +442,52 @@ func (r *Room) handle_play(action Play_Action) {
 			Player_Id:  action.client.id,
 			Seat:       seat,
 			Cards:      cards,
-			Combo_Type: combo_type_name(combo.Type),
+			Combo_Type: result.Combo.Type.String(),
 			Is_Pass:    false,
 		},
 	})
+	r.send_card_trackers()
 
-	if len(r.game.Hands[seat]) == 0 {
-		r.game.Finish_Order = append(r.game.Finish_Order, seat)
-		if r.check_hand_end() {
-			return
-		}
+	if result.Finished {
+		r.record(history.Event{
+			Kind:     history.Event_Finish,
+			Seat:     seat,
+			Position: len(r.game.Finish_Order),
+		})
 	}
 
-	r.advance_turn()
+	if result.Hand != nil {
+		r.end_hand(result.Hand)
+		return
+	}
+
+	r.send_turn_notification()
+	r.trigger_bot_turn_if_needed()
 }
 
 func (r *Room) handle_pass(client *Client) {
 	if r.game == nil {
+		client.send_error("no game in progress")
 		return
 	}
 
 	seat := r.get_seat(client)
-	if seat == -1 || seat != r.game.Current_Turn {
+	if seat == -1 {
 		client.send_error("not your turn")
 		return
 	}
 
-	if r.game.Current_Lead.Type == game.Comb_Invalid {
-		client.send_error("cannot pass when leading")
+	lead, lead_seat := r.game.Current_Lead, r.game.Lead_Player
+	if _, err := r.game.Pass(seat); err != nil {
+		client.send_error(err.Error())
 		return
 	}
+	r.observe(func(b *game.Belief) { b.Observe_Pass(seat, lead, lead_seat) })
 
-	r.game.Pass_Count++
+	r.record(history.Event{
+		Kind: history.Event_Pass,
+		Seat: seat,
+	})
 
 	r.broadcast(&protocol.Message{
 		Type: protocol.Msg_Play_Made,
@@ -184,63 +498,49 @@ func (r *Room) handle_pass(client *Client) {
 		},
 	})
 
-	if r.game.Pass_Count >= 3 {
-		r.game.Current_Lead = game.Combination{Type: game.Comb_Invalid}
-		next_leader := r.game.Lead_Player
-		if r.is_finished(next_leader) {
-			teammate := (next_leader + 2) % 4
-			next_leader = teammate
-		}
-		r.game.Current_Turn = next_leader
-		r.game.Pass_Count = 0
-		r.send_turn_notification()
-		r.trigger_bot_turn_if_needed()
-		return
-	}
-
-	r.advance_turn()
+	r.send_turn_notification()
+	r.trigger_bot_turn_if_needed()
 }
 
 func (r *Room) handle_tribute(action Tribute_Action) {
-	if r.game == nil || r.game.Phase != game.Phase_Tribute {
+	if r.game == nil {
+		action.client.send_error("no game in progress")
 		return
 	}
 
 	seat := r.get_seat(action.client)
-	tribute_info := r.game.Get_Tribute_Info(seat)
-	if tribute_info == nil {
-		action.client.send_error("you don't need to give tribute")
-		return
-	}
-
-	card := r.game.Get_Card_By_Id(seat, action.card_id)
-	if card == nil {
-		action.client.send_error("invalid card")
+	if seat == -1 {
+		action.client.send_error("not in this room")
 		return
 	}
 
-	if game.Is_Wild(*card, r.game.Level) {
-		action.client.send_error("cannot tribute wild cards")
+	result, err := r.game.Give_Tribute(seat, action.card_id)
+	if err != nil {
+		action.client.send_error(err.Error())
 		return
 	}
 
-	r.game.Remove_Cards(seat, []int{action.card_id})
-	r.game.Hands[tribute_info.To_Seat] = append(r.game.Hands[tribute_info.To_Seat], *card)
+	r.observe(func(b *game.Belief) { b.Observe_Tribute(seat, result.Tribute.To_Seat, result.Card) })
+	r.record(history.Event{
+		Kind: history.Event_Tribute,
+		Seat: seat,
+		Tribute: &history.Tribute{
+			To_Seat: result.Tribute.To_Seat,
+			Card:    result.Card,
+		},
+	})
 
-	if r.clients[tribute_info.To_Seat] != nil {
-		r.clients[tribute_info.To_Seat].send_message(&protocol.Message{
+	if to := r.clients[result.Tribute.To_Seat]; to != nil {
+		r.send(to, &protocol.Message{
 			Type: protocol.Msg_Tribute_Recv,
 			Payload: protocol.Tribute_Recv_Payload{
-				Card: *card,
+				Card: result.Card,
 			},
 		})
 	}
 
-	r.game.Mark_Tribute_Done(seat)
-
-	if r.game.All_Tributes_Done() {
-		r.game.Phase = game.Phase_Play
-		r.game.Current_Turn = r.game.Tribute_Leader
+	if result.All_Done {
+		r.send_card_trackers()
 		r.send_turn_notification()
 		r.trigger_bot_turn_if_needed()
 	}
@@ -248,18 +548,42 @@ func (r *Room) handle_tribute(action Tribute_Action) {
 
 func (r *Room) start_game() {
 	r.game = game.New_Game_State()
+	r.status = Status_Playing
+	r.match_id = generate_id()
+	r.hand_number = 0
+	r.series_game++
 
-	deck := game.New_Deck()
-	deck.Shuffle()
-	hands := deck.Deal()
+	r.deal_hand()
+}
 
-	for i := 0; i < 4; i++ {
-		r.game.Hands[i] = hands[i]
+func (r *Room) deal_hand() {
+	r.hand_number++
+	r.hand_log = history.New_Hand_Log(r.match_id, r.id, r.hand_number, r.history_players())
+
+	seed := game.New_Seed()
+	hands := game.Deal_From_Seed(seed)
+
+	r.record(history.Event{
+		Kind: history.Event_Deal,
+		Seat: -1,
+		Deal: &history.Deal{
+			Seed:        seed,
+			Level:       r.game.Level,
+			Team_Levels: r.game.Team_Levels,
+			Leader:      r.game.Tribute_Leader,
+			Tributes:    append([]game.Tribute_Info(nil), r.game.Tributes...),
+			Hands:       hands,
+		},
+	})
+
+	r.game.Deal(hands)
+	for seat := range r.beliefs {
+		r.beliefs[seat] = game.New_Belief(seat, hands[seat], r.game.Level)
 	}
 
 	for i := 0; i < 4; i++ {
 		if r.clients[i] != nil {
-			r.clients[i].send_message(&protocol.Message{
+			r.send(r.clients[i], &protocol.Message{
 				Type: protocol.Msg_Deal_Cards,
 				Payload: protocol.Deal_Cards_Payload{
 					Cards: r.game.Hands[i],
@@ -269,228 +593,213 @@ func (r *Room) start_game() {
 		}
 	}
 
-	r.game.Phase = game.Phase_Play
-	r.game.Current_Turn = 0
-	r.send_turn_notification()
-	r.trigger_bot_turn_if_needed()
-}
-
-func (r *Room) check_hand_end() bool {
-	if len(r.game.Finish_Order) < 2 {
-		return false
-	}
-
-	first := r.game.Finish_Order[0]
-	second := r.game.Finish_Order[1]
-
-	first_team := first % 2
-	second_team := second % 2
-
-	if first_team == second_team {
-		r.end_hand(first_team, r.calculate_level_advance())
-		return true
-	}
-
-	if len(r.game.Finish_Order) >= 3 {
-		third := r.game.Finish_Order[2]
-		third_team := third % 2
-
-		winning_team := first_team
-		r.end_hand(winning_team, r.calculate_level_advance())
-		return true
-		_ = third_team
-	}
-
-	return false
-}
-
-func (r *Room) calculate_level_advance() int {
-	if len(r.game.Finish_Order) < 2 {
-		return 0
-	}
-
-	first := r.game.Finish_Order[0]
-	first_team := first % 2
-
-	for i, seat := range r.game.Finish_Order {
-		if seat%2 != first_team {
-			partner_pos := -1
-			for j, s := range r.game.Finish_Order {
-				if s%2 == first_team && j != 0 {
-					partner_pos = j
-					break
-				}
-			}
-
-			if partner_pos == -1 {
-				partner_pos = 3
-			}
-
-			switch {
-			case i == 3 && partner_pos == 1:
-				return 4
-			case i == 2 && partner_pos == 1:
-				return 2
-			default:
-				return 1
+	if r.game.Phase == game.Phase_Tribute {
+		for _, t := range r.game.Tributes {
+			if r.clients[t.From_Seat] != nil {
+				r.send(r.clients[t.From_Seat], &protocol.Message{
+					Type: protocol.Msg_Tribute,
+					Payload: protocol.Tribute_Payload{
+						From_Seat: t.From_Seat,
+						To_Seat:   t.To_Seat,
+					},
+				})
 			}
 		}
+		r.trigger_bot_turn_if_needed()
+		return
 	}
 
-	return 4
+	r.send_card_trackers()
+	r.send_turn_notification()
+	r.trigger_bot_turn_if_needed()
 }
 
-func (r *Room) end_hand(winning_team int, level_advance int) {
-	old_level := r.game.Team_Levels[winning_team]
-	new_level := old_level + level_advance
-	if new_level > 12 {
-		new_level = 12
-	}
-	r.game.Team_Levels[winning_team] = new_level
+func (r *Room) end_hand(result *game.Hand_Result) {
+	r.record(history.Event{
+		Kind: history.Event_Level_Change,
+		Seat: -1,
+		Level_Change: &history.Level_Change{
+			Finish_Order:  result.Finish_Order,
+			Winning_Team:  result.Winning_Team,
+			Level_Advance: result.Level_Advance,
+			Old_Level:     result.Old_Level,
+			New_Levels:    result.New_Levels,
+			Game_Over:     result.Game_Over,
+		},
+	})
+	r.archive_hand()
 
 	r.broadcast(&protocol.Message{
 		Type: protocol.Msg_Hand_End,
 		Payload: protocol.Hand_End_Payload{
-			Finish_Order:  seats_to_ids(r.game.Finish_Order, r.clients),
-			Winning_Team:  winning_team,
-			Level_Advance: level_advance,
-			New_Levels:    r.game.Team_Levels,
+			Finish_Order:  seats_to_ids(result.Finish_Order, r.clients),
+			Winning_Team:  result.Winning_Team,
+			Level_Advance: result.Level_Advance,
+			New_Levels:    result.New_Levels,
 		},
 	})
 
-	if new_level >= 12 && game.Rank(old_level) == game.Rank_Ace {
-		r.broadcast(&protocol.Message{
-			Type: protocol.Msg_Game_End,
-			Payload: protocol.Game_End_Payload{
-				Winning_Team: winning_team,
-				Final_Levels: r.game.Team_Levels,
-			},
-		})
+	if result.Game_Over {
+		r.status = Status_Finished
+		r.series_wins[result.Winning_Team]++
+		r.log_event("game_end", -1, nil)
+
+		payload := protocol.Game_End_Payload{
+			Winning_Team: result.Winning_Team,
+			Final_Levels: result.New_Levels,
+			Series:       r.series_state(),
+		}
+		r.rate_match(payload)
 		return
 	}
 
-	r.setup_tribute()
+	r.deal_hand()
 }
 
-func (r *Room) setup_tribute() {
-	r.game.Setup_Tributes()
-
-	if len(r.game.Tributes) == 0 {
-		r.start_new_hand()
+func (r *Room) record(ev history.Event) {
+	if r.hand_log == nil {
 		return
 	}
 
-	for _, t := range r.game.Tributes {
-		if r.clients[t.From_Seat] != nil {
-			r.clients[t.From_Seat].send_message(&protocol.Message{
-				Type: protocol.Msg_Tribute,
-				Payload: protocol.Tribute_Payload{
-					From_Seat: t.From_Seat,
-					To_Seat:   t.To_Seat,
-				},
-			})
-		}
-	}
-
-	r.game.Phase = game.Phase_Tribute
+	ev = r.hand_log.Add(ev)
+	r.log_event(string(ev.Kind), ev.Seat, ev)
 }
 
-func (r *Room) start_new_hand() {
-	r.game.Reset_Hand()
-
-	deck := game.New_Deck()
-	deck.Shuffle()
-	hands := deck.Deal()
-
-	for i := 0; i < 4; i++ {
-		r.game.Hands[i] = hands[i]
-	}
-
-	for i := 0; i < 4; i++ {
-		if r.clients[i] != nil {
-			r.clients[i].send_message(&protocol.Message{
-				Type: protocol.Msg_Deal_Cards,
-				Payload: protocol.Deal_Cards_Payload{
-					Cards: r.game.Hands[i],
-					Level: r.game.Level,
-				},
-			})
+func (r *Room) history_players() [4]history.Player {
+	var players [4]history.Player
+	for i, c := range r.clients {
+		if c != nil {
+			players[i] = history.Player{
+				Id:       c.id,
+				Name:     c.name,
+				Username: c.username,
+				Is_Bot:   c.is_bot,
+			}
 		}
 	}
-
-	r.game.Phase = game.Phase_Play
-	r.game.Current_Turn = r.game.Tribute_Leader
-	r.send_turn_notification()
-	r.trigger_bot_turn_if_needed()
+	return players
 }
 
-func (r *Room) advance_turn() {
-	for i := 1; i <= 4; i++ {
-		next := (r.game.Current_Turn + i) % 4
-		if !r.is_finished(next) {
-			r.game.Current_Turn = next
-			r.send_turn_notification()
-			r.trigger_bot_turn_if_needed()
-			return
-		}
+func (r *Room) send_turn_notification() {
+	if r.game.Phase != game.Phase_Play {
+		return
 	}
-}
 
-func (r *Room) is_finished(seat int) bool {
-	for _, s := range r.game.Finish_Order {
-		if s == seat {
-			return true
-		}
+	can_pass := r.game.Current_Lead.Type != game.Comb_Invalid
+	lead_type := ""
+	if can_pass {
+		lead_type = r.game.Current_Lead.Type.String()
 	}
-	return false
-}
 
-func (r *Room) send_turn_notification() {
-	can_pass := r.game.Current_Lead.Type != game.Comb_Invalid
+	player_id := ""
+	if c := r.clients[r.game.Current_Turn]; c != nil {
+		player_id = c.id
+	}
 
 	r.broadcast(&protocol.Message{
 		Type: protocol.Msg_Turn,
 		Payload: protocol.Turn_Payload{
-			Player_Id: r.clients[r.game.Current_Turn].id,
-			Seat:      r.game.Current_Turn,
-			Can_Pass:  can_pass,
+			Player_Id:       player_id,
+			Seat:            r.game.Current_Turn,
+			Lead_Combo_Type: lead_type,
+			Can_Pass:        can_pass,
 		},
 	})
 }
 
+// send stamps msg with the number of the room's latest event. Only messages
+// that reach every member of the room count as an event, so a client that
+// sees the number jump by more than one has missed one.
+func (r *Room) send(client *Client, msg *protocol.Message) {
+	out := *msg
+	out.Seq = r.seq
+	client.send_message(&out)
+}
+
 func (r *Room) broadcast(msg *protocol.Message) {
+	r.seq++
 	for _, client := range r.clients {
 		if client != nil {
-			client.send_message(msg)
+			r.send(client, msg)
 		}
 	}
+	for _, client := range r.spectators {
+		r.send(client, msg)
+	}
 }
 
 func (r *Room) broadcast_room_state() {
+	r.seq++
+	for _, client := range r.clients {
+		if client != nil {
+			r.send(client, r.room_state(client))
+		}
+	}
+	for _, client := range r.spectators {
+		r.send(client, r.room_state(client))
+	}
+}
+
+// room_state is the room as client sees it; only seated players get the
+// invite token.
+func (r *Room) room_state(client *Client) *protocol.Message {
 	players := make([]protocol.Player_Info, 0)
 	for i, c := range r.clients {
 		if c != nil {
 			players = append(players, protocol.Player_Info{
-				Id:   c.id,
-				Name: c.name,
-				Seat: i,
-				Team: i % 2,
+				Id:         c.id,
+				Name:       c.name,
+				Seat:       i,
+				Team:       i % 2,
+				Is_Ready:   c.is_ready,
+				Is_Bot:     c.is_bot,
+				Is_Host:    c == r.host,
+				Is_Offline: c.offline,
+				Username:   c.username,
 			})
 		}
 	}
 
-	for _, client := range r.clients {
-		if client != nil {
-			client.send_message(&protocol.Message{
-				Type: protocol.Msg_Room_State,
-				Payload: protocol.Room_State_Payload{
-					Room_Id:     r.id,
-					Players:     players,
-					Game_Active: r.game != nil,
-					Your_Id:     client.id,
-				},
-			})
-		}
+	spectators := make([]protocol.Spectator_Info, 0, len(r.spectators))
+	for _, c := range r.spectators {
+		spectators = append(spectators, protocol.Spectator_Info{
+			Id:       c.id,
+			Name:     c.name,
+			Username: c.username,
+		})
+	}
+
+	host_id := ""
+	if r.host != nil {
+		host_id = r.host.id
+	}
+
+	invite := ""
+	if r.get_seat(client) != -1 {
+		invite = r.invite
+	}
+
+	return &protocol.Message{
+		Type: protocol.Msg_Room_State,
+		Payload: protocol.Room_State_Payload{
+			Room_Id:       r.id,
+			Players:       players,
+			Spectators:    spectators,
+			Game_Active:   r.game != nil,
+			Status:        r.status.String(),
+			Your_Id:       client.id,
+			Host_Id:       host_id,
+			Session_Token: client.token,
+			Is_Spectator:  r.is_spectator(client),
+			Quiet_Hands:   r.quiet_hands,
+			Rated:         !r.casual,
+			Card_Tracker:  r.tracking(),
+			Visibility:    r.visibility(),
+			Has_Password:  r.password.Load() != nil,
+			Invite_Token:  invite,
+			Series:        r.series_state(),
+			Rematch_Votes: r.rematch_votes_info(),
+		},
 	}
 }
 
@@ -521,20 +830,6 @@ func (r *Room) get_seat(client *Client) int {
 	return -1
 }
 
-func combo_type_name(t game.Combination_Type) string {
-	names := map[game.Combination_Type]string{
-		game.Comb_Single:     "single",
-		game.Comb_Pair:       "pair",
-		game.Comb_Triple:     "triple",
-		game.Comb_Full_House: "full_house",
-		game.Comb_Straight:   "straight",
-		game.Comb_Tube:       "tube",
-		game.Comb_Plate:      "plate",
-		game.Comb_Bomb:       "bomb",
-	}
-	return names[t]
-}
-
 func seats_to_ids(seats []int, clients [4]*Client) []string {
 	ids := make([]string, len(seats))
 	for i, seat := range seats {
@@ -545,38 +840,76 @@ func seats_to_ids(seats []int, clients [4]*Client) []string {
 	return ids
 }
 
-func (r *Room) handle_fill_bots() {
-	bot_idx := 0
+func (r *Room) handle_fill_bots(client *Client) {
+	if !r.check_host_in_lobby(client) {
+		return
+	}
+
 	for i := 0; i < 4; i++ {
-		if r.clients[i] == nil && bot_idx < len(bot_names) {
-			bot := new_bot(generate_id(), bot_names[bot_idx])
-			bot.room = r
-			r.clients[i] = bot
-			bot_idx++
+		if r.clients[i] == nil {
+			r.seat_bot(i)
 		}
 	}
 
 	r.broadcast_room_state()
+}
 
-	if r.is_full() {
-		r.start_game()
-	}
+// Bot_Turn_Action asks the bot in seat to act. A bot in play first thinks
+// off the room goroutine and comes back with thought set, holding the
+// position it thought about and, near the end of a hand, a solved move.
+type Bot_Turn_Action struct {
+	seat    int
+	thought bool
+	log     *history.Hand_Log
+	step    int
+	move    game.Solve_Move
+	solved  bool
 }
 
-func (r *Room) handle_bot_turn(seat int) {
-	if r.game == nil || r.game.Current_Turn != seat {
+const (
+	bot_delay           = 1500 * time.Millisecond
+	bot_endgame_cards   = 14
+	bot_endgame_samples = 4
+	bot_endgame_nodes   = 10000
+)
+
+func (r *Room) handle_bot_turn(action Bot_Turn_Action) {
+	if r.game == nil {
 		return
 	}
 
+	seat := action.seat
 	client := r.clients[seat]
 	if client == nil || !client.is_bot {
 		return
 	}
 
-	time.Sleep(1500 * time.Millisecond)
+	if r.game.Phase == game.Phase_Tribute {
+		r.handle_bot_tribute(client, seat)
+		return
+	}
 
-	hand := r.game.Hands[seat]
-	if len(hand) == 0 {
+	if r.game.Phase != game.Phase_Play || r.game.Current_Turn != seat || len(r.game.Hands[seat]) == 0 {
+		return
+	}
+
+	if !action.thought {
+		r.think_bot_turn(seat)
+		return
+	}
+	if action.log != r.hand_log || r.hand_log != nil && action.step != len(r.hand_log.Events) {
+		return
+	}
+
+	if action.solved {
+		if action.move.Pass {
+			r.handle_pass(client)
+		} else {
+			r.handle_play(Play_Action{
+				client:   client,
+				card_ids: card_ids(action.move.Play.Cards),
+			})
+		}
 		return
 	}
 
@@ -588,7 +921,7 @@ func (r *Room) handle_bot_turn(seat int) {
 			return
 		}
 
-		play := r.find_lowest_valid_play(seat)
+		play := r.choose_bot_follow(seat)
 		if play == nil {
 			r.handle_pass(client)
 			return
@@ -601,99 +934,137 @@ func (r *Room) handle_bot_turn(seat int) {
 		return
 	}
 
-	card_ids := []int{hand[0].Id}
 	r.handle_play(Play_Action{
 		client:   client,
-		card_ids: card_ids,
+		card_ids: r.choose_bot_lead(seat),
 	})
 }
 
-func (r *Room) find_lowest_valid_play(seat int) []int {
-	hand := r.game.Hands[seat]
-	lead := r.game.Current_Lead
-
-	sorted_hand := make([]game.Card, len(hand))
-	copy(sorted_hand, hand)
-	for i := 0; i < len(sorted_hand)-1; i++ {
-		for j := i + 1; j < len(sorted_hand); j++ {
-			vi := game.Card_Value(sorted_hand[i], r.game.Level)
-			vj := game.Card_Value(sorted_hand[j], r.game.Level)
-			if vi > vj {
-				sorted_hand[i], sorted_hand[j] = sorted_hand[j], sorted_hand[i]
-			}
-		}
+// think_bot_turn waits out the bot's delay on its own goroutine and, once
+// few enough cards are left, spends it solving the endgame over deals drawn
+// from the bot's belief. The game and belief are copied first, so the room
+// goes on handling messages meanwhile.
+func (r *Room) think_bot_turn(seat int) {
+	action := Bot_Turn_Action{seat: seat, thought: true, log: r.hand_log}
+	if r.hand_log != nil {
+		action.step = len(r.hand_log.Events)
 	}
 
-	if lead.Type == game.Comb_Single {
-		for _, card := range sorted_hand {
-			combo := game.Detect_Combination([]game.Card{card}, r.game.Level)
-			if game.Can_Beat(combo, lead) {
-				return []int{card.Id}
-			}
-		}
+	var g *game.Game_State
+	var belief *game.Belief
+	if r.endgame() && r.beliefs[seat] != nil {
+		g, belief = r.game.Clone(), r.beliefs[seat].Clone()
 	}
 
-	if lead.Type == game.Comb_Pair {
-		rank_cards := make(map[game.Rank][]game.Card)
-		for _, card := range sorted_hand {
-			rank_cards[card.Rank] = append(rank_cards[card.Rank], card)
+	go func() {
+		start := time.Now()
+		if belief != nil {
+			rng := mrand.New(mrand.NewPCG(game.New_Seed(), game.New_Seed()))
+			action.move, action.solved = game.Solve_Sampled(g, belief, bot_endgame_samples, bot_endgame_nodes, rng)
 		}
+		time.Sleep(bot_delay - time.Since(start))
+		send_to_room(r, r.bot_turn, action)
+	}()
+}
 
-		var valid_pairs [][]game.Card
-		for _, cards := range rank_cards {
-			if len(cards) >= 2 {
-				combo := game.Detect_Combination(cards[:2], r.game.Level)
-				if game.Can_Beat(combo, lead) {
-					valid_pairs = append(valid_pairs, cards[:2])
-				}
-			}
-		}
+// endgame reports whether few enough cards are left for bots to solve.
+func (r *Room) endgame() bool {
+	left := 0
+	for _, hand := range r.game.Hands {
+		left += len(hand)
+	}
+	return left <= bot_endgame_cards
+}
 
-		if len(valid_pairs) > 0 {
-			lowest := valid_pairs[0]
-			lowest_val := game.Card_Value(lowest[0], r.game.Level)
-			for _, pair := range valid_pairs[1:] {
-				val := game.Card_Value(pair[0], r.game.Level)
-				if val < lowest_val {
-					lowest = pair
-					lowest_val = val
-				}
+// choose_bot_lead leads the weakest play of the hand's best decomposition,
+// keeping bombs back while anything else is left.
+func (r *Room) choose_bot_lead(seat int) []int {
+	plays := game.Decompose(r.game.Hands[seat], r.game.Level).Plays
+	best := plays[0]
+	for _, p := range plays[1:] {
+		if (best.Type == game.Comb_Bomb) != (p.Type == game.Comb_Bomb) {
+			if best.Type == game.Comb_Bomb {
+				best = p
 			}
-			return []int{lowest[0].Id, lowest[1].Id}
+			continue
+		}
+		if p.Rank_Value < best.Rank_Value || p.Rank_Value == best.Rank_Value && len(p.Cards) > len(best.Cards) {
+			best = p
 		}
 	}
+	return card_ids(best.Cards)
+}
 
-	if lead.Type == game.Comb_Triple {
-		rank_cards := make(map[game.Rank][]game.Card)
-		for _, card := range sorted_hand {
-			rank_cards[card.Rank] = append(rank_cards[card.Rank], card)
+// choose_bot_follow beats the lead with the play that leaves the strongest
+// hand. It bombs only to go out or to stop an opponent close to going out.
+func (r *Room) choose_bot_follow(seat int) []int {
+	hand := r.game.Hands[seat]
+	plays := game.Legal_Plays(hand, r.game.Current_Lead, r.game.Level)
+
+	scorer := game.New_Hand_Scorer(r.game.Level)
+	var best *game.Combination
+	best_strength := 0
+	for i, p := range plays {
+		if p.Type == game.Comb_Bomb {
+			continue
+		}
+		strength := scorer.Strength_After(hand, p)
+		if best == nil || strength > best_strength {
+			best, best_strength = &plays[i], strength
 		}
+	}
+	if best != nil {
+		return card_ids(best.Cards)
+	}
 
-		var valid_triples [][]game.Card
-		for _, cards := range rank_cards {
-			if len(cards) >= 3 {
-				combo := game.Detect_Combination(cards[:3], r.game.Level)
-				if game.Can_Beat(combo, lead) {
-					valid_triples = append(valid_triples, cards[:3])
-				}
-			}
+	for _, p := range plays {
+		if len(p.Cards) == len(hand) || len(r.game.Hands[r.game.Lead_Player]) <= 6 {
+			return card_ids(p.Cards)
 		}
+	}
+	return nil
+}
 
-		if len(valid_triples) > 0 {
-			lowest := valid_triples[0]
-			lowest_val := game.Card_Value(lowest[0], r.game.Level)
-			for _, triple := range valid_triples[1:] {
-				val := game.Card_Value(triple[0], r.game.Level)
-				if val < lowest_val {
-					lowest = triple
-					lowest_val = val
-				}
-			}
-			return []int{lowest[0].Id, lowest[1].Id, lowest[2].Id}
+func card_ids(cards []game.Card) []int {
+	ids := make([]int, len(cards))
+	for i, c := range cards {
+		ids[i] = c.Id
+	}
+	return ids
+}
+
+func (r *Room) handle_bot_tribute(client *Client, seat int) {
+	if r.game.Get_Tribute_Info(seat) == nil {
+		return
+	}
+
+	// Tribute must be the highest card; among equal ones give the card the
+	// rest of the hand misses least.
+	hand := r.game.Hands[seat]
+	scorer := game.New_Hand_Scorer(r.game.Level)
+	var best *game.Card
+	best_strength := 0
+	for i, card := range hand {
+		if game.Is_Wild(card, r.game.Level) {
+			continue
+		}
+		if best != nil && game.Card_Value(card, r.game.Level) < game.Card_Value(*best, r.game.Level) {
+			continue
+		}
+		strength := scorer.Strength_After(hand, game.Combination{Cards: []game.Card{card}})
+		if best == nil || game.Card_Value(card, r.game.Level) > game.Card_Value(*best, r.game.Level) || strength > best_strength {
+			best, best_strength = &hand[i], strength
 		}
 	}
 
-	return nil
+	if best == nil {
+		return
+	}
+
+	r.handle_tribute(Tribute_Action{
+		client:  client,
+		card_id: best.Id,
+	})
 }
 
 func (r *Room) trigger_bot_turn_if_needed() {
@@ -701,11 +1072,22 @@ func (r *Room) trigger_bot_turn_if_needed() {
 		return
 	}
 
+	if r.game.Phase == game.Phase_Tribute {
+		for _, t := range r.game.Tributes {
+			if c := r.clients[t.From_Seat]; !t.Done && c != nil && c.is_bot {
+				go send_to_room(r, r.bot_turn, Bot_Turn_Action{seat: t.From_Seat})
+			}
+		}
+		return
+	}
+
+	if r.game.Phase != game.Phase_Play {
+		return
+	}
+
 	seat := r.game.Current_Turn
 	client := r.clients[seat]
 	if client != nil && client.is_bot {
-		go func() {
-			r.bot_turn <- seat
-		}()
+		go send_to_room(r, r.bot_turn, Bot_Turn_Action{seat: seat})
 	}
 }
@@ -0,0 +1,100 @@
+package room
+
+import (
+	"encoding/json"
+	"testing"
+
+	"guandanbtw/protocol"
+)
+
+type sent_message struct {
+	Type protocol.Msg_Type `json:"type"`
+	Seq  int64             `json:"seq"`
+}
+
+func drain(c *Client) []sent_message {
+	var out []sent_message
+	for {
+		select {
+		case data := <-c.send:
+			var m sent_message
+			json.Unmarshal(data, &m)
+			out = append(out, m)
+		default:
+			return out
+		}
+	}
+}
+
+func TestRoomSeqCountsOnlyRoomWideMessages(t *testing.T) {
+	r := new_test_room(t)
+	players := seat_test_players(r)
+	spectator := new_client(generate_id(), nil)
+	r.spectators = append(r.spectators, spectator)
+
+	r.broadcast_room_state()
+	r.send(players[0], &protocol.Message{Type: protocol.Msg_Error})
+	r.broadcast(&protocol.Message{Type: protocol.Msg_Player_Left})
+
+	got := drain(players[0])
+	want := []sent_message{{protocol.Msg_Room_State, 1}, {protocol.Msg_Error, 1}, {protocol.Msg_Player_Left, 2}}
+	if len(got) != len(want) {
+		t.Fatalf("seat 0 got %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("seat 0 got %+v, want %+v", got, want)
+		}
+	}
+
+	for _, c := range []*Client{players[1], spectator} {
+		got := drain(c)
+		if len(got) != 2 || got[0].Seq != 1 || got[1].Seq != 2 {
+			t.Fatalf("got %+v, want events 1 and 2 with no gap", got)
+		}
+	}
+}
+
+func TestActionsWithoutEffectAreRejected(t *testing.T) {
+	r := new_test_room(t)
+	players := seat_test_players(r)
+	outsider := new_client(generate_id(), nil)
+
+	tests := []struct {
+		name   string
+		client *Client
+		act    func(c *Client)
+	}{
+		{"pass with no game", players[0], func(c *Client) { r.handle_pass(c) }},
+		{"play with no game", players[0], func(c *Client) { r.handle_play(Play_Action{client: c, card_ids: []int{1}}) }},
+		{"tribute with no game", players[0], func(c *Client) { r.handle_tribute(Tribute_Action{client: c}) }},
+		{"lobby action from outsider", outsider, func(c *Client) {
+			r.handle_lobby(Lobby_Action{client: c, kind: protocol.Msg_Set_Ready, ready: true})
+		}},
+		{"rematch vote from outsider", outsider, func(c *Client) { r.handle_rematch_vote(Rematch_Action{client: c, vote: true}) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			drain(tt.client)
+			tt.client.begin_request("r1")
+			tt.act(tt.client)
+			if !tt.client.rejected {
+				t.Fatal("request was not rejected")
+			}
+			got := drain(tt.client)
+			if len(got) == 0 || got[len(got)-1].Type != protocol.Msg_Error {
+				t.Fatalf("got %+v, want an error", got)
+			}
+		})
+	}
+}
+
+func TestClientWithoutRoomIsRejected(t *testing.T) {
+	c := new_client(generate_id(), nil)
+	c.begin_request("r1")
+	c.handle_pass()
+	if !c.rejected {
+		t.Fatal("a pass outside any room was not rejected")
+	}
+}
@@ -0,0 +1,143 @@
+package room
+
+import (
+	"slices"
+
+	"guandanbtw/protocol"
+)
+
+var series_lengths = []int{1, 3, 5, 7}
+
+type Rematch_Action struct {
+	client *Client
+	vote   bool
+	swap   bool
+}
+
+func (r *Room) series_winner() int {
+	for t, wins := range r.series_wins {
+		if wins > r.best_of/2 {
+			return t
+		}
+	}
+	return -1
+}
+
+func (r *Room) series_state() protocol.Series_State {
+	return protocol.Series_State{
+		Best_Of: r.best_of,
+		Wins:    r.series_wins,
+		Game:    r.series_game,
+		Winner:  r.series_winner(),
+	}
+}
+
+// handle_set_series is allowed in the lobby and between matches, but not in
+// the middle of an undecided series.
+func (r *Room) handle_set_series(client *Client, best_of int) {
+	if client != r.host {
+		client.send_error("only the host can do that")
+		return
+	}
+	if r.status == Status_Playing {
+		client.send_error("game already in progress")
+		return
+	}
+	if r.series_game > 0 && r.series_winner() == -1 {
+		client.send_error("the series is still being played")
+		return
+	}
+	if !slices.Contains(series_lengths, best_of) {
+		client.send_error("series must be best of 1, 3, 5 or 7")
+		return
+	}
+
+	r.best_of = best_of
+	r.series_wins = [2]int{}
+	r.series_game = 0
+	r.broadcast_room_state()
+}
+
+func (r *Room) handle_rematch_vote(action Rematch_Action) {
+	client := action.client
+	seat := r.get_seat(client)
+	if seat == -1 || client.is_bot {
+		client.send_error("you are not seated")
+		return
+	}
+	if r.status != Status_Finished {
+		client.send_error("the match is not over")
+		return
+	}
+
+	if !action.vote {
+		delete(r.rematch_votes, seat)
+		r.broadcast_room_state()
+		return
+	}
+	if action.swap && r.series_winner() == -1 && r.series_game > 0 {
+		client.send_error("partners stay fixed until the series is decided")
+		return
+	}
+
+	r.rematch_votes[seat] = action.swap
+	r.broadcast_room_state()
+	r.check_rematch()
+}
+
+// check_rematch starts the next match once every connected player has voted.
+func (r *Room) check_rematch() {
+	if len(r.rematch_votes) == 0 {
+		return
+	}
+	for i, c := range r.clients {
+		if c == nil || c.is_bot || c.offline {
+			continue
+		}
+		if _, ok := r.rematch_votes[i]; !ok {
+			return
+		}
+	}
+	r.start_rematch()
+}
+
+// start_rematch replays with the same seats, seating bots where players have
+// left or are still offline. Partners swap only if every voter asked for it.
+func (r *Room) start_rematch() {
+	swap := len(r.rematch_votes) > 0
+	for _, s := range r.rematch_votes {
+		swap = swap && s
+	}
+	clear(r.rematch_votes)
+
+	if r.series_winner() != -1 {
+		r.series_wins = [2]int{}
+		r.series_game = 0
+	}
+	if swap {
+		r.clients[1], r.clients[2] = r.clients[2], r.clients[1]
+	}
+	for i, c := range r.clients {
+		if c != nil && c.offline {
+			r.vacate(i)
+			c = nil
+		}
+		if c == nil {
+			r.seat_bot(i)
+		}
+	}
+
+	r.log_event("rematch", -1, map[string]bool{"swap_partners": swap})
+	r.broadcast_room_state()
+	r.start_game()
+}
+
+func (r *Room) rematch_votes_info() []protocol.Rematch_Vote {
+	var votes []protocol.Rematch_Vote
+	for seat, c := range r.clients {
+		if swap, ok := r.rematch_votes[seat]; ok && c != nil {
+			votes = append(votes, protocol.Rematch_Vote{Player_Id: c.id, Swap_Partners: swap})
+		}
+	}
+	return votes
+}
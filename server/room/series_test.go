@@ -0,0 +1,111 @@
+package room
+
+import (
+	"testing"
+
+	"guandanbtw/protocol"
+)
+
+func new_test_room(t *testing.T) *Room {
+	t.Helper()
+	hub := New_Hub(Hub_Options{Rooms: Default_Room_Config()})
+	r := new_room("TEST", hub, hub.room_config)
+	t.Cleanup(func() { close(r.done) })
+	return r
+}
+
+func seat_test_players(r *Room) [4]*Client {
+	var players [4]*Client
+	for i := range players {
+		players[i] = new_client(generate_id(), nil)
+		players[i].name = "Player"
+		players[i].set_room(r)
+		r.clients[i] = players[i]
+	}
+	r.host = players[0]
+	return players
+}
+
+func TestLobbyActionsBetweenMatches(t *testing.T) {
+	r := new_test_room(t)
+	players := seat_test_players(r)
+	r.start_game()
+	if r.game == nil {
+		t.Fatal("no game started")
+	}
+
+	r.handle_lobby(Lobby_Action{client: players[0], kind: protocol.Msg_Set_Series, best_of: 3})
+	if r.best_of != 1 {
+		t.Fatal("series changed in the middle of a match")
+	}
+
+	r.status = Status_Finished
+	r.series_game = 1
+	r.series_wins = [2]int{1, 0}
+	r.handle_lobby(Lobby_Action{client: players[0], kind: protocol.Msg_Set_Series, best_of: 3})
+	if r.best_of != 3 {
+		t.Fatalf("best_of = %d after the match, want 3", r.best_of)
+	}
+}
+
+func TestRematchSeatsBotsForOfflinePlayers(t *testing.T) {
+	r := new_test_room(t)
+	players := seat_test_players(r)
+	r.status = Status_Finished
+	players[0].offline = true
+
+	r.start_rematch()
+
+	for i, c := range r.clients {
+		if c == nil {
+			t.Fatalf("seat %d is empty", i)
+		}
+	}
+	if !r.clients[0].is_bot {
+		t.Fatal("the offline player kept their seat")
+	}
+	if players[0].current_room() != nil {
+		t.Fatal("the offline player still points at the room")
+	}
+	if r.host != players[1] {
+		t.Fatal("the host role did not pass to a connected player")
+	}
+	for _, c := range players[1:] {
+		if r.get_seat(c) == -1 {
+			t.Fatal("a connected player lost their seat")
+		}
+	}
+	if r.status != Status_Playing {
+		t.Fatalf("status = %v, want playing", r.status)
+	}
+}
+
+func TestStartGameBetweenMatchesNeedsRematch(t *testing.T) {
+	r := new_test_room(t)
+	players := seat_test_players(r)
+	for _, c := range players {
+		c.is_ready = true
+	}
+	r.best_of = 3
+	r.start_game()
+	r.status = Status_Finished
+	r.series_wins = [2]int{1, 0}
+	r.rematch_votes[1] = false
+	match_id := r.match_id
+
+	players[0].begin_request("r1")
+	r.handle_lobby(Lobby_Action{client: players[0], kind: protocol.Msg_Start_Game})
+	if !players[0].rejected {
+		t.Fatal("start_game between matches was accepted")
+	}
+	if r.status != Status_Finished || r.match_id != match_id || r.series_game != 1 {
+		t.Fatal("start_game between matches started a match")
+	}
+
+	for _, c := range players {
+		r.handle_rematch_vote(Rematch_Action{client: c, vote: true})
+	}
+	if r.status != Status_Playing || r.series_game != 2 || len(r.rematch_votes) != 0 {
+		t.Fatalf("rematch: status %v, series game %d, %d votes left", r.status, r.series_game, len(r.rematch_votes))
+	}
+}
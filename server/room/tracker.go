@@ -0,0 +1,47 @@
+package room
+
+import (
+	"guandanbtw/game"
+	"guandanbtw/protocol"
+)
+
+func (r *Room) handle_set_card_tracker(client *Client, on bool) {
+	if !r.check_host_in_lobby(client) {
+		return
+	}
+	if on && !r.casual {
+		client.send_error("the card tracker is off in rated rooms")
+		return
+	}
+
+	r.card_tracker = on
+	r.broadcast_room_state()
+}
+
+// tracking reports whether players get card trackers. Making the room
+// rated turns them off.
+func (r *Room) tracking() bool {
+	return r.casual && r.card_tracker
+}
+
+func (r *Room) send_card_trackers() {
+	for seat, c := range r.clients {
+		if c != nil && !c.is_bot {
+			r.send_card_tracker(c, seat)
+		}
+	}
+}
+
+// send_card_tracker tells a seated player what they have not seen yet,
+// worked out from the seat's belief, which holds only public events, rather
+// than from the game state.
+func (r *Room) send_card_tracker(client *Client, seat int) {
+	if !r.tracking() || r.game == nil || r.game.Phase != game.Phase_Play || r.beliefs[seat] == nil {
+		return
+	}
+
+	r.send(client, &protocol.Message{
+		Type:    protocol.Msg_Card_Tracker,
+		Payload: protocol.Project_Card_Tracker(r.beliefs[seat]),
+	})
+}
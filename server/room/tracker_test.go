@@ -0,0 +1,60 @@
+package room
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"guandanbtw/game"
+	"guandanbtw/protocol"
+)
+
+func last_card_tracker(t *testing.T, c *Client) protocol.Card_Tracker_Payload {
+	t.Helper()
+
+	var out *protocol.Card_Tracker_Payload
+	for {
+		select {
+		case data := <-c.send:
+			var m struct {
+				Type    protocol.Msg_Type             `json:"type"`
+				Payload protocol.Card_Tracker_Payload `json:"payload"`
+			}
+			if err := json.Unmarshal(data, &m); err != nil {
+				t.Fatal(err)
+			}
+			if m.Type == protocol.Msg_Card_Tracker {
+				out = &m.Payload
+			}
+		default:
+			if out == nil {
+				t.Fatal("no card tracker sent")
+			}
+			return *out
+		}
+	}
+}
+
+func TestCardTrackerMatchesTheHandLog(t *testing.T) {
+	r := new_test_room(t)
+	players := seat_test_players(r)
+	r.casual, r.card_tracker = true, true
+	r.start_game()
+
+	for range 3 {
+		seat := r.game.Current_Turn
+		plays := game.Legal_Plays(r.game.Hands[seat], r.game.Current_Lead, r.game.Level)
+		r.handle_play(Play_Action{client: players[seat], card_ids: card_ids(plays[0].Cards)})
+	}
+
+	for seat, c := range players {
+		got := last_card_tracker(t, c)
+		b, err := r.hand_log.Belief_At(seat, len(r.hand_log.Events))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := protocol.Project_Card_Tracker(b); !reflect.DeepEqual(got, want) {
+			t.Fatalf("seat %d tracker %+v, replay gives %+v", seat, got, want)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package stats
+
+import (
+	"encoding/csv"
+	"html/template"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"guandanbtw/web"
+)
+
+var page = template.Must(template.New("stats").Funcs(template.FuncMap{
+	"pct":  func(f float64) string { return strconv.FormatFloat(f*100, 'f', 1, 64) + "%" },
+	"num":  func(f float64) string { return strconv.FormatFloat(f, 'f', 2, 64) },
+	"secs": func(ms float64) string { return strconv.FormatFloat(ms/1000, 'f', 1, 64) + "s" },
+}).Parse(`<!doctype html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Guan Dan stats</title>
+<style>
+body { font-family: sans-serif; background: #1a1a2e; color: #eee; padding: 24px; }
+table { border-collapse: collapse; }
+th, td { padding: 6px 10px; border-bottom: 1px solid #333; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+a { color: #4da3ff; }
+</style>
+</head>
+<body>
+<h1>Player stats</h1>
+<p><a href="/api/stats/players.csv">Download CSV</a></p>
+<table>
+<tr><th>Player</th><th>Hands</th><th>Head wins</th><th>Last place</th><th>Double wins</th><th>Tributes paid</th><th>Tributes received</th><th>Bombs</th><th>Avg finish</th><th>Deal strength</th><th>Deal rank</th><th>Avg think</th><th>Hints</th></tr>
+{{range .}}<tr>
+<td>{{.Name}} (@{{.Username}})</td>
+<td>{{.Hands_Played}}</td>
+<td>{{pct .Head_Win_Rate}}</td>
+<td>{{pct .Last_Place_Rate}}</td>
+<td>{{.Double_Wins}}</td>
+<td>{{.Tributes_Paid}}</td>
+<td>{{.Tributes_Received}}</td>
+<td>{{range $kind, $n := .Bombs}}{{$kind}}: {{$n}}<br>{{else}}-{{end}}</td>
+<td>{{num .Avg_Finish_Position}}</td>
+<td>{{num .Avg_Deal_Strength}}</td>
+<td>{{num .Avg_Deal_Rank}}</td>
+<td>{{secs .Avg_Think_Ms}}</td>
+<td>{{.Hints_Used}}</td>
+</tr>
+{{else}}<tr><td colspan="13">No recorded hands yet.</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+func (t *Tracker) Handle_Players(w http.ResponseWriter, r *http.Request) {
+	web.Write_JSON(w, http.StatusOK, map[string]interface{}{
+		"players": t.Players(),
+	})
+}
+
+func (t *Tracker) Handle_Player(w http.ResponseWriter, r *http.Request) {
+	s, ok := t.Player(r.PathValue("player"))
+	if !ok {
+		web.Write_Error(w, http.StatusNotFound, "player not found")
+		return
+	}
+	web.Write_JSON(w, http.StatusOK, s)
+}
+
+func (t *Tracker) Handle_CSV(w http.ResponseWriter, r *http.Request) {
+	players := t.Players()
+
+	var kinds []string
+	for _, p := range players {
+		for kind := range p.Bombs {
+			if !slices.Contains(kinds, kind) {
+				kinds = append(kinds, kind)
+			}
+		}
+	}
+	slices.Sort(kinds)
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="player_stats.csv"`)
+
+	out := csv.NewWriter(w)
+	header := []string{
+		"id", "username", "name", "hands_played", "head_wins", "head_win_rate",
+		"last_places", "last_place_rate", "double_wins", "tributes_paid",
+		"tributes_received", "avg_finish_position", "avg_deal_strength",
+		"avg_deal_rank", "avg_think_ms", "hints_used",
+	}
+	for _, kind := range kinds {
+		header = append(header, "bombs_"+kind)
+	}
+	out.Write(header)
+
+	for _, p := range players {
+		row := []string{
+			csv_text(p.Id), csv_text(p.Username), csv_text(p.Name),
+			strconv.Itoa(p.Hands_Played),
+			strconv.Itoa(p.Head_Wins), format_float(p.Head_Win_Rate),
+			strconv.Itoa(p.Last_Places), format_float(p.Last_Place_Rate),
+			strconv.Itoa(p.Double_Wins),
+			strconv.Itoa(p.Tributes_Paid),
+			strconv.Itoa(p.Tributes_Received),
+			format_float(p.Avg_Finish_Position),
+			format_float(p.Avg_Deal_Strength),
+			format_float(p.Avg_Deal_Rank),
+			format_float(p.Avg_Think_Ms),
+			strconv.Itoa(p.Hints_Used),
+		}
+		for _, kind := range kinds {
+			row = append(row, strconv.Itoa(p.Bombs[kind]))
+		}
+		out.Write(row)
+	}
+
+	out.Flush()
+	if err := out.Error(); err != nil {
+		log.Printf("stats csv: %v", err)
+	}
+}
+
+func (t *Tracker) Handle_Page(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := page.Execute(w, t.Players()); err != nil {
+		log.Printf("stats page: %v", err)
+	}
+}
+
+func format_float(f float64) string {
+	return strconv.FormatFloat(f, 'f', 4, 64)
+}
+
+// csv_text keeps a player-chosen field from being read as a formula by
+// spreadsheets, which treat a leading =, +, - or @ as one.
+func csv_text(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
@@ -0,0 +1,21 @@
+package stats
+
+import "testing"
+
+func TestCSVText(t *testing.T) {
+	tests := map[string]string{
+		"Ana":      "Ana",
+		"":         "",
+		"=1+2":     "'=1+2",
+		"+cmd":     "'+cmd",
+		"-2":       "'-2",
+		"@SUM(A1)": "'@SUM(A1)",
+		"\t=x":     "'\t=x",
+		"a=b":      "a=b",
+	}
+	for in, want := range tests {
+		if got := csv_text(in); got != want {
+			t.Errorf("csv_text(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
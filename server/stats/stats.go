@@ -0,0 +1,272 @@
+package stats
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"guandanbtw/game"
+	"guandanbtw/history"
+)
+
+// Player_Stats aggregates every archived hand a signed-in player sat in.
+// Players who had not gone out when a hand ended share the remaining finishing
+// positions and all count as last place. Deal strength is game.Hand_Strength
+// of the dealt hand before tribute; deal rank puts it among the four seats
+// (1 is the strongest), to set against the average finish.
+type Player_Stats struct {
+	Id                  string         `json:"id"`
+	Username            string         `json:"username"`
+	Name                string         `json:"name"`
+	Hands_Played        int            `json:"hands_played"`
+	Head_Wins           int            `json:"head_wins"`
+	Last_Places         int            `json:"last_places"`
+	Double_Wins         int            `json:"double_wins"`
+	Tributes_Paid       int            `json:"tributes_paid"`
+	Tributes_Received   int            `json:"tributes_received"`
+	Bombs               map[string]int `json:"bombs"`
+	Moves               int            `json:"moves"`
+	Hints_Used          int            `json:"hints_used"`
+	Head_Win_Rate       float64        `json:"head_win_rate"`
+	Last_Place_Rate     float64        `json:"last_place_rate"`
+	Avg_Finish_Position float64        `json:"avg_finish_position"`
+	Avg_Think_Ms        float64        `json:"avg_think_ms"`
+	Avg_Deal_Strength   float64        `json:"avg_deal_strength"`
+	Avg_Deal_Rank       float64        `json:"avg_deal_rank"`
+
+	finish_position_sum float64
+	think_ms            int64
+	deals               int
+	deal_strength_sum   int
+	deal_rank_sum       int
+}
+
+type Tracker struct {
+	mu      sync.Mutex
+	players map[string]*Player_Stats
+	seen    map[string]bool
+}
+
+func New_Tracker() *Tracker {
+	return &Tracker{
+		players: make(map[string]*Player_Stats),
+		seen:    make(map[string]bool),
+	}
+}
+
+// Load feeds every archived hand into the tracker.
+func (t *Tracker) Load(src history.Archive) error {
+	if err := history.Load_All(src, t.Add); err != nil {
+		return fmt.Errorf("stats: %w", err)
+	}
+	return nil
+}
+
+func (t *Tracker) Add(l *history.Hand_Log) {
+	outcome := l.Outcome()
+	if outcome == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := fmt.Sprintf("%s/%d", l.Header.Match_Id, l.Header.Hand)
+	if t.seen[key] {
+		return
+	}
+	t.seen[key] = true
+
+	var seats [4]*Player_Stats
+	for i, p := range l.Header.Players {
+		if p.Username == "" || p.Is_Bot {
+			continue
+		}
+		s, ok := t.players[p.Id]
+		if !ok {
+			s = &Player_Stats{Id: p.Id, Bombs: make(map[string]int)}
+			t.players[p.Id] = s
+		}
+		s.Username = p.Username
+		s.Name = p.Name
+		seats[i] = s
+	}
+
+	positions := finish_positions(outcome.Finish_Order)
+	double_win := len(outcome.Finish_Order) >= 2 && outcome.Finish_Order[0]%2 == outcome.Finish_Order[1]%2
+
+	for i, s := range seats {
+		if s == nil {
+			continue
+		}
+		s.Hands_Played++
+		s.finish_position_sum += positions[i]
+		if len(outcome.Finish_Order) > 0 && outcome.Finish_Order[0] == i {
+			s.Head_Wins++
+		}
+		if !finished(outcome.Finish_Order, i) {
+			s.Last_Places++
+		}
+		if double_win && i%2 == outcome.Winning_Team {
+			s.Double_Wins++
+		}
+	}
+
+	var last_at int64
+	level := game.Rank_Two
+	for _, ev := range l.Events {
+		var s *Player_Stats
+		if ev.Seat >= 0 && ev.Seat < 4 {
+			s = seats[ev.Seat]
+		}
+
+		switch ev.Kind {
+		case history.Event_Deal:
+			if ev.Deal != nil {
+				level = ev.Deal.Level
+				add_deal_strengths(seats, ev.Deal)
+			}
+		case history.Event_Tribute:
+			if s != nil {
+				s.Tributes_Paid++
+			}
+			if ev.Tribute != nil && ev.Tribute.To_Seat >= 0 && ev.Tribute.To_Seat < 4 && seats[ev.Tribute.To_Seat] != nil {
+				seats[ev.Tribute.To_Seat].Tributes_Received++
+			}
+		case history.Event_Play, history.Event_Pass:
+			if s != nil {
+				s.Moves++
+				s.think_ms += ev.At_Ms - last_at
+				if ev.Kind == history.Event_Play && ev.Play != nil && ev.Play.Combo.Type == game.Comb_Bomb.String() {
+					s.Bombs[game.Bomb_Kind(cards_of(ev.Play.Card_Ids), level)]++
+				}
+			}
+		case history.Event_Hint:
+			if s != nil {
+				s.Hints_Used++
+			}
+			continue
+		}
+
+		if ev.Kind != history.Event_Finish && ev.Kind != history.Event_Level_Change {
+			last_at = ev.At_Ms
+		}
+	}
+
+	for _, s := range seats {
+		if s != nil {
+			s.derive()
+		}
+	}
+}
+
+func (t *Tracker) Players() []Player_Stats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	players := make([]Player_Stats, 0, len(t.players))
+	for _, s := range t.players {
+		players = append(players, s.copy())
+	}
+	sort.Slice(players, func(i, j int) bool {
+		if players[i].Hands_Played != players[j].Hands_Played {
+			return players[i].Hands_Played > players[j].Hands_Played
+		}
+		return players[i].Username < players[j].Username
+	})
+	return players
+}
+
+// Player looks a player up by account id or username.
+func (t *Tracker) Player(key string) (Player_Stats, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if s, ok := t.players[key]; ok {
+		return s.copy(), true
+	}
+	for _, s := range t.players {
+		if strings.EqualFold(s.Username, key) {
+			return s.copy(), true
+		}
+	}
+	return Player_Stats{}, false
+}
+
+func (s *Player_Stats) derive() {
+	hands := float64(s.Hands_Played)
+	s.Head_Win_Rate = float64(s.Head_Wins) / hands
+	s.Last_Place_Rate = float64(s.Last_Places) / hands
+	s.Avg_Finish_Position = s.finish_position_sum / hands
+	if s.Moves > 0 {
+		s.Avg_Think_Ms = float64(s.think_ms) / float64(s.Moves)
+	}
+	if s.deals > 0 {
+		s.Avg_Deal_Strength = float64(s.deal_strength_sum) / float64(s.deals)
+		s.Avg_Deal_Rank = float64(s.deal_rank_sum) / float64(s.deals)
+	}
+}
+
+func add_deal_strengths(seats [4]*Player_Stats, deal *history.Deal) {
+	var strengths [4]int
+	for i, hand := range deal.Hands {
+		strengths[i] = game.Hand_Strength(hand, deal.Level)
+	}
+	for i, s := range seats {
+		if s == nil {
+			continue
+		}
+		rank := 1
+		for _, other := range strengths {
+			if other > strengths[i] {
+				rank++
+			}
+		}
+		s.deals++
+		s.deal_strength_sum += strengths[i]
+		s.deal_rank_sum += rank
+	}
+}
+
+// cards_of looks cards up by id; a fresh deck holds every card at its id.
+func cards_of(ids []int) []game.Card {
+	deck := game.New_Deck().Cards
+	cards := make([]game.Card, 0, len(ids))
+	for _, id := range ids {
+		if id >= 0 && id < len(deck) {
+			cards = append(cards, deck[id])
+		}
+	}
+	return cards
+}
+
+func (s *Player_Stats) copy() Player_Stats {
+	c := *s
+	c.Bombs = make(map[string]int, len(s.Bombs))
+	for k, v := range s.Bombs {
+		c.Bombs[k] = v
+	}
+	return c
+}
+
+func finish_positions(order []int) [4]float64 {
+	var positions [4]float64
+	shared := float64(len(order)+1+4) / 2
+	for seat := 0; seat < 4; seat++ {
+		positions[seat] = shared
+	}
+	for i, seat := range order {
+		positions[seat] = float64(i + 1)
+	}
+	return positions
+}
+
+func finished(order []int, seat int) bool {
+	for _, s := range order {
+		if s == seat {
+			return true
+		}
+	}
+	return false
+}
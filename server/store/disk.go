@@ -0,0 +1,581 @@
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"guandanbtw/account"
+	"guandanbtw/history"
+	"guandanbtw/rating"
+)
+
+const (
+	compact_after  = 256
+	max_line_bytes = 8 << 20
+	save_delay     = 250 * time.Millisecond
+)
+
+type log_entry struct {
+	Kind  string       `json:"kind"`
+	Room  *Room_Record `json:"room,omitempty"`
+	Event *Event       `json:"event,omitempty"`
+}
+
+// room_file is one room's log. Its lock is held while the log is written or
+// rewritten, so rooms never wait on each other's disk writes. Snapshots are
+// encoded when saved, since the room goes on changing the state they point
+// at, then held back for save_delay so only the latest is written; appended
+// events are written at once and synced with it.
+type room_file struct {
+	mu        sync.Mutex
+	f         *os.File
+	snapshots int
+	pending   []byte
+	dirty     bool
+	timer     *time.Timer
+	deleted   bool
+}
+
+type Disk_Store struct {
+	dir        string
+	save_delay time.Duration
+	mu         sync.Mutex
+	files      map[string]*room_file
+}
+
+func Open_Disk(dir string) (*Disk_Store, error) {
+	for _, sub := range []string{"rooms", "hands", "accounts"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Disk_Store{
+		dir:        dir,
+		save_delay: save_delay,
+		files:      make(map[string]*room_file),
+	}, nil
+}
+
+func (s *Disk_Store) Save_Room(rec *Room_Record) error {
+	line, err := encode_entry(&log_entry{Kind: "room", Room: rec})
+	if err != nil {
+		return err
+	}
+
+	rf := s.room(rec.Id)
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if rf.deleted {
+		return nil
+	}
+	rf.pending = line
+	s.schedule(rec.Id, rf)
+	return nil
+}
+
+func (s *Disk_Store) Append_Event(room_id string, ev *Event) error {
+	rf := s.room(room_id)
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if rf.deleted {
+		return nil
+	}
+	if err := s.open(room_id, rf); err != nil {
+		return err
+	}
+	if err := write_entry(rf.f, &log_entry{Kind: "event", Event: ev}); err != nil {
+		return err
+	}
+	rf.dirty = true
+	s.schedule(room_id, rf)
+	return nil
+}
+
+func (s *Disk_Store) Load_Rooms() ([]*Room_Record, error) {
+	s.mu.Lock()
+	held := make(map[string]*room_file, len(s.files))
+	for id, rf := range s.files {
+		held[id] = rf
+	}
+	s.mu.Unlock()
+	for id, rf := range held {
+		rf.mu.Lock()
+		err := s.flush(id, rf)
+		rf.mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Join(s.dir, "rooms"))
+	if err != nil {
+		return nil, err
+	}
+
+	var rooms []*Room_Record
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".jsonl")
+		if !ok || entry.IsDir() {
+			continue
+		}
+
+		rec, err := s.load_room(id)
+		if err != nil {
+			return nil, err
+		}
+		if rec != nil {
+			rooms = append(rooms, rec)
+		}
+	}
+
+	return rooms, nil
+}
+
+func (s *Disk_Store) Load_Events(room_id string) ([]*Event, error) {
+	rf := s.room(room_id)
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if err := s.flush(room_id, rf); err != nil {
+		return nil, err
+	}
+	_, events, err := read_log(s.room_path(room_id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return events, err
+}
+
+func (s *Disk_Store) Delete_Room(room_id string) error {
+	s.mu.Lock()
+	rf, ok := s.files[room_id]
+	delete(s.files, room_id)
+	s.mu.Unlock()
+
+	if ok {
+		rf.mu.Lock()
+		defer rf.mu.Unlock()
+		rf.deleted = true
+		rf.pending = nil
+		if rf.timer != nil {
+			rf.timer.Stop()
+		}
+		rf.close()
+	}
+
+	err := os.Remove(s.room_path(room_id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *Disk_Store) Save_Hand(l *history.Hand_Log) error {
+	if !valid_id(l.Header.Match_Id) {
+		return fmt.Errorf("store: invalid match id %q", l.Header.Match_Id)
+	}
+
+	dir := filepath.Join(s.dir, "hands", l.Header.Match_Id)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	return write_file_atomic(s.hand_path(l.Header.Match_Id, l.Header.Hand), func(w *bufio.Writer) error {
+		return l.Write(w)
+	})
+}
+
+func (s *Disk_Store) Load_Hand(match_id string, hand int) (*history.Hand_Log, error) {
+	if !valid_id(match_id) {
+		return nil, os.ErrNotExist
+	}
+
+	f, err := os.Open(s.hand_path(match_id, hand))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return history.Read(f)
+}
+
+func (s *Disk_Store) List_Hands(match_id string) ([]int, error) {
+	if !valid_id(match_id) {
+		return nil, os.ErrNotExist
+	}
+
+	entries, err := os.ReadDir(filepath.Join(s.dir, "hands", match_id))
+	if err != nil {
+		return nil, err
+	}
+
+	var hands []int
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".jsonl")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.Atoi(name); err == nil {
+			hands = append(hands, n)
+		}
+	}
+	slices.Sort(hands)
+
+	return hands, nil
+}
+
+func (s *Disk_Store) List_Matches() ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(s.dir, "hands"))
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []string
+	for _, entry := range entries {
+		if entry.IsDir() && valid_id(entry.Name()) {
+			matches = append(matches, entry.Name())
+		}
+	}
+	return matches, nil
+}
+
+func (s *Disk_Store) Save_Account(a *account.Account) error {
+	if !valid_id(a.Id) {
+		return fmt.Errorf("store: invalid account id %q", a.Id)
+	}
+
+	return write_file_atomic(s.account_path(a.Id), func(w *bufio.Writer) error {
+		return json.NewEncoder(w).Encode(a)
+	})
+}
+
+func (s *Disk_Store) Load_Accounts() ([]*account.Account, error) {
+	entries, err := os.ReadDir(filepath.Join(s.dir, "accounts"))
+	if err != nil {
+		return nil, err
+	}
+
+	var accounts []*account.Account
+	for _, entry := range entries {
+		if _, ok := strings.CutSuffix(entry.Name(), ".json"); !ok || entry.IsDir() {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(s.dir, "accounts", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		var a account.Account
+		if err := json.Unmarshal(data, &a); err != nil {
+			return nil, fmt.Errorf("store: account %s: %w", entry.Name(), err)
+		}
+		accounts = append(accounts, &a)
+	}
+
+	return accounts, nil
+}
+
+func (s *Disk_Store) Save_Ratings(snap *rating.Snapshot) error {
+	return write_file_atomic(filepath.Join(s.dir, "ratings.json"), func(w *bufio.Writer) error {
+		return json.NewEncoder(w).Encode(snap)
+	})
+}
+
+func (s *Disk_Store) Load_Ratings() (*rating.Snapshot, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, "ratings.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var snap rating.Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("store: ratings: %w", err)
+	}
+	return &snap, nil
+}
+
+// Close writes every held-back snapshot and closes the room logs.
+func (s *Disk_Store) Close() error {
+	s.mu.Lock()
+	files := s.files
+	s.files = make(map[string]*room_file)
+	s.mu.Unlock()
+
+	var first error
+	for id, rf := range files {
+		rf.mu.Lock()
+		if rf.timer != nil {
+			rf.timer.Stop()
+		}
+		if err := s.flush(id, rf); err != nil && first == nil {
+			first = err
+		}
+		if err := rf.close(); err != nil && first == nil {
+			first = err
+		}
+		rf.deleted = true
+		rf.mu.Unlock()
+	}
+	return first
+}
+
+func (s *Disk_Store) room_path(room_id string) string {
+	return filepath.Join(s.dir, "rooms", room_id+".jsonl")
+}
+
+func (s *Disk_Store) hand_path(match_id string, hand int) string {
+	return filepath.Join(s.dir, "hands", match_id, fmt.Sprintf("%04d.jsonl", hand))
+}
+
+func (s *Disk_Store) account_path(id string) string {
+	return filepath.Join(s.dir, "accounts", id+".json")
+}
+
+func (s *Disk_Store) room(room_id string) *room_file {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rf, ok := s.files[room_id]
+	if !ok {
+		rf = &room_file{}
+		s.files[room_id] = rf
+	}
+	return rf
+}
+
+func (s *Disk_Store) open(room_id string, rf *room_file) error {
+	if rf.f != nil {
+		return nil
+	}
+
+	f, err := os.OpenFile(s.room_path(room_id), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	rf.f = f
+	return nil
+}
+
+func (rf *room_file) close() error {
+	if rf.f == nil {
+		return nil
+	}
+	err := rf.f.Close()
+	rf.f = nil
+	return err
+}
+
+// schedule starts the timer that flushes the room, unless one is running.
+// Called with rf.mu held.
+func (s *Disk_Store) schedule(room_id string, rf *room_file) {
+	if rf.timer != nil {
+		return
+	}
+	rf.timer = time.AfterFunc(s.save_delay, func() {
+		rf.mu.Lock()
+		defer rf.mu.Unlock()
+
+		rf.timer = nil
+		if rf.deleted {
+			return
+		}
+		if err := s.flush(room_id, rf); err != nil {
+			log.Printf("store: room %s: flush failed: %v", room_id, err)
+		}
+	})
+}
+
+// flush writes the held-back snapshot, syncs the log once for it and every
+// event written since, and compacts the log when it has grown. Called with
+// rf.mu held.
+func (s *Disk_Store) flush(room_id string, rf *room_file) error {
+	if rf.pending != nil {
+		if err := s.open(room_id, rf); err != nil {
+			return err
+		}
+		if _, err := rf.f.Write(rf.pending); err != nil {
+			return err
+		}
+		rf.pending = nil
+		rf.dirty = true
+		rf.snapshots++
+	}
+
+	if rf.dirty {
+		if err := rf.f.Sync(); err != nil {
+			return err
+		}
+		rf.dirty = false
+	}
+
+	if rf.snapshots >= compact_after {
+		return s.compact(room_id, rf)
+	}
+	return nil
+}
+
+func (s *Disk_Store) load_room(room_id string) (*Room_Record, error) {
+	rf := s.room(room_id)
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if err := s.flush(room_id, rf); err != nil {
+		return nil, err
+	}
+	rec, _, err := read_log(s.room_path(room_id))
+	if err != nil || rec == nil {
+		return nil, err
+	}
+	return rec, s.compact(room_id, rf)
+}
+
+// compact rewrites a room log as its latest snapshot followed by the events
+// that came after it, which also drops a torn trailing line left behind by a
+// crash mid-write. Called with rf.mu held.
+func (s *Disk_Store) compact(room_id string, rf *room_file) error {
+	path := s.room_path(room_id)
+
+	rec, events, err := read_log(path)
+	if err != nil {
+		return err
+	}
+
+	rf.close()
+	rf.snapshots = 0
+
+	return write_file_atomic(path, func(w *bufio.Writer) error {
+		enc := json.NewEncoder(w)
+		if rec != nil {
+			if err := enc.Encode(&log_entry{Kind: "room", Room: rec}); err != nil {
+				return err
+			}
+		}
+		for _, ev := range events {
+			if rec != nil && ev.Seq <= rec.Event_Seq {
+				continue
+			}
+			if err := enc.Encode(&log_entry{Kind: "event", Event: ev}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func write_file_atomic(path string, write func(w *bufio.Writer) error) error {
+	tmp_path := path + ".tmp"
+	tmp, err := os.Create(tmp_path)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(tmp)
+	if err := write(w); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp_path, path); err != nil {
+		return err
+	}
+	sync_dir(filepath.Dir(path))
+
+	return nil
+}
+
+func read_log(path string) (*Room_Record, []*Event, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	var rec *Room_Record
+	var events []*Event
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), max_line_bytes)
+	for scanner.Scan() {
+		var entry log_entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			break
+		}
+
+		switch entry.Kind {
+		case "room":
+			rec = entry.Room
+		case "event":
+			events = append(events, entry.Event)
+		}
+	}
+
+	return rec, events, nil
+}
+
+func write_entry(f *os.File, entry *log_entry) error {
+	line, err := encode_entry(entry)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(line)
+	return err
+}
+
+// encode_entry renders entry as one log line.
+func encode_entry(entry *log_entry) ([]byte, error) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+func valid_id(id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+func sync_dir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
@@ -0,0 +1,220 @@
+package store
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func open_test_store(t *testing.T) *Disk_Store {
+	t.Helper()
+	s, err := Open_Disk(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.save_delay = time.Hour
+	return s
+}
+
+func test_room(id string, event_seq int) *Room_Record {
+	return &Room_Record{
+		Id:        id,
+		Host_Id:   "host",
+		Event_Seq: event_seq,
+		Seats:     [4]*Seat_Record{{Player_Id: "host", Name: "Ana", Token: "t1"}},
+		Series:    &Series_Record{Best_Of: 3, Wins: [2]int{1, 0}, Game: 2},
+	}
+}
+
+func TestRoomRoundTrip(t *testing.T) {
+	s := open_test_store(t)
+	if err := s.Save_Room(test_room("ROOM", 0)); err != nil {
+		t.Fatal(err)
+	}
+	for seq := 1; seq <= 3; seq++ {
+		if err := s.Append_Event("ROOM", &Event{Seq: seq, Type: "play", Seat: seq % 4}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := Open_Disk(s.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err := s.Load_Events("ROOM")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 3 || events[2].Seq != 3 {
+		t.Fatalf("events = %+v, want seqs 1..3", events)
+	}
+
+	rooms, err := s.Load_Rooms()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rooms) != 1 {
+		t.Fatalf("loaded %d rooms, want 1", len(rooms))
+	}
+	rec := rooms[0]
+	if rec.Id != "ROOM" || rec.Host_Id != "host" || rec.Seats[0].Name != "Ana" || rec.Series.Wins != [2]int{1, 0} {
+		t.Fatalf("loaded room = %+v", rec)
+	}
+}
+
+func TestSaveRoomKeepsLatestSnapshot(t *testing.T) {
+	s := open_test_store(t)
+	for seq := 1; seq <= 5; seq++ {
+		if err := s.Save_Room(test_room("ROOM", seq)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rooms, err := s.Load_Rooms()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rooms) != 1 || rooms[0].Event_Seq != 5 {
+		t.Fatalf("rooms = %+v, want one room at event 5", rooms)
+	}
+
+	data, err := os.ReadFile(s.room_path("ROOM"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), "\n"); n != 1 {
+		t.Fatalf("log has %d lines, want the held-back snapshot written once", n)
+	}
+}
+
+func TestCompactDropsCoveredEvents(t *testing.T) {
+	tests := []struct {
+		name      string
+		event_seq int
+		events    int
+		want      []int
+	}{
+		{"all covered", 4, 4, nil},
+		{"some after", 2, 4, []int{3, 4}},
+		{"none covered", 0, 2, []int{1, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := open_test_store(t)
+			for seq := 1; seq <= tt.events; seq++ {
+				if err := s.Append_Event("ROOM", &Event{Seq: seq, Type: "play"}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := s.Save_Room(test_room("ROOM", tt.event_seq)); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.Load_Rooms(); err != nil {
+				t.Fatal(err)
+			}
+
+			events, err := s.Load_Events("ROOM")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []int
+			for _, ev := range events {
+				got = append(got, ev.Seq)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("events = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("events = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCompactAfterManySnapshots(t *testing.T) {
+	s := open_test_store(t)
+	s.save_delay = time.Millisecond
+	for seq := 1; seq <= compact_after+10; seq++ {
+		if err := s.Save_Room(test_room("ROOM", seq)); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(s.room_path("ROOM"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), "\n"); n >= compact_after {
+		t.Fatalf("log has %d lines, want it compacted", n)
+	}
+}
+
+func TestDeleteRoomDropsHeldBackSnapshot(t *testing.T) {
+	s := open_test_store(t)
+	if err := s.Save_Room(test_room("ROOM", 1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete_Room("ROOM"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rooms, err := s.Load_Rooms()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rooms) != 0 {
+		t.Fatalf("rooms = %+v, want none", rooms)
+	}
+}
+
+func TestTornTrailingLineIsDropped(t *testing.T) {
+	s := open_test_store(t)
+	if err := s.Save_Room(test_room("ROOM", 1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.OpenFile(s.room_path("ROOM"), os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"kind":"room","room":{"id":"ROO`)
+	f.Close()
+
+	rooms, err := s.Load_Rooms()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rooms) != 1 || rooms[0].Event_Seq != 1 {
+		t.Fatalf("rooms = %+v, want the last whole snapshot", rooms)
+	}
+}
+
+func TestInvalidIdsAreRejected(t *testing.T) {
+	for _, id := range []string{"", "../x", "a/b", "a b"} {
+		if valid_id(id) {
+			t.Errorf("valid_id(%q) = true", id)
+		}
+	}
+	for _, id := range []string{"ABCD", "m-1_2"} {
+		if !valid_id(id) {
+			t.Errorf("valid_id(%q) = false", id)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package store
+
+import (
+	"encoding/json"
+	"time"
+
+	"guandanbtw/account"
+	"guandanbtw/game"
+	"guandanbtw/history"
+	"guandanbtw/rating"
+)
+
+type Seat_Record struct {
+	Player_Id string `json:"player_id"`
+	Name      string `json:"name"`
+	Token     string `json:"token,omitempty"`
+	Username  string `json:"username,omitempty"`
+	Is_Bot    bool   `json:"is_bot"`
+	Is_Ready  bool   `json:"is_ready"`
+}
+
+type Room_Record struct {
+	Id           string            `json:"id"`
+	Status       int               `json:"status"`
+	Host_Id      string            `json:"host_id"`
+	Seats        [4]*Seat_Record   `json:"seats"`
+	Game         *game.Game_State  `json:"game,omitempty"`
+	Match_Id     string            `json:"match_id,omitempty"`
+	Hand_Number  int               `json:"hand_number,omitempty"`
+	Hand_Log     *history.Hand_Log `json:"hand_log,omitempty"`
+	Event_Seq    int               `json:"event_seq"`
+	Quiet_Hands  bool              `json:"quiet_hands,omitempty"`
+	Casual       bool              `json:"casual,omitempty"`
+	Card_Tracker bool              `json:"card_tracker,omitempty"`
+	Access       *Room_Access      `json:"access,omitempty"`
+	Series       *Series_Record    `json:"series,omitempty"`
+	Updated_At   time.Time         `json:"updated_at"`
+}
+
+// Room_Access holds a private room's password hash and invite token.
+// Password_Hash is the salted SHA-256 that older records carry instead of
+// Password; it cannot be checked any more, so such a room only takes its
+// invite until the host sets a new password.
+type Room_Access struct {
+	Private       bool            `json:"private"`
+	Password      *account.Secret `json:"password,omitempty"`
+	Password_Hash string          `json:"password_hash,omitempty"`
+	Invite_Token  string          `json:"invite_token"`
+}
+
+type Series_Record struct {
+	Best_Of int    `json:"best_of"`
+	Wins    [2]int `json:"wins"`
+	Game    int    `json:"game"`
+}
+
+type Event struct {
+	Seq  int             `json:"seq"`
+	Time time.Time       `json:"time"`
+	Type string          `json:"type"`
+	Seat int             `json:"seat"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+type Store interface {
+	Save_Room(rec *Room_Record) error
+	Append_Event(room_id string, ev *Event) error
+	Load_Rooms() ([]*Room_Record, error)
+	Load_Events(room_id string) ([]*Event, error)
+	Delete_Room(room_id string) error
+	Save_Hand(l *history.Hand_Log) error
+	Load_Hand(match_id string, hand int) (*history.Hand_Log, error)
+	List_Hands(match_id string) ([]int, error)
+	List_Matches() ([]string, error)
+	Save_Account(a *account.Account) error
+	Load_Accounts() ([]*account.Account, error)
+	Save_Ratings(s *rating.Snapshot) error
+	Load_Ratings() (*rating.Snapshot, error)
+	Close() error
+}
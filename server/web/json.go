@@ -0,0 +1,42 @@
+package web
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+const max_body_bytes = 16 << 10
+
+func Read_JSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, max_body_bytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		Write_Error(w, http.StatusBadRequest, "invalid request body")
+		return false
+	}
+	return true
+}
+
+func Write_JSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("write response: %v", err)
+	}
+}
+
+func Write_Error(w http.ResponseWriter, status int, message string) {
+	Write_JSON(w, status, map[string]string{"error": message})
+}
+
+// Query_Int reads an integer query parameter, falling back to def when it is
+// missing or malformed and clamping the result to [lo, hi].
+func Query_Int(r *http.Request, name string, def int, lo int, hi int) int {
+	n, err := strconv.Atoi(r.URL.Query().Get(name))
+	if err != nil {
+		n = def
+	}
+	return min(max(n, lo), hi)
+}
@@ -0,0 +1,16 @@
+package web
+
+import (
+	"net"
+	"net/http"
+)
+
+// Client_Addr is the connecting address without its port. Forwarding
+// headers are not trusted since any client can set them.
+func Client_Addr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}